@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag voter-api:v1  -f ./voter-api/Dockerfile ./voter-api
+docker build --tag voter-api:v1  --build-context config=../config -f ./voter-api/Dockerfile ./voter-api
@@ -10,6 +10,7 @@ import (
 	"voter-api/db"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 type VoteAPI struct {
@@ -19,8 +20,9 @@ type VoteAPI struct {
 	badRequests int
 }
 
-func NewVoteAPI() (*VoteAPI, error) {
-	dbHandler, err := db.NewHandler[voter.Voter]("voter")
+// NewVoteAPI builds the api on the redis client of the service
+func NewVoteAPI(client redis.UniversalClient) (*VoteAPI, error) {
+	dbHandler, err := db.NewHandler[voter.Voter]("voter", client)
 	if err != nil {
 		return nil, err
 	}
@@ -1,12 +1,12 @@
 package db
 
 import (
+	"config"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
@@ -18,22 +18,20 @@ const (
 )
 
 type Handler[T Item] struct {
-	cacheClient *redis.Client
+	cacheClient redis.UniversalClient
 	jsonHelper  *rejson.Handler
 	context     context.Context
 	keyPrefix   string
 }
 
-func NewHandler[T Item](dbName string) (*Handler[T], error) {
-	redisUrl := os.Getenv("REDIS_URL")
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
+// NewHandler keeps its items with client, which can be a single server, a
+// sentinel or a cluster client, or at RedisDefaultLocation when client is
+// nil
+func NewHandler[T Item](dbName string, client redis.UniversalClient) (*Handler[T], error) {
+	if client == nil {
+		client = redis.NewClient(&redis.Options{Addr: RedisDefaultLocation})
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr: redisUrl,
-	})
-
 	ctx := context.Background()
 
 	err := client.Ping(ctx).Err()
@@ -57,6 +55,30 @@ func NewHandler[T Item](dbName string) (*Handler[T], error) {
 // REDIS HELPERS
 //------------------------------------------------------------
 
+// keys returns the keys that match pattern, see config.Keys
+func (r *Handler[T]) keys(pattern string) ([]string, error) {
+	return config.Keys(r.context, r.cacheClient, pattern)
+}
+
+// del deletes the keys one by one in a pipeline, a DEL of many keys fails
+// in a cluster when they are in different slots
+func (r *Handler[T]) del(ks []string) (int64, error) {
+	cmds, err := r.cacheClient.Pipelined(r.context, func(pipe redis.Pipeliner) error {
+		for _, key := range ks {
+			pipe.Del(r.context, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.(*redis.IntCmd).Val()
+	}
+	return n, nil
+}
+
 func (r *Handler[T]) getItemFromDB(key string) (T, error) {
 	var it T
 	itemObject, err := r.jsonHelper.JSONGet(key, ".")
@@ -135,7 +157,10 @@ func (r *Handler[T]) Get(id uint) (T, error) {
 func (r *Handler[T]) All() ([]T, error) {
 	itemList := make([]T, 0)
 	pattern := r.keyPrefix + "*"
-	ks, _ := r.cacheClient.Keys(r.context, pattern).Result()
+	ks, err := r.keys(pattern)
+	if err != nil {
+		return itemList, err
+	}
 	for _, key := range ks {
 		existedItem, err := r.getItemFromDB(key)
 		if err != nil {
@@ -149,14 +174,14 @@ func (r *Handler[T]) All() ([]T, error) {
 
 func (r *Handler[T]) Clear() error {
 	pattern := r.keyPrefix + "*"
-	ks, err := r.cacheClient.Keys(r.context, pattern).Result()
+	ks, err := r.keys(pattern)
 	if err != nil {
 		return err
 	}
 	if len(ks) == 0 {
 		return nil
 	}
-	numDeleted, err := r.cacheClient.Del(r.context, ks...).Result()
+	numDeleted, err := r.del(ks)
 	if err != nil {
 		return err
 	}
@@ -1,33 +1,43 @@
 package main
 
 import (
-	"flag"
+	"config"
 	"fmt"
 	"os"
 	"voter-api/api"
+	"voter-api/db"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	hostFlag string
-	portFlag uint
-)
-
-func processCmdLineFlags() {
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 1080, "Default Port")
+// Config is the configuration of the voter-api, from the flags, the
+// environment and the -config file, see the config package
+type Config struct {
+	config.Server
+	Redis config.Redis `config:"redis"`
+}
 
-	flag.Parse()
+func loadConfig() Config {
+	cfg := Config{
+		Server: config.Server{Host: "0.0.0.0", Port: 1080},
+		Redis:  config.Redis{URL: db.RedisDefaultLocation},
+	}
+	config.MustLoad("", &cfg)
+	return cfg
 }
 
 func main() {
-	processCmdLineFlags()
+	cfg := loadConfig()
 	r := gin.Default()
 	r.Use(cors.Default())
 
-	apiHandler, err := api.NewVoteAPI()
+	redisClient, err := cfg.Redis.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apiHandler, err := api.NewVoteAPI(redisClient)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
@@ -54,6 +64,5 @@ func main() {
 	v2 := r.Group("/v2")
 	v2.GET("/crash", apiHandler.CrashSim)
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	r.Run(cfg.Addr())
 }
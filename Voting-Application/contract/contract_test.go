@@ -0,0 +1,158 @@
+// Package contract runs the scenarios from tests.sh against in-process
+// poll, voter and votes apis backed by db.MemoryStore, so it does not need
+// redis or docker.  Every response is checked for the status tests.sh
+// expects and validated against the OpenAPI document of its api.
+//
+//	cd contract && go test ./...
+package contract
+
+import (
+	"db"
+	"db/dbproblem"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openapi"
+	"poll-api/poll"
+	"problem"
+	"voter-api/voter"
+	"votes-api/vote"
+
+	pollapi "poll-api/api"
+	voterapi "voter-api/api"
+	votesapi "votes-api/api"
+
+	"github.com/gin-gonic/gin"
+)
+
+// service is one api under test, with the document it generated
+type service struct {
+	name   string
+	router *gin.Engine
+	doc    *openapi.Document
+}
+
+func newRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(dbproblem.Middleware())
+	return r
+}
+
+func TestContract(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	if err := dbproblem.RegisterValidators(); err != nil {
+		t.Fatal(err)
+	}
+
+	polls := service{name: "poll-api", router: newRouter()}
+	polls.doc = pollapi.NewPollAPIWithStore(db.NewMemoryStore[poll.Poll]("poll")).RegisterRoutes(polls.router)
+
+	voters := service{name: "voter-api", router: newRouter()}
+	voters.doc = voterapi.NewVoterAPIWithStore(db.NewMemoryStore[voter.Voter]("voter")).RegisterRoutes(voters.router)
+
+	//the votes-api checks the voters and polls a vote refers to over http,
+	//so the other two apis also have to be reachable on a real port
+	pollServer := httptest.NewServer(polls.router)
+	t.Cleanup(pollServer.Close)
+	voterServer := httptest.NewServer(voters.router)
+	t.Cleanup(voterServer.Close)
+
+	votes := service{name: "votes-api", router: newRouter()}
+	votes.doc = votesapi.NewVotesAPIWithStore(db.NewMemoryStore[vote.Vote]("vote"), votesapi.Endpoints{
+		HostName:         "localhost",
+		VoterApiInternal: voterServer.URL,
+		PollApiInternal:  pollServer.URL,
+		VoterApiExternal: "localhost:1080",
+		PollApiExternal:  "localhost:1081",
+	}).RegisterRoutes(votes.router)
+
+	//the steps depend on each other, so they run one after the other,
+	//and a failed step does not stop the ones after it, like in tests.sh
+	for _, s := range scenarios(polls, voters, votes) {
+		t.Run(s.name, func(t *testing.T) {
+			if err := s.run(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// step is one curl call from tests.sh.  A path ending in "?confirm" is a
+// confirmed bulk delete, like the confirmed_delete function of tests.sh:
+// the step asks for a token first and then sends the request with it.
+type step struct {
+	name   string
+	svc    service
+	method string
+	path   string
+	body   string
+	want   int
+}
+
+func (s step) run() error {
+	base, confirmed := strings.CutSuffix(s.path, "?confirm")
+	if !confirmed {
+		return s.check(s.send(s.path))
+	}
+
+	w, path := s.send(base)
+	if w.Code != http.StatusPreconditionRequired {
+		return fmt.Errorf("%s %s: got status %d, want %d: %s", s.method, path, w.Code, http.StatusPreconditionRequired, w.Body.String())
+	}
+	if err := s.svc.doc.ValidateResponse(s.method, path, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes()); err != nil {
+		return err
+	}
+	var p problem.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil || p.Confirm == "" {
+		return fmt.Errorf("%s %s: no confirmation token in %s", s.method, path, w.Body.String())
+	}
+	return s.check(s.send(base + "?confirm=" + p.Confirm))
+}
+
+// send sends the request and follows redirects the way curl --location
+// does, gin redirects eg "PUT /polls" to "PUT /polls/".  It returns the
+// last response and the path it was sent to.
+func (s step) send(path string) (*httptest.ResponseRecorder, string) {
+	for redirects := 0; ; redirects++ {
+		var body io.Reader
+		if s.body != "" {
+			body = strings.NewReader(s.body)
+		}
+		req := httptest.NewRequest(s.method, path, body)
+		if s.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		s.svc.router.ServeHTTP(w, req)
+
+		loc := w.Header().Get("Location")
+		if isRedirect(w.Code) && loc != "" && redirects < 5 {
+			path = loc
+			continue
+		}
+		return w, path
+	}
+}
+
+// check compares the response with the step and validates it against the
+// document of the api
+func (s step) check(w *httptest.ResponseRecorder, path string) error {
+	if w.Code != s.want {
+		return fmt.Errorf("%s %s: got status %d, want %d: %s", s.method, path, w.Code, s.want, w.Body.String())
+	}
+	path, _, _ = strings.Cut(path, "?")
+	return s.svc.doc.ValidateResponse(s.method, path, w.Code, w.Header().Get("Content-Type"), w.Body.Bytes())
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package contract
+
+import "net/http"
+
+const (
+	poll1 = `{"id": 1, "title": "pet type", "question": "which type of pet do you like?",
+		"options": [{"id": 1, "value": "dog"}, {"id": 2, "value": "cat"}]}`
+	poll1Update = `{"id": 1, "title": "animal type", "question": "which type of animal do you like?",
+		"options": [{"id": 1, "value": "fish"}, {"id": 2, "value": "bird"}]}`
+	poll2 = `{"id": 2, "title": "paper type", "question": "paper down or paper up?",
+		"options": [{"id": 1, "value": "down"}, {"id": 2, "value": "up"}]}`
+
+	voter1       = `{"id": 1, "firstName": "Mike", "lastName": "F"}`
+	voter1Update = `{"id": 1, "firstName": "Mike", "lastName": "F",
+		"history": [{"id": 20, "date": "2023-07-25T16:47:26.3570871-04:00"}]}`
+	voter2 = `{"id": 2, "firstName": "Xiao", "lastName": "Fang"}`
+)
+
+// scenarios mirrors tests.sh, section by section, and in the same order
+// because later steps depend on the data earlier steps create
+func scenarios(polls, voters, votes service) []step {
+	reset := []step{
+		{"reset votes", votes, http.MethodDelete, "/votes?confirm", "", http.StatusOK},
+		{"reset voters", voters, http.MethodDelete, "/voters?confirm", "", http.StatusOK},
+		{"reset polls", polls, http.MethodDelete, "/polls?confirm", "", http.StatusOK},
+	}
+
+	steps := append([]step{}, reset...)
+	steps = append(steps,
+		step{"openapi poll-api", polls, http.MethodGet, "/openapi.json", "", http.StatusOK},
+		step{"openapi voter-api", voters, http.MethodGet, "/openapi.json", "", http.StatusOK},
+		step{"openapi votes-api", votes, http.MethodGet, "/openapi.json", "", http.StatusOK},
+
+		// Test Section 1 - Poll API
+		step{"1.1 create poll_1", polls, http.MethodPost, "/polls/1", poll1, http.StatusOK},
+		step{"1.2 get poll_1", polls, http.MethodGet, "/polls/1", "", http.StatusOK},
+		step{"1.3 update poll_1", polls, http.MethodPut, "/polls", poll1Update, http.StatusOK},
+		step{"1.4 delete poll_1", polls, http.MethodDelete, "/polls/1", "", http.StatusOK},
+		step{"1.5 create poll_2", polls, http.MethodPost, "/polls/2", poll2, http.StatusOK},
+		step{"1.6 add option_3", polls, http.MethodPost, "/polls/2/options",
+			`{"options": [{"id": 3, "value": "whatever"}]}`, http.StatusOK},
+		step{"1.7 get option_3", polls, http.MethodGet, "/polls/2/options/3", "", http.StatusOK},
+		step{"1.8 update option_3", polls, http.MethodPut, "/polls/2/options",
+			`{"options": [{"id": 3, "value": "I don't use paper"}]}`, http.StatusOK},
+		step{"1.9 delete all the options of poll_2", polls, http.MethodDelete, "/polls/2/options", "", http.StatusOK},
+		step{"1.10 get all the options of poll_2", polls, http.MethodGet, "/polls/2/options", "", http.StatusOK},
+		step{"1.11 delete all the polls", polls, http.MethodDelete, "/polls?confirm", "", http.StatusOK},
+		step{"1.12 get all the polls", polls, http.MethodGet, "/polls", "", http.StatusOK},
+		step{"1.13 check poll-api health", polls, http.MethodGet, "/polls/health", "", http.StatusOK},
+		step{"1.14 list the deleted polls", polls, http.MethodGet, "/polls/trash", "", http.StatusOK},
+		step{"1.15 restore poll_2", polls, http.MethodPost, "/polls/trash/2/restore", "", http.StatusOK},
+		step{"1.16 delete poll_2 again", polls, http.MethodDelete, "/polls/2", "", http.StatusOK},
+
+		// Test Section 2 - Voter API
+		step{"2.1 create voter_1", voters, http.MethodPost, "/voters/1", voter1, http.StatusOK},
+		step{"2.2 get voter_1", voters, http.MethodGet, "/voters/1", "", http.StatusOK},
+		step{"2.3 update voter_1", voters, http.MethodPut, "/voters", voter1Update, http.StatusOK},
+		step{"2.4 delete voter_1", voters, http.MethodDelete, "/voters/1", "", http.StatusOK},
+		step{"2.5 create voter_2", voters, http.MethodPost, "/voters/2", voter2, http.StatusOK},
+		step{"2.6 add voterPoll record", voters, http.MethodPost, "/voters/2/polls",
+			`{"history": [{"id": 1, "date": "2021-07-25T16:47:26.3570871-04:00"}]}`, http.StatusOK},
+		step{"2.7 get voterPoll record", voters, http.MethodGet, "/voters/2/polls/1", "", http.StatusOK},
+		step{"2.8 update voterPoll record", voters, http.MethodPut, "/voters/2/polls",
+			`{"history": [{"id": 1, "date": "2023-07-25T16:47:26.3570871-04:00"}]}`, http.StatusOK},
+		step{"2.9 delete the voteHistory of voter_2", voters, http.MethodDelete, "/voters/2/polls", "", http.StatusOK},
+		step{"2.10 get the voteHistory of voter_2", voters, http.MethodGet, "/voters/2/polls", "", http.StatusOK},
+		step{"2.11 delete all the voters", voters, http.MethodDelete, "/voters?confirm", "", http.StatusOK},
+		step{"2.12 get all the voters", voters, http.MethodGet, "/voters", "", http.StatusOK},
+		step{"2.13 check voter-api health", voters, http.MethodGet, "/voters/health", "", http.StatusOK},
+		step{"2.14 list the deleted voters", voters, http.MethodGet, "/voters/trash", "", http.StatusOK},
+		step{"2.15 restore voter_2", voters, http.MethodPost, "/voters/trash/2/restore", "", http.StatusOK},
+		step{"2.16 delete voter_2 again", voters, http.MethodDelete, "/voters/2", "", http.StatusOK},
+
+		// Test Section 3 - Votes API
+		step{"3.1 vote needs an existing voter", votes, http.MethodPost, "/votes/1",
+			`{"id": 1, "voterId": 1, "pollId": 2, "choiceId": 2}`, http.StatusUnprocessableEntity},
+		step{"3.2 create voter_1", voters, http.MethodPost, "/voters/1",
+			`{"id": 1, "firstName": "Xiao", "lastName": "Fang"}`, http.StatusOK},
+		step{"3.2 vote needs an existing poll", votes, http.MethodPost, "/votes/1",
+			`{"id": 1, "voterId": 1, "pollId": 2, "choiceId": 2}`, http.StatusUnprocessableEntity},
+		step{"3.3 create poll_2", polls, http.MethodPost, "/polls/2", poll2, http.StatusOK},
+		step{"3.3 vote needs an existing option", votes, http.MethodPost, "/votes/1",
+			`{"id": 1, "voterId": 1, "pollId": 2, "choiceId": 3}`, http.StatusUnprocessableEntity},
+		step{"3.4 vote with existing voter, poll and option", votes, http.MethodPost, "/votes/1",
+			`{"id": 1, "voterId": 1, "pollId": 2, "choiceId": 2}`, http.StatusOK},
+		step{"3.5 one vote per voter and poll", votes, http.MethodPost, "/votes/2",
+			`{"id": 2, "voterId": 1, "pollId": 2, "choiceId": 1}`, http.StatusConflict},
+		step{"3.6 change the vote", votes, http.MethodPut, "/votes",
+			`{"id": 1, "voterId": 1, "pollId": 2, "choiceId": 1}`, http.StatusOK},
+		step{"3.7 get the vote_1", votes, http.MethodGet, "/votes/1", "", http.StatusOK},
+		step{"3.8 delete the vote_1", votes, http.MethodDelete, "/votes/1", "", http.StatusOK},
+		step{"3.9 create vote_2", votes, http.MethodPost, "/votes/2",
+			`{"id": 2, "voterId": 1, "pollId": 2, "choiceId": 1}`, http.StatusOK},
+		step{"3.10 delete all the votes", votes, http.MethodDelete, "/votes?confirm", "", http.StatusOK},
+		step{"3.11 get all the votes", votes, http.MethodGet, "/votes", "", http.StatusOK},
+		step{"3.12 check votes-api health", votes, http.MethodGet, "/votes/health", "", http.StatusOK},
+		step{"3.13 list the deleted votes", votes, http.MethodGet, "/votes/trash", "", http.StatusOK},
+		step{"3.14 restore vote_2", votes, http.MethodPost, "/votes/trash/2/restore", "", http.StatusOK},
+		step{"3.15 delete vote_2 again", votes, http.MethodDelete, "/votes/2", "", http.StatusOK},
+	)
+	return append(steps, reset...)
+}
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"validate"
+)
+
+// Domain errors returned by the Handler and by the item packages built on
+// top of it.  They are wrapped with details using fmt.Errorf("%w ..."), so
+// callers should test for them with errors.Is.
+var (
+	ErrNotFound             = errors.New("not found")
+	ErrConflict             = errors.New("conflict")
+	ErrValidation           = validate.ErrValidation
+	ErrConfirmationRequired = errors.New("confirmation required")
+)
+
+// errIdempotencyPending is returned for a request whose Idempotency-Key is
+// still taken by an earlier request that has not created its item yet
+var errIdempotencyPending = fmt.Errorf("%w: a request with this Idempotency-Key is still running", ErrConflict)
@@ -1,38 +1,56 @@
 package db
 
 import (
+	"config"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
+	"github.com/nitishm/go-rejson/v4/rjs"
 )
 
 const (
 	RedisNilError        = "redis: nil"
 	RedisDefaultLocation = "0.0.0.0:6379"
+	IdempotencyKeyTTL    = 24 * time.Hour
+
+	//IdempotencyClaimTTL bounds how long an Idempotency-Key stays taken
+	//by a request that died before it created its item
+	IdempotencyClaimTTL = time.Minute
+	idempotencyPending  = "pending"
 )
 
 type Handler[T Item] struct {
-	cacheClient *redis.Client
+	cacheClient redis.UniversalClient
 	jsonHelper  *rejson.Handler
 	context     context.Context
 	keyPrefix   string
+	counterKey  string
+	recallKey   string
+	trashPrefix string
+	retention   time.Duration
 }
 
-func NewHandler[T Item](dbName string) (*Handler[T], error) {
-	redisUrl := os.Getenv("REDIS_URL")
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
-	}
+// Options are how a Handler reaches redis, with the Client of the service,
+// which can be a single server, a sentinel or a cluster client, or at
+// RedisDefaultLocation when Client is nil, and how long it keeps the deleted
+// items in the trash, DefaultRetention when Retention is 0
+type Options struct {
+	Client    redis.UniversalClient
+	Retention time.Duration
+}
 
-	client := redis.NewClient(&redis.Options{
-		Addr: redisUrl,
-	})
+func NewHandler[T Item](dbName string, opts Options) (*Handler[T], error) {
+	client := opts.Client
+	if client == nil {
+		client = redis.NewClient(&redis.Options{Addr: RedisDefaultLocation})
+	}
 
 	ctx := context.Background()
 
@@ -50,6 +68,10 @@ func NewHandler[T Item](dbName string) (*Handler[T], error) {
 		jsonHelper:  jsonHelper,
 		context:     ctx,
 		keyPrefix:   dbName + ":",
+		counterKey:  "nextid:" + dbName,
+		recallKey:   "idempotency:" + dbName + ":",
+		trashPrefix: "trash:" + dbName + ":",
+		retention:   retentionOrDefault(opts.Retention),
 	}, nil
 }
 
@@ -59,46 +81,224 @@ func NewHandler[T Item](dbName string) (*Handler[T], error) {
 
 func (r *Handler[T]) getItemFromDB(key string) (T, error) {
 	var it T
-	itemObject, err := r.jsonHelper.JSONGet(key, ".")
-	if err != nil {
-		return it, err
+	err := r.getJSON(key, &it)
+	if isRedisNilError(err) {
+		return it, fmt.Errorf("%w: %s does not exist", ErrNotFound, key)
+	}
+	return it, err
+}
+
+func (r *Handler[T]) getTombstoneFromDB(key string) (Tombstone[T], error) {
+	var t Tombstone[T]
+	err := r.getJSON(key, &t)
+	if isRedisNilError(err) {
+		return t, fmt.Errorf("%w: %s is not in the trash", ErrNotFound, key)
 	}
-	err = json.Unmarshal(itemObject.([]byte), &it)
+	return t, err
+}
+
+func (r *Handler[T]) getJSON(key string, v any) error {
+	object, err := r.jsonHelper.JSONGet(key, ".")
 	if err != nil {
-		return it, err
+		return err
 	}
-	return it, nil
+	return json.Unmarshal(object.([]byte), v)
 }
 
 func (r *Handler[T]) getKeyFromId(id uint) string {
 	return fmt.Sprintf("%s%d", r.keyPrefix, id)
 }
 
+func (r *Handler[T]) getTrashKeyFromId(id uint) string {
+	return fmt.Sprintf("%s%d", r.trashPrefix, id)
+}
+
+// keys returns the keys that match pattern, see config.Keys
+func (r *Handler[T]) keys(pattern string) ([]string, error) {
+	return config.Keys(r.context, r.cacheClient, pattern)
+}
+
+func isRedisNilError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(err, redis.Nil) || err.Error() == RedisNilError
+}
+
+// setIfAbsent stores the item only when its key is free, so two writers
+// racing on the same id cannot overwrite each other
+func (r *Handler[T]) setIfAbsent(it T) (bool, error) {
+	redisKey := r.getKeyFromId(it.GetID())
+	res, err := r.jsonHelper.JSONSet(redisKey, ".", it, rjs.SetOptionNX)
+	if err != nil {
+		return false, err
+	}
+	return res != nil, nil
+}
+
+// moveToTrash writes the tombstone of it before deleting it, so an item is
+// never lost when redis fails in between
+func (r *Handler[T]) moveToTrash(it T) error {
+	if _, err := r.jsonHelper.JSONSet(r.getTrashKeyFromId(it.GetID()), ".", newTombstone(it, r.retention)); err != nil {
+		return err
+	}
+	return r.cacheClient.Del(r.context, r.getKeyFromId(it.GetID())).Err()
+}
+
 //------------------------------------------------------------
 // THESE ARE THE PUBLIC FUNCTIONS THAT SUPPORT OUR TODO APP
 //------------------------------------------------------------
 
 func (r *Handler[T]) Add(it T) error {
-	redisKey := r.getKeyFromId(it.GetID())
-	if _, err := r.getItemFromDB(redisKey); err == nil {
-		return errors.New("item already exists")
-	}
-
-	if _, err := r.jsonHelper.JSONSet(redisKey, ".", it); err != nil {
+	ok, err := r.setIfAbsent(it)
+	if err != nil {
 		return err
 	}
+	if !ok {
+		return fmt.Errorf("%w: %s already exists", ErrConflict, r.getKeyFromId(it.GetID()))
+	}
 
 	return nil
 }
 
+// Create allocates the next free id from a redis counter and stores the
+// item built by withID under it.  Ids that were taken by a client through
+// Add are skipped, so the counter and client chosen ids can coexist.
+func (r *Handler[T]) Create(withID func(id uint) T) (T, error) {
+	for {
+		next, err := r.cacheClient.Incr(r.context, r.counterKey).Result()
+		if err != nil {
+			var it T
+			return it, err
+		}
+
+		it := withID(uint(next))
+		ok, err := r.setIfAbsent(it)
+		if err != nil {
+			return it, err
+		}
+		if ok {
+			return it, nil
+		}
+	}
+}
+
+// Recall returns the item that was created under an Idempotency-Key, if
+// the key was seen before and the item still exists
+func (r *Handler[T]) Recall(idempotencyKey string) (T, bool, error) {
+	var it T
+	idS, err := r.cacheClient.Get(r.context, r.recallKey+idempotencyKey).Result()
+	if err != nil {
+		if isRedisNilError(err) {
+			return it, false, nil
+		}
+		return it, false, err
+	}
+	if idS == idempotencyPending {
+		return it, false, nil
+	}
+
+	return r.recalled(idS)
+}
+
+// recalled returns the item whose id an Idempotency-Key holds, if it
+// still exists
+func (r *Handler[T]) recalled(idS string) (T, bool, error) {
+	var it T
+	id64, err := strconv.ParseUint(idS, 10, 32)
+	if err != nil {
+		return it, false, err
+	}
+
+	it, err = r.Get(uint(id64))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return it, false, nil
+		}
+		return it, false, err
+	}
+	return it, true, nil
+}
+
+// CreateOnce is Create for a request with an Idempotency-Key, which it
+// claims before creating the item, so of two requests with the same key
+// only one creates an item.  The bool result is true when an earlier
+// request created the item, which is returned instead.  While that
+// request is still running it fails with ErrConflict.  Without a key it
+// is Create.
+func (r *Handler[T]) CreateOnce(idempotencyKey string, withID func(id uint) T) (T, bool, error) {
+	if idempotencyKey == "" {
+		it, err := r.Create(withID)
+		return it, false, err
+	}
+
+	key := r.recallKey + idempotencyKey
+	it, ok, err := r.claim(key)
+	if err != nil || ok {
+		return it, ok, err
+	}
+
+	it, err = r.Create(withID)
+	if err != nil {
+		//free the key, so that a retry can create the item
+		r.cacheClient.Del(r.context, key)
+		return it, false, err
+	}
+	if err := r.cacheClient.Set(r.context, key, it.GetID(), IdempotencyKeyTTL).Err(); err != nil {
+		log.Println("Error remembering idempotency key: ", err)
+	}
+	return it, false, nil
+}
+
+// claim takes the Idempotency-Key key for a request that is about to
+// create an item, unless an earlier request created one with it that is
+// still there.  WATCH makes sure that only one request gets the key.
+func (r *Handler[T]) claim(key string) (T, bool, error) {
+	var it T
+	found := false
+	err := r.cacheClient.Watch(r.context, func(tx *redis.Tx) error {
+		idS, err := tx.Get(r.context, key).Result()
+		switch {
+		case err == nil && idS == idempotencyPending:
+			return errIdempotencyPending
+		case err == nil:
+			it, found, err = r.recalled(idS)
+			if err != nil || found {
+				return err
+			}
+			//its item was deleted since, the key is free again
+		case !isRedisNilError(err):
+			return err
+		}
+		_, err = tx.TxPipelined(r.context, func(pipe redis.Pipeliner) error {
+			pipe.Set(r.context, key, idempotencyPending, IdempotencyClaimTTL)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		//another request took the key between GET and SET
+		err = errIdempotencyPending
+	}
+	return it, found, err
+}
+
 func (r *Handler[T]) Delete(id uint) error {
+	it, err := r.Get(id)
+	if err != nil {
+		return err
+	}
+	return r.moveToTrash(it)
+}
+
+func (r *Handler[T]) Discard(id uint) error {
 	pattern := r.getKeyFromId(id)
 	numDeleted, err := r.cacheClient.Del(r.context, pattern).Result()
 	if err != nil {
 		return err
 	}
 	if numDeleted == 0 {
-		return errors.New("attempted to delete non-existent item")
+		return fmt.Errorf("%w: %s does not exist", ErrNotFound, pattern)
 	}
 
 	return nil
@@ -108,7 +308,7 @@ func (r *Handler[T]) Update(it T, updater func(old T, new T) (T, error)) (T, err
 	redisKey := r.getKeyFromId(it.GetID())
 	existedItem, err := r.getItemFromDB(redisKey)
 	if err != nil {
-		return existedItem, errors.New("item does not exist")
+		return existedItem, err
 	}
 
 	updatedItem, err := updater(existedItem, it)
@@ -135,7 +335,10 @@ func (r *Handler[T]) Get(id uint) (T, error) {
 func (r *Handler[T]) All() ([]T, error) {
 	itemList := make([]T, 0)
 	pattern := r.keyPrefix + "*"
-	ks, _ := r.cacheClient.Keys(r.context, pattern).Result()
+	ks, err := r.keys(pattern)
+	if err != nil {
+		return itemList, err
+	}
 	for _, key := range ks {
 		existedItem, err := r.getItemFromDB(key)
 		if err != nil {
@@ -149,21 +352,82 @@ func (r *Handler[T]) All() ([]T, error) {
 
 func (r *Handler[T]) Clear() error {
 	pattern := r.keyPrefix + "*"
-	ks, err := r.cacheClient.Keys(r.context, pattern).Result()
+	ks, err := r.keys(pattern)
 	if err != nil {
 		return err
 	}
-	if len(ks) == 0 {
-		return nil
+	for _, key := range ks {
+		it, err := r.getItemFromDB(key)
+		if errors.Is(err, ErrNotFound) {
+			continue //deleted meanwhile
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.moveToTrash(it); err != nil {
+			return err
+		}
 	}
-	numDeleted, err := r.cacheClient.Del(r.context, ks...).Result()
+
+	return nil
+}
+
+// Trash returns the deleted items ordered by id
+func (r *Handler[T]) Trash() ([]Tombstone[T], error) {
+	trash := make([]Tombstone[T], 0)
+	ks, err := r.keys(r.trashPrefix + "*")
 	if err != nil {
-		return err
+		return trash, err
 	}
+	for _, key := range ks {
+		t, err := r.getTombstoneFromDB(key)
+		if errors.Is(err, ErrNotFound) {
+			continue //purged meanwhile
+		}
+		if err != nil {
+			return trash, err
+		}
+		trash = append(trash, t)
+	}
+	sortTombstones(trash)
+	return trash, nil
+}
 
-	if numDeleted != int64(len(ks)) {
-		return errors.New("one or more items could not be deleted")
+// Restore moves an item from the trash back to its id, as long as no other
+// item took the id in the meantime
+func (r *Handler[T]) Restore(id uint) (T, error) {
+	trashKey := r.getTrashKeyFromId(id)
+	t, err := r.getTombstoneFromDB(trashKey)
+	if err != nil {
+		return t.Item, err
 	}
 
-	return nil
+	ok, err := r.setIfAbsent(t.Item)
+	if err != nil {
+		return t.Item, err
+	}
+	if !ok {
+		return t.Item, fmt.Errorf("%w: %s already exists, delete it before restoring the old one", ErrConflict, r.getKeyFromId(id))
+	}
+	return t.Item, r.cacheClient.Del(r.context, trashKey).Err()
+}
+
+// Purge drops the tombstones whose retention period is over at now
+func (r *Handler[T]) Purge(now time.Time) (int, error) {
+	trash, err := r.Trash()
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, t := range trash {
+		if !t.expired(now) {
+			continue
+		}
+		if err := r.cacheClient.Del(r.context, r.getTrashKeyFromId(t.Item.GetID())).Err(); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
 }
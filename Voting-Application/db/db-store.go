@@ -0,0 +1,246 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store is what the apis need from a database.  Handler implements it on
+// top of redis, MemoryStore keeps everything in process, which lets the
+// apis run without a redis server, eg in the contract tests.
+//
+// Delete and Clear move items to the trash, see Tombstone.  Discard is
+// for undoing a write that failed half way, it skips the trash.
+type Store[T Item] interface {
+	Add(it T) error
+	Create(withID func(id uint) T) (T, error)
+	Recall(idempotencyKey string) (T, bool, error)
+	CreateOnce(idempotencyKey string, withID func(id uint) T) (T, bool, error)
+	Delete(id uint) error
+	Discard(id uint) error
+	Update(it T, updater func(old T, new T) (T, error)) (T, error)
+	Get(id uint) (T, error)
+	All() ([]T, error)
+	Clear() error
+	Trash() ([]Tombstone[T], error)
+	Restore(id uint) (T, error)
+	Purge(now time.Time) (int, error)
+}
+
+var (
+	_ Store[Item] = (*Handler[Item])(nil)
+	_ Store[Item] = (*MemoryStore[Item])(nil)
+)
+
+// MemoryStore is a Store that keeps its items in a map.  It follows the
+// same rules as Handler, so the apis behave the same on either one.
+// Idempotency keys never expire.
+type MemoryStore[T Item] struct {
+	mu        sync.Mutex
+	name      string
+	items     map[uint]T
+	nextID    uint
+	recalled  map[string]uint
+	trash     map[uint]Tombstone[T]
+	retention time.Duration
+}
+
+func NewMemoryStore[T Item](dbName string) *MemoryStore[T] {
+	return &MemoryStore[T]{
+		name:      dbName,
+		items:     make(map[uint]T),
+		recalled:  make(map[string]uint),
+		trash:     make(map[uint]Tombstone[T]),
+		retention: DefaultRetention,
+	}
+}
+
+func (m *MemoryStore[T]) key(id uint) string {
+	return fmt.Sprintf("%s:%d", m.name, id)
+}
+
+func (m *MemoryStore[T]) Add(it T) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.items[it.GetID()]; ok {
+		return fmt.Errorf("%w: %s already exists", ErrConflict, m.key(it.GetID()))
+	}
+	m.items[it.GetID()] = it
+	return nil
+}
+
+func (m *MemoryStore[T]) Create(withID func(id uint) T) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.create(withID), nil
+}
+
+func (m *MemoryStore[T]) create(withID func(id uint) T) T {
+	for {
+		m.nextID++
+		if _, ok := m.items[m.nextID]; ok {
+			continue
+		}
+		it := withID(m.nextID)
+		m.items[m.nextID] = it
+		return it
+	}
+}
+
+func (m *MemoryStore[T]) Recall(idempotencyKey string) (T, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var it T
+	id, ok := m.recalled[idempotencyKey]
+	if !ok {
+		return it, false, nil
+	}
+	it, ok = m.items[id]
+	return it, ok, nil
+}
+
+// CreateOnce looks the key up and creates the item under one lock, so
+// no other request can come in between
+func (m *MemoryStore[T]) CreateOnce(idempotencyKey string, withID func(id uint) T) (T, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if idempotencyKey == "" {
+		return m.create(withID), false, nil
+	}
+	if id, ok := m.recalled[idempotencyKey]; ok {
+		if it, ok := m.items[id]; ok {
+			return it, true, nil
+		}
+	}
+	it := m.create(withID)
+	m.recalled[idempotencyKey] = it.GetID()
+	return it, false, nil
+}
+
+func (m *MemoryStore[T]) Delete(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.items[id]
+	if !ok {
+		return fmt.Errorf("%w: %s does not exist", ErrNotFound, m.key(id))
+	}
+	m.trash[id] = newTombstone(it, m.retention)
+	delete(m.items, id)
+	return nil
+}
+
+func (m *MemoryStore[T]) Discard(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.items[id]; !ok {
+		return fmt.Errorf("%w: %s does not exist", ErrNotFound, m.key(id))
+	}
+	delete(m.items, id)
+	return nil
+}
+
+func (m *MemoryStore[T]) Update(it T, updater func(old T, new T) (T, error)) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existedItem, ok := m.items[it.GetID()]
+	if !ok {
+		return existedItem, fmt.Errorf("%w: %s does not exist", ErrNotFound, m.key(it.GetID()))
+	}
+
+	updatedItem, err := updater(existedItem, it)
+	if err != nil {
+		return existedItem, err
+	}
+	m.items[it.GetID()] = updatedItem
+	return updatedItem, nil
+}
+
+func (m *MemoryStore[T]) Get(id uint) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	it, ok := m.items[id]
+	if !ok {
+		return it, fmt.Errorf("%w: %s does not exist", ErrNotFound, m.key(id))
+	}
+	return it, nil
+}
+
+// All returns the items ordered by id, redis makes no promise about the
+// order, so callers should not rely on it either
+func (m *MemoryStore[T]) All() ([]T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	itemList := make([]T, 0, len(m.items))
+	for _, it := range m.items {
+		itemList = append(itemList, it)
+	}
+	sort.Slice(itemList, func(i, j int) bool {
+		return itemList[i].GetID() < itemList[j].GetID()
+	})
+	return itemList, nil
+}
+
+func (m *MemoryStore[T]) Clear() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, it := range m.items {
+		m.trash[id] = newTombstone(it, m.retention)
+	}
+	m.items = make(map[uint]T)
+	return nil
+}
+
+// Trash returns the deleted items ordered by id
+func (m *MemoryStore[T]) Trash() ([]Tombstone[T], error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	trash := make([]Tombstone[T], 0, len(m.trash))
+	for _, t := range m.trash {
+		trash = append(trash, t)
+	}
+	sortTombstones(trash)
+	return trash, nil
+}
+
+func (m *MemoryStore[T]) Restore(id uint) (T, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.trash[id]
+	if !ok {
+		return t.Item, fmt.Errorf("%w: %s is not in the trash", ErrNotFound, m.key(id))
+	}
+	if _, ok := m.items[id]; ok {
+		return t.Item, fmt.Errorf("%w: %s already exists, delete it before restoring the old one", ErrConflict, m.key(id))
+	}
+	m.items[id] = t.Item
+	delete(m.trash, id)
+	return t.Item, nil
+}
+
+func (m *MemoryStore[T]) Purge(now time.Time) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purged := 0
+	for id, t := range m.trash {
+		if t.expired(now) {
+			delete(m.trash, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,185 @@
+package db_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"db"
+	"db/dbtest"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type item struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func (it item) GetID() uint {
+	return it.ID
+}
+
+func (it item) WithID(id uint) item {
+	it.ID = id
+	return it
+}
+
+// stores are the backends that every test runs against, a fresh empty one
+// per call
+var stores = []struct {
+	name string
+	new  func(t *testing.T) db.Store[item]
+}{
+	{"memory", func(t *testing.T) db.Store[item] {
+		return db.NewMemoryStore[item]("item")
+	}},
+	{"redis", func(t *testing.T) db.Store[item] {
+		m := dbtest.Redis(t)
+		client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+		t.Cleanup(func() { client.Close() })
+		store, err := db.NewHandler[item]("item", db.Options{Client: client})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}},
+}
+
+func TestCreateIDs(t *testing.T) {
+	tests := []struct {
+		name  string
+		added []uint
+		want  []uint
+	}{
+		{"counts up from 1", nil, []uint{1, 2, 3}},
+		{"skips added ids", []uint{2, 3}, []uint{1, 4, 5}},
+		{"skips an id added ahead", []uint{5}, []uint{1, 2, 3, 4, 6}},
+	}
+	for _, s := range stores {
+		for _, tt := range tests {
+			t.Run(s.name+"/"+tt.name, func(t *testing.T) {
+				store := s.new(t)
+				for _, id := range tt.added {
+					if err := store.Add(item{ID: id}); err != nil {
+						t.Fatal(err)
+					}
+				}
+				for _, want := range tt.want {
+					it, err := store.Create(item{Name: "new"}.WithID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if it.ID != want {
+						t.Fatalf("got id %d, want %d", it.ID, want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCreateOnce(t *testing.T) {
+	type create struct {
+		key          string
+		wantID       uint
+		wantReplayed bool
+	}
+	tests := []struct {
+		name    string
+		creates []create
+		deleted uint
+		after   create
+	}{
+		{
+			name:    "no key creates every time",
+			creates: []create{{"", 1, false}, {"", 2, false}},
+		},
+		{
+			name:    "the same key replays",
+			creates: []create{{"a", 1, false}, {"a", 1, true}, {"a", 1, true}},
+		},
+		{
+			name:    "other keys create",
+			creates: []create{{"a", 1, false}, {"b", 2, false}, {"a", 1, true}},
+		},
+		{
+			name:    "a key whose item is gone creates again",
+			creates: []create{{"a", 1, false}},
+			deleted: 1,
+			after:   create{"a", 2, false},
+		},
+	}
+	for _, s := range stores {
+		for _, tt := range tests {
+			t.Run(s.name+"/"+tt.name, func(t *testing.T) {
+				store := s.new(t)
+				check := func(c create) {
+					t.Helper()
+					it, replayed, err := store.CreateOnce(c.key, item{Name: c.key}.WithID)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if it.ID != c.wantID || replayed != c.wantReplayed {
+						t.Fatalf("key %q: got id %d replayed %v, want id %d replayed %v",
+							c.key, it.ID, replayed, c.wantID, c.wantReplayed)
+					}
+					recalled, ok, err := store.Recall(c.key)
+					if c.key != "" && (err != nil || !ok || recalled != it) {
+						t.Fatalf("recall %q: got %+v, %v, %v, want %+v", c.key, recalled, ok, err, it)
+					}
+				}
+				for _, c := range tt.creates {
+					check(c)
+				}
+				if tt.deleted != 0 {
+					if err := store.Delete(tt.deleted); err != nil {
+						t.Fatal(err)
+					}
+					if _, ok, err := store.Recall(tt.after.key); ok || err != nil {
+						t.Fatalf("recall after delete: got %v, %v", ok, err)
+					}
+					check(tt.after)
+				}
+			})
+		}
+	}
+}
+
+// TestCreateOnceConcurrent sends the same request many times at once, of
+// which exactly one may create the item.  The others replay it, or are
+// told that it is still being created.
+func TestCreateOnceConcurrent(t *testing.T) {
+	const requests = 20
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			store := s.new(t)
+
+			var wg sync.WaitGroup
+			errs := make(chan error, requests)
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _, err := store.CreateOnce("retried", item{Name: "once"}.WithID)
+					if err != nil && !errors.Is(err, db.ErrConflict) {
+						errs <- err
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				t.Error(err)
+			}
+
+			all, err := store.All()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(all) != 1 {
+				t.Fatalf("got %d items, want 1: %+v", len(all), all)
+			}
+		})
+	}
+}
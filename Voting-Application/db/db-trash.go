@@ -0,0 +1,98 @@
+package db
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+)
+
+// Delete and Clear do not throw items away, they move them to the trash
+// of the store.  An item stays there for the retention period, until
+// Restore brings it back or Purge drops it for good.  The trash keeps the
+// last deleted item of every id.
+
+const (
+	DefaultRetention  = 7 * 24 * time.Hour
+	DefaultPurgeEvery = time.Hour
+)
+
+// Tombstone is an item in the trash.  The apis hand out tombstones of the
+// json form of their items, see TrashOf.
+type Tombstone[T any] struct {
+	Item      T         `json:"item"`
+	DeletedAt time.Time `json:"deletedAt"`
+	PurgeAt   time.Time `json:"purgeAt"`
+}
+
+func newTombstone[T Item](it T, retention time.Duration) Tombstone[T] {
+	now := time.Now().UTC()
+	return Tombstone[T]{Item: it, DeletedAt: now, PurgeAt: now.Add(retention)}
+}
+
+// expired tells if the tombstone may be purged at now
+func (t Tombstone[T]) expired(now time.Time) bool {
+	return !t.PurgeAt.After(now)
+}
+
+// retentionOrDefault is retention, or DefaultRetention when it is not
+// set
+func retentionOrDefault(retention time.Duration) time.Duration {
+	if retention <= 0 {
+		return DefaultRetention
+	}
+	return retention
+}
+
+// TrashOf turns the items in trash into what toJson makes of them
+func TrashOf[T Item, J any](trash []Tombstone[T], toJson func(T) J) []Tombstone[J] {
+	converted := make([]Tombstone[J], 0, len(trash))
+	for _, t := range trash {
+		converted = append(converted, Tombstone[J]{Item: toJson(t.Item), DeletedAt: t.DeletedAt, PurgeAt: t.PurgeAt})
+	}
+	return converted
+}
+
+func sortTombstones[T Item](trash []Tombstone[T]) {
+	sort.Slice(trash, func(i, j int) bool {
+		return trash[i].Item.GetID() < trash[j].Item.GetID()
+	})
+}
+
+// Purger is the part of a Store the purge worker needs
+type Purger interface {
+	Purge(now time.Time) (int, error)
+}
+
+// StartPurger purges the expired tombstones of stores every interval, until
+// the returned function is called
+func StartPurger(every time.Duration, stores ...Purger) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			for _, store := range stores {
+				n, err := store.Purge(time.Now())
+				if err != nil {
+					log.Println("Error purging the trash: ", err)
+				} else if n > 0 {
+					log.Printf("Purged %d item(s) from the trash", n)
+				}
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
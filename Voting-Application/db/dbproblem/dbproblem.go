@@ -0,0 +1,52 @@
+// Package dbproblem renders the errors of the db package as problem
+// documents, it is what the apis of the voting application have in common
+// on top of the problem package.
+package dbproblem
+
+import (
+	"db"
+	"errors"
+	"net/http"
+	"problem"
+	"validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// problems maps the domain errors from the db package to HTTP status
+// codes.  ShouldBindJSON returns the validator's own error type, Convert
+// turns it into a validate.ValidationError so the client gets a list of the
+// fields that failed.
+var problems = problem.Options{
+	Statuses: []problem.Status{
+		{Err: db.ErrNotFound, Code: http.StatusNotFound},
+		{Err: db.ErrConflict, Code: http.StatusConflict},
+		{Err: db.ErrValidation, Code: http.StatusUnprocessableEntity},
+		{Err: db.ErrConfirmationRequired, Code: http.StatusPreconditionRequired},
+	},
+	Convert: validate.AsValidationError,
+	Extend: func(p *problem.Problem, err error) {
+		var ve *validate.ValidationError
+		if errors.As(err, &ve) {
+			for _, f := range ve.Fields {
+				p.Errors = append(p.Errors, problem.FieldError(f))
+			}
+		}
+		var ce *db.ConfirmationError
+		if errors.As(err, &ce) {
+			p.Confirm = ce.Token
+		}
+	},
+}
+
+// Middleware renders the errors the handlers record with problem.Abort or
+// problem.AbortBadRequest as problem+json
+func Middleware() gin.HandlerFunc {
+	return problem.Middleware(problems)
+}
+
+// RegisterValidators adds the custom validation rules from the validate
+// package to the validator gin uses when it binds request bodies
+func RegisterValidators() error {
+	return problem.RegisterValidators(validate.RegisterValidators)
+}
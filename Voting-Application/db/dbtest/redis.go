@@ -0,0 +1,113 @@
+// Package dbtest runs the redis server that the tests of the db package and
+// of the apis built on it use.
+package dbtest
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+)
+
+// Redis runs miniredis, an in-process redis server, for the length of the
+// test.  miniredis does not know the RedisJSON commands, so we teach it the
+// two that db.Handler uses.  The json is stored as a plain string key, which
+// keeps KEYS and DEL working on it the way they do on a real RedisJSON
+// server.
+func Redis(t testing.TB) *miniredis.Miniredis {
+	t.Helper()
+	m := miniredis.RunT(t)
+
+	shim := &jsonShim{m: m}
+	if err := m.Server().Register("JSON.SET", shim.set); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Server().Register("JSON.GET", shim.get); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// jsonShim implements JSON.SET and JSON.GET on the root path only, which
+// is all db.Handler needs
+type jsonShim struct {
+	mu sync.Mutex // makes NX and XX atomic
+	m  *miniredis.Miniredis
+}
+
+// JSON.SET <key> <path> <json> [NX | XX]
+func (s *jsonShim) set(c *server.Peer, cmd string, args []string) {
+	if len(args) < 3 || len(args) > 4 {
+		c.WriteError("ERR wrong number of arguments for '" + cmd + "' command")
+		return
+	}
+	key, path, value := args[0], args[1], args[2]
+	if !isRootPath(path) {
+		c.WriteError("ERR the json shim only supports the root path")
+		return
+	}
+	if !json.Valid([]byte(value)) {
+		c.WriteError("ERR invalid json")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(args) == 4 {
+		exists := s.m.Exists(key)
+		switch args[3] {
+		case "NX", "nx":
+			if exists {
+				c.WriteNull()
+				return
+			}
+		case "XX", "xx":
+			if !exists {
+				c.WriteNull()
+				return
+			}
+		default:
+			c.WriteError("ERR syntax error")
+			return
+		}
+	}
+
+	if err := s.m.Set(key, value); err != nil {
+		c.WriteError("ERR " + err.Error())
+		return
+	}
+	c.WriteOK()
+}
+
+// JSON.GET <key> [path]
+func (s *jsonShim) get(c *server.Peer, cmd string, args []string) {
+	if len(args) < 1 || len(args) > 2 {
+		c.WriteError("ERR wrong number of arguments for '" + cmd + "' command")
+		return
+	}
+	if len(args) == 2 && !isRootPath(args[1]) {
+		c.WriteError("ERR the json shim only supports the root path")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.m.Exists(args[0]) {
+		c.WriteNull()
+		return
+	}
+	value, err := s.m.Get(args[0])
+	if err != nil {
+		c.WriteError("ERR " + err.Error())
+		return
+	}
+	c.WriteBulk(value)
+}
+
+func isRootPath(path string) bool {
+	return path == "." || path == "$"
+}
@@ -0,0 +1,755 @@
+// Package integration runs the three apis together, in process, and checks
+// the paths that tests.sh can not reach: the rules every db.Store has to
+// follow, the whole life of a vote, and what the votes-api does when the
+// voter-api fails half way through a change.
+//
+// Every test runs twice, as the subtests redis and memory: once on
+// db.Handler talking to miniredis, an in process redis server, and once on
+// db.MemoryStore.  poll-api and voter-api listen on httptest servers and
+// the votes-api is pointed at them, the same way the containers are wired
+// together by docker-compose.
+//
+//	cd integration && go test ./...
+package integration
+
+import (
+	"db"
+	"db/dbproblem"
+	"db/dbtest"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"poll-api/poll"
+	"problem"
+	"voter-api/voter"
+	"votes-api/vote"
+
+	pollapi "poll-api/api"
+	voterapi "voter-api/api"
+	votesapi "votes-api/api"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if err := dbproblem.RegisterValidators(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// backend is where the apis under test keep their data
+type backend struct {
+	redis *miniredis.Miniredis // nil for the memory backend
+}
+
+// eachBackend runs check as a subtest on every backend, each one empty
+func eachBackend(t *testing.T, check func(t *testing.T, b backend)) {
+	t.Run("redis", func(t *testing.T) {
+		check(t, backend{redis: dbtest.Redis(t)})
+	})
+	t.Run("memory", func(t *testing.T) {
+		check(t, backend{})
+	})
+}
+
+// openStore returns an empty store for dbName on the backend
+func openStore[T db.Item](t *testing.T, b backend, dbName string) db.Store[T] {
+	t.Helper()
+	if b.redis == nil {
+		return db.NewMemoryStore[T](dbName)
+	}
+	client := redis.NewClient(&redis.Options{Addr: b.redis.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store, err := db.NewHandler[T](dbName, db.Options{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// The store tests pin down the rules of db.Store that the apis rely on.
+// They run on both backends, so they also keep MemoryStore honest about
+// behaving like Handler.
+
+func newVoteStore(t *testing.T, b backend) db.Store[vote.Vote] {
+	t.Helper()
+	return openStore[vote.Vote](t, b, "vote")
+}
+
+func TestAddGet(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		want := vote.Vote{VoteID: 7, VoterID: 1, PollID: 2, VoteValue: 3}
+		if err := store.Add(want); err != nil {
+			t.Fatal(err)
+		}
+		got, err := store.Get(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("got %+v, want %+v", got, want)
+		}
+	})
+}
+
+func TestAddConflict(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		if err := store.Add(vote.Vote{VoteID: 1, VoteValue: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Add(vote.Vote{VoteID: 1, VoteValue: 2}); !errors.Is(err, db.ErrConflict) {
+			t.Fatalf("second add: got %v, want ErrConflict", err)
+		}
+		expectChoice(t, store, 1, 1)
+	})
+}
+
+func TestNotFound(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		if _, err := store.Get(1); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("get: got %v, want ErrNotFound", err)
+		}
+		if err := store.Delete(1); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("delete: got %v, want ErrNotFound", err)
+		}
+		if _, err := store.Update(vote.Vote{VoteID: 1}, vote.Vote.Update); !errors.Is(err, db.ErrNotFound) {
+			t.Errorf("update: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestCreateSkipsTaken(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		if err := store.Add(vote.Vote{VoteID: 1}); err != nil {
+			t.Fatal(err)
+		}
+		v, err := store.Create(vote.Vote{VoterID: 5}.WithID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.VoteID != 2 || v.VoterID != 5 {
+			t.Fatalf("got %+v, want id 2 for voter 5", v)
+		}
+	})
+}
+
+func TestUpdate(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		if err := store.Add(vote.Vote{VoteID: 1, VoterID: 1, PollID: 1, VoteValue: 1}); err != nil {
+			t.Fatal(err)
+		}
+		//vote.Update only takes the new choice, the rest of the vote stays
+		v, err := store.Update(vote.Vote{VoteID: 1, VoterID: 9, VoteValue: 2}, vote.Vote.Update)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.VoterID != 1 || v.VoteValue != 2 {
+			t.Fatalf("update returned %+v", v)
+		}
+		expectChoice(t, store, 1, 2)
+	})
+}
+
+func TestUpdaterError(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		if err := store.Add(vote.Vote{VoteID: 1, VoteValue: 1}); err != nil {
+			t.Fatal(err)
+		}
+		refuse := func(old vote.Vote, new vote.Vote) (vote.Vote, error) {
+			return new, db.ErrValidation
+		}
+		if _, err := store.Update(vote.Vote{VoteID: 1, VoteValue: 2}, refuse); !errors.Is(err, db.ErrValidation) {
+			t.Fatalf("got %v, want the updater's error", err)
+		}
+		expectChoice(t, store, 1, 1)
+	})
+}
+
+func TestRecall(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		if _, ok, err := store.Recall("unknown"); ok || err != nil {
+			t.Fatalf("unknown key: got %v, %v", ok, err)
+		}
+
+		v, replayed, err := store.CreateOnce("key-1", vote.Vote{VoteValue: 4}.WithID)
+		if err != nil || replayed {
+			t.Fatalf("create once: got %v, %v", replayed, err)
+		}
+		again, replayed, err := store.CreateOnce("key-1", vote.Vote{VoteValue: 5}.WithID)
+		if err != nil || !replayed || again != v {
+			t.Fatalf("create once again: got %+v, %v, %v, want %+v", again, replayed, err, v)
+		}
+		got, ok, err := store.Recall("key-1")
+		if err != nil || !ok || got != v {
+			t.Fatalf("recall: got %+v, %v, %v, want %+v", got, ok, err, v)
+		}
+
+		//a key whose item is gone is as good as unknown
+		if err := store.Delete(v.VoteID); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok, err := store.Recall("key-1"); ok || err != nil {
+			t.Fatalf("recall after delete: got %v, %v", ok, err)
+		}
+	})
+}
+
+func TestAllClear(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		for id := uint(1); id <= 3; id++ {
+			if err := store.Add(vote.Vote{VoteID: id}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		expectVotes(t, store, 3)
+
+		if err := store.Clear(); err != nil {
+			t.Fatal(err)
+		}
+		expectVotes(t, store, 0)
+		expectTrash(t, store, 1, 2, 3)
+		//clearing an empty store is not an error
+		if err := store.Clear(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestTrashRestore(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		want := vote.Vote{VoteID: 1, VoterID: 2, PollID: 3, VoteValue: 4}
+		if err := store.Add(want); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Delete(1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Get(1); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("get deleted: got %v, want ErrNotFound", err)
+		}
+		trash, err := store.Trash()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(trash) != 1 || trash[0].Item != want || !trash[0].PurgeAt.After(trash[0].DeletedAt) {
+			t.Fatalf("trash: got %+v, want a tombstone of %+v", trash, want)
+		}
+
+		got, err := store.Restore(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("restore: got %+v, want %+v", got, want)
+		}
+		expectChoice(t, store, 1, 4)
+		expectTrash(t, store)
+		if _, err := store.Restore(1); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("second restore: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestRestoreConflict(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		if err := store.Add(vote.Vote{VoteID: 1, VoteValue: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Delete(1); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Add(vote.Vote{VoteID: 1, VoteValue: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.Restore(1); !errors.Is(err, db.ErrConflict) {
+			t.Fatalf("restore: got %v, want ErrConflict", err)
+		}
+		//both the new vote and the tombstone are left alone
+		expectChoice(t, store, 1, 2)
+		expectTrash(t, store, 1)
+	})
+}
+
+func TestDiscard(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		if err := store.Add(vote.Vote{VoteID: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Discard(1); err != nil {
+			t.Fatal(err)
+		}
+		expectVotes(t, store, 0)
+		expectTrash(t, store)
+		if err := store.Discard(1); !errors.Is(err, db.ErrNotFound) {
+			t.Fatalf("discard: got %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestPurge(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		store := newVoteStore(t, b)
+
+		for id := uint(1); id <= 2; id++ {
+			if err := store.Add(vote.Vote{VoteID: id}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Delete(id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n, err := store.Purge(time.Now()); n != 0 || err != nil {
+			t.Fatalf("purge now: got %d, %v, want nothing purged", n, err)
+		}
+		expectTrash(t, store, 1, 2)
+		if n, err := store.Purge(time.Now().Add(db.DefaultRetention + time.Minute)); n != 2 || err != nil {
+			t.Fatalf("purge after the retention period: got %d, %v, want 2 purged", n, err)
+		}
+		expectTrash(t, store)
+	})
+}
+
+// The lifecycle tests drive the votes-api over http.  A vote lives in two
+// places, the votes store and the voting history of its voter in the
+// voter-api, and the tests make sure both always agree, also when the
+// voter-api fails in the middle of a change and the votes-api has to undo
+// its own half.
+
+const (
+	poll1  = `{"id": 1, "title": "pet type", "question": "which type of pet do you like?", "options": [{"id": 1, "value": "dog"}, {"id": 2, "value": "cat"}]}`
+	voter1 = `{"id": 1, "firstName": "Mike", "lastName": "F"}`
+
+	vote1       = `{"id": 1, "voterId": 1, "pollId": 1, "choiceId": 1}`
+	vote1Change = `{"id": 1, "voterId": 1, "pollId": 1, "choiceId": 2}`
+	vote2       = `{"id": 2, "voterId": 1, "pollId": 1, "choiceId": 2}`
+	newVote     = `{"voterId": 1, "pollId": 1, "choiceId": 1}`
+)
+
+func TestLifecycle(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		key := []string{"Idempotency-Key", "lifecycle-1"}
+		body := expect(t, "create vote", http.StatusCreated)(call(s.votesRouter, http.MethodPost, "/votes", newVote, key...))
+		var links vote.Links
+		if err := json.Unmarshal([]byte(body), &links); err != nil {
+			t.Fatal(err)
+		}
+		if links.Vote != "localhost/votes/1" {
+			t.Fatalf("create vote: got link %q, want localhost/votes/1", links.Vote)
+		}
+
+		//a retry with the same key gets the same vote, not a second one
+		expect(t, "retry create vote", http.StatusCreated)(call(s.votesRouter, http.MethodPost, "/votes", newVote, key...))
+		expectVotes(t, s.votes, 1)
+		s.expectHistory(t, true)
+
+		expect(t, "get vote", http.StatusOK)(call(s.votesRouter, http.MethodGet, "/votes/1", ""))
+		expect(t, "change vote", http.StatusOK)(call(s.votesRouter, http.MethodPut, "/votes/1", vote1Change))
+		expectChoice(t, s.votes, 1, 2)
+
+		expect(t, "delete vote", http.StatusOK)(call(s.votesRouter, http.MethodDelete, "/votes/1", ""))
+		s.expectNoVote(t, 1)
+		s.expectHistory(t, false)
+		expect(t, "get deleted vote", http.StatusNotFound)(call(s.votesRouter, http.MethodGet, "/votes/1", ""))
+	})
+}
+
+func TestAddVoteRejected(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		s.faults.failNext(http.MethodPost, http.StatusInternalServerError)
+		expect(t, "add vote", http.StatusInternalServerError)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+		s.expectNoVote(t, 1)
+		s.expectHistory(t, false)
+		//the vote never existed as far as the client knows, so it is not
+		//in the trash either
+		expectTrash(t, s.votes)
+
+		//the undo freed the id, so the voter can simply try again
+		expect(t, "add vote again", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+		s.expectHistory(t, true)
+	})
+}
+
+func TestAddVoteUnreachable(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		s.faults.failNext(http.MethodPost, dropConnection)
+		expect(t, "add vote", http.StatusInternalServerError)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+		s.expectNoVote(t, 1)
+		s.expectHistory(t, false)
+	})
+}
+
+func TestAddVoteConflict(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		expect(t, "add vote 1", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+		expect(t, "add vote 2", http.StatusConflict)(call(s.votesRouter, http.MethodPost, "/votes/2", vote2))
+		s.expectNoVote(t, 2)
+		//the first vote and its history record are left alone
+		expectChoice(t, s.votes, 1, 1)
+		s.expectHistory(t, true)
+	})
+}
+
+func TestCreateVoteRejected(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		key := []string{"Idempotency-Key", "rejected-1"}
+		s.faults.failNext(http.MethodPost, http.StatusServiceUnavailable)
+		expect(t, "create vote", http.StatusInternalServerError)(call(s.votesRouter, http.MethodPost, "/votes", newVote, key...))
+		expectVotes(t, s.votes, 0)
+		if _, ok, err := s.votes.Recall("rejected-1"); ok || err != nil {
+			t.Fatalf("the idempotency key of the failed request was kept: %v, %v", ok, err)
+		}
+
+		//so a retry with the same key really creates the vote
+		expect(t, "retry create vote", http.StatusCreated)(call(s.votesRouter, http.MethodPost, "/votes", newVote, key...))
+		expectVotes(t, s.votes, 1)
+		s.expectHistory(t, true)
+	})
+}
+
+func TestUpdateVoteRejected(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		expect(t, "add vote", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+
+		s.faults.failNext(http.MethodPut, http.StatusInternalServerError)
+		expect(t, "change vote", http.StatusInternalServerError)(call(s.votesRouter, http.MethodPut, "/votes/1", vote1Change))
+		expectChoice(t, s.votes, 1, 1)
+	})
+}
+
+// TestDeleteVoteFails makes the voter-api fail to delete the history record
+// of the vote, safeDeleteVote has to bring the vote back as it was
+func TestDeleteVoteFails(t *testing.T) {
+	faults := []struct {
+		name  string
+		fault int
+	}{
+		{"rejected", http.StatusInternalServerError},
+		{"unreachable", dropConnection},
+	}
+	for _, f := range faults {
+		t.Run(f.name, func(t *testing.T) {
+			eachBackend(t, func(t *testing.T, b backend) {
+				s := newStack(t, b)
+
+				expect(t, "add vote", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+
+				s.faults.failNext(http.MethodDelete, f.fault)
+				expect(t, "delete vote", http.StatusInternalServerError)(call(s.votesRouter, http.MethodDelete, "/votes/1", ""))
+				expectChoice(t, s.votes, 1, 1)
+				s.expectHistory(t, true)
+
+				//once the voter-api is back the delete goes through
+				expect(t, "delete vote again", http.StatusOK)(call(s.votesRouter, http.MethodDelete, "/votes/1", ""))
+				s.expectHistory(t, false)
+			})
+		})
+	}
+}
+
+func TestRestoreVote(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		expect(t, "add vote", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+		expect(t, "delete vote", http.StatusOK)(call(s.votesRouter, http.MethodDelete, "/votes/1", ""))
+		s.expectHistory(t, false)
+
+		expect(t, "list trash", http.StatusOK)(call(s.votesRouter, http.MethodGet, "/votes/trash", ""))
+		expect(t, "restore vote", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/trash/1/restore", ""))
+		expectChoice(t, s.votes, 1, 1)
+		s.expectHistory(t, true)
+		expect(t, "restore vote again", http.StatusNotFound)(call(s.votesRouter, http.MethodPost, "/votes/trash/1/restore", ""))
+	})
+}
+
+func TestRestoreVoteGone(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		expect(t, "add vote", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+		expect(t, "delete vote", http.StatusOK)(call(s.votesRouter, http.MethodDelete, "/votes/1", ""))
+		expect(t, "delete voter", http.StatusOK)(call(s.voterRouter, http.MethodDelete, "/voters/1", ""))
+
+		expect(t, "restore vote", http.StatusUnprocessableEntity)(call(s.votesRouter, http.MethodPost, "/votes/trash/1/restore", ""))
+		s.expectNoVote(t, 1)
+		//the vote went back to the trash, it can be restored with its voter
+		expectTrash(t, s.votes, 1)
+		expect(t, "restore voter", http.StatusOK)(call(s.voterRouter, http.MethodPost, "/voters/trash/1/restore", ""))
+		expect(t, "restore vote again", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/trash/1/restore", ""))
+		s.expectHistory(t, true)
+	})
+}
+
+func TestDeleteAllConfirmed(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		s := newStack(t, b)
+
+		expect(t, "add vote", http.StatusOK)(call(s.votesRouter, http.MethodPost, "/votes/1", vote1))
+
+		body := expect(t, "delete all votes", http.StatusPreconditionRequired)(call(s.votesRouter, http.MethodDelete, "/votes", ""))
+		var p problem.Problem
+		if err := json.Unmarshal([]byte(body), &p); err != nil || p.Confirm == "" {
+			t.Fatalf("delete all votes: no confirmation token in %s", body)
+		}
+		expectVotes(t, s.votes, 1)
+
+		expect(t, "wrong token", http.StatusPreconditionRequired)(call(s.votesRouter, http.MethodDelete, "/votes?confirm=nope", ""))
+		expect(t, "confirmed delete", http.StatusOK)(call(s.votesRouter, http.MethodDelete, "/votes?confirm="+p.Confirm, ""))
+		expectVotes(t, s.votes, 0)
+		s.expectHistory(t, false)
+		//a token is only good once
+		expect(t, "token used twice", http.StatusPreconditionRequired)(call(s.votesRouter, http.MethodDelete, "/votes?confirm="+p.Confirm, ""))
+	})
+}
+
+// TestStoreFailure makes redis itself fail, the memory backend has no way
+// to fail
+func TestStoreFailure(t *testing.T) {
+	eachBackend(t, func(t *testing.T, b backend) {
+		if b.redis == nil {
+			t.Skip("the memory store can not fail")
+		}
+		s := newStack(t, b)
+
+		b.redis.SetError("ERR injected failure")
+		defer b.redis.SetError("")
+		expect(t, "get vote", http.StatusInternalServerError)(call(s.votesRouter, http.MethodGet, "/votes/1", ""))
+	})
+}
+
+// expect returns a function that checks the result of call and returns the
+// body, so the two can be chained: expect(t, "what", 200)(call(...))
+func expect(t *testing.T, what string, want int) func(status int, body string) string {
+	return func(status int, body string) string {
+		t.Helper()
+		if status != want {
+			t.Fatalf("%s: got status %d, want %d: %s", what, status, want, body)
+		}
+		return body
+	}
+}
+
+// expectChoice fails unless the stored vote id has the choice
+func expectChoice(t *testing.T, store db.Store[vote.Vote], id uint, choice uint) {
+	t.Helper()
+	v, err := store.Get(id)
+	if err != nil {
+		t.Fatalf("vote %d: %v", id, err)
+	}
+	if v.VoteValue != choice {
+		t.Fatalf("vote %d has choice %d, want %d", id, v.VoteValue, choice)
+	}
+}
+
+// expectTrash fails unless the trash of the store holds the votes ids, in
+// order
+func expectTrash(t *testing.T, store db.Store[vote.Vote], ids ...uint) {
+	t.Helper()
+	trash, err := store.Trash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]uint, 0, len(trash))
+	for _, ts := range trash {
+		got = append(got, ts.Item.VoteID)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(ids) {
+		t.Fatalf("the trash holds votes %v, want %v", got, ids)
+	}
+}
+
+// expectVotes fails unless the store holds n votes
+func expectVotes(t *testing.T, store db.Store[vote.Vote], n int) {
+	t.Helper()
+	all, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != n {
+		t.Fatalf("the store holds %d votes, want %d", len(all), n)
+	}
+}
+
+// stack is the three apis wired together.  The votes store is kept so the
+// tests can look at what the votes-api really stored, and the voter-api
+// sits behind faults so the tests can make it fail.
+type stack struct {
+	votes  db.Store[vote.Vote]
+	faults *faults
+
+	pollRouter  *gin.Engine
+	voterRouter *gin.Engine
+	votesRouter *gin.Engine
+}
+
+func newRouter() *gin.Engine {
+	r := gin.New()
+	r.Use(dbproblem.Middleware())
+	return r
+}
+
+// newStack wires the apis together on the backend, with poll 1, with
+// options 1 and 2, and voter 1 in it
+func newStack(t *testing.T, b backend) *stack {
+	t.Helper()
+	s := &stack{
+		votes:       openStore[vote.Vote](t, b, "vote"),
+		faults:      &faults{next: make(map[string]int)},
+		pollRouter:  newRouter(),
+		voterRouter: newRouter(),
+		votesRouter: newRouter(),
+	}
+	pollapi.NewPollAPIWithStore(openStore[poll.Poll](t, b, "poll")).RegisterRoutes(s.pollRouter)
+	voterapi.NewVoterAPIWithStore(openStore[voter.Voter](t, b, "voter")).RegisterRoutes(s.voterRouter)
+
+	pollServer := httptest.NewServer(s.pollRouter)
+	t.Cleanup(pollServer.Close)
+	voterServer := httptest.NewServer(s.faults.wrap(s.voterRouter))
+	t.Cleanup(voterServer.Close)
+
+	votesapi.NewVotesAPIWithStore(s.votes, votesapi.Endpoints{
+		HostName:         "localhost",
+		VoterApiInternal: voterServer.URL,
+		PollApiInternal:  pollServer.URL,
+		VoterApiExternal: "localhost:1080",
+		PollApiExternal:  "localhost:1081",
+	}).RegisterRoutes(s.votesRouter)
+
+	expect(t, "create poll 1", http.StatusOK)(call(s.pollRouter, http.MethodPost, "/polls/1", poll1))
+	expect(t, "create voter 1", http.StatusOK)(call(s.voterRouter, http.MethodPost, "/voters/1", voter1))
+	return s
+}
+
+// expectHistory fails unless the voting history of voter 1 has a record
+// for poll 1 exactly when want is true
+func (s *stack) expectHistory(t *testing.T, want bool) {
+	t.Helper()
+	status, body := call(s.voterRouter, http.MethodGet, "/voters/1/polls/1", "")
+	switch {
+	case want && status != http.StatusOK:
+		t.Fatalf("voter 1 has no history record for poll 1: %d %s", status, body)
+	case !want && status != http.StatusNotFound:
+		t.Fatalf("voter 1 still has a history record for poll 1: %d %s", status, body)
+	}
+}
+
+// expectNoVote fails if the votes store still holds vote id
+func (s *stack) expectNoVote(t *testing.T, id uint) {
+	t.Helper()
+	if _, err := s.votes.Get(id); !errors.Is(err, db.ErrNotFound) {
+		t.Fatalf("vote %d was not removed, get returned %v", id, err)
+	}
+}
+
+// call sends one request to router and returns the status and the body
+func call(router http.Handler, method string, path string, body string, header ...string) (int, string) {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, path, r)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code, w.Body.String()
+}
+
+// dropConnection is the fault status that closes the connection instead
+// of answering, the votes-api then sees a transport error
+const dropConnection = 0
+
+// faults makes the voter-api fail on purpose.  After failNext(method,
+// status) the next request with that method on a voting history record
+// is answered with status, and never reaches the voter-api.
+type faults struct {
+	mu   sync.Mutex
+	next map[string]int
+}
+
+func (f *faults) failNext(method string, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.next[method] = status
+}
+
+// take returns the fault planned for r, if any, and forgets it
+func (f *faults) take(r *http.Request) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.Contains(r.URL.Path, "/polls") {
+		return 0, false
+	}
+	status, ok := f.next[r.Method]
+	delete(f.next, r.Method)
+	return status, ok
+}
+
+func (f *faults) wrap(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, ok := f.take(r)
+		switch {
+		case !ok:
+			h.ServeHTTP(w, r)
+		case status == dropConnection:
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+		default:
+			http.Error(w, "injected failure", status)
+		}
+	})
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"poll-api/poll"
@@ -8,24 +9,33 @@ import (
 	"time"
 
 	"db"
+	"problem"
 
 	"github.com/gin-gonic/gin"
 )
 
 type PollAPI struct {
-	polls       *db.Handler[poll.Poll]
-	bootTime    time.Time
-	successes   int
-	badRequests int
+	polls         db.Store[poll.Poll]
+	confirmations *db.Confirmations
+	bootTime      time.Time
+	successes     int
+	badRequests   int
 }
 
-func NewPollAPI() (*PollAPI, error) {
-	dbHandler, err := db.NewHandler[poll.Poll]("poll")
+// NewPollAPI builds the api on the redis store that opts describe
+func NewPollAPI(opts db.Options) (*PollAPI, error) {
+	dbHandler, err := db.NewHandler[poll.Poll]("poll", opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &PollAPI{polls: dbHandler, bootTime: time.Now(), successes: 0, badRequests: 0}, nil
+	return NewPollAPIWithStore(dbHandler), nil
+}
+
+// NewPollAPIWithStore builds the api on top of any store, eg a
+// db.MemoryStore when there is no redis server to talk to
+func NewPollAPIWithStore(store db.Store[poll.Poll]) *PollAPI {
+	return &PollAPI{polls: store, confirmations: db.NewConfirmations(), bootTime: time.Now(), successes: 0, badRequests: 0}
 }
 
 func (api *PollAPI) ListAllPolls(c *gin.Context) {
@@ -33,7 +43,7 @@ func (api *PollAPI) ListAllPolls(c *gin.Context) {
 	if err != nil {
 		log.Println("Error Getting All Polls: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -48,12 +58,20 @@ func (api *PollAPI) ListAllPolls(c *gin.Context) {
 	api.successes++
 }
 
+// DeleteAllPolls moves every poll to the trash.  It has to be confirmed,
+// the first call answers 428 with a token to repeat the call with.
 func (api *PollAPI) DeleteAllPolls(c *gin.Context) {
+	if err := api.confirmations.Confirm("delete all polls", c.Query("confirm")); err != nil {
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
 	err := api.polls.Clear()
 	if err != nil {
 		log.Println("Error deleting All Polls: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 	c.Status(http.StatusOK)
@@ -66,14 +84,14 @@ func (api *PollAPI) GetPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	p, err := api.polls.Get(uint(id64))
 	if err != nil {
 		log.Println("poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -87,7 +105,7 @@ func (api *PollAPI) AddPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -95,14 +113,14 @@ func (api *PollAPI) AddPoll(c *gin.Context) {
 	if err := c.ShouldBindJSON(&p); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	if uint(id64) != p.PollID {
 		log.Println("URL parameter (id) does not match Request Body (poll.PollID)")
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, fmt.Errorf("%w: URL parameter (id) does not match Request Body (poll.PollID)", db.ErrValidation))
 		return
 	}
 
@@ -110,7 +128,7 @@ func (api *PollAPI) AddPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error adding poll: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -118,19 +136,58 @@ func (api *PollAPI) AddPoll(c *gin.Context) {
 	api.successes++
 }
 
+func (api *PollAPI) CreatePoll(c *gin.Context) {
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey != "" {
+		p, ok, err := api.polls.Recall(idempotencyKey)
+		if err != nil {
+			log.Println("Error recalling idempotency key: ", err)
+			api.badRequests++
+			problem.Abort(c, err)
+			return
+		}
+		if ok {
+			c.Header("Location", p.ToJson().Poll)
+			c.JSON(http.StatusCreated, p.ToJson())
+			api.successes++
+			return
+		}
+	}
+
+	p := poll.NewPoll()
+	if err := c.ShouldBindJSON(&p); err != nil {
+		log.Println("Error binding JSON: ", err)
+		api.badRequests++
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	p, _, err := api.polls.CreateOnce(idempotencyKey, p.WithID)
+	if err != nil {
+		log.Println("Error creating poll: ", err)
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	c.Header("Location", p.ToJson().Poll)
+	c.JSON(http.StatusCreated, p.ToJson())
+	api.successes++
+}
+
 func (api *PollAPI) UpdatePoll(c *gin.Context) {
 	var p poll.Poll
 	if err := c.ShouldBindJSON(&p); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	p, err := api.polls.Update(p, poll.Poll.Update)
 	if err != nil {
 		log.Println("Error updating PollInfo: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -144,14 +201,14 @@ func (api *PollAPI) DeletePoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	if err := api.polls.Delete(uint(pollId64)); err != nil {
 		log.Println("Error deleting poll: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -165,14 +222,14 @@ func (api *PollAPI) GetAllOptions(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	p, err := api.polls.Get(uint(id64))
 	if err != nil {
 		log.Println("poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -186,14 +243,14 @@ func (api *PollAPI) DeleteAllOptions(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	p, err := api.polls.Get(uint(id64))
 	if err != nil {
 		log.Println("poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -201,7 +258,7 @@ func (api *PollAPI) DeleteAllOptions(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating PollOptions: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -215,7 +272,7 @@ func (api *PollAPI) GetOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	optionIdS := c.Param("optionid")
@@ -223,7 +280,7 @@ func (api *PollAPI) GetOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -231,7 +288,7 @@ func (api *PollAPI) GetOption(c *gin.Context) {
 	if err != nil {
 		log.Println("poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -239,7 +296,7 @@ func (api *PollAPI) GetOption(c *gin.Context) {
 	if err != nil {
 		log.Println("poll option not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -253,29 +310,23 @@ func (api *PollAPI) AddOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
-	var p poll.Poll
-	if err := c.ShouldBindJSON(&p); err != nil {
+	var req poll.OptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
-	if len(p.PollOptions) != 1 {
-		log.Println("len(p.PollOptions) != 1")
-		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
-	po := p.PollOptions[0]
+	po := req.PollOptions[0]
 
-	p, err = api.polls.Get(uint(pollId64))
+	p, err := api.polls.Get(uint(pollId64))
 	if err != nil {
 		log.Println("poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -283,7 +334,7 @@ func (api *PollAPI) AddOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error adding poll option: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -291,7 +342,7 @@ func (api *PollAPI) AddOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating PollOptions: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -305,29 +356,23 @@ func (api *PollAPI) UpdateOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
-	var p poll.Poll
-	if err := c.ShouldBindJSON(&p); err != nil {
+	var req poll.OptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
-	if len(p.PollOptions) != 1 {
-		log.Println("len(p.PollOptions) != 1")
-		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
-	po := p.PollOptions[0]
+	po := req.PollOptions[0]
 
-	p, err = api.polls.Get(uint(pollId64))
+	p, err := api.polls.Get(uint(pollId64))
 	if err != nil {
 		log.Println("poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -335,7 +380,7 @@ func (api *PollAPI) UpdateOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating poll option: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -343,7 +388,7 @@ func (api *PollAPI) UpdateOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating pollOptions: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -357,7 +402,7 @@ func (api *PollAPI) DeleteOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	optionIdS := c.Param("optionid")
@@ -365,7 +410,7 @@ func (api *PollAPI) DeleteOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -373,7 +418,7 @@ func (api *PollAPI) DeleteOption(c *gin.Context) {
 	if err != nil {
 		log.Println("poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -381,7 +426,7 @@ func (api *PollAPI) DeleteOption(c *gin.Context) {
 	if err != nil {
 		log.Println("poll option not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -389,7 +434,7 @@ func (api *PollAPI) DeleteOption(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating pollOptions: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"poll-api/poll"
+
+	"db"
+	"openapi"
+	"problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Routes is the route table of the poll-api, RegisterRoutes serves it and
+// the OpenAPI document generated from it
+func (api *PollAPI) Routes() []openapi.Route {
+	var (
+		ok         = openapi.Reply(http.StatusOK, nil)
+		pollJson   = poll.PollJson{}
+		optionJson = poll.PollOptionJson{}
+		health     = map[string]any{}
+	)
+
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/polls", Summary: "List the urls of all polls", Handler: api.ListAllPolls,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []string{})}},
+		{Method: http.MethodPost, Path: "/polls", Summary: "Create a poll with a server assigned id", Handler: api.CreatePoll,
+			Body: poll.Poll{}, Responses: []openapi.Response{openapi.Reply(http.StatusCreated, pollJson)}},
+		{Method: http.MethodDelete, Path: "/polls", Summary: "Move all polls to the trash, confirmed with a token", Handler: api.DeleteAllPolls,
+			Query: []string{"confirm"}, Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/polls/:id", Summary: "Get a poll", Handler: api.GetPoll,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, pollJson)}},
+		{Method: http.MethodPost, Path: "/polls/:id", Summary: "Add a poll with the given id", Handler: api.AddPoll,
+			Body: poll.Poll{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, pollJson)}},
+		{Method: http.MethodPut, Path: "/polls/:id", Summary: "Update a poll", Handler: api.UpdatePoll,
+			Body: poll.Poll{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, pollJson)}},
+		{Method: http.MethodPut, Path: "/polls/", Summary: "Update the poll named in the body", Handler: api.UpdatePoll,
+			Body: poll.Poll{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, pollJson)}},
+		{Method: http.MethodDelete, Path: "/polls/:id", Summary: "Move a poll to the trash", Handler: api.DeletePoll,
+			Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/polls/:id/options", Summary: "List the urls of the options of a poll", Handler: api.GetAllOptions,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []string{})}},
+		{Method: http.MethodDelete, Path: "/polls/:id/options", Summary: "Delete all options of a poll", Handler: api.DeleteAllOptions,
+			Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/polls/:id/options/:optionid", Summary: "Get a poll option", Handler: api.GetOption,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, optionJson)}},
+		{Method: http.MethodPost, Path: "/polls/:id/options/:optionid", Summary: "Add an option to a poll", Handler: api.AddOption,
+			Body: poll.OptionRequest{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, optionJson)}},
+		{Method: http.MethodPost, Path: "/polls/:id/options/", Summary: "Add an option to a poll", Handler: api.AddOption,
+			Body: poll.OptionRequest{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, optionJson)}},
+		{Method: http.MethodPut, Path: "/polls/:id/options/:optionid", Summary: "Update a poll option", Handler: api.UpdateOption,
+			Body: poll.OptionRequest{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, optionJson)}},
+		{Method: http.MethodPut, Path: "/polls/:id/options/", Summary: "Update a poll option", Handler: api.UpdateOption,
+			Body: poll.OptionRequest{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, optionJson)}},
+		{Method: http.MethodDelete, Path: "/polls/:id/options/:optionid", Summary: "Delete a poll option", Handler: api.DeleteOption,
+			Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/polls/trash", Summary: "List the deleted polls that can still be restored", Handler: api.ListTrash,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []db.Tombstone[poll.PollJson]{})}},
+		{Method: http.MethodPost, Path: "/polls/trash/:id/restore", Summary: "Restore a deleted poll", Handler: api.RestorePoll,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, pollJson)}},
+
+		{Method: http.MethodGet, Path: "/polls/health", Summary: "Report the health of the api", Handler: api.HealthCheck,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, health)}},
+	}
+}
+
+// RegisterRoutes adds the routes to r, serves the OpenAPI document at
+// /openapi.json and returns the document
+func (api *PollAPI) RegisterRoutes(r gin.IRouter) *openapi.Document {
+	routes := api.Routes()
+	doc := openapi.New(openapi.Info{Title: "poll-api", Version: "1.0.0"}, routes, problem.Problem{}, problem.ContentType)
+	openapi.Register(r, doc, routes)
+	return doc
+}
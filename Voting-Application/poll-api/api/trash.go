@@ -0,0 +1,56 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"poll-api/poll"
+	"strconv"
+	"time"
+
+	"db"
+	"problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash lists the deleted polls that can still be restored
+func (api *PollAPI) ListTrash(c *gin.Context) {
+	trash, err := api.polls.Trash()
+	if err != nil {
+		log.Println("Error Getting the Trash: ", err)
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, db.TrashOf(trash, poll.Poll.ToJson))
+	api.successes++
+}
+
+func (api *PollAPI) RestorePoll(c *gin.Context) {
+	idS := c.Param("id")
+	id64, err := strconv.ParseUint(idS, 10, 32)
+	if err != nil {
+		log.Println("Error converting id to int64: ", err)
+		api.badRequests++
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	p, err := api.polls.Restore(uint(id64))
+	if err != nil {
+		log.Println("Error restoring poll: ", err)
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p.ToJson())
+	api.successes++
+}
+
+// StartPurger drops the polls whose retention period is over every
+// interval, until the returned function is called
+func (api *PollAPI) StartPurger(every time.Duration) (stop func()) {
+	return db.StartPurger(every, api.polls)
+}
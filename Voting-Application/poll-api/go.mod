@@ -3,9 +3,12 @@ module poll-api
 go 1.20
 
 require (
+	config v0.0.0
 	db v0.0.0
 	github.com/gin-contrib/cors v1.4.0
 	github.com/gin-gonic/gin v1.9.1
+	openapi v0.0.0
+	problem v0.0.0
 )
 
 require (
@@ -37,6 +40,15 @@ require (
 	golang.org/x/text v0.9.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
+	validate v0.0.0 // indirect
 )
 
 replace db => ../db
+
+replace problem => ../../problem
+
+replace openapi => ../../openapi
+
+replace config => ../../config
+
+replace validate => ../../validate
@@ -1,59 +1,64 @@
 package main
 
 import (
-	"flag"
+	"config"
+	"db"
+	"db/dbproblem"
 	"fmt"
 	"os"
 	"poll-api/api"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	hostFlag string
-	portFlag uint
-)
-
-func processCmdLineFlags() {
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 1081, "Default Port")
+// Config is the configuration of the poll-api, from the flags, the
+// environment and the -config file, see the config package
+type Config struct {
+	config.Server
+	Redis          config.Redis  `config:"redis"`
+	Purge          time.Duration `config:"purge" validate:"min=0s" usage:"How often to purge the expired items from the trash, 0 to never"`
+	TrashRetention time.Duration `config:"trash-retention" validate:"min=1s" usage:"How long the deleted items stay in the trash"`
+}
 
-	flag.Parse()
+func loadConfig() Config {
+	cfg := Config{
+		Server:         config.Server{Host: "0.0.0.0", Port: 1081},
+		Redis:          config.Redis{URL: db.RedisDefaultLocation},
+		Purge:          db.DefaultPurgeEvery,
+		TrashRetention: db.DefaultRetention,
+	}
+	config.MustLoad("", &cfg)
+	return cfg
 }
 
 func main() {
-	processCmdLineFlags()
+	cfg := loadConfig()
 	r := gin.Default()
 	r.Use(cors.Default())
+	r.Use(dbproblem.Middleware())
+	if err := dbproblem.RegisterValidators(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	apiHandler, err := api.NewPollAPI()
+	redisClient, err := cfg.Redis.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apiHandler, err := api.NewPollAPI(db.Options{Client: redisClient, Retention: cfg.TrashRetention})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	r.GET("/polls", apiHandler.ListAllPolls)
-	r.DELETE("/polls", apiHandler.DeleteAllPolls)
-
-	r.GET("/polls/:id", apiHandler.GetPoll)
-	r.POST("/polls/:id", apiHandler.AddPoll)
-	r.PUT("/polls/:id", apiHandler.UpdatePoll)
-	r.PUT("/polls/", apiHandler.UpdatePoll)
-	r.DELETE("/polls/:id", apiHandler.DeletePoll)
-
-	r.GET("/polls/:id/options", apiHandler.GetAllOptions)
-	r.DELETE("/polls/:id/options", apiHandler.DeleteAllOptions)
-
-	r.GET("/polls/:id/options/:optionid", apiHandler.GetOption)
-	r.POST("/polls/:id/options/:optionid", apiHandler.AddOption)
-	r.POST("/polls/:id/options/", apiHandler.AddOption)
-	r.PUT("/polls/:id/options/:optionid", apiHandler.UpdateOption)
-	r.PUT("/polls/:id/options/", apiHandler.UpdateOption)
-	r.DELETE("/polls/:id/options/:optionid", apiHandler.DeleteOption)
-
-	r.GET("/polls/health", apiHandler.HealthCheck)
+	apiHandler.RegisterRoutes(r)
+	if cfg.Purge > 0 {
+		stop := apiHandler.StartPurger(cfg.Purge)
+		defer stop()
+	}
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	r.Run(cfg.Addr())
 }
@@ -1,25 +1,32 @@
 package poll
 
 import (
-	"errors"
+	"db"
+	"fmt"
 	"strconv"
 )
 
 type pollOption struct {
-	PollOptionID    uint   `json:"id"`
-	PollOptionValue string `json:"value"`
+	PollOptionID    uint   `json:"id" binding:"required"`
+	PollOptionValue string `json:"value" binding:"notblank"`
 }
 
-type pollOptionJson struct {
+type PollOptionJson struct {
 	Option string `json:"option"`
 	Value  string `json:"value"`
 }
 
 type Poll struct {
 	PollID       uint         `json:"id"`
-	PollTitle    string       `json:"title"`
-	PollQuestion string       `json:"question"`
-	PollOptions  []pollOption `json:"options,omitempty"`
+	PollTitle    string       `json:"title" binding:"notblank"`
+	PollQuestion string       `json:"question" binding:"notblank"`
+	PollOptions  []pollOption `json:"options,omitempty" binding:"min=1,unique=PollOptionID,dive"`
+}
+
+// OptionRequest is the body of the poll option endpoints, it carries
+// exactly one option
+type OptionRequest struct {
+	PollOptions []pollOption `json:"options" binding:"len=1,dive"`
 }
 
 type PollJson struct {
@@ -33,6 +40,11 @@ func (p Poll) GetID() uint {
 	return p.PollID
 }
 
+func (p Poll) WithID(id uint) Poll {
+	p.PollID = id
+	return p
+}
+
 func NewPoll() Poll {
 	p := Poll{}
 	p.PollOptions = make([]pollOption, 0)
@@ -61,7 +73,7 @@ func (p Poll) DeleteAllOptions() Poll {
 func (p Poll) AddPoll(newpo pollOption) (Poll, error) {
 	for _, po := range p.PollOptions {
 		if newpo.PollOptionID == po.PollOptionID {
-			return Poll{}, errors.New("pollOption already exists")
+			return Poll{}, fmt.Errorf("%w: pollOption %d already exists", db.ErrConflict, newpo.PollOptionID)
 		}
 	}
 	p.PollOptions = append(p.PollOptions, newpo)
@@ -74,7 +86,7 @@ func (p Poll) GetOption(optionId uint) (pollOption, error) {
 			return po, nil
 		}
 	}
-	return pollOption{}, errors.New("pollOption does not exist")
+	return pollOption{}, fmt.Errorf("%w: pollOption %d does not exist", db.ErrNotFound, optionId)
 }
 
 func (p Poll) UpdateOption(newpo pollOption) (Poll, error) {
@@ -85,7 +97,7 @@ func (p Poll) UpdateOption(newpo pollOption) (Poll, error) {
 			return p, nil
 		}
 	}
-	return Poll{}, errors.New("pollOption does not exist")
+	return Poll{}, fmt.Errorf("%w: pollOption %d does not exist", db.ErrNotFound, optionId)
 }
 
 func (p Poll) DeleteOption(optionId uint) (Poll, error) {
@@ -95,7 +107,7 @@ func (p Poll) DeleteOption(optionId uint) (Poll, error) {
 			return p, nil
 		}
 	}
-	return Poll{}, errors.New("pollOption does not exist")
+	return Poll{}, fmt.Errorf("%w: pollOption %d does not exist", db.ErrNotFound, optionId)
 }
 
 func (p Poll) ToJson() PollJson {
@@ -112,8 +124,8 @@ func (p Poll) ToJson() PollJson {
 	return pj
 }
 
-func (p Poll) GetOptionJson(po pollOption) pollOptionJson {
-	return pollOptionJson{
+func (p Poll) GetOptionJson(po pollOption) PollOptionJson {
+	return PollOptionJson{
 		Option: p.ToJson().Poll + "/options/" + strconv.FormatUint(uint64(po.PollOptionID), 10),
 		Value:  po.PollOptionValue,
 	}
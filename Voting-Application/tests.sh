@@ -4,11 +4,18 @@
 # need previous to create necessary data. So please
 # run them in order. Otherwise some tests might fail.
 
+# confirmed_delete sends a bulk DELETE, which answers 428 with a
+# confirmation token, and repeats it with the token
+confirmed_delete() {
+    token=$(curl --silent --request DELETE "$1" | sed -n 's/.*"confirm":"\([^"]*\)".*/\1/p')
+    curl -i --request DELETE "$1?confirm=$token"
+}
+
 # reset database
 echo '<<<--- Reset Database' &&
-curl -i --request DELETE 'http://localhost/votes' &&
-curl -i --request DELETE 'http://localhost:1080/voters' &&
-curl -i --request DELETE 'http://localhost:1081/polls'
+confirmed_delete 'http://localhost/votes' &&
+confirmed_delete 'http://localhost:1080/voters' &&
+confirmed_delete 'http://localhost:1081/polls'
 
 # Test Section 1 - Poll API --------------------------------
 echo $'<<<--- Test Section 1 - Poll API ---------------------------------\n'
@@ -110,7 +117,7 @@ echo '--->>> test 1.10 get all the options of poll_2' &&
 curl --silent --location 'http://localhost:1081/polls/2/options' && echo $'\n'
 
 echo '--->>> test 1.11 delete all the polls' &&
-curl -i --location --request DELETE 'http://localhost:1081/polls'
+confirmed_delete 'http://localhost:1081/polls'
 
 echo '--->>> test 1.12 get all the polls' &&
 curl --silent --location 'http://localhost:1081/polls' && echo $'\n'
@@ -118,6 +125,15 @@ curl --silent --location 'http://localhost:1081/polls' && echo $'\n'
 echo '--->>> test 1.13 check poll-api health' &&
 curl --silent --location 'http://localhost:1081/polls/health' && echo $'\n'
 
+echo '--->>> test 1.14 list the deleted polls' &&
+curl --silent --location 'http://localhost:1081/polls/trash' && echo $'\n'
+
+echo '--->>> test 1.15 restore poll_2' &&
+curl --silent --location --request POST 'http://localhost:1081/polls/trash/2/restore' && echo $'\n'
+
+echo '--->>> test 1.16 delete poll_2 again' &&
+curl -i --location --request DELETE 'http://localhost:1081/polls/2'
+
 # Test Section 2 - Voter API --------------------------------
 echo $'<<<--- Test Section 2 - Voter API ---------------------------------\n'
 
@@ -126,7 +142,8 @@ curl --silent --location 'http://localhost:1080/voters/1' \
 --header 'Content-Type: application/json' \
 --data '{
     "id": 1,
-    "firstName": "Mike"
+    "firstName": "Mike",
+    "lastName": "F"
 }' && echo $'\n'
 
 echo '--->>> test 2.2 get voter_1' &&
@@ -193,7 +210,7 @@ echo '--->>> test 2.10 get the voteHistory of voter_2' &&
 curl --silent --location 'http://localhost:1080/voters/2/polls' && echo $'\n'
 
 echo '--->>> test 2.11 delete all the voters' &&
-curl -i --location --request DELETE 'http://localhost:1080/voters'
+confirmed_delete 'http://localhost:1080/voters'
 
 echo '--->>> test 2.12 get all the voters' &&
 curl --silent --location 'http://localhost:1080/voters' && echo $'\n'
@@ -201,6 +218,15 @@ curl --silent --location 'http://localhost:1080/voters' && echo $'\n'
 echo '--->>> test 2.13 check voter-api health' &&
 curl --silent --location 'http://localhost:1080/voters/health' && echo $'\n'
 
+echo '--->>> test 2.14 list the deleted voters' &&
+curl --silent --location 'http://localhost:1080/voters/trash' && echo $'\n'
+
+echo '--->>> test 2.15 restore voter_2' &&
+curl --silent --location --request POST 'http://localhost:1080/voters/trash/2/restore' && echo $'\n'
+
+echo '--->>> test 2.16 delete voter_2 again' &&
+curl -i --location --request DELETE 'http://localhost:1080/voters/2'
+
 # Test Section 3 - Votes API --------------------------------
 echo $'<<<--- Test Section 3 - Votes API ---------------------------------\n'
 
@@ -307,7 +333,7 @@ curl --silent --location 'http://localhost/votes/2' \
 }' && echo $'\n'
 
 echo '--->>> test 3.10 delete all the votes' &&
-curl -i --location --request DELETE 'http://localhost/votes'
+confirmed_delete 'http://localhost/votes'
 
 echo '--->>> test 3.9 get all the votes' &&
 curl --silent --location 'http://localhost/votes' && echo $'\n'
@@ -315,8 +341,17 @@ curl --silent --location 'http://localhost/votes' && echo $'\n'
 echo '--->>> test 3.10 check votes-api health' &&
 curl --silent --location 'http://localhost/votes/health' && echo $'\n'
 
+echo '--->>> test 3.13 list the deleted votes' &&
+curl --silent --location 'http://localhost/votes/trash' && echo $'\n'
+
+echo '--->>> test 3.14 restore vote_2, and its voting history record' &&
+curl --silent --location --request POST 'http://localhost/votes/trash/2/restore' && echo $'\n'
+
+echo '--->>> test 3.15 delete vote_2 again' &&
+curl -i --location --request DELETE 'http://localhost/votes/2'
+
 # reset database again
 echo '<<<--- Reset Database Again' &&
-curl -i --request DELETE 'http://localhost/votes' &&
-curl -i --request DELETE 'http://localhost:1080/voters' &&
+confirmed_delete 'http://localhost/votes' &&
+confirmed_delete 'http://localhost:1080/voters' &&
 curl -i --request DELETE 'http://localhost:1081/polls'
\ No newline at end of file
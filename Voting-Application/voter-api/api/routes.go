@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"voter-api/voter"
+
+	"db"
+	"openapi"
+	"problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Routes is the route table of the voter-api, RegisterRoutes serves it
+// and the OpenAPI document generated from it
+func (api *VoterAPI) Routes() []openapi.Route {
+	var (
+		ok            = openapi.Reply(http.StatusOK, nil)
+		voterJson     = voter.VoterJson{}
+		voterPollJson = voter.VoterPollJson{}
+		health        = map[string]any{}
+	)
+
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/voters", Summary: "List the urls of all voters", Handler: api.ListAllVoters,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []string{})}},
+		{Method: http.MethodPost, Path: "/voters", Summary: "Create a voter with a server assigned id", Handler: api.CreateVoter,
+			Body: voter.Voter{}, Responses: []openapi.Response{openapi.Reply(http.StatusCreated, voterJson)}},
+		{Method: http.MethodDelete, Path: "/voters", Summary: "Move all voters to the trash, confirmed with a token", Handler: api.DeleteAllVoters,
+			Query: []string{"confirm"}, Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/voters/:id", Summary: "Get a voter", Handler: api.GetVoter,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterJson)}},
+		{Method: http.MethodPost, Path: "/voters/:id", Summary: "Add a voter with the given id", Handler: api.AddVoter,
+			Body: voter.Voter{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterJson)}},
+		{Method: http.MethodPut, Path: "/voters/:id", Summary: "Update a voter", Handler: api.UpdateVoter,
+			Body: voter.Voter{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterJson)}},
+		{Method: http.MethodPut, Path: "/voters/", Summary: "Update the voter named in the body", Handler: api.UpdateVoter,
+			Body: voter.Voter{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterJson)}},
+		{Method: http.MethodDelete, Path: "/voters/:id", Summary: "Move a voter to the trash", Handler: api.DeleteVoter,
+			Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/voters/:id/polls", Summary: "List the urls of the voting history of a voter", Handler: api.GetVoterHistory,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []string{})}},
+		{Method: http.MethodDelete, Path: "/voters/:id/polls", Summary: "Delete the voting history of a voter", Handler: api.DeleteVoterHistory,
+			Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/voters/:id/polls/:pollid", Summary: "Get a voting history record", Handler: api.GetVoterPoll,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterPollJson)}},
+		{Method: http.MethodPost, Path: "/voters/:id/polls/:pollid", Summary: "Add a voting history record", Handler: api.AddVoterPoll,
+			Body: voter.HistoryRequest{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterPollJson)}},
+		{Method: http.MethodPost, Path: "/voters/:id/polls/", Summary: "Add a voting history record", Handler: api.AddVoterPoll,
+			Body: voter.HistoryRequest{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterPollJson)}},
+		{Method: http.MethodPut, Path: "/voters/:id/polls/:pollid", Summary: "Update a voting history record", Handler: api.UpdateVoterPoll,
+			Body: voter.HistoryRequest{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterPollJson)}},
+		{Method: http.MethodPut, Path: "/voters/:id/polls/", Summary: "Update a voting history record", Handler: api.UpdateVoterPoll,
+			Body: voter.HistoryRequest{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterPollJson)}},
+		{Method: http.MethodDelete, Path: "/voters/:id/polls/:pollid", Summary: "Delete a voting history record", Handler: api.DeleteVoterPoll,
+			Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/voters/trash", Summary: "List the deleted voters that can still be restored", Handler: api.ListTrash,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []db.Tombstone[voter.VoterJson]{})}},
+		{Method: http.MethodPost, Path: "/voters/trash/:id/restore", Summary: "Restore a deleted voter", Handler: api.RestoreVoter,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, voterJson)}},
+
+		{Method: http.MethodGet, Path: "/voters/health", Summary: "Report the health of the api", Handler: api.HealthCheck,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, health)}},
+	}
+}
+
+// RegisterRoutes adds the routes to r, serves the OpenAPI document at
+// /openapi.json and returns the document
+func (api *VoterAPI) RegisterRoutes(r gin.IRouter) *openapi.Document {
+	routes := api.Routes()
+	doc := openapi.New(openapi.Info{Title: "voter-api", Version: "1.0.0"}, routes, problem.Problem{}, problem.ContentType)
+	openapi.Register(r, doc, routes)
+	return doc
+}
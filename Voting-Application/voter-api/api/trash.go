@@ -0,0 +1,55 @@
+package api
+
+import (
+	"db"
+	"log"
+	"net/http"
+	"problem"
+	"strconv"
+	"time"
+	"voter-api/voter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash lists the deleted voters that can still be restored
+func (api *VoterAPI) ListTrash(c *gin.Context) {
+	trash, err := api.voters.Trash()
+	if err != nil {
+		log.Println("Error Getting the Trash: ", err)
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, db.TrashOf(trash, voter.Voter.ToJson))
+	api.successes++
+}
+
+func (api *VoterAPI) RestoreVoter(c *gin.Context) {
+	idS := c.Param("id")
+	id64, err := strconv.ParseUint(idS, 10, 32)
+	if err != nil {
+		log.Println("Error converting id to int64: ", err)
+		api.badRequests++
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	vr, err := api.voters.Restore(uint(id64))
+	if err != nil {
+		log.Println("Error restoring voter: ", err)
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, vr.ToJson())
+	api.successes++
+}
+
+// StartPurger drops the voters whose retention period is over every
+// interval, until the returned function is called
+func (api *VoterAPI) StartPurger(every time.Duration) (stop func()) {
+	return db.StartPurger(every, api.voters)
+}
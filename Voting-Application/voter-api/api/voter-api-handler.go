@@ -2,8 +2,10 @@ package api
 
 import (
 	"db"
+	"fmt"
 	"log"
 	"net/http"
+	"problem"
 	"strconv"
 	"time"
 	"voter-api/voter"
@@ -12,19 +14,27 @@ import (
 )
 
 type VoterAPI struct {
-	voters      *db.Handler[voter.Voter]
-	bootTime    time.Time
-	successes   int
-	badRequests int
+	voters        db.Store[voter.Voter]
+	confirmations *db.Confirmations
+	bootTime      time.Time
+	successes     int
+	badRequests   int
 }
 
-func NewVoterAPI() (*VoterAPI, error) {
-	dbHandler, err := db.NewHandler[voter.Voter]("voter")
+// NewVoterAPI builds the api on the redis store that opts describe
+func NewVoterAPI(opts db.Options) (*VoterAPI, error) {
+	dbHandler, err := db.NewHandler[voter.Voter]("voter", opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &VoterAPI{voters: dbHandler, bootTime: time.Now(), successes: 0, badRequests: 0}, nil
+	return NewVoterAPIWithStore(dbHandler), nil
+}
+
+// NewVoterAPIWithStore builds the api on top of any store, eg a
+// db.MemoryStore when there is no redis server to talk to
+func NewVoterAPIWithStore(store db.Store[voter.Voter]) *VoterAPI {
+	return &VoterAPI{voters: store, confirmations: db.NewConfirmations(), bootTime: time.Now(), successes: 0, badRequests: 0}
 }
 
 func (api *VoterAPI) ListAllVoters(c *gin.Context) {
@@ -32,7 +42,7 @@ func (api *VoterAPI) ListAllVoters(c *gin.Context) {
 	if err != nil {
 		log.Println("Error Getting All Voters: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -49,12 +59,20 @@ func (api *VoterAPI) ListAllVoters(c *gin.Context) {
 	api.successes++
 }
 
+// DeleteAllVoters moves every voter to the trash.  It has to be confirmed,
+// the first call answers 428 with a token to repeat the call with.
 func (api *VoterAPI) DeleteAllVoters(c *gin.Context) {
+	if err := api.confirmations.Confirm("delete all voters", c.Query("confirm")); err != nil {
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
 	err := api.voters.Clear()
 	if err != nil {
 		log.Println("Error deleting All Voters: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 	c.Status(http.StatusOK)
@@ -67,14 +85,14 @@ func (api *VoterAPI) GetVoter(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	vr, err := api.voters.Get(uint(id64))
 	if err != nil {
 		log.Println("voter not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -88,7 +106,7 @@ func (api *VoterAPI) AddVoter(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -96,14 +114,14 @@ func (api *VoterAPI) AddVoter(c *gin.Context) {
 	if err := c.ShouldBindJSON(&vr); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	if uint(id64) != vr.VoterID {
 		log.Println("URL parameter (id) does not match Request Body (voter.VoterID)")
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, fmt.Errorf("%w: URL parameter (id) does not match Request Body (voter.VoterID)", db.ErrValidation))
 		return
 	}
 
@@ -111,7 +129,7 @@ func (api *VoterAPI) AddVoter(c *gin.Context) {
 	if err != nil {
 		log.Println("Error adding voter: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -119,20 +137,59 @@ func (api *VoterAPI) AddVoter(c *gin.Context) {
 	api.successes++
 }
 
+func (api *VoterAPI) CreateVoter(c *gin.Context) {
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey != "" {
+		vr, ok, err := api.voters.Recall(idempotencyKey)
+		if err != nil {
+			log.Println("Error recalling idempotency key: ", err)
+			api.badRequests++
+			problem.Abort(c, err)
+			return
+		}
+		if ok {
+			c.Header("Location", vr.ToJson().Voter)
+			c.JSON(http.StatusCreated, vr.ToJson())
+			api.successes++
+			return
+		}
+	}
+
+	vr := voter.NewVoter()
+	if err := c.ShouldBindJSON(&vr); err != nil {
+		log.Println("Error binding JSON: ", err)
+		api.badRequests++
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	vr, _, err := api.voters.CreateOnce(idempotencyKey, vr.DeleteHistory().WithID)
+	if err != nil {
+		log.Println("Error creating voter: ", err)
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	c.Header("Location", vr.ToJson().Voter)
+	c.JSON(http.StatusCreated, vr.ToJson())
+	api.successes++
+}
+
 func (api *VoterAPI) DeleteVoter(c *gin.Context) {
 	voterIdS := c.Param("id")
 	voterId64, err := strconv.ParseUint(voterIdS, 10, 32)
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	if err := api.voters.Delete(uint(voterId64)); err != nil {
 		log.Println("Error deleting voter: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -145,14 +202,14 @@ func (api *VoterAPI) UpdateVoter(c *gin.Context) {
 	if err := c.ShouldBindJSON(&vr); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	vr, err := api.voters.Update(vr, voter.Voter.UpdateVoterInfo)
 	if err != nil {
 		log.Println("Error updating VoterInfo: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -166,14 +223,14 @@ func (api *VoterAPI) GetVoterHistory(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	vr, err := api.voters.Get(uint(id64))
 	if err != nil {
 		log.Println("voter not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -187,14 +244,14 @@ func (api *VoterAPI) DeleteVoterHistory(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	vr, err := api.voters.Get(uint(id64))
 	if err != nil {
 		log.Println("voter not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -202,7 +259,7 @@ func (api *VoterAPI) DeleteVoterHistory(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating voteHistory: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -216,7 +273,7 @@ func (api *VoterAPI) GetVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	voterPollIdS := c.Param("pollid")
@@ -224,7 +281,7 @@ func (api *VoterAPI) GetVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -232,7 +289,7 @@ func (api *VoterAPI) GetVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("voter not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -240,7 +297,7 @@ func (api *VoterAPI) GetVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("voter poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -254,29 +311,23 @@ func (api *VoterAPI) AddVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
-	var vr voter.Voter
-	if err := c.ShouldBindJSON(&vr); err != nil {
+	var req voter.HistoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
-	if len(vr.VoteHistory) != 1 {
-		log.Println("len(vr.VoteHistory) != 1")
-		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
-	vp := vr.VoteHistory[0]
+	vp := req.VoteHistory[0]
 
-	vr, err = api.voters.Get(uint(voterId64))
+	vr, err := api.voters.Get(uint(voterId64))
 	if err != nil {
 		log.Println("voter not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -284,7 +335,7 @@ func (api *VoterAPI) AddVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error adding voter poll: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -292,7 +343,7 @@ func (api *VoterAPI) AddVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating voteHistory: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -306,29 +357,23 @@ func (api *VoterAPI) UpdateVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
-	var vr voter.Voter
-	if err := c.ShouldBindJSON(&vr); err != nil {
+	var req voter.HistoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
-	if len(vr.VoteHistory) != 1 {
-		log.Println("len(vr.VoteHistory) != 1")
-		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
-	vp := vr.VoteHistory[0]
+	vp := req.VoteHistory[0]
 
-	vr, err = api.voters.Get(uint(voterId64))
+	vr, err := api.voters.Get(uint(voterId64))
 	if err != nil {
 		log.Println("voter not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -336,7 +381,7 @@ func (api *VoterAPI) UpdateVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating voter poll: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -344,7 +389,7 @@ func (api *VoterAPI) UpdateVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating voteHistory: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -358,7 +403,7 @@ func (api *VoterAPI) DeleteVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	voterPollIdS := c.Param("pollid")
@@ -366,7 +411,7 @@ func (api *VoterAPI) DeleteVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -374,7 +419,7 @@ func (api *VoterAPI) DeleteVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("voter not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -382,7 +427,7 @@ func (api *VoterAPI) DeleteVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("voter poll not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -390,7 +435,7 @@ func (api *VoterAPI) DeleteVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating voteHistory: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -1,59 +1,64 @@
 package main
 
 import (
-	"flag"
+	"config"
+	"db"
+	"db/dbproblem"
 	"fmt"
 	"os"
+	"time"
 	"voter-api/api"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	hostFlag string
-	portFlag uint
-)
-
-func processCmdLineFlags() {
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 1080, "Default Port")
+// Config is the configuration of the voter-api, from the flags, the
+// environment and the -config file, see the config package
+type Config struct {
+	config.Server
+	Redis          config.Redis  `config:"redis"`
+	Purge          time.Duration `config:"purge" validate:"min=0s" usage:"How often to purge the expired items from the trash, 0 to never"`
+	TrashRetention time.Duration `config:"trash-retention" validate:"min=1s" usage:"How long the deleted items stay in the trash"`
+}
 
-	flag.Parse()
+func loadConfig() Config {
+	cfg := Config{
+		Server:         config.Server{Host: "0.0.0.0", Port: 1080},
+		Redis:          config.Redis{URL: db.RedisDefaultLocation},
+		Purge:          db.DefaultPurgeEvery,
+		TrashRetention: db.DefaultRetention,
+	}
+	config.MustLoad("", &cfg)
+	return cfg
 }
 
 func main() {
-	processCmdLineFlags()
+	cfg := loadConfig()
 	r := gin.Default()
 	r.Use(cors.Default())
+	r.Use(dbproblem.Middleware())
+	if err := dbproblem.RegisterValidators(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	apiHandler, err := api.NewVoterAPI()
+	redisClient, err := cfg.Redis.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apiHandler, err := api.NewVoterAPI(db.Options{Client: redisClient, Retention: cfg.TrashRetention})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	r.GET("/voters", apiHandler.ListAllVoters)
-	r.DELETE("/voters", apiHandler.DeleteAllVoters)
-
-	r.GET("/voters/:id", apiHandler.GetVoter)
-	r.POST("/voters/:id", apiHandler.AddVoter)
-	r.PUT("/voters/:id", apiHandler.UpdateVoter)
-	r.PUT("/voters/", apiHandler.UpdateVoter)
-	r.DELETE("/voters/:id", apiHandler.DeleteVoter)
-
-	r.GET("/voters/:id/polls", apiHandler.GetVoterHistory)
-	r.DELETE("/voters/:id/polls", apiHandler.DeleteVoterHistory)
-
-	r.GET("/voters/:id/polls/:pollid", apiHandler.GetVoterPoll)
-	r.POST("/voters/:id/polls/:pollid", apiHandler.AddVoterPoll)
-	r.POST("/voters/:id/polls/", apiHandler.AddVoterPoll)
-	r.PUT("/voters/:id/polls/:pollid", apiHandler.UpdateVoterPoll)
-	r.PUT("/voters/:id/polls/", apiHandler.UpdateVoterPoll)
-	r.DELETE("/voters/:id/polls/:pollid", apiHandler.DeleteVoterPoll)
-
-	r.GET("/voters/health", apiHandler.HealthCheck)
+	apiHandler.RegisterRoutes(r)
+	if cfg.Purge > 0 {
+		stop := apiHandler.StartPurger(cfg.Purge)
+		defer stop()
+	}
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	r.Run(cfg.Addr())
 }
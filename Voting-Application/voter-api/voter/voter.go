@@ -1,26 +1,33 @@
 package voter
 
 import (
-	"errors"
+	"db"
+	"fmt"
 	"strconv"
 	"time"
 )
 
 type voterPoll struct {
-	PollID   uint      `json:"id"`
-	VoteDate time.Time `json:"date"`
+	PollID   uint      `json:"id" binding:"required"`
+	VoteDate time.Time `json:"date" binding:"required"`
 }
 
-type voterPollJson struct {
+type VoterPollJson struct {
 	VoterPoll string `json:"voterPoll"`
 	Date      string `json:"date"`
 }
 
 type Voter struct {
 	VoterID     uint        `json:"id"`
-	FirstName   string      `json:"firstName"`
-	LastName    string      `json:"lastName"`
-	VoteHistory []voterPoll `json:"history,omitempty"`
+	FirstName   string      `json:"firstName" binding:"notblank"`
+	LastName    string      `json:"lastName" binding:"notblank"`
+	VoteHistory []voterPoll `json:"history,omitempty" binding:"unique=PollID,dive"`
+}
+
+// HistoryRequest is the body of the voter poll endpoints, it carries
+// exactly one history entry
+type HistoryRequest struct {
+	VoteHistory []voterPoll `json:"history" binding:"len=1,dive"`
 }
 
 type VoterJson struct {
@@ -34,6 +41,11 @@ func (vr Voter) GetID() uint {
 	return vr.VoterID
 }
 
+func (vr Voter) WithID(id uint) Voter {
+	vr.VoterID = id
+	return vr
+}
+
 func NewVoter() Voter {
 	vr := Voter{}
 	vr.VoteHistory = make([]voterPoll, 0)
@@ -63,7 +75,7 @@ func (vr Voter) DeleteHistory() Voter {
 func (vr Voter) AddPoll(newvp voterPoll) (Voter, error) {
 	for _, vp := range vr.VoteHistory {
 		if newvp.PollID == vp.PollID {
-			return Voter{}, errors.New("vr poll already exists")
+			return Voter{}, fmt.Errorf("%w: voter poll %d already exists", db.ErrConflict, newvp.PollID)
 		}
 	}
 	vr.VoteHistory = append(vr.VoteHistory, newvp)
@@ -76,7 +88,7 @@ func (vr Voter) GetPoll(pollid uint) (voterPoll, error) {
 			return vp, nil
 		}
 	}
-	return voterPoll{}, errors.New("vr poll does not exist")
+	return voterPoll{}, fmt.Errorf("%w: voter poll %d does not exist", db.ErrNotFound, pollid)
 }
 
 func (vr Voter) UpdatePoll(newvp voterPoll) (Voter, error) {
@@ -87,7 +99,7 @@ func (vr Voter) UpdatePoll(newvp voterPoll) (Voter, error) {
 			return vr, nil
 		}
 	}
-	return Voter{}, errors.New("vr poll does not exist")
+	return Voter{}, fmt.Errorf("%w: voter poll %d does not exist", db.ErrNotFound, pollid)
 }
 
 func (vr Voter) DeletePoll(pollid uint) (Voter, error) {
@@ -97,7 +109,7 @@ func (vr Voter) DeletePoll(pollid uint) (Voter, error) {
 			return vr, nil
 		}
 	}
-	return Voter{}, errors.New("vr poll does not exist")
+	return Voter{}, fmt.Errorf("%w: voter poll %d does not exist", db.ErrNotFound, pollid)
 }
 
 func (vr Voter) ToJson() VoterJson {
@@ -114,8 +126,8 @@ func (vr Voter) ToJson() VoterJson {
 	return vj
 }
 
-func (vr Voter) GetPollJson(vp voterPoll) voterPollJson {
-	return voterPollJson{
+func (vr Voter) GetPollJson(vp voterPoll) VoterPollJson {
+	return VoterPollJson{
 		VoterPoll: vr.ToJson().Voter + "/polls/" + strconv.FormatUint(uint64(vp.PollID), 10),
 		Date:      vp.VoteDate.String(),
 	}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"votes-api/vote"
+
+	"db"
+	"openapi"
+	"problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Routes is the route table of the votes-api, RegisterRoutes serves it
+// and the OpenAPI document generated from it
+func (api *VoteAPI) Routes() []openapi.Route {
+	var (
+		ok     = openapi.Reply(http.StatusOK, nil)
+		links  = vote.Links{}
+		health = map[string]any{}
+	)
+
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/votes", Summary: "List the voter urls of all votes", Handler: api.ListAllVotes,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []string{})}},
+		{Method: http.MethodPost, Path: "/votes", Summary: "Create a vote with a server assigned id", Handler: api.CreateVote,
+			Body: vote.Vote{}, Responses: []openapi.Response{openapi.Reply(http.StatusCreated, links)}},
+		{Method: http.MethodDelete, Path: "/votes", Summary: "Move all votes to the trash, confirmed with a token", Handler: api.DeleteAllVotes,
+			Query: []string{"confirm"}, Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/votes/:id", Summary: "Get the links of a vote", Handler: api.GetVote,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, links)}},
+		{Method: http.MethodPost, Path: "/votes/:id", Summary: "Add a vote with the given id", Handler: api.AddVote,
+			Body: vote.Vote{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, links)}},
+		{Method: http.MethodPut, Path: "/votes/:id", Summary: "Change the choice of a vote", Handler: api.UpdateVote,
+			Body: vote.Vote{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, links)}},
+		{Method: http.MethodPut, Path: "/votes/", Summary: "Change the choice of the vote named in the body", Handler: api.UpdateVote,
+			Body: vote.Vote{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, links)}},
+		{Method: http.MethodDelete, Path: "/votes/:id", Summary: "Move a vote to the trash", Handler: api.DeleteVote,
+			Responses: []openapi.Response{ok}},
+
+		{Method: http.MethodGet, Path: "/votes/trash", Summary: "List the deleted votes that can still be restored", Handler: api.ListTrash,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []db.Tombstone[vote.Links]{})}},
+		{Method: http.MethodPost, Path: "/votes/trash/:id/restore", Summary: "Restore a deleted vote and its voting history record", Handler: api.RestoreVote,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, links)}},
+
+		{Method: http.MethodGet, Path: "/votes/health", Summary: "Report the health of the api", Handler: api.HealthCheck,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, health)}},
+	}
+}
+
+// RegisterRoutes adds the routes to r, serves the OpenAPI document at
+// /openapi.json and returns the document
+func (api *VoteAPI) RegisterRoutes(r gin.IRouter) *openapi.Document {
+	routes := api.Routes()
+	doc := openapi.New(openapi.Info{Title: "votes-api", Version: "1.0.0"}, routes, problem.Problem{}, problem.ContentType)
+	openapi.Register(r, doc, routes)
+	return doc
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"votes-api/vote"
+
+	"db"
+	"problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTrash lists the deleted votes that can still be restored
+func (api *VoteAPI) ListTrash(c *gin.Context) {
+	trash, err := api.votes.Trash()
+	if err != nil {
+		log.Println("Error Getting the Trash: ", err)
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, db.TrashOf(trash, func(v vote.Vote) vote.Links {
+		return v.ToLinks(api.hostName, api.voterApiExternal, api.pollApiExternal)
+	}))
+	api.successes++
+}
+
+// RestoreVote brings a deleted vote back and puts it into the voting
+// history of its voter again.  The voter, the poll and the choice have to
+// still exist, otherwise the vote goes back to the trash.
+func (api *VoteAPI) RestoreVote(c *gin.Context) {
+	idS := c.Param("id")
+	id64, err := strconv.ParseUint(idS, 10, 32)
+	if err != nil {
+		log.Println("Error converting id to int64: ", err)
+		api.badRequests++
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	v, err := api.votes.Restore(uint(id64))
+	if err != nil {
+		log.Println("Error restoring vote: ", err)
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	links := v.ToLinks(api.hostName, api.voterApiInternal, api.pollApiInternal)
+	if err := api.validateVoteLinks(links); err != nil {
+		api.votes.Delete(v.VoteID) // the vote refers to something that is gone, back to the trash
+		api.badRequests++
+		problem.Abort(c, fmt.Errorf("vote %d can not be restored: %w", v.VoteID, err))
+		return
+	}
+
+	if err := api.addToVoterHistory(v, api.votes.Delete); err != nil {
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, v.ToLinks(api.hostName, api.voterApiExternal, api.pollApiExternal))
+	api.successes++
+}
+
+// StartPurger drops the votes whose retention period is over every
+// interval, until the returned function is called
+func (api *VoteAPI) StartPurger(every time.Duration) (stop func()) {
+	return db.StartPurger(every, api.votes)
+}
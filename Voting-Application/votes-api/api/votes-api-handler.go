@@ -1,22 +1,23 @@
 package api
 
 import (
-	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
 	"time"
 	"votes-api/vote"
 
 	"db"
+	"problem"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-resty/resty/v2"
 )
 
 type VoteAPI struct {
-	votes            *db.Handler[vote.Vote]
+	votes            db.Store[vote.Vote]
+	confirmations    *db.Confirmations
 	bootTime         time.Time
 	successes        int
 	badRequests      int
@@ -28,36 +29,43 @@ type VoteAPI struct {
 	pollApiExternal  string
 }
 
-func NewVotesAPI() (*VoteAPI, error) {
-	hostName := os.Getenv("HOST_NAME")
-	voterApiInternal := os.Getenv("VOTER_API_INTERNAL")
-	pollApiInternal := os.Getenv("POLL_API_INTERNAL")
-	voterApiExternal := os.Getenv("VOTER_API_EXTERAL")
-	pollApiExternal := os.Getenv("POLL_API_EXTERAL")
-
-	log.Println("HOST_NAME: " + hostName)
-	log.Println("VOTER_API_INTERNAL: " + voterApiInternal)
-	log.Println("POLL_API_INTERNAL: " + pollApiInternal)
-	log.Println("VOTER_API_EXTERAL: " + voterApiExternal)
-	log.Println("POLL_API_EXTERAL: " + pollApiExternal)
+// Endpoints tells the votes-api where it and the apis it links to live.
+// The internal urls are used to check the voters and polls a vote refers
+// to, the external ones are handed out to clients in the vote links.
+type Endpoints struct {
+	HostName         string
+	VoterApiInternal string
+	PollApiInternal  string
+	VoterApiExternal string
+	PollApiExternal  string
+}
 
-	dbHandler, err := db.NewHandler[vote.Vote]("vote")
+// NewVotesAPI builds the api on the redis store that opts describe
+func NewVotesAPI(endpoints Endpoints, opts db.Options) (*VoteAPI, error) {
+	dbHandler, err := db.NewHandler[vote.Vote]("vote", opts)
 	if err != nil {
 		return nil, err
 	}
 
+	return NewVotesAPIWithStore(dbHandler, endpoints), nil
+}
+
+// NewVotesAPIWithStore builds the api on top of any store, eg a
+// db.MemoryStore when there is no redis server to talk to
+func NewVotesAPIWithStore(store db.Store[vote.Vote], endpoints Endpoints) *VoteAPI {
 	return &VoteAPI{
-		votes:            dbHandler,
+		votes:            store,
+		confirmations:    db.NewConfirmations(),
 		bootTime:         time.Now(),
 		successes:        0,
 		badRequests:      0,
 		apiClient:        resty.New(),
-		hostName:         hostName,
-		voterApiInternal: voterApiInternal,
-		pollApiInternal:  pollApiInternal,
-		voterApiExternal: voterApiExternal,
-		pollApiExternal:  pollApiExternal,
-	}, nil
+		hostName:         endpoints.HostName,
+		voterApiInternal: endpoints.VoterApiInternal,
+		pollApiInternal:  endpoints.PollApiInternal,
+		voterApiExternal: endpoints.VoterApiExternal,
+		pollApiExternal:  endpoints.PollApiExternal,
+	}
 }
 
 func (api *VoteAPI) ListAllVotes(c *gin.Context) {
@@ -65,7 +73,7 @@ func (api *VoteAPI) ListAllVotes(c *gin.Context) {
 	if err != nil {
 		log.Println("Error Getting All Votes: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -82,12 +90,21 @@ func (api *VoteAPI) ListAllVotes(c *gin.Context) {
 	api.successes++
 }
 
+// DeleteAllVotes moves every vote to the trash, and takes it out of the
+// voting history of its voter.  It has to be confirmed, the first call
+// answers 428 with a token to repeat the call with.
 func (api *VoteAPI) DeleteAllVotes(c *gin.Context) {
+	if err := api.confirmations.Confirm("delete all votes", c.Query("confirm")); err != nil {
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
 	voteList, err := api.votes.All()
 	if err != nil {
 		log.Println("Error Getting All Votes: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -95,7 +112,7 @@ func (api *VoteAPI) DeleteAllVotes(c *gin.Context) {
 		err = api.safeDeleteVote(v, c)
 		if err != nil {
 			api.badRequests++
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Some of the votes are not deleted success"})
+			problem.Abort(c, fmt.Errorf("some of the votes were not deleted: %w", err))
 			return
 		}
 	}
@@ -110,14 +127,14 @@ func (api *VoteAPI) GetVote(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	v, err := api.votes.Get(uint(id64))
 	if err != nil {
 		log.Println("vote not found: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -127,31 +144,47 @@ func (api *VoteAPI) GetVote(c *gin.Context) {
 
 func (api *VoteAPI) validateVoteLinks(links vote.Links) error {
 	// exam whether all the associate entities existed
-	checkVoterExist, err := api.apiClient.R().Get(links.Voter)
-	if err != nil || checkVoterExist.StatusCode() != 200 {
-		return errors.New("associated Voter doesn't existed")
+	if err := api.checkReference(links.Voter, "Voter"); err != nil {
+		return err
 	}
 
-	checkPollExist, err := api.apiClient.R().Get(links.Poll)
-	if err != nil || checkPollExist.StatusCode() != 200 {
-		return errors.New("associated Poll doesn't existed")
+	if err := api.checkReference(links.Poll, "Poll"); err != nil {
+		return err
 	}
 
-	checkOptionExist, err := api.apiClient.R().Get(links.Choice)
-	if err != nil || checkOptionExist.StatusCode() != 200 {
-		return errors.New("associated vote option doesn't existed")
+	if err := api.checkReference(links.Choice, "vote option"); err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// checkReference asks the owning api whether a referenced entity exists.
+// A 404 means the vote points at something that does not exist, which is
+// the client's fault, any other failure is ours.
+func (api *VoteAPI) checkReference(url string, name string) error {
+	resp, err := api.apiClient.R().Get(url)
+	if err != nil {
+		return fmt.Errorf("could not check associated %s: %w", name, err)
+	}
+
+	switch resp.StatusCode() {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: associated %s doesn't exist", db.ErrValidation, name)
+	default:
+		return fmt.Errorf("could not check associated %s: %s returned %d", name, url, resp.StatusCode())
+	}
+}
+
 func (api *VoteAPI) AddVote(c *gin.Context) {
 	idS := c.Param("id")
 	id64, err := strconv.ParseUint(idS, 10, 32)
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -159,14 +192,14 @@ func (api *VoteAPI) AddVote(c *gin.Context) {
 	if err := c.ShouldBindJSON(&v); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	if uint(id64) != v.VoteID {
 		log.Println("URL parameter (id) does not match Request Body (v.VoteID)")
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, fmt.Errorf("%w: URL parameter (id) does not match Request Body (v.VoteID)", db.ErrValidation))
 		return
 	}
 
@@ -174,7 +207,7 @@ func (api *VoteAPI) AddVote(c *gin.Context) {
 	err = api.validateVoteLinks(links)
 	if err != nil {
 		api.badRequests++
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Abort(c, err)
 		return
 	}
 
@@ -182,21 +215,14 @@ func (api *VoteAPI) AddVote(c *gin.Context) {
 	if err != nil {
 		log.Println("Error adding vote: ", err)
 		api.badRequests++
-		emsg := "Error adding vote: " + err.Error()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": emsg})
+		problem.Abort(c, fmt.Errorf("error adding vote: %w", err))
 		return
 	}
 
-	// call voter-api to add voterPoll to associate voter's voting history
-	addVoterPoll, err := api.apiClient.R().
-		SetBody(v.ToVoteHistoryRecord()).
-		Post(links.VoterPoll)
-
-	if err != nil || addVoterPoll.StatusCode() != 200 {
-		api.votes.Delete(v.VoteID) // add VoterPoll fail, undo adding vote to redis
+	err = api.addToVoterHistory(v, api.votes.Discard)
+	if err != nil {
 		api.badRequests++
-		emsg := "Adding voterPoll to associate voter's voting history fail. One voter can only has one voting in a poll."
-		c.JSON(http.StatusInternalServerError, gin.H{"error": emsg})
+		problem.Abort(c, err)
 		return
 	}
 
@@ -204,12 +230,88 @@ func (api *VoteAPI) AddVote(c *gin.Context) {
 	api.successes++
 }
 
+func (api *VoteAPI) CreateVote(c *gin.Context) {
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey != "" {
+		v, ok, err := api.votes.Recall(idempotencyKey)
+		if err != nil {
+			log.Println("Error recalling idempotency key: ", err)
+			api.badRequests++
+			problem.Abort(c, err)
+			return
+		}
+		if ok {
+			c.Header("Location", "/votes/"+strconv.FormatUint(uint64(v.VoteID), 10))
+			c.JSON(http.StatusCreated, v.ToLinks(api.hostName, api.voterApiExternal, api.pollApiExternal))
+			api.successes++
+			return
+		}
+	}
+
+	v := vote.NewVote()
+	if err := c.ShouldBindJSON(&v); err != nil {
+		log.Println("Error binding JSON: ", err)
+		api.badRequests++
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	links := v.ToLinks(api.hostName, api.voterApiInternal, api.pollApiInternal)
+	err := api.validateVoteLinks(links)
+	if err != nil {
+		api.badRequests++
+		problem.Abort(c, err)
+		return
+	}
+
+	v, replayed, err := api.votes.CreateOnce(idempotencyKey, v.WithID)
+	if err != nil {
+		log.Println("Error creating vote: ", err)
+		api.badRequests++
+		problem.Abort(c, fmt.Errorf("error creating vote: %w", err))
+		return
+	}
+
+	//a replayed vote is in the voter's history already
+	if !replayed {
+		err = api.addToVoterHistory(v, api.votes.Discard)
+		if err != nil {
+			api.badRequests++
+			problem.Abort(c, err)
+			return
+		}
+	}
+
+	c.Header("Location", "/votes/"+strconv.FormatUint(uint64(v.VoteID), 10))
+	c.JSON(http.StatusCreated, v.ToLinks(api.hostName, api.voterApiExternal, api.pollApiExternal))
+	api.successes++
+}
+
+// addToVoterHistory calls voter-api to add the voterPoll to the associated
+// voter's voting history.  If that fails the vote is removed again with
+// undo, so a vote is never stored without its history record.
+func (api *VoteAPI) addToVoterHistory(v vote.Vote, undo func(id uint) error) error {
+	links := v.ToLinks(api.hostName, api.voterApiInternal, api.pollApiInternal)
+	addVoterPoll, err := api.apiClient.R().
+		SetBody(v.ToVoteHistoryRecord()).
+		Post(links.VoterPoll)
+
+	if err != nil || addVoterPoll.StatusCode() != 200 {
+		undo(v.VoteID) // add VoterPoll fail, undo adding vote to redis
+		if err == nil && addVoterPoll.StatusCode() == http.StatusConflict {
+			return fmt.Errorf("%w: one voter can only have one vote in a poll", db.ErrConflict)
+		}
+		return fmt.Errorf("adding voterPoll to associate voter's voting history failed: %v", voterApiFailure(addVoterPoll, err))
+	}
+	return nil
+}
+
 func (api *VoteAPI) UpdateVote(c *gin.Context) {
 	var v vote.Vote
 	if err := c.ShouldBindJSON(&v); err != nil {
 		log.Println("Error binding JSON: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -217,7 +319,7 @@ func (api *VoteAPI) UpdateVote(c *gin.Context) {
 	err := api.validateVoteLinks(links)
 	if err != nil {
 		api.badRequests++
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Abort(c, err)
 		return
 	}
 
@@ -225,7 +327,7 @@ func (api *VoteAPI) UpdateVote(c *gin.Context) {
 	if err != nil {
 		log.Println("Vote not exist: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -233,7 +335,7 @@ func (api *VoteAPI) UpdateVote(c *gin.Context) {
 	if err != nil {
 		log.Println("Error updating vote: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -244,8 +346,7 @@ func (api *VoteAPI) UpdateVote(c *gin.Context) {
 	if err != nil || updateVoterPoll.StatusCode() != 200 {
 		api.votes.Update(prev, vote.Vote.Update) // updating by voter-api fail, undo updating redis
 		api.badRequests++
-		emsg := "Updating voterPoll to associate voter's voting history fail"
-		c.JSON(http.StatusInternalServerError, gin.H{"error": emsg})
+		problem.Abort(c, fmt.Errorf("updating voterPoll to associate voter's voting history failed: %v", voterApiFailure(updateVoterPoll, err)))
 		return
 	}
 
@@ -259,7 +360,7 @@ func (api *VoteAPI) DeleteVote(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	voteId := uint(voteId64)
@@ -268,14 +369,14 @@ func (api *VoteAPI) DeleteVote(c *gin.Context) {
 	if err != nil {
 		log.Println("Vote not exist: ", err)
 		api.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
 	err = api.safeDeleteVote(v, c)
 	if err != nil {
 		api.badRequests++
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		problem.Abort(c, err)
 		return
 	}
 
@@ -285,8 +386,7 @@ func (api *VoteAPI) DeleteVote(c *gin.Context) {
 
 func (api *VoteAPI) safeDeleteVote(v vote.Vote, c *gin.Context) error {
 	if err := api.votes.Delete(v.VoteID); err != nil {
-		return errors.New("Error deleting vote: " + err.Error())
-
+		return fmt.Errorf("error deleting vote: %w", err)
 	}
 
 	// try call voter-api to delete voterPoll from associate voter's voting history
@@ -295,12 +395,21 @@ func (api *VoteAPI) safeDeleteVote(v vote.Vote, c *gin.Context) error {
 		SetBody(v.ToVoteHistoryRecord()).
 		Delete(links.VoterPoll)
 	if err != nil || deleteVoterPoll.StatusCode() != 200 {
-		api.votes.Add(v) // delete by voter-api fail, undo deleting through redis
-		return errors.New("deleting voterPoll from associate voter's voting history fail")
+		api.votes.Restore(v.VoteID) // delete by voter-api fail, undo deleting through redis
+		return fmt.Errorf("deleting voterPoll from associate voter's voting history failed: %v", voterApiFailure(deleteVoterPoll, err))
 	}
 	return nil
 }
 
+// voterApiFailure describes why a call to voter-api did not succeed, either
+// the transport error or the unexpected status code
+func voterApiFailure(resp *resty.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "voter-api returned " + resp.Status()
+}
+
 func (api *VoteAPI) HealthCheck(c *gin.Context) {
 	c.JSON(http.StatusOK,
 		gin.H{
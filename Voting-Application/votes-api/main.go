@@ -1,49 +1,78 @@
 package main
 
 import (
-	"flag"
+	"config"
+	"db"
+	"db/dbproblem"
 	"fmt"
 	"os"
+	"time"
 	"votes-api/api"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	hostFlag string
-	portFlag uint
-)
+// Config is the configuration of the votes-api, from the flags, the
+// environment and the -config file, see the config package
+type Config struct {
+	config.Server
+	Redis          config.Redis  `config:"redis"`
+	Purge          time.Duration `config:"purge" validate:"min=0s" usage:"How often to purge the expired items from the trash, 0 to never"`
+	TrashRetention time.Duration `config:"trash-retention" validate:"min=1s" usage:"How long the deleted items stay in the trash"`
 
-func processCmdLineFlags() {
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 80, "Default Port")
+	//where this api and the apis it links to live, see api.Endpoints
+	HostName         string `config:"host-name" usage:"The host name the clients reach the apis at"`
+	VoterAPIInternal string `config:"voter-api-internal" usage:"host:port of the voter-api, for the votes-api"`
+	PollAPIInternal  string `config:"poll-api-internal" usage:"host:port of the poll-api, for the votes-api"`
+	VoterAPIExternal string `config:"voter-api-external" env:"VOTER_API_EXTERAL" usage:"host:port of the voter-api, for the clients"`
+	PollAPIExternal  string `config:"poll-api-external" env:"POLL_API_EXTERAL" usage:"host:port of the poll-api, for the clients"`
+}
 
-	flag.Parse()
+func loadConfig() Config {
+	cfg := Config{
+		Server:         config.Server{Host: "0.0.0.0", Port: 80},
+		Redis:          config.Redis{URL: db.RedisDefaultLocation},
+		Purge:          db.DefaultPurgeEvery,
+		TrashRetention: db.DefaultRetention,
+	}
+	config.MustLoad("", &cfg)
+	return cfg
 }
 
 func main() {
-	processCmdLineFlags()
+	cfg := loadConfig()
 	r := gin.Default()
 	r.Use(cors.Default())
+	r.Use(dbproblem.Middleware())
+	if err := dbproblem.RegisterValidators(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	apiHandler, err := api.NewVotesAPI()
+	redisClient, err := cfg.Redis.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	endpoints := api.Endpoints{
+		HostName:         cfg.HostName,
+		VoterApiInternal: cfg.VoterAPIInternal,
+		PollApiInternal:  cfg.PollAPIInternal,
+		VoterApiExternal: cfg.VoterAPIExternal,
+		PollApiExternal:  cfg.PollAPIExternal,
+	}
+	apiHandler, err := api.NewVotesAPI(endpoints, db.Options{Client: redisClient, Retention: cfg.TrashRetention})
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	r.GET("/votes", apiHandler.ListAllVotes)
-	r.DELETE("/votes", apiHandler.DeleteAllVotes)
-
-	r.GET("/votes/:id", apiHandler.GetVote)
-	r.POST("/votes/:id", apiHandler.AddVote)
-	r.PUT("/votes/:id", apiHandler.UpdateVote)
-	r.PUT("/votes/", apiHandler.UpdateVote)
-	r.DELETE("/votes/:id", apiHandler.DeleteVote)
-
-	r.GET("/votes/health", apiHandler.HealthCheck)
+	apiHandler.RegisterRoutes(r)
+	if cfg.Purge > 0 {
+		stop := apiHandler.StartPurger(cfg.Purge)
+		defer stop()
+	}
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	r.Run(cfg.Addr())
 }
@@ -7,9 +7,9 @@ import (
 
 type Vote struct {
 	VoteID    uint `json:"id"`
-	VoterID   uint `json:"voterId"`
-	PollID    uint `json:"pollId"`
-	VoteValue uint `json:"choiceId"`
+	VoterID   uint `json:"voterId" binding:"required"`
+	PollID    uint `json:"pollId" binding:"required"`
+	VoteValue uint `json:"choiceId" binding:"required"`
 }
 
 type Links struct {
@@ -24,6 +24,11 @@ func (v Vote) GetID() uint {
 	return v.VoteID
 }
 
+func (v Vote) WithID(id uint) Vote {
+	v.VoteID = id
+	return v
+}
+
 func NewVote() Vote {
 	v := Vote{}
 	return v
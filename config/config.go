@@ -0,0 +1,418 @@
+// Package config loads the configuration of the services in this repo.  A
+// service describes its configuration with a struct that holds the
+// defaults, and Load fills it from, the first one that has a value wins:
+//
+//  1. the command line flags, eg -port 8080
+//  2. the environment variables, eg PUBAPI_PORT=8080
+//  3. a YAML or TOML file named by -config or <PREFIX>CONFIG
+//  4. the defaults
+//
+// Every field is a key, named by its config tag, eg port, or redis.url in a
+// nested struct.  The flag of a key is the key with dashes, -redis-url, its
+// environment variable the key in upper case with underscores after the
+// prefix of the service, PUBAPI_REDIS_URL, and in the file it is nested:
+//
+//	redis:
+//	  url: cache:6379
+//
+// The other tags of a field are
+//
+//	flag:"p,port"          more flags for the key, eg the short ones
+//	env:"CACHE_URL"        more environment variables, after the prefix
+//	usage:"..."            the help of the flag
+//	validate:"min=1,max=9" the rules of the value, see checkRules
+//	secret:"true"          the value is not printed
+//
+// An embedded struct without a config tag adds its keys as they are, like
+// Server does.  The fields are strings, bools, numbers, time.Durations and
+// []strings, which are separated by commas in flags and environment
+// variables.  A struct with a Validate() error method is checked after it is
+// loaded.
+//
+// -print-config prints the configuration that was loaded, with where every
+// value came from, and MustLoad exits after that.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The sources of a value, see Print
+const (
+	sourceDefault = "default"
+	sourceFile    = "file"
+	sourceEnv     = "env"
+	sourceFlag    = "flag"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Validator is a configuration struct that checks itself once it is loaded
+type Validator interface {
+	Validate() error
+}
+
+// field is a key of the configuration
+type field struct {
+	key    string
+	flags  []string
+	envs   []string
+	usage  string
+	rules  string
+	secret bool
+	value  reflect.Value
+	def    string //the default, as a flag would give it
+	source string
+}
+
+// givenFlag is a flag from the command line, applied after the file and
+// the environment
+type givenFlag struct {
+	f     *field
+	name  string
+	value reflect.Value
+}
+
+// Set is the configuration of a service while it is loaded
+type Set struct {
+	name       string
+	prefix     string
+	fields     []*field
+	validators []Validator
+	flags      *flag.FlagSet
+	given      []givenFlag
+	file       string
+	print      bool
+}
+
+// New describes cfg, a pointer to the configuration struct of the service
+// name, whose environment variables start with prefix, eg PUBAPI_.  It
+// fails if cfg has a field of a type it can not load.
+func New(name string, prefix string, cfg any) (*Set, error) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("the configuration of %s must be a pointer to a struct, not %T", name, cfg)
+	}
+
+	s := &Set{name: name, prefix: prefix, flags: flag.NewFlagSet(name, flag.ContinueOnError)}
+	if err := s.walk(v.Elem(), ""); err != nil {
+		return nil, err
+	}
+	if val, ok := cfg.(Validator); ok {
+		s.validators = append(s.validators, val)
+	}
+
+	seen := map[string]string{}
+	for _, f := range s.fields {
+		for _, name := range f.flags {
+			if other, ok := seen[name]; ok {
+				return nil, fmt.Errorf("the flag -%s of %s is also the flag of %s", name, f.key, other)
+			}
+			seen[name] = f.key
+		}
+		s.register(f)
+	}
+	s.flags.StringVar(&s.file, "config", "", "A YAML or TOML file to read the configuration from (env "+prefix+"CONFIG)")
+	s.flags.BoolVar(&s.print, "print-config", false, "Print the configuration and where its values came from, then exit")
+	s.flags.Usage = s.usage
+	return s, nil
+}
+
+// walk adds the fields of the struct v, whose keys start with keyPrefix
+func (s *Set) walk(v reflect.Value, keyPrefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("config")
+		if !sf.IsExported() || tag == "-" {
+			continue
+		}
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct && fv.Type() != durationType {
+			nested := keyPrefix
+			if !sf.Anonymous || tag != "" {
+				nested = keyPrefix + keyName(sf, tag) + "."
+			}
+			if err := s.walk(fv, nested); err != nil {
+				return err
+			}
+			if val, ok := fv.Addr().Interface().(Validator); ok {
+				s.validators = append(s.validators, val)
+			}
+			continue
+		}
+
+		if !supported(fv.Type()) {
+			return fmt.Errorf("%s.%s is a %s, which the configuration can not hold", t.Name(), sf.Name, fv.Type())
+		}
+		key := keyPrefix + keyName(sf, tag)
+		f := &field{
+			key:    key,
+			flags:  append([]string{strings.ReplaceAll(key, ".", "-")}, splitTag(sf.Tag.Get("flag"))...),
+			envs:   []string{s.prefix + envName(key)},
+			usage:  sf.Tag.Get("usage"),
+			rules:  sf.Tag.Get("validate"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  fv,
+			def:    defaultText(fv),
+			source: sourceDefault,
+		}
+		for _, env := range splitTag(sf.Tag.Get("env")) {
+			f.envs = append(f.envs, s.prefix+env)
+		}
+		s.fields = append(s.fields, f)
+	}
+	return nil
+}
+
+// defaultText is the default of a flag, empty for the zero values, which
+// the usage leaves out
+func defaultText(v reflect.Value) string {
+	if v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+		return ""
+	}
+	return format(v)
+}
+
+func keyName(sf reflect.StructField, tag string) string {
+	if tag != "" {
+		return tag
+	}
+	return strings.ToLower(sf.Name)
+}
+
+func envName(key string) string {
+	return strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+func splitTag(tag string) []string {
+	var names []string
+	for _, name := range strings.Split(tag, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// register adds the flags of f, the first one with the usage and the
+// others as its aliases
+func (s *Set) register(f *field) {
+	for i, name := range f.flags {
+		usage := fmt.Sprintf("%s (env %s)", f.usage, strings.Join(f.envs, " or "))
+		if i > 0 {
+			usage = "Same as -" + f.flags[0]
+		}
+		s.flags.Var(&flagValue{set: s, f: f, name: name}, name, strings.TrimSpace(usage))
+	}
+}
+
+func (s *Set) usage() {
+	out := s.flags.Output()
+	fmt.Fprintf(out, "Usage of %s:\n", s.name)
+	s.flags.PrintDefaults()
+	fmt.Fprintf(out, "\nThe flags win over the environment, which wins over the -config file.\n")
+}
+
+// flagValue is the flag.Value of a field, it keeps the value until the
+// file and the environment are loaded
+type flagValue struct {
+	set  *Set
+	f    *field
+	name string
+}
+
+func (v *flagValue) String() string {
+	if v == nil || v.f == nil {
+		return ""
+	}
+	return v.f.def
+}
+
+func (v *flagValue) Set(text string) error {
+	value, err := parse(v.f.value.Type(), text)
+	if err != nil {
+		return err
+	}
+	v.set.given = append(v.set.given, givenFlag{f: v.f, name: v.name, value: value})
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v != nil && v.f != nil && v.f.value.Kind() == reflect.Bool
+}
+
+// Load loads the configuration from args, the command line without the
+// program name, the environment and the config file, and validates it.
+// It returns flag.ErrHelp for -h or -help when the service does not use
+// -h itself.
+func (s *Set) Load(args []string) error {
+	if err := s.flags.Parse(args); err != nil {
+		return usageError{err}
+	}
+	if s.flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(s.flags.Args(), " "))
+	}
+
+	file := s.file
+	if file == "" {
+		file = os.Getenv(s.prefix + "CONFIG")
+	}
+	if file != "" {
+		if err := s.loadFile(file); err != nil {
+			return err
+		}
+	}
+
+	if err := s.loadEnv(); err != nil {
+		return err
+	}
+
+	for _, g := range s.given {
+		g.f.value.Set(g.value)
+		g.f.source = sourceFlag + " -" + g.name
+	}
+
+	return s.validate()
+}
+
+// PrintRequested tells if -print-config was given
+func (s *Set) PrintRequested() bool {
+	return s.print
+}
+
+func (s *Set) loadEnv() error {
+	var errs []error
+	for _, f := range s.fields {
+		for _, env := range f.envs {
+			//an empty variable is the same as none, like it always was
+			text := os.Getenv(env)
+			if text == "" {
+				continue
+			}
+			value, err := parse(f.value.Type(), text)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("env %s: %w", env, err))
+				break
+			}
+			f.value.Set(value)
+			f.source = sourceEnv + " " + env
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Set) loadFile(path string) error {
+	values, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	byKey := make(map[string]*field, len(s.fields))
+	for _, f := range s.fields {
+		byKey[f.key] = f
+	}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		f, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s is not a configuration key", path, key))
+			continue
+		}
+		if values[key] == nil {
+			continue //a key without a value keeps the default
+		}
+		value, err := fileValue(f.value.Type(), values[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+			continue
+		}
+		f.value.Set(value)
+		f.source = sourceFile + " " + filepath.Base(path)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Set) validate() error {
+	var errs []error
+	for _, f := range s.fields {
+		if err := checkRules(f.value, f.rules); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w (%s)", f.key, err, f.source))
+		}
+	}
+	for _, v := range s.validators {
+		if err := v.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// usageError is a wrong command line, the flag package already printed it
+// with the usage
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+func (e usageError) Unwrap() error { return e.err }
+
+// Load loads cfg like Set.Load, see New for the arguments
+func Load(name string, prefix string, cfg any, args []string) (*Set, error) {
+	s, err := New(name, prefix, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s, s.Load(args)
+}
+
+// MustLoad loads cfg from the command line of the program, see New for the
+// prefix.  It exits when the configuration is wrong, after printing why,
+// and after printing the configuration for -print-config.
+func MustLoad(prefix string, cfg any) {
+	name := filepath.Base(os.Args[0])
+	s, err := Load(name, prefix, cfg, os.Args[1:])
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.As(err, &usageError{}):
+		os.Exit(2)
+	case err != nil && s != nil && s.PrintRequested():
+		//still show what was loaded, it helps to find what is wrong
+		s.Print(os.Stdout)
+		fallthrough
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "%s: the configuration is not valid:\n%v\n", name, err)
+		os.Exit(2)
+	case s.PrintRequested():
+		if err := s.Print(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+}
+
+// Print writes the configuration as YAML, which a -config file can start
+// from, with where every value came from.  Secrets, and the passwords in
+// URLs, are masked.
+func (s *Set) Print(w io.Writer) error {
+	return printYAML(w, s.name, s.fields)
+}
@@ -0,0 +1,276 @@
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"config"
+)
+
+type testConfig struct {
+	config.Server
+	Redis struct {
+		URL string `config:"url" env:"CACHE_URL"`
+	} `config:"redis"`
+	Timeout time.Duration `config:"timeout"`
+	Tags    []string      `config:"tags"`
+	Token   string        `config:"token" secret:"true"`
+}
+
+func defaults() *testConfig {
+	cfg := &testConfig{Server: config.Server{Host: "0.0.0.0", Port: 1080}, Timeout: 5 * time.Second}
+	cfg.Redis.URL = "cache:6379"
+	return cfg
+}
+
+// writeFile writes a config file named name into a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// printed is the configuration as Print writes it
+func printed(t *testing.T, s *config.Set) string {
+	t.Helper()
+	var b strings.Builder
+	if err := s.Print(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string // the YAML file, none if empty
+		fileByEnv  bool   // name the file with TEST_CONFIG instead of -config
+		env        map[string]string
+		args       []string
+		wantPort   uint
+		wantSource string
+	}{
+		{
+			name:       "the default",
+			wantPort:   1080,
+			wantSource: "port: 1080 # default, env TEST_PORT",
+		},
+		{
+			name:       "the file wins over the default",
+			file:       "port: 2000\n",
+			wantPort:   2000,
+			wantSource: "port: 2000 # file config.yaml",
+		},
+		{
+			name:       "the file can be named by the environment",
+			file:       "port: 2000\n",
+			fileByEnv:  true,
+			wantPort:   2000,
+			wantSource: "port: 2000 # file config.yaml",
+		},
+		{
+			name:       "a key without a value keeps the default",
+			file:       "port:\n",
+			wantPort:   1080,
+			wantSource: "port: 1080 # default, env TEST_PORT",
+		},
+		{
+			name:       "the environment wins over the file",
+			file:       "port: 2000\n",
+			env:        map[string]string{"TEST_PORT": "3000"},
+			wantPort:   3000,
+			wantSource: "port: 3000 # env TEST_PORT",
+		},
+		{
+			name:       "an empty variable is the same as none",
+			file:       "port: 2000\n",
+			env:        map[string]string{"TEST_PORT": ""},
+			wantPort:   2000,
+			wantSource: "port: 2000 # file config.yaml",
+		},
+		{
+			name:       "the flag wins over the environment and the file",
+			file:       "port: 2000\n",
+			env:        map[string]string{"TEST_PORT": "3000"},
+			args:       []string{"-port", "4000"},
+			wantPort:   4000,
+			wantSource: "port: 4000 # flag -port",
+		},
+		{
+			name:       "the last of the flags wins",
+			args:       []string{"-port", "4000", "-p", "5000"},
+			wantPort:   5000,
+			wantSource: "port: 5000 # flag -p",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_PORT", "")
+			t.Setenv("TEST_CONFIG", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				path := writeFile(t, "config.yaml", tt.file)
+				if tt.fileByEnv {
+					t.Setenv("TEST_CONFIG", path)
+				} else {
+					args = append([]string{"-config", path}, args...)
+				}
+			}
+
+			cfg := defaults()
+			s, err := config.Load("test", "TEST_", cfg, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Port != tt.wantPort {
+				t.Errorf("got port %d, want %d", cfg.Port, tt.wantPort)
+			}
+			if out := printed(t, s); !strings.Contains(out, tt.wantSource) {
+				t.Errorf("got\n%s\nwant it to have %q", out, tt.wantSource)
+			}
+		})
+	}
+}
+
+// TestLoadKinds loads every kind of field from each source
+func TestLoadKinds(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+		},
+		{
+			name: "env",
+			env: map[string]string{
+				"TEST_HOST": "localhost", "TEST_CACHE_URL": "redis://r:1", "TEST_TIMEOUT": "1m30s",
+				"TEST_TAGS": "a, b", "TEST_TOKEN": "s3cret",
+			},
+		},
+		{
+			name: "flags",
+			args: []string{"-h", "localhost", "-redis-url", "redis://r:1", "-timeout", "1m30s", "-tags", "a,b", "-token", "s3cret"},
+		},
+	}
+	files := map[string]string{
+		"config.yaml": "host: localhost\nredis:\n  url: redis://r:1\ntimeout: 1m30s\ntags: [a, b]\ntoken: s3cret\n",
+		"config.toml": "host = \"localhost\"\ntimeout = \"1m30s\"\ntags = [\"a\", \"b\"]\ntoken = \"s3cret\"\n[redis]\nurl = \"redis://r:1\"\n",
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"TEST_HOST", "TEST_REDIS_URL", "TEST_CACHE_URL", "TEST_TIMEOUT", "TEST_TAGS", "TEST_TOKEN", "TEST_CONFIG"} {
+				t.Setenv(name, "")
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = []string{"-config", writeFile(t, tt.file, files[tt.file])}
+			}
+
+			cfg := defaults()
+			s, err := config.Load("test", "TEST_", cfg, args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := defaults()
+			want.Host, want.Redis.URL, want.Timeout = "localhost", "redis://r:1", 90*time.Second
+			want.Tags, want.Token = []string{"a", "b"}, "s3cret"
+			if !reflect.DeepEqual(cfg, want) {
+				t.Errorf("got %+v, want %+v", cfg, want)
+			}
+			if out := printed(t, s); strings.Contains(out, "s3cret") {
+				t.Errorf("the secret was printed:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string // the name and the content of the file, "name:content"
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{name: "an unknown flag", args: []string{"-color", "red"}, wantErr: "flag provided but not defined"},
+		{name: "an argument after the flags", args: []string{"-p", "1", "extra"}, wantErr: "unexpected arguments: extra"},
+		{name: "a flag that is not a number", args: []string{"-p", "x"}, wantErr: `"x" is not a whole number`},
+		{name: "a variable that is not a duration", env: map[string]string{"TEST_TIMEOUT": "5"}, wantErr: "env TEST_TIMEOUT:"},
+		{name: "an unknown key in the file", file: "config.yaml:colour: red\n", wantErr: "colour is not a configuration key"},
+		{name: "a file value of the wrong type", file: "config.yaml:port: [1]\n", wantErr: "config.yaml: port:"},
+		{name: "a file that is not yaml or toml", file: "config.json:{}", wantErr: "is not .yaml, .yml or .toml"},
+		{name: "a value that breaks a rule", args: []string{"-p", "0"}, wantErr: "port:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_TIMEOUT", "")
+			t.Setenv("TEST_CONFIG", "")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				name, content, _ := strings.Cut(tt.file, ":")
+				args = []string{"-config", writeFile(t, name, content)}
+			}
+
+			_, err := config.Load("test", "TEST_", defaults(), args)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got %v, want an error with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHelp(t *testing.T) {
+	if _, err := config.Load("test", "TEST_", defaults(), []string{"-help"}); !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("got %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestNewRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  any
+	}{
+		{"not a pointer", testConfig{}},
+		{"not a struct", new(int)},
+		{"a field it can not hold", &struct {
+			Ports []int `config:"ports"`
+		}{}},
+		{"a flag given twice", &struct {
+			Port uint `config:"port" flag:"v"`
+			Verb bool `config:"v"`
+		}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := config.New("test", "TEST_", tt.cfg); err == nil {
+				t.Error("got no error")
+			}
+		})
+	}
+}
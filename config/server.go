@@ -0,0 +1,14 @@
+package config
+
+import "fmt"
+
+// Server is where a service listens, every service embeds it
+type Server struct {
+	Host string `config:"host" flag:"h" usage:"The interface to listen on, 0.0.0.0 for all of them"`
+	Port uint   `config:"port" flag:"p" validate:"min=1,max=65535" usage:"The port to listen on"`
+}
+
+// Addr is the address to listen on, eg 0.0.0.0:1080
+func (s Server) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.Port)
+}
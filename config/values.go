@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// supported tells if the configuration can hold a value of type t
+func supported(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String, reflect.Bool, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String
+	}
+	return false
+}
+
+// parse reads text as a value of type t, the way flags and environment
+// variables give it
+func parse(t reflect.Type, text string) (reflect.Value, error) {
+	text = strings.TrimSpace(text)
+	if t == durationType {
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not a duration, eg 90s or 1h30m", text)
+		}
+		return reflect.ValueOf(d), nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(text).Convert(t), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not true or false", text)
+		}
+		return reflect.ValueOf(b).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not a whole number", text)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not a whole number of at least 0", text)
+		}
+		return reflect.ValueOf(n).Convert(t), nil
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%q is not a number", text)
+		}
+		return reflect.ValueOf(n), nil
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return reflect.ValueOf(items).Convert(t), nil
+	}
+	return reflect.Value{}, fmt.Errorf("can not hold a %s", t)
+}
+
+// fileValue converts what the YAML or TOML decoder made of a value to type
+// t.  The scalars are read like flags, the lists have to be lists.
+func fileValue(t reflect.Type, value any) (reflect.Value, error) {
+	switch v := value.(type) {
+	case []any:
+		if t.Kind() != reflect.Slice {
+			return reflect.Value{}, errors.New("is a list, it should be a single value")
+		}
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			if _, ok := item.(map[string]any); ok {
+				return reflect.Value{}, errors.New("is a list of tables, it should be a list of values")
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		return reflect.ValueOf(items).Convert(t), nil
+	case map[string]any:
+		return reflect.Value{}, errors.New("is a table, it should be a value")
+	}
+	return parse(t, fmt.Sprint(value))
+}
+
+// format writes v the way parse reads it
+func format(v reflect.Value) string {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).String()
+	}
+	if v.Kind() == reflect.Slice {
+		return strings.Join(v.Convert(reflect.TypeOf([]string{})).Interface().([]string), ",")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// checkRules checks v against the rules of its validate tag, separated by
+// commas:
+//
+//	required      a string or list is not empty
+//	min=1, max=9  a number or duration is within the bounds, eg min=0s
+//	oneof=a b c   the value is one of these
+func checkRules(v reflect.Value, rules string) error {
+	for _, rule := range splitTag(rules) {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			if v.IsZero() || ((v.Kind() == reflect.String || v.Kind() == reflect.Slice) && v.Len() == 0) {
+				return errors.New("is required")
+			}
+		case "min", "max":
+			bound, err := parse(v.Type(), arg)
+			if err != nil {
+				return fmt.Errorf("the rule %s: %w", rule, err)
+			}
+			if name == "min" && less(v, bound) {
+				return fmt.Errorf("%s is less than %s", format(v), arg)
+			}
+			if name == "max" && less(bound, v) {
+				return fmt.Errorf("%s is more than %s", format(v), arg)
+			}
+		case "oneof":
+			options := strings.Fields(arg)
+			found := false
+			for _, o := range options {
+				found = found || o == format(v)
+			}
+			if !found {
+				return fmt.Errorf("%q is not one of %s", format(v), strings.Join(options, ", "))
+			}
+		default:
+			return fmt.Errorf("the rule %s is unknown", rule)
+		}
+	}
+	return nil
+}
+
+// less compares two numbers or durations of the same type
+func less(a reflect.Value, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return a.Uint() < b.Uint()
+	case reflect.Float64:
+		return a.Float() < b.Float()
+	}
+	return false
+}
@@ -1,9 +1,11 @@
 #!/bin/bash
-cat pubs.json | jq -c '.[]' |\
-    while read json_object; do \
-        pubid=$(jq -r '.id' <<< $json_object); \
-        #echo $pubid  \
-        rediscmd="redis-cli JSON.set pubs:$pubid . '$json_object'"; \
-        echo $rediscmd; \
-        eval $rediscmd; \
-    done 
\ No newline at end of file
+#load pubs.json through the publication api, in one transaction, the api
+#validates every publication first.  replace=true drops the pubs that are
+#not in the file
+PUBAPI=${1:-http://localhost:2080}
+
+status=$(curl -sS --fail-with-body -o /dev/stderr -w '%{http_code}' -X POST -H "Content-Type: application/json" \
+    --data-binary @pubs.json "$PUBAPI/pubs:bulk?replace=true") || exit 1
+echo
+#207 is a partial load, the publications under "failed" were not written
+[ "$status" = 200 ]
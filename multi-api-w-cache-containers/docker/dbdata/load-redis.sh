@@ -7,15 +7,8 @@ apt-get -y install jq
 #delete the database
 redis-cli -h $1 flushdb
 
-#load pubs
-cat /data/pubs.json | jq -c '.[]' |\
-    while read json_object; do \
-        pubid=$(jq -r '.id' <<< $json_object); \
-        #echo $pubid  \
-        rediscmd="redis-cli -h $1 JSON.set pubs:$pubid . '$json_object'"; \
-        echo $rediscmd; \
-        eval $rediscmd; \
-    done 
+#the pubs are loaded through the publication api once it is up, see the
+#pubs-init service in docker-compose.yml
 
 #load reading list
 cat /data/readinglist.json | jq -c '.[]' |\
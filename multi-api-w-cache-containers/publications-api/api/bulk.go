@@ -0,0 +1,213 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"problem"
+	"strconv"
+	"strings"
+	"validate"
+
+	"architectingsoftware.com/pub-api/bib"
+	"architectingsoftware.com/pub-api/schema"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// BulkResult is what POST /pubs:bulk reports back.  Failed lists the
+// publications that redis did not write or delete, the load is partial
+// then and the status is 207.
+type BulkResult struct {
+	Loaded  int           `json:"loaded"`
+	Deleted int           `json:"deleted"`
+	Failed  []BulkFailure `json:"failed,omitempty"`
+}
+
+// BulkFailure is a publication of a bulk load that was not written, or
+// not deleted with ?replace=true.  Why only goes to the log.
+type BulkFailure struct {
+	ID int    `json:"id"`
+	Op string `json:"op"` // "write" or "delete"
+}
+
+// BulkLoadPublications loads a pubs.json file, a json array of
+// publications, or a .bib file sent as application/x-bibtex, in one redis
+// transaction.  The whole file is validated first, a publication that
+// fails fails the load before anything is written.  Publications that are
+// in the cache but not in the file are kept, unless the request has
+// ?replace=true, then they are deleted in the same transaction.  The
+// change events of every publication are published in it too.
+//
+// A redis transaction is not rolled back when one of its commands fails,
+// and a cluster runs one transaction per slot, so the load can end up
+// partial.  Then the publications that failed are listed in the result.
+func (p *PubAPI) BulkLoadPublications(c *gin.Context) {
+	//gin can not register "/pubs:bulk" as a literal path, it reads the
+	//route as /pubs followed by a parameter named bulk, so anything
+	//after /pubs that is not ":bulk" ends up here too
+	if c.Param("bulk") != ":bulk" {
+		problem.Abort(c, fmt.Errorf("%w: %s", ErrNotFound, c.Request.URL.Path))
+		return
+	}
+
+	var pubs []schema.Publication
+	if c.ContentType() == BibTeXContentType {
+		data, err := c.GetRawData()
+		if err == nil {
+			pubs, err = bib.Unmarshal(data)
+		}
+		if err != nil {
+			problem.AbortBadRequest(c, fmt.Errorf("the body must be a .bib file: %w", err))
+			return
+		}
+		if err := p.assignPubIds(pubs); err != nil {
+			problem.Abort(c, err)
+			return
+		}
+	} else if err := json.NewDecoder(c.Request.Body).Decode(&pubs); err != nil {
+		problem.AbortBadRequest(c, fmt.Errorf("the body must be a json array of publications: %w", err))
+		return
+	}
+	if err := checkPublications(pubs); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	//the keys that are there tell which publications are created and
+	//which are updated, and with ?replace=true which are deleted
+	ks, err := p.keys(p.context, pubKeyPrefix+"*")
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+	existing := make(map[string]bool, len(ks))
+	for _, key := range ks {
+		existing[key] = true
+	}
+	events := make([]ChangeEvent, 0, len(pubs))
+	for _, pub := range pubs {
+		if existing[pubKey(pub.ID)] {
+			events = append(events, pubEvent(EventUpdated, pub.ID))
+		} else {
+			events = append(events, pubEvent(EventCreated, pub.ID))
+		}
+	}
+
+	var stale []string
+	if c.Query("replace") == "true" {
+		inFile := make(map[string]bool, len(pubs))
+		for _, pub := range pubs {
+			inFile[pubKey(pub.ID)] = true
+		}
+		for _, key := range ks {
+			if inFile[key] {
+				continue
+			}
+			stale = append(stale, key)
+			if id, err := strconv.Atoi(strings.TrimPrefix(key, pubKeyPrefix)); err == nil {
+				events = append(events, pubEvent(EventDeleted, id))
+			}
+		}
+	}
+
+	docs := make([]string, len(pubs))
+	for i, pub := range pubs {
+		b, err := json.Marshal(pub)
+		if err != nil {
+			problem.Abort(c, err)
+			return
+		}
+		docs[i] = string(b)
+	}
+
+	writes := make([]*redis.Cmd, len(pubs))
+	deletes := make([]*redis.IntCmd, len(stale))
+	_, err = p.client.TxPipelined(p.context, func(pipe redis.Pipeliner) error {
+		for i, pub := range pubs {
+			writes[i] = pipe.Do(p.context, "JSON.SET", pubKey(pub.ID), ".", docs[i])
+		}
+		//one key at a time, in a cluster they can be in different slots
+		for i, key := range stale {
+			deletes[i] = pipe.Del(p.context, key)
+		}
+		pipe.Incr(p.context, pubGenerationKey)
+		for _, e := range events {
+			p.addEvent(p.context, pipe, e)
+		}
+		return nil
+	})
+	if err == nil {
+		c.JSON(http.StatusOK, BulkResult{Loaded: len(pubs), Deleted: len(stale)})
+		return
+	}
+
+	result := BulkResult{}
+	for i, cmd := range writes {
+		if cmd.Err() != nil {
+			log.Printf("Error loading publication %d: %v", pubs[i].ID, cmd.Err())
+			result.Failed = append(result.Failed, BulkFailure{ID: pubs[i].ID, Op: "write"})
+		} else {
+			result.Loaded++
+		}
+	}
+	for i, cmd := range deletes {
+		if cmd.Err() != nil {
+			log.Printf("Error deleting %s: %v", stale[i], cmd.Err())
+			id, _ := strconv.Atoi(strings.TrimPrefix(stale[i], pubKeyPrefix))
+			result.Failed = append(result.Failed, BulkFailure{ID: id, Op: "delete"})
+		} else {
+			result.Deleted++
+		}
+	}
+	if result.Loaded == 0 && result.Deleted == 0 {
+		//every command failed with the error of the transaction, which
+		//does not tell if redis ran any of them, eg when the connection
+		//broke after EXEC was sent
+		problem.Abort(c, fmt.Errorf("loading the publications failed, some of them may have been written: %w", err))
+		return
+	}
+	c.JSON(http.StatusMultiStatus, result)
+}
+
+// checkPublications checks every publication of a bulk load, that there
+// is one, and that no two of them have the same id.  The fields in the returned
+// *validate.ValidationError are prefixed with the index of the publication, eg
+// "[3].slides[0].link"
+func checkPublications(pubs []schema.Publication) error {
+	//an empty file is a mistake, with ?replace=true it would delete
+	//every publication
+	if len(pubs) == 0 {
+		return fmt.Errorf("%w: the file has no publications", ErrValidation)
+	}
+
+	ve := &validate.ValidationError{}
+	seen := make(map[int]int, len(pubs))
+	for i := range pubs {
+		prefix := fmt.Sprintf("[%d].", i)
+		pubs[i].Normalize()
+		completeCitation(&pubs[i])
+		var pve *validate.ValidationError
+		err := validate.StructAt(pubs[i], prefix)
+		if err != nil && !errors.As(err, &pve) {
+			return err
+		}
+		if pve != nil {
+			ve.Fields = append(ve.Fields, pve.Fields...)
+		}
+		if first, ok := seen[pubs[i].ID]; ok && pubs[i].ID > 0 {
+			ve.Fields = append(ve.Fields, validate.FieldError{
+				Field:   prefix + "id",
+				Message: fmt.Sprintf("duplicates the id of [%d]", first),
+			})
+			continue
+		}
+		seen[pubs[i].ID] = i
+	}
+	if len(ve.Fields) > 0 {
+		return ve
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Every change to the publications is published as an event on the
+// pub-events redis stream, the reading list api reads them to keep the
+// links of its lists to the publications in order.  An event has the
+// fields
+//
+//	type   created, updated, deleted or moved
+//	id     the id of the publication
+//	path   its path, eg /pubs/10
+//	to     for moved, the new path
+//
+// The stream keeps about the last pubEventStreamLen events.
+const (
+	pubEventStream    = "pub-events"
+	pubEventStreamLen = 10000
+)
+
+// The types of ChangeEvent
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+	EventMoved   = "moved"
+)
+
+// ChangeEvent tells that the publication at Path changed
+type ChangeEvent struct {
+	Type string
+	ID   int
+	Path string
+	To   string
+}
+
+func pubEvent(eventType string, id int) ChangeEvent {
+	return ChangeEvent{Type: eventType, ID: id, Path: pubPath(id)}
+}
+
+// addEvent queues e on pipe, to be added to the stream with the writes
+// that pipe makes
+func (p *PubAPI) addEvent(ctx context.Context, pipe redis.Pipeliner, e ChangeEvent) {
+	values := map[string]interface{}{"type": e.Type, "id": e.ID, "path": e.Path}
+	if e.To != "" {
+		values["to"] = e.To
+	}
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: pubEventStream,
+		MaxLen: pubEventStreamLen,
+		Approx: true,
+		Values: values,
+	})
+}
+
+// maxWriteTries bounds how often write starts over because a key it
+// watches changed under it
+const maxWriteTries = 10
+
+// write makes the writes of writes, bumps the generation and publishes
+// events in one transaction, so there is an event for every change and
+// none for a change that was not made.  check runs before it and can
+// refuse the write, eg when a publication to create is there already.
+// The keys are WATCHed from before check to the end of the transaction,
+// when someone else changes them in between it starts over.  In a cluster
+// the publications, the generation and the stream are in different slots,
+// which neither WATCH nor a transaction can span: there check runs
+// without a WATCH and the transaction is split by slot, like the one of
+// the bulk load.
+func (p *PubAPI) write(keys []string, check func(c redis.Cmdable) error, writes func(pipe redis.Pipeliner), events ...ChangeEvent) error {
+	commit := func(c redis.Cmdable) error {
+		if err := check(c); err != nil {
+			return err
+		}
+		_, err := c.TxPipelined(p.context, func(pipe redis.Pipeliner) error {
+			writes(pipe)
+			pipe.Incr(p.context, pubGenerationKey)
+			for _, e := range events {
+				p.addEvent(p.context, pipe, e)
+			}
+			return nil
+		})
+		return err
+	}
+	if _, ok := p.client.(*redis.ClusterClient); ok {
+		return commit(p.client)
+	}
+	for tries := 0; tries < maxWriteTries; tries++ {
+		err := p.client.Watch(p.context, func(tx *redis.Tx) error {
+			return commit(tx)
+		}, keys...)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: the publication kept changing while it was written, try again", ErrConflict)
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"problem"
+	"strconv"
+	"strings"
+
+	"architectingsoftware.com/pub-api/bib"
+	"architectingsoftware.com/pub-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// The media types GET /pubs and GET /pubs/:id answer with, picked by the
+// Accept header of the request.  text/x-bibliography is the rendered
+// citation, in the style of the style parameter, "text/x-bibliography;
+// style=ieee", or of ?style=, APA if neither is there.
+const (
+	BibTeXContentType       = "application/x-bibtex"
+	CSLContentType          = "application/vnd.citationstyles.csl+json"
+	BibliographyContentType = "text/x-bibliography"
+
+	DefaultStyle = "apa"
+)
+
+var offeredFormats = []string{gin.MIMEJSON, BibTeXContentType, CSLContentType, BibliographyContentType}
+
+// renderPublications answers with pubs in the format the client accepts.
+// one tells if the client asked for a single publication, it gets an
+// object instead of an array in the json formats.
+func renderPublications(c *gin.Context, pubs []schema.Publication, one bool) {
+	format := c.NegotiateFormat(offeredFormats...)
+	switch format {
+	case BibTeXContentType:
+		c.Data(http.StatusOK, BibTeXContentType+"; charset=utf-8", bib.Marshal(pubs))
+
+	case CSLContentType:
+		items := make([]bib.CSLItem, 0, len(pubs))
+		for _, pub := range pubs {
+			items = append(items, bib.CSL(pub))
+		}
+		c.Header("Content-Type", CSLContentType)
+		if one {
+			c.JSON(http.StatusOK, items[0])
+		} else {
+			c.JSON(http.StatusOK, items)
+		}
+
+	case BibliographyContentType:
+		style := citationStyle(c)
+		var b strings.Builder
+		for _, pub := range pubs {
+			cite, err := bib.Format(pub, style)
+			if err != nil {
+				problem.AbortBadRequest(c, err)
+				return
+			}
+			b.WriteString(cite + "\n")
+		}
+		c.Data(http.StatusOK, fmt.Sprintf("%s; charset=utf-8; style=%s", BibliographyContentType, strings.ToLower(style)), []byte(b.String()))
+
+	case gin.MIMEJSON:
+		if one {
+			c.JSON(http.StatusOK, pubs[0])
+		} else {
+			c.JSON(http.StatusOK, pubs)
+		}
+
+	default:
+		problem.Abort(c, fmt.Errorf("%w: the publications are served as %s", ErrNotAcceptable, strings.Join(offeredFormats, ", ")))
+	}
+}
+
+// citationStyle reads the style from ?style=, or from the style parameter
+// of text/x-bibliography in the Accept header
+func citationStyle(c *gin.Context) string {
+	if style := c.Query("style"); style != "" {
+		return style
+	}
+	for _, accepted := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(accepted)
+		if err == nil && mediaType == BibliographyContentType && params["style"] != "" {
+			return params["style"]
+		}
+	}
+	return DefaultStyle
+}
+
+// completeCitation renders the free text citation of a publication that
+// has its authors but no citation, in the IEEE style the older
+// publications roughly use
+func completeCitation(pub *schema.Publication) {
+	if strings.TrimSpace(pub.Cite) != "" || len(pub.Authors) == 0 {
+		return
+	}
+	pub.Cite, _ = bib.Format(*pub, "ieee")
+}
+
+// assignPubIds gives the publications of a .bib file that had no "id"
+// field the ids after the highest one in the cache and in the file
+func (p *PubAPI) assignPubIds(pubs []schema.Publication) error {
+	ks, err := p.keys(p.context, pubKeyPrefix+"*")
+	if err != nil {
+		return err
+	}
+	highest := 0
+	for _, key := range ks {
+		if id, err := strconv.Atoi(strings.TrimPrefix(key, pubKeyPrefix)); err == nil && id > highest {
+			highest = id
+		}
+	}
+	for _, pub := range pubs {
+		if pub.ID > highest {
+			highest = pub.ID
+		}
+	}
+	for i := range pubs {
+		if pubs[i].ID == 0 {
+			highest++
+			pubs[i].ID = highest
+		}
+	}
+	return nil
+}
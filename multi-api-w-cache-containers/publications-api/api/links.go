@@ -0,0 +1,274 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"problem"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"architectingsoftware.com/pub-api/links"
+	"architectingsoftware.com/pub-api/schema"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// The link checker requests the links of every publication, to the paper
+// and to the slides, every so often and keeps what it found under
+// pub-links:<id>, a json object of the results by url.  Only one replica
+// of the api checks in an interval, the one that sets pub-links-checked,
+// which expires after the interval.
+//
+// The reading list api counts the clicks on the links under
+// pub-clicks:<id>, a hash of the total, the time of the last click and the
+// clicks from every list, eg list:2.
+const (
+	linkKeyPrefix         = "pub-links:"
+	linkCheckedKey        = "pub-links-checked"
+	clickKeyPrefix        = "pub-clicks:"
+	maxConcurrentChecks   = 4
+	DefaultLinkCheckEvery = 24 * time.Hour
+)
+
+// The kinds of Link
+const (
+	LinkPaper  = "paper"
+	LinkSlides = "slides"
+)
+
+// Link is a link of a publication with what the last check found
+type Link struct {
+	Kind        string `json:"kind"`
+	Description string `json:"description,omitempty"`
+	links.Result
+}
+
+// Clicks counts the clicks on the link to the paper through the reading
+// list api, Lists by the id of the list
+type Clicks struct {
+	Total int64            `json:"total"`
+	Last  string           `json:"last,omitempty"`
+	Lists map[string]int64 `json:"lists,omitempty"`
+}
+
+// LinkReport is what GET /pubs/:id/links answers
+type LinkReport struct {
+	ID     int    `json:"id"`
+	Links  []Link `json:"links"`
+	Dead   int    `json:"dead"`
+	Clicks Clicks `json:"clicks"`
+}
+
+// SetLinkAllowlist sets the hosts whose links are checked, see
+// links.NewChecker.  Without any, no link is checked.
+func (p *PubAPI) SetLinkAllowlist(hosts []string) {
+	p.links = links.NewChecker(hosts)
+}
+
+// GetPublicationLinks reports the links of a publication, whether they
+// worked when they were last checked, and the clicks on them.  With
+// ?check=true the links are checked right away.
+func (p *PubAPI) GetPublicationLinks(c *gin.Context) {
+	id, err := pubIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+	var pub schema.Publication
+	if err := p.getItemFromRedis(pubKey(id), &pub); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	var results map[string]links.Result
+	if c.Query("check") == "true" {
+		results = p.checkLinks(c.Request.Context(), []schema.Publication{pub})[pub.ID]
+		err = p.storeLinkResults(pub.ID, results)
+	} else {
+		results, err = p.linkResults(pub.ID)
+	}
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	report := LinkReport{ID: pub.ID, Links: pubLinks(pub)}
+	for i, l := range report.Links {
+		switch res, ok := results[l.URL]; {
+		case ok:
+			report.Links[i].Result = res
+		case !p.links.Allowed(l.URL):
+			report.Links[i].Result = links.Skipped(l.URL)
+		}
+		if report.Links[i].Status == links.StatusDead {
+			report.Dead++
+		}
+	}
+
+	report.Clicks, err = p.clicks(pub.ID)
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// pubLinks lists the links of pub, the paper first, unchecked
+func pubLinks(pub schema.Publication) []Link {
+	var ls []Link
+	if pub.Link != "" {
+		ls = append(ls, Link{Kind: LinkPaper, Result: links.Result{URL: pub.Link, Status: links.StatusUnchecked}})
+	}
+	for _, s := range pub.Slides {
+		ls = append(ls, Link{Kind: LinkSlides, Description: s.Description, Result: links.Result{URL: s.Link, Status: links.StatusUnchecked}})
+	}
+	if ls == nil {
+		ls = []Link{}
+	}
+	return ls
+}
+
+// checkLinks checks the allowed links of pubs, at most
+// maxConcurrentChecks at a time, and returns the results by publication
+// id and url
+func (p *PubAPI) checkLinks(ctx context.Context, pubs []schema.Publication) map[int]map[string]links.Result {
+	results := make(map[int]map[string]links.Result, len(pubs))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentChecks)
+
+	for _, pub := range pubs {
+		results[pub.ID] = map[string]links.Result{}
+		for _, l := range pubLinks(pub) {
+			if !p.links.Allowed(l.URL) {
+				continue
+			}
+			wg.Add(1)
+			go func(id int, link string) {
+				defer wg.Done()
+				slots <- struct{}{}
+				defer func() { <-slots }()
+
+				res := p.links.Check(ctx, link)
+				mu.Lock()
+				results[id][link] = res
+				mu.Unlock()
+			}(pub.ID, l.URL)
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+func (p *PubAPI) linkResults(id int) (map[string]links.Result, error) {
+	results := map[string]links.Result{}
+	data, err := p.client.Get(p.context, linkKeyPrefix+strconv.Itoa(id)).Bytes()
+	if err == redis.Nil {
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, fmt.Errorf("cached link results of publication %d seem to be wrong: %w", id, err)
+	}
+	return results, nil
+}
+
+func (p *PubAPI) storeLinkResults(id int, results map[string]links.Result) error {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	return p.client.Set(p.context, linkKeyPrefix+strconv.Itoa(id), data, 0).Err()
+}
+
+func (p *PubAPI) clicks(id int) (Clicks, error) {
+	var clicks Clicks
+	fields, err := p.client.HGetAll(p.context, clickKeyPrefix+strconv.Itoa(id)).Result()
+	if err != nil {
+		return clicks, err
+	}
+	for name, value := range fields {
+		switch {
+		case name == "total":
+			clicks.Total, _ = strconv.ParseInt(value, 10, 64)
+		case name == "last":
+			clicks.Last = value
+		case strings.HasPrefix(name, "list:"):
+			if clicks.Lists == nil {
+				clicks.Lists = map[string]int64{}
+			}
+			clicks.Lists[strings.TrimPrefix(name, "list:")], _ = strconv.ParseInt(value, 10, 64)
+		}
+	}
+	return clicks, nil
+}
+
+// StartLinkChecker checks the links of every publication now and every
+// interval after, until the returned function is called
+func (p *PubAPI) StartLinkChecker(every time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(p.context)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			p.checkAllLinks(ctx, every)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// checkAllLinks checks the links of every publication, unless another
+// replica did within every
+func (p *PubAPI) checkAllLinks(ctx context.Context, every time.Duration) {
+	if len(p.links.Allowlist()) == 0 {
+		log.Println("Not checking the publication links, no host is on the allowlist")
+		return
+	}
+	ok, err := p.client.SetNX(ctx, linkCheckedKey, time.Now().UTC().Format(time.RFC3339), every).Result()
+	if err != nil {
+		log.Println("Error checking the publication links: ", err)
+		return
+	}
+	if !ok {
+		return //checked by another replica
+	}
+
+	pubs, err := p.loadPublications(ctx)
+	if err != nil {
+		log.Println("Error checking the publication links: ", err)
+		return
+	}
+	checked, dead := 0, 0
+	for id, results := range p.checkLinks(ctx, pubs) {
+		for _, res := range results {
+			checked++
+			if res.Status == links.StatusDead {
+				dead++
+				log.Printf("Publication %d has a dead link %s: %s", id, res.URL, res.Error)
+			}
+		}
+		if err := p.storeLinkResults(id, results); err != nil {
+			log.Println("Error storing the publication links: ", err)
+			return
+		}
+	}
+	log.Printf("Checked %d links of %d publications, %d dead", checked, len(pubs), dead)
+}
@@ -1,34 +1,56 @@
 package api
 
 import (
+	"config"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"problem"
+	"sort"
+	"strconv"
+	"validate"
 
+	"architectingsoftware.com/pub-api/links"
 	"architectingsoftware.com/pub-api/schema"
+	"architectingsoftware.com/pub-api/search"
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
 )
 
+// pubKeyPrefix is the prefix of the cache keys of the publications, a
+// publication is stored as JSON under pubs:<id>.  pubGenerationKey counts
+// the changes to the publications, pubSearchIndex is the RediSearch index
+// of them.
+const (
+	pubKeyPrefix     = "pubs:"
+	pubGenerationKey = "pubs-generation"
+	pubSearchIndex   = "pubs-idx"
+)
+
 type cache struct {
-	client  *redis.Client
+	client  redis.UniversalClient
 	helper  *rejson.Handler
 	context context.Context
 }
 
+// keys returns the keys that match pattern, see config.Keys
+func (c *cache) keys(ctx context.Context, pattern string) ([]string, error) {
+	return config.Keys(ctx, c.client, pattern)
+}
+
 type PubAPI struct {
 	cache
+	searcher search.Searcher
+	links    *links.Checker
 }
 
-func NewPubAPI(location string) (*PubAPI, error) {
-
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
+// NewPubAPI keeps the publications with client, which can be a single
+// server, a sentinel or a cluster client
+func NewPubAPI(client redis.UniversalClient) (*PubAPI, error) {
 
 	//We use this context to coordinate betwen our go code and
 	//the redis operaitons
@@ -52,58 +74,298 @@ func NewPubAPI(location string) (*PubAPI, error) {
 	jsonHelper.SetGoRedisClientWithContext(ctx, client)
 
 	//Return a pointer to a new ToDo struct
-	return &PubAPI{
+	p := &PubAPI{
 		cache: cache{
 			client:  client,
 			helper:  jsonHelper,
 			context: ctx,
 		},
-	}, nil
+		links: links.NewChecker(nil),
+	}
+
+	//Search with RediSearch if redis has it, redis/redis-stack does, and
+	//with our own index otherwise.  In a cluster every master would only
+	//index the publications of its own slots
+	if single, ok := client.(*redis.Client); ok {
+		p.searcher, err = search.NewRediSearch(ctx, single, pubSearchIndex, pubKeyPrefix)
+	} else {
+		err = errors.New("RediSearch can not index the publications of a cluster")
+	}
+	if err != nil {
+		log.Println("Searching the publications in memory: ", err)
+		p.searcher = search.NewMemory(p.generation, p.loadPublications)
+	}
+	return p, nil
 }
 
 func (p *PubAPI) GetPublication(c *gin.Context) {
 
 	pubid := c.Param("id")
 	if pubid == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication ID provided"})
-		return
-	}
-
-	cacheKey := "pubs:" + pubid
-	pubBytes, err := p.helper.JSONGet(cacheKey, ".")
-	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find publication in cache with id=" + cacheKey})
+		problem.AbortBadRequest(c, errors.New("no publication id provided"))
 		return
 	}
 
+	cacheKey := pubKeyPrefix + pubid
 	var pub schema.Publication
-	err = json.Unmarshal(pubBytes.([]byte), &pub)
+	err := p.getItemFromRedis(cacheKey, &pub)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cached data seems to be wrong type"})
+		problem.Abort(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, pub)
+	renderPublications(c, []schema.Publication{pub}, true)
 }
 
 func (p *PubAPI) GetPublications(c *gin.Context) {
+	pubList, err := p.loadPublications(p.context)
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+	renderPublications(c, pubList, false)
+}
 
-	var pubList []schema.Publication
-	var pubItem schema.Publication
+// loadPublications reads every publication from the cache, ordered by id
+func (p *PubAPI) loadPublications(ctx context.Context) ([]schema.Publication, error) {
+	pubList := []schema.Publication{}
 
 	//Lets query redis for all of the items
-	pattern := "pubs:*"
-	ks, _ := p.client.Keys(p.context, pattern).Result()
+	pattern := pubKeyPrefix + "*"
+	ks, err := p.keys(ctx, pattern)
+	if err != nil {
+		return nil, err
+	}
 	for _, key := range ks {
+		//a fresh struct for every item, otherwise the slides of one
+		//publication show up in the next one that has none
+		var pubItem schema.Publication
 		err := p.getItemFromRedis(key, &pubItem)
+		if errors.Is(err, ErrNotFound) {
+			continue //deleted since SCAN listed it
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find publication in cache with id=" + key})
-			return
+			return nil, err
 		}
 		pubList = append(pubList, pubItem)
 	}
 
-	c.JSON(http.StatusOK, pubList)
+	sort.Slice(pubList, func(i, j int) bool { return pubList[i].ID < pubList[j].ID })
+	return pubList, nil
+}
+
+// generation reads the counter every change to the publications bumps,
+// the in-memory search index is rebuilt when it changes
+func (p *PubAPI) generation(ctx context.Context) (int64, error) {
+	gen, err := p.client.Get(ctx, pubGenerationKey).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return gen, err
+}
+
+// CreatePublication adds a new publication, the id comes with it and must
+// not be taken yet
+func (p *PubAPI) CreatePublication(c *gin.Context) {
+	var pub schema.Publication
+	if err := decodePublication(c, &pub); err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+	if err := checkPublication(&pub); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	doc, err := json.Marshal(pub)
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+	key := pubKey(pub.ID)
+	err = p.write([]string{key}, p.mustExist(pub.ID, false), func(pipe redis.Pipeliner) {
+		pipe.Do(p.context, "JSON.SET", key, ".", string(doc))
+	}, pubEvent(EventCreated, pub.ID))
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, pub)
+}
+
+// UpdatePublication replaces an existing publication.  The id in the body
+// may be left out, if it is there it has to match the id in the path
+func (p *PubAPI) UpdatePublication(c *gin.Context) {
+	id, err := pubIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	var pub schema.Publication
+	if err := decodePublication(c, &pub); err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+	if pub.ID == 0 {
+		pub.ID = id
+	}
+	if pub.ID != id {
+		problem.Abort(c, &validate.ValidationError{Fields: []validate.FieldError{
+			{Field: "id", Message: fmt.Sprintf("must match the id in the path, %d", id)},
+		}})
+		return
+	}
+	if err := checkPublication(&pub); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	doc, err := json.Marshal(pub)
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+	key := pubKey(pub.ID)
+	err = p.write([]string{key}, p.mustExist(pub.ID, true), func(pipe redis.Pipeliner) {
+		pipe.Do(p.context, "JSON.SET", key, ".", string(doc))
+	}, pubEvent(EventUpdated, pub.ID))
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pub)
+}
+
+// DeletePublication removes a publication.  The reading list api hears
+// about it from the deleted event, and marks or drops the items of its
+// lists that point to it
+func (p *PubAPI) DeletePublication(c *gin.Context) {
+	id, err := pubIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	key := pubKey(id)
+	err = p.write([]string{key}, p.mustExist(id, true), func(pipe redis.Pipeliner) {
+		pipe.Del(p.context, key)
+	}, pubEvent(EventDeleted, id))
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Move is the body of POST /pubs/:id/move
+type Move struct {
+	ID int `json:"id"`
+}
+
+// MovePublication gives a publication a new id, and so a new path.  The
+// new id must not be taken yet.  The publication is written under the new
+// id and deleted under the old one in one transaction, a failure leaves it
+// where it was.  The reading list api hears about it from the moved
+// event, and points the items of its lists to the new path.
+func (p *PubAPI) MovePublication(c *gin.Context) {
+	id, err := pubIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	var move Move
+	if err := json.NewDecoder(c.Request.Body).Decode(&move); err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+	if move.ID <= 0 || move.ID == id {
+		problem.Abort(c, &validate.ValidationError{Fields: []validate.FieldError{
+			{Field: "id", Message: fmt.Sprintf("must be a positive number other than %d", id)},
+		}})
+		return
+	}
+
+	//the copy to the new id, the delete of the old one and the event are
+	//one transaction, the publication is never under both ids
+	var pub schema.Publication
+	var doc []byte
+	from, to := pubKey(id), pubKey(move.ID)
+	err = p.write([]string{from, to}, func(c redis.Cmdable) error {
+		pub = schema.Publication{}
+		if err := p.getItemFromRedis(from, &pub); err != nil {
+			return err
+		}
+		pub.ID = move.ID
+		var err error
+		if doc, err = json.Marshal(pub); err != nil {
+			return err
+		}
+		return p.mustExist(move.ID, false)(c)
+	}, func(pipe redis.Pipeliner) {
+		pipe.Do(p.context, "JSON.SET", to, ".", string(doc))
+		pipe.Del(p.context, from)
+	}, ChangeEvent{Type: EventMoved, ID: id, Path: pubPath(id), To: pubPath(move.ID)})
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pub)
+}
+
+// mustExist is a check for write, the publication id has to be there or,
+// if exists is false, must not be there yet
+func (p *PubAPI) mustExist(id int, exists bool) func(c redis.Cmdable) error {
+	return func(c redis.Cmdable) error {
+		n, err := c.Exists(p.context, pubKey(id)).Result()
+		switch {
+		case err != nil:
+			return err
+		case exists && n == 0:
+			return fmt.Errorf("%w: could not find publication with id=%d", ErrNotFound, id)
+		case !exists && n > 0:
+			return fmt.Errorf("%w: publication %d already exists", ErrConflict, id)
+		}
+		return nil
+	}
+}
+
+func pubKey(id int) string {
+	return pubKeyPrefix + strconv.Itoa(id)
+}
+
+// pubPath is the path of a publication in the api, the reading lists
+// point to publications with it
+func pubPath(id int) string {
+	return "/pubs/" + strconv.Itoa(id)
+}
+
+func pubIdFromPath(c *gin.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("publication id %q is not a positive number", c.Param("id"))
+	}
+	return id, nil
+}
+
+// decodePublication reads a publication from the request body.  We do not
+// use ShouldBindJSON, gin would validate the publication before its links
+// are trimmed
+func decodePublication(c *gin.Context, pub *schema.Publication) error {
+	return json.NewDecoder(c.Request.Body).Decode(pub)
+}
+
+// checkPublication trims the links of pub, renders its citation if it
+// only has its authors, and validates it
+func checkPublication(pub *schema.Publication) error {
+	pub.Normalize()
+	completeCitation(pub)
+	return validate.Struct(*pub)
 }
 
 // Helper to return a ToDoItem from redis provided a key
@@ -113,6 +375,9 @@ func (p *PubAPI) getItemFromRedis(key string, pub *schema.Publication) error {
 	//json structure, the second parameter "." means return the entire
 	//json structure
 	itemObject, err := p.helper.JSONGet(key, ".")
+	if err == redis.Nil {
+		return fmt.Errorf("%w: could not find publication in cache with id=%s", ErrNotFound, key)
+	}
 	if err != nil {
 		return err
 	}
@@ -123,7 +388,7 @@ func (p *PubAPI) getItemFromRedis(key string, pub *schema.Publication) error {
 	//it into our ToDoItem struct
 	err = json.Unmarshal(itemObject.([]byte), pub)
 	if err != nil {
-		return err
+		return fmt.Errorf("cached data for %s seems to be wrong type: %w", key, err)
 	}
 
 	return nil
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"architectingsoftware.com/pub-api/schema"
+)
+
+var (
+	pub1 = schema.Publication{ID: 1, Title: "Bunch", Cite: "Mitchell (2006)"}
+	pub2 = schema.Publication{ID: 2, Title: "Search Based", Cite: "Harman (2001)"}
+)
+
+func TestWritePublications(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		wantStatus int
+		wantIDs    []int
+		wantEvents []string
+	}{
+		{
+			name: "create", method: http.MethodPost, path: "/pubs",
+			body:       schema.Publication{ID: 3, Title: "Modularity", Cite: "Parnas (1972)"},
+			wantStatus: http.StatusCreated,
+			wantIDs:    []int{1, 2, 3},
+			wantEvents: []string{"created /pubs/3"},
+		},
+		{
+			name: "create a taken id", method: http.MethodPost, path: "/pubs",
+			body:       schema.Publication{ID: 2, Title: "Modularity", Cite: "Parnas (1972)"},
+			wantStatus: http.StatusConflict,
+			wantIDs:    []int{1, 2},
+			wantEvents: []string{},
+		},
+		{
+			name: "create an invalid publication", method: http.MethodPost, path: "/pubs",
+			body:       schema.Publication{ID: 3, Title: " "},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantIDs:    []int{1, 2},
+			wantEvents: []string{},
+		},
+		{
+			name: "update", method: http.MethodPut, path: "/pubs/2",
+			body:       schema.Publication{Title: "Search Based SE", Cite: "Harman (2001)"},
+			wantStatus: http.StatusOK,
+			wantIDs:    []int{1, 2},
+			wantEvents: []string{"updated /pubs/2"},
+		},
+		{
+			name: "update a missing publication", method: http.MethodPut, path: "/pubs/7",
+			body:       schema.Publication{Title: "Modularity", Cite: "Parnas (1972)"},
+			wantStatus: http.StatusNotFound,
+			wantIDs:    []int{1, 2},
+			wantEvents: []string{},
+		},
+		{
+			name: "delete", method: http.MethodDelete, path: "/pubs/1",
+			wantStatus: http.StatusNoContent,
+			wantIDs:    []int{2},
+			wantEvents: []string{"deleted /pubs/1"},
+		},
+		{
+			name: "delete a missing publication", method: http.MethodDelete, path: "/pubs/7",
+			wantStatus: http.StatusNotFound,
+			wantIDs:    []int{1, 2},
+			wantEvents: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, nil, pub1, pub2)
+			expect(t, a.do(t, tt.method, tt.path, tt.body), tt.wantStatus, nil)
+			if got := a.ids(t); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("got the publications %v, want %v", got, tt.wantIDs)
+			}
+			if got := a.events(t); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("got the events %q, want %q", got, tt.wantEvents)
+			}
+			gen, err := a.generation(a.context)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := int64(len(tt.wantEvents)); gen != want {
+				t.Errorf("got generation %d, want %d", gen, want)
+			}
+		})
+	}
+}
+
+// TestWriteRetries changes the publication between the check and the
+// transaction of an update, the update starts over and is published once
+func TestWriteRetries(t *testing.T) {
+	var a *testAPI
+	checks := 0
+	a = newTestAPI(t, func(cmd string, args ...string) {
+		if cmd != "EXISTS" {
+			return
+		}
+		checks++
+		if checks == 1 {
+			a.redis.Set(pubKey(2), `{"id":2,"title":"changed","cite":"c"}`)
+		}
+	}, pub1, pub2)
+
+	expect(t, a.do(t, http.MethodPut, "/pubs/2", schema.Publication{Title: "Search Based SE", Cite: "Harman (2001)"}), http.StatusOK, nil)
+	var pub schema.Publication
+	if err := a.getItemFromRedis(pubKey(2), &pub); err != nil {
+		t.Fatal(err)
+	}
+	if pub.Title != "Search Based SE" || checks != 2 {
+		t.Errorf("got title %q after %d checks, want 2", pub.Title, checks)
+	}
+	if got, want := a.events(t), []string{"updated /pubs/2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the events %q, want %q", got, want)
+	}
+}
+
+func TestMovePublication(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		to         int
+		wantStatus int
+		wantIDs    []int
+		wantEvents []string
+	}{
+		{
+			name: "move", path: "/pubs/1/move", to: 5,
+			wantStatus: http.StatusOK,
+			wantIDs:    []int{2, 5},
+			wantEvents: []string{"moved /pubs/1 /pubs/5"},
+		},
+		{
+			name: "move to a taken id", path: "/pubs/1/move", to: 2,
+			wantStatus: http.StatusConflict,
+			wantIDs:    []int{1, 2},
+			wantEvents: []string{},
+		},
+		{
+			name: "move a missing publication", path: "/pubs/7/move", to: 5,
+			wantStatus: http.StatusNotFound,
+			wantIDs:    []int{1, 2},
+			wantEvents: []string{},
+		},
+		{
+			name: "move to the same id", path: "/pubs/1/move", to: 1,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantIDs:    []int{1, 2},
+			wantEvents: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, nil, pub1, pub2)
+			var moved schema.Publication
+			w := a.do(t, http.MethodPost, tt.path, Move{ID: tt.to})
+			if tt.wantStatus == http.StatusOK {
+				expect(t, w, tt.wantStatus, &moved)
+				if moved.ID != tt.to || moved.Title != pub1.Title {
+					t.Errorf("got %+v", moved)
+				}
+			} else {
+				expect(t, w, tt.wantStatus, nil)
+			}
+			if got := a.ids(t); !reflect.DeepEqual(got, tt.wantIDs) {
+				t.Errorf("got the publications %v, want %v", got, tt.wantIDs)
+			}
+			if got := a.events(t); !reflect.DeepEqual(got, tt.wantEvents) {
+				t.Errorf("got the events %q, want %q", got, tt.wantEvents)
+			}
+		})
+	}
+}
+
+// TestMoveRace creates the new id between the check and the transaction
+// of a move.  The transaction is dropped, the publication stays under its
+// old id only and the move is a conflict.
+func TestMoveRace(t *testing.T) {
+	var a *testAPI
+	checks := 0
+	a = newTestAPI(t, func(cmd string, args ...string) {
+		switch {
+		case cmd == "EXISTS":
+			checks++
+		case cmd == "MULTI" && !a.redis.Exists(pubKey(5)):
+			a.redis.Set(pubKey(5), `{"id":5,"title":"other","cite":"c"}`)
+		}
+	}, pub1, pub2)
+
+	expect(t, a.do(t, http.MethodPost, "/pubs/1/move", Move{ID: 5}), http.StatusConflict, nil)
+	if got, want := a.ids(t), []int{1, 2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the publications %v, want %v", got, want)
+	}
+	var pub schema.Publication
+	if err := a.getItemFromRedis(pubKey(5), &pub); err != nil {
+		t.Fatal(err)
+	}
+	if pub.Title != "other" {
+		t.Errorf("the move wrote over publication 5: %+v", pub)
+	}
+	if got := a.events(t); len(got) != 0 {
+		t.Errorf("got the events %q, want none", got)
+	}
+	if checks != 2 {
+		t.Errorf("checked %d times, want 2", checks)
+	}
+}
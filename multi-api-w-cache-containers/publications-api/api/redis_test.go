@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"architectingsoftware.com/pub-api/schema"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// testRedis runs miniredis for the length of the test.  miniredis does not
+// know the RedisJSON commands, a hook turns JSON.SET and JSON.GET on the
+// root path into SET and GET of a string key.  As they become plain redis
+// commands they take part in MULTI and WATCH like on a RedisJSON server.
+// before, if it is not nil, sees every command first.
+func testRedis(t *testing.T, before func(cmd string, args ...string)) *miniredis.Miniredis {
+	t.Helper()
+	m := miniredis.RunT(t)
+	srv := m.Server()
+	srv.SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+		if before != nil {
+			before(strings.ToUpper(cmd), args...)
+		}
+		switch strings.ToUpper(cmd) {
+		case "JSON.SET":
+			if len(args) < 3 || len(args) > 4 || args[1] != "." || !json.Valid([]byte(args[2])) {
+				c.WriteError("ERR only JSON.SET <key> . <json> [NX|XX] is supported")
+				return true
+			}
+			set := []string{"SET", args[0], args[2]}
+			if len(args) == 4 {
+				set = append(set, strings.ToUpper(args[3]))
+			}
+			srv.Dispatch(c, set)
+			return true
+		case "JSON.GET":
+			if len(args) != 2 || args[1] != "." {
+				c.WriteError("ERR only JSON.GET <key> . is supported")
+				return true
+			}
+			srv.Dispatch(c, []string{"GET", args[0]})
+			return true
+		}
+		return false
+	})
+	return m
+}
+
+// testAPI is the publication api on miniredis, with its routes
+type testAPI struct {
+	*PubAPI
+	redis  *miniredis.Miniredis
+	router *gin.Engine
+}
+
+// newTestAPI starts the api with the publications that are in the cache
+// already
+func newTestAPI(t *testing.T, before func(cmd string, args ...string), pubs ...schema.Publication) *testAPI {
+	t.Helper()
+	m := testRedis(t, before)
+	for _, pub := range pubs {
+		doc, err := json.Marshal(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.Set(pubKey(pub.ID), string(doc))
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	p, err := NewPubAPI(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(ProblemMiddleware())
+	p.RegisterRoutes(router)
+	return &testAPI{PubAPI: p, redis: m, router: router}
+}
+
+// do sends a request with body, if it is not nil, as json
+func (a *testAPI) do(t *testing.T, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect checks the status of w and decodes its body into v, if v is not
+// nil
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got %d %s, want %d", w.Code, w.Body.String(), status)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%v in %s", err, w.Body.String())
+		}
+	}
+}
+
+// ids lists the ids of the publications in the cache
+func (a *testAPI) ids(t *testing.T) []int {
+	t.Helper()
+	pubs, err := a.loadPublications(a.context)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, pub := range pubs {
+		ids = append(ids, pub.ID)
+	}
+	return ids
+}
+
+// events lists the events on the stream, eg "moved /pubs/1 /pubs/5"
+func (a *testAPI) events(t *testing.T) []string {
+	t.Helper()
+	msgs, err := a.client.XRange(a.context, pubEventStream, "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []string{}
+	for _, msg := range msgs {
+		e := msg.Values["type"].(string) + " " + msg.Values["path"].(string)
+		if to, ok := msg.Values["to"].(string); ok {
+			e += " " + to
+		}
+		events = append(events, e)
+	}
+	return events
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"openapi"
+	"problem"
+
+	"architectingsoftware.com/pub-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes is the route table of the publication api, RegisterRoutes serves
+// it and the OpenAPI document generated from it
+func (p *PubAPI) Routes() []openapi.Route {
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/pubs", Summary: "List all publications, as json, BibTeX, CSL-JSON or citations", Handler: p.GetPublications,
+			Query:     []string{"style"},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []schema.Publication{})}},
+		{Method: http.MethodPost, Path: "/pubs", Summary: "Add a publication", Handler: p.CreatePublication,
+			Body:      schema.Publication{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusCreated, schema.Publication{})}},
+		{Method: http.MethodPost, Path: "/pubs:bulk", Summary: "Load a pubs.json or .bib file in one transaction", Handler: p.BulkLoadPublications,
+			Query: []string{"replace"}, Body: []schema.Publication{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, BulkResult{}), openapi.Reply(http.StatusMultiStatus, BulkResult{})}},
+		{Method: http.MethodGet, Path: "/pubs/search", Summary: "Search the titles, abstracts and citations", Handler: p.SearchPublications,
+			Query:     []string{"q", "limit"},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, SearchResults{})}},
+		{Method: http.MethodGet, Path: "/pubs/:id", Summary: "Get a publication, as json, BibTeX, CSL-JSON or a citation", Handler: p.GetPublication,
+			Query:     []string{"style"},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, schema.Publication{})}},
+		{Method: http.MethodPut, Path: "/pubs/:id", Summary: "Replace a publication", Handler: p.UpdatePublication,
+			Body:      schema.Publication{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, schema.Publication{})}},
+		{Method: http.MethodDelete, Path: "/pubs/:id", Summary: "Delete a publication", Handler: p.DeletePublication,
+			Responses: []openapi.Response{openapi.Reply(http.StatusNoContent, nil)}},
+		{Method: http.MethodGet, Path: "/pubs/:id/links", Summary: "Report the links of a publication, if they work, and the clicks on them", Handler: p.GetPublicationLinks,
+			Query:     []string{"check"},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, LinkReport{})}},
+		{Method: http.MethodPost, Path: "/pubs/:id/move", Summary: "Give a publication a new id", Handler: p.MovePublication,
+			Body:      Move{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, schema.Publication{})}},
+	}
+}
+
+// RegisterRoutes adds the routes to r, serves the OpenAPI document at
+// /openapi.json and returns the document
+func (p *PubAPI) RegisterRoutes(r gin.IRouter) *openapi.Document {
+	routes := p.Routes()
+	doc := openapi.New(openapi.Info{Title: "publications-api", Version: "1.0.0"}, routes, problem.Problem{}, problem.ContentType)
+	openapi.Register(r, doc, routes)
+	return doc
+}
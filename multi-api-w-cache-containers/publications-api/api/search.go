@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"problem"
+	"strconv"
+
+	"architectingsoftware.com/pub-api/search"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 100
+)
+
+// SearchResults is what GET /pubs/search answers, the best match first
+type SearchResults struct {
+	Query   string          `json:"query"`
+	Engine  string          `json:"engine"`
+	Results []search.Result `json:"results"`
+}
+
+// SearchPublications searches the titles, abstracts and citations for
+// ?q=, see the search package for the query syntax.  ?limit= caps the
+// number of results.
+func (p *PubAPI) SearchPublications(c *gin.Context) {
+	q, err := search.ParseQuery(c.Query("q"))
+	if err != nil {
+		problem.AbortBadRequest(c, fmt.Errorf("q: %w", err))
+		return
+	}
+
+	limit := DefaultSearchLimit
+	if s := c.Query("limit"); s != "" {
+		limit, err = strconv.Atoi(s)
+		if err != nil || limit <= 0 || limit > MaxSearchLimit {
+			problem.AbortBadRequest(c, fmt.Errorf("limit %q is not a number from 1 to %d", s, MaxSearchLimit))
+			return
+		}
+	}
+
+	results, err := p.searcher.Search(c.Request.Context(), q, limit)
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SearchResults{Query: c.Query("q"), Engine: p.searcher.Engine(), Results: results})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"architectingsoftware.com/pub-api/schema"
+)
+
+func TestSearchPublications(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantIDs    []int
+	}{
+		{name: "a word", path: "/pubs/search?q=bunch", wantStatus: http.StatusOK, wantIDs: []int{1}},
+		{name: "the citation", path: "/pubs/search?q=harman", wantStatus: http.StatusOK, wantIDs: []int{2}},
+		{name: "a prefix", path: "/pubs/search?q=b*", wantStatus: http.StatusOK, wantIDs: []int{1, 2}},
+		{name: "a limit", path: "/pubs/search?q=b*&limit=1", wantStatus: http.StatusOK, wantIDs: []int{1}},
+		{name: "no match", path: "/pubs/search?q=cobol", wantStatus: http.StatusOK, wantIDs: []int{}},
+		{name: "no query", path: "/pubs/search", wantStatus: http.StatusBadRequest},
+		{name: "a query without words", path: "/pubs/search?q=%22%22", wantStatus: http.StatusBadRequest},
+		{name: "a limit that is too high", path: "/pubs/search?q=bunch&limit=101", wantStatus: http.StatusBadRequest},
+		{name: "a limit that is not a number", path: "/pubs/search?q=bunch&limit=ten", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, nil, pub1, pub2)
+			var results SearchResults
+			expect(t, a.do(t, http.MethodGet, tt.path, nil), tt.wantStatus, &results)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			//miniredis has no RediSearch
+			if results.Engine != "memory" {
+				t.Errorf("got the engine %q", results.Engine)
+			}
+			ids := []int{}
+			for _, r := range results.Results {
+				ids = append(ids, r.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("got %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+// TestSearchNewPublications finds a publication as soon as it is created
+func TestSearchNewPublications(t *testing.T) {
+	a := newTestAPI(t, nil, pub1, pub2)
+	var results SearchResults
+	expect(t, a.do(t, http.MethodGet, "/pubs/search?q=modularity", nil), http.StatusOK, &results)
+	if len(results.Results) != 0 {
+		t.Fatalf("got %+v", results.Results)
+	}
+
+	expect(t, a.do(t, http.MethodPost, "/pubs", schema.Publication{ID: 3, Title: "On Modularity", Cite: "Parnas (1972)"}), http.StatusCreated, nil)
+	results = SearchResults{}
+	expect(t, a.do(t, http.MethodGet, "/pubs/search?q=modularity", nil), http.StatusOK, &results)
+	if len(results.Results) != 1 || results.Results[0].Title != "On <b>Modularity</b>" {
+		t.Errorf("got %+v", results.Results)
+	}
+}
@@ -0,0 +1,455 @@
+package bib
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"architectingsoftware.com/pub-api/schema"
+)
+
+// Entry is one BibTeX entry, eg @article{mitchell08, title = {...}}.  The
+// type and the field names are lower case, the values are plain text.
+type Entry struct {
+	Type   string
+	Key    string
+	Fields map[string]string
+}
+
+// SyntaxError is returned by Parse for a file that is not BibTeX
+type SyntaxError struct {
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("bibtex line %d: %s", e.Line, e.Msg)
+}
+
+// Parse reads the entries of a .bib file.  It understands @string macros
+// and # concatenation, and skips @comment, @preamble and any text between
+// the entries, which BibTeX treats as a comment as well.
+func Parse(src string) ([]Entry, error) {
+	p := &parser{src: src, macros: map[string]string{
+		"jan": "January", "feb": "February", "mar": "March", "apr": "April",
+		"may": "May", "jun": "June", "jul": "July", "aug": "August",
+		"sep": "September", "oct": "October", "nov": "November", "dec": "December",
+	}}
+
+	entries := []Entry{}
+	for {
+		at := strings.IndexByte(p.src[p.pos:], '@')
+		if at < 0 {
+			return entries, nil
+		}
+		p.pos += at + 1
+
+		typ := strings.ToLower(p.ident())
+		if typ == "" {
+			return nil, p.errorf("expected an entry type after @")
+		}
+		p.space()
+		var closing byte
+		switch p.next() {
+		case '{':
+			closing = '}'
+		case '(':
+			closing = ')'
+		default:
+			return nil, p.errorf("expected { after @%s", typ)
+		}
+
+		switch typ {
+		case "comment", "preamble":
+			if _, err := p.group(closing); err != nil {
+				return nil, err
+			}
+		case "string":
+			name, value, err := p.field()
+			if err != nil {
+				return nil, err
+			}
+			p.macros[name] = value
+			p.space()
+			if p.next() != closing {
+				return nil, p.errorf("expected %c after @string %s", closing, name)
+			}
+		default:
+			e, err := p.entry(typ, closing)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, e)
+		}
+	}
+}
+
+type parser struct {
+	src    string
+	pos    int
+	macros map[string]string //the raw values of the @string macros
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	end := p.pos
+	if end > len(p.src) {
+		end = len(p.src)
+	}
+	line := 1 + strings.Count(p.src[:end], "\n")
+	return &SyntaxError{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) next() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	c := p.src[p.pos]
+	p.pos++
+	return c
+}
+
+func (p *parser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *parser) space() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+// ident reads a type, field or macro name
+func (p *parser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if !isLetter(c) && !(c >= '0' && c <= '9') && strings.IndexByte("_-:./+", c) < 0 {
+			break
+		}
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) entry(typ string, closing byte) (Entry, error) {
+	e := Entry{Type: typ, Fields: map[string]string{}}
+
+	p.space()
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != ',' && p.src[p.pos] != closing && !unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	e.Key = p.src[start:p.pos]
+
+	for {
+		p.space()
+		switch p.next() {
+		case closing:
+			return e, nil
+		case ',':
+		default:
+			return e, p.errorf("expected , or %c in entry %q", closing, e.Key)
+		}
+		//a comma may come after the last field
+		p.space()
+		if p.peek() == closing {
+			p.pos++
+			return e, nil
+		}
+		name, raw, err := p.field()
+		if err != nil {
+			return e, err
+		}
+		if verbatim[name] {
+			e.Fields[name] = strings.TrimSpace(raw)
+		} else {
+			e.Fields[name] = fromLaTeX(raw)
+		}
+	}
+}
+
+// field reads name = value and returns the raw value, with the macros
+// expanded and the parts joined
+func (p *parser) field() (string, string, error) {
+	name := strings.ToLower(p.ident())
+	if name == "" {
+		return "", "", p.errorf("expected a field name")
+	}
+	p.space()
+	if p.next() != '=' {
+		return "", "", p.errorf("expected = after %s", name)
+	}
+
+	var value strings.Builder
+	for {
+		p.space()
+		switch c := p.peek(); {
+		case c == '{':
+			p.pos++
+			part, err := p.group('}')
+			if err != nil {
+				return "", "", err
+			}
+			value.WriteString(part)
+		case c == '"':
+			p.pos++
+			part, err := p.quoted()
+			if err != nil {
+				return "", "", err
+			}
+			value.WriteString(part)
+		case c >= '0' && c <= '9':
+			start := p.pos
+			for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+				p.pos++
+			}
+			value.WriteString(p.src[start:p.pos])
+		default:
+			macro := strings.ToLower(p.ident())
+			expanded, ok := p.macros[macro]
+			if !ok {
+				return "", "", p.errorf("unknown @string %q in field %s", macro, name)
+			}
+			value.WriteString(expanded)
+		}
+		p.space()
+		if p.peek() != '#' {
+			return name, value.String(), nil
+		}
+		p.pos++
+	}
+}
+
+// group reads up to the closing brace or parenthesis that matches the one
+// just read, nested braces included
+func (p *parser) group(closing byte) (string, error) {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			p.pos++
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case c == closing && depth == 0:
+			return p.src[start : p.pos-1], nil
+		}
+	}
+	p.pos = start
+	return "", p.errorf("missing %c", closing)
+}
+
+// quoted reads up to the closing quote, quotes inside braces do not count
+func (p *parser) quoted() (string, error) {
+	start := p.pos
+	depth := 0
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			p.pos++
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == '"' && depth == 0:
+			return p.src[start : p.pos-1], nil
+		}
+	}
+	p.pos = start
+	return "", p.errorf(`missing "`)
+}
+
+// Publication turns an entry into a publication.  The id comes from an
+// "id" field, 0 if the entry has none.  A "cite" field becomes the free
+// text citation, the api renders one if it is missing.
+func (e Entry) Publication() (schema.Publication, error) {
+	pub := schema.Publication{
+		Title:    e.Fields["title"],
+		Cite:     e.Fields["cite"],
+		Link:     e.Fields["url"],
+		Abstract: e.Fields["abstract"],
+		Type:     pubType(e.Type),
+		Authors:  splitAuthors(e.Fields["author"]),
+		DOI:      e.Fields["doi"],
+		Pages:    strings.ReplaceAll(e.Fields["pages"], "–", "-"),
+		Key:      e.Key,
+	}
+	for _, venue := range []string{"journal", "booktitle", "publisher", "school", "institution", "howpublished"} {
+		if e.Fields[venue] != "" {
+			pub.Venue = e.Fields[venue]
+			break
+		}
+	}
+
+	var err error
+	if s := e.Fields["id"]; s != "" {
+		if pub.ID, err = strconv.Atoi(s); err != nil {
+			return pub, fmt.Errorf("entry %q: id %q is not a number", e.Key, s)
+		}
+	}
+	if s := e.Fields["year"]; s != "" {
+		if pub.Year, err = strconv.Atoi(s); err != nil {
+			return pub, fmt.Errorf("entry %q: year %q is not a number", e.Key, s)
+		}
+	}
+	return pub, nil
+}
+
+// pubType maps the BibTeX entry types to the ones a publication can have
+func pubType(entryType string) string {
+	switch entryType {
+	case "article", "inproceedings", "incollection", "book", "techreport", "phdthesis", "mastersthesis":
+		return entryType
+	case "conference":
+		return "inproceedings"
+	case "inbook":
+		return "incollection"
+	default:
+		return "misc"
+	}
+}
+
+// venueField is the BibTeX field that holds the venue of a type
+func venueField(pubType string) string {
+	switch pubType {
+	case "article":
+		return "journal"
+	case "inproceedings", "incollection":
+		return "booktitle"
+	case "book":
+		return "publisher"
+	case "techreport":
+		return "institution"
+	case "phdthesis", "mastersthesis":
+		return "school"
+	default:
+		return "howpublished"
+	}
+}
+
+// CiteKey is the BibTeX key of pub, its Key or pub<id>
+func CiteKey(pub schema.Publication) string {
+	if pub.Key != "" {
+		return pub.Key
+	}
+	return "pub" + strconv.Itoa(pub.ID)
+}
+
+// EntryOf turns a publication into a BibTeX entry.  The id and the free
+// text citation go into "id" and "cite" fields, which BibTeX ignores, so
+// that Parse brings the publication back.  The slides are left out.
+func EntryOf(pub schema.Publication) Entry {
+	typ := pub.Type
+	if typ == "" {
+		typ = "misc"
+	}
+	e := Entry{Type: typ, Key: CiteKey(pub), Fields: map[string]string{}}
+	set := func(name, value string) {
+		if value != "" {
+			e.Fields[name] = value
+		}
+	}
+	if pub.ID != 0 {
+		set("id", strconv.Itoa(pub.ID))
+	}
+	set("author", strings.Join(pub.Authors, " and "))
+	set("title", pub.Title)
+	set(venueField(typ), pub.Venue)
+	if pub.Year != 0 {
+		set("year", strconv.Itoa(pub.Year))
+	}
+	set("pages", strings.ReplaceAll(pub.Pages, "-", "--"))
+	set("doi", pub.DOI)
+	set("url", pub.Link)
+	set("cite", pub.Cite)
+	set("abstract", pub.Abstract)
+	return e
+}
+
+// verbatim are the fields that are not LaTeX, a ~ or _ in a url is
+// part of the url
+var verbatim = map[string]bool{"url": true, "doi": true}
+
+// fieldOrder is the order String writes the fields in, fields that are
+// not listed come after these, sorted by name
+var fieldOrder = []string{"id", "author", "title", "journal", "booktitle", "publisher",
+	"institution", "school", "howpublished", "year", "pages", "doi", "url", "cite", "abstract"}
+
+// String formats the entry as BibTeX
+func (e Entry) String() string {
+	names := make([]string, 0, len(e.Fields))
+	for _, name := range fieldOrder {
+		if _, ok := e.Fields[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var rest []string
+	for name := range e.Fields {
+		if !contains(fieldOrder, name) {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	names = append(names, rest...)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "@%s{%s,\n", e.Type, e.Key)
+	for _, name := range names {
+		value := e.Fields[name]
+		if !verbatim[name] {
+			value = toLaTeX(value)
+		}
+		fmt.Fprintf(&b, "  %s = {%s},\n", name, value)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Marshal formats pubs as a .bib file
+func Marshal(pubs []schema.Publication) []byte {
+	var b strings.Builder
+	for i, pub := range pubs {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(EntryOf(pub).String())
+	}
+	return []byte(b.String())
+}
+
+// Unmarshal reads the publications of a .bib file
+func Unmarshal(data []byte) ([]schema.Publication, error) {
+	entries, err := Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	pubs := make([]schema.Publication, 0, len(entries))
+	for _, e := range entries {
+		pub, err := e.Publication()
+		if err != nil {
+			return nil, err
+		}
+		pubs = append(pubs, pub)
+	}
+	return pubs, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
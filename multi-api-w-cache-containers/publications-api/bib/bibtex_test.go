@@ -0,0 +1,200 @@
+package bib_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"architectingsoftware.com/pub-api/bib"
+	"architectingsoftware.com/pub-api/schema"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []bib.Entry
+	}{
+		{
+			name: "an empty file",
+			src:  "",
+			want: []bib.Entry{},
+		},
+		{
+			name: "fields in braces, quotes and bare numbers",
+			src:  "@Article{mitchell08,\n  Title = {Search Based},\n  journal = \"Soft Comp\",\n  year = 2008\n}",
+			want: []bib.Entry{{Type: "article", Key: "mitchell08", Fields: map[string]string{
+				"title": "Search Based", "journal": "Soft Comp", "year": "2008",
+			}}},
+		},
+		{
+			name: "parentheses and a comma after the last field",
+			src:  "@misc(k, title = {t},)",
+			want: []bib.Entry{{Type: "misc", Key: "k", Fields: map[string]string{"title": "t"}}},
+		},
+		{
+			name: "macros, month names and concatenation",
+			src:  "@string{ieee = \"IEEE\"}\n@article{k, journal = ieee # { Trans}, month = jan}",
+			want: []bib.Entry{{Type: "article", Key: "k", Fields: map[string]string{
+				"journal": "IEEE Trans", "month": "January",
+			}}},
+		},
+		{
+			name: "comments, preambles and text between entries are skipped",
+			src:  "a note\n@comment{@article{x, title={no}}}\n@preamble{\"\\newcommand\"}\n@book{k, title={yes}}",
+			want: []bib.Entry{{Type: "book", Key: "k", Fields: map[string]string{"title": "yes"}}},
+		},
+		{
+			name: "LaTeX becomes plain text",
+			src:  `@misc{k, title = {Caf\'e {\"U}ber \emph{Alles} \& 1--2~pages}, author = {M{\"u}ller and Stra\ss e}}`,
+			want: []bib.Entry{{Type: "misc", Key: "k", Fields: map[string]string{
+				"title": "Café Über Alles & 1–2 pages", "author": "Müller and Straße",
+			}}},
+		},
+		{
+			name: "urls and dois are kept as they are",
+			src:  `@misc{k, url = { http://a.b/~c_d }, doi = {10.1/x_y}}`,
+			want: []bib.Entry{{Type: "misc", Key: "k", Fields: map[string]string{
+				"url": "http://a.b/~c_d", "doi": "10.1/x_y",
+			}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := bib.Parse(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	tests := []struct {
+		name     string
+		src      string
+		wantLine int
+	}{
+		{"no type after @", "@{k}", 1},
+		{"no brace after the type", "\n@article k", 2},
+		{"an unclosed entry", "@article{k,\n title = {t}", 2},
+		{"an unclosed brace", "@article{k,\n\n title = {t", 3},
+		{"an unclosed quote", `@article{k, title = "t}`, 1},
+		{"an unknown macro", "@article{k,\n journal = ieee}", 2},
+		{"a field without =", "@article{k, title {t}}", 1},
+		{"an unclosed @string", `@string{a = "b"`, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := bib.Parse(tt.src)
+			var syntaxErr *bib.SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("got %v, want a *bib.SyntaxError", err)
+			}
+			if syntaxErr.Line != tt.wantLine {
+				t.Errorf("got line %d, want %d: %v", syntaxErr.Line, tt.wantLine, err)
+			}
+		})
+	}
+}
+
+func TestPublication(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   bib.Entry
+		want    schema.Publication
+		wantErr bool
+	}{
+		{
+			name: "an article",
+			entry: bib.Entry{Type: "article", Key: "m08", Fields: map[string]string{
+				"id": "7", "title": "T", "author": "A. B and C. D", "journal": "J", "year": "2008", "pages": "1–9",
+			}},
+			want: schema.Publication{ID: 7, Title: "T", Type: "article", Authors: []string{"A. B", "C. D"},
+				Venue: "J", Year: 2008, Pages: "1-9", Key: "m08"},
+		},
+		{
+			name:  "a conference paper is inproceedings",
+			entry: bib.Entry{Type: "conference", Key: "c", Fields: map[string]string{"booktitle": "ICSE"}},
+			want:  schema.Publication{Type: "inproceedings", Authors: []string{}, Venue: "ICSE", Key: "c"},
+		},
+		{
+			name:  "an unknown type is misc",
+			entry: bib.Entry{Type: "online", Key: "o", Fields: map[string]string{"howpublished": "web"}},
+			want:  schema.Publication{Type: "misc", Authors: []string{}, Venue: "web", Key: "o"},
+		},
+		{
+			name:    "an id that is not a number",
+			entry:   bib.Entry{Type: "misc", Key: "k", Fields: map[string]string{"id": "seven"}},
+			wantErr: true,
+		},
+		{
+			name:    "a year that is not a number",
+			entry:   bib.Entry{Type: "misc", Key: "k", Fields: map[string]string{"year": "2008a"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.entry.Publication()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMarshalRoundTrip checks that Unmarshal brings back what Marshal
+// wrote, the slides aside
+func TestMarshalRoundTrip(t *testing.T) {
+	pubs := []schema.Publication{
+		{
+			ID: 1, Title: "Bunch & Clustering: 100% {modular}", Cite: "Mitchell, B. (2008)", Link: "http://a.b/~c_d",
+			Abstract: "Über search_based clustering", Type: "article", Authors: []string{"Brian S. Mitchell", "Spiros Mancoridis"},
+			Venue: "Soft Comp", Year: 2008, DOI: "10.1007/s00500_007", Pages: "1-9", Key: "mitchell08",
+		},
+		{ID: 2, Title: "Notes", Cite: "Notes (2020)"},
+	}
+	got, err := bib.Unmarshal(bib.Marshal(pubs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []schema.Publication{pubs[0], pubs[1]}
+	want[0].Title = "Bunch & Clustering: 100% modular" //braces are dropped
+	want[1].Type, want[1].Key, want[1].Authors = "misc", "pub2", []string{}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseName(t *testing.T) {
+	tests := []struct {
+		author       string
+		want         bib.Name
+		wantInitials string
+	}{
+		{"Brian S. Mitchell", bib.Name{Given: "Brian S.", Family: "Mitchell"}, "B. S."},
+		{"Mitchell, Brian S.", bib.Name{Given: "Brian S.", Family: "Mitchell"}, "B. S."},
+		{"  Jean-Paul   Sartre ", bib.Name{Given: "Jean-Paul", Family: "Sartre"}, "J.-P."},
+		{"van Gogh, Émile", bib.Name{Given: "Émile", Family: "van Gogh"}, "É."},
+		{"Plato", bib.Name{Family: "Plato"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.author, func(t *testing.T) {
+			got := bib.ParseName(tt.author)
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+			if initials := got.Initials(); initials != tt.wantInitials {
+				t.Errorf("got initials %q, want %q", initials, tt.wantInitials)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package bib
+
+import "architectingsoftware.com/pub-api/schema"
+
+// CSLItem is a publication in CSL-JSON, the format of the Citation Style
+// Language processors like citeproc, Zotero and pandoc
+type CSLItem struct {
+	ID             string    `json:"id"`
+	Type           string    `json:"type"`
+	Title          string    `json:"title"`
+	Author         []CSLName `json:"author,omitempty"`
+	ContainerTitle string    `json:"container-title,omitempty"`
+	Publisher      string    `json:"publisher,omitempty"`
+	Issued         *CSLDate  `json:"issued,omitempty"`
+	Page           string    `json:"page,omitempty"`
+	DOI            string    `json:"DOI,omitempty"`
+	URL            string    `json:"URL,omitempty"`
+	Abstract       string    `json:"abstract,omitempty"`
+
+	//Note holds the free text citation of a publication without
+	//authors, it is all we know about who wrote it and where
+	Note string `json:"note,omitempty"`
+}
+
+type CSLName struct {
+	Family string `json:"family"`
+	Given  string `json:"given,omitempty"`
+}
+
+type CSLDate struct {
+	DateParts [][]int `json:"date-parts"`
+}
+
+// CSL turns a publication into a CSL-JSON item, the item id is the
+// BibTeX key
+func CSL(pub schema.Publication) CSLItem {
+	item := CSLItem{
+		ID:       CiteKey(pub),
+		Type:     cslType(pub.Type),
+		Title:    pub.Title,
+		Page:     pub.Pages,
+		DOI:      pub.DOI,
+		URL:      pub.Link,
+		Abstract: pub.Abstract,
+	}
+	for _, author := range pub.Authors {
+		n := ParseName(author)
+		item.Author = append(item.Author, CSLName{Family: n.Family, Given: n.Given})
+	}
+	if len(item.Author) == 0 {
+		item.Note = pub.Cite
+	}
+	switch pub.Type {
+	case "book", "techreport", "phdthesis", "mastersthesis":
+		item.Publisher = pub.Venue
+	default:
+		item.ContainerTitle = pub.Venue
+	}
+	if pub.Year != 0 {
+		item.Issued = &CSLDate{DateParts: [][]int{{pub.Year}}}
+	}
+	return item
+}
+
+func cslType(pubType string) string {
+	switch pubType {
+	case "article":
+		return "article-journal"
+	case "inproceedings":
+		return "paper-conference"
+	case "incollection":
+		return "chapter"
+	case "book":
+		return "book"
+	case "techreport":
+		return "report"
+	case "phdthesis", "mastersthesis":
+		return "thesis"
+	default:
+		return "document"
+	}
+}
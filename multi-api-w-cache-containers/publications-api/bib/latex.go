@@ -0,0 +1,125 @@
+package bib
+
+import "strings"
+
+// BibTeX values are LaTeX, the publications are plain text.  These are
+// the accents and symbols we translate, anything more exotic keeps its
+// letter and loses its markup.
+
+// accented maps an accent command and a letter, eg `"o` for \"o, to
+// the accented letter
+var accented = map[string]string{
+	`"a`: "ä", `"e`: "ë", `"i`: "ï", `"o`: "ö", `"u`: "ü", `"y`: "ÿ",
+	`"A`: "Ä", `"E`: "Ë", `"I`: "Ï", `"O`: "Ö", `"U`: "Ü",
+	`'a`: "á", `'e`: "é", `'i`: "í", `'o`: "ó", `'u`: "ú", `'y`: "ý", `'c`: "ć", `'n`: "ń", `'s`: "ś", `'z`: "ź",
+	`'A`: "Á", `'E`: "É", `'I`: "Í", `'O`: "Ó", `'U`: "Ú", `'Y`: "Ý", `'C`: "Ć", `'N`: "Ń", `'S`: "Ś", `'Z`: "Ź",
+	"`a": "à", "`e": "è", "`i": "ì", "`o": "ò", "`u": "ù",
+	"`A": "À", "`E": "È", "`I": "Ì", "`O": "Ò", "`U": "Ù",
+	`^a`: "â", `^e`: "ê", `^i`: "î", `^o`: "ô", `^u`: "û",
+	`^A`: "Â", `^E`: "Ê", `^I`: "Î", `^O`: "Ô", `^U`: "Û",
+	`~a`: "ã", `~n`: "ñ", `~o`: "õ", `~A`: "Ã", `~N`: "Ñ", `~O`: "Õ",
+	`cc`: "ç", `cC`: "Ç", `cs`: "ş", `cS`: "Ş",
+	`vc`: "č", `vs`: "š", `vz`: "ž", `vr`: "ř", `ve`: "ě", `vC`: "Č", `vS`: "Š", `vZ`: "Ž",
+}
+
+// symbols are the commands that stand for a letter on their own, eg \ss
+var symbols = map[string]string{
+	"ss": "ß", "o": "ø", "O": "Ø", "aa": "å", "AA": "Å", "ae": "æ", "AE": "Æ",
+	"oe": "œ", "OE": "Œ", "l": "ł", "L": "Ł", "i": "ı", "j": "ȷ",
+}
+
+// fromLaTeX turns a BibTeX value into plain text: accents become accented
+// letters, escaped characters lose their backslash, other commands like
+// \emph{...} keep their argument, braces go and the whitespace, including
+// the line breaks of long values, is collapsed
+func fromLaTeX(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\\' && i+1 < len(s):
+			i++
+			cmd := s[i]
+			if strings.IndexByte(`&%$#_{}\`, cmd) >= 0 {
+				b.WriteByte(cmd)
+				i++
+				continue
+			}
+			if strings.IndexByte("\"'`^~", cmd) >= 0 || ((cmd == 'c' || cmd == 'v') && i+1 < len(s) && (s[i+1] == '{' || s[i+1] == ' ')) {
+				i++
+				letter, n := accentTarget(s[i:])
+				i += n
+				if a, ok := accented[string(cmd)+letter]; ok {
+					b.WriteString(a)
+				} else {
+					b.WriteString(letter)
+				}
+				continue
+			}
+			j := i
+			for j < len(s) && isLetter(s[j]) {
+				j++
+			}
+			name := s[i:j]
+			i = j
+			if sym, ok := symbols[name]; ok {
+				b.WriteString(sym)
+				//the space that ends a command word is not text
+				if i < len(s) && s[i] == ' ' {
+					i++
+				}
+			}
+		case c == '{' || c == '}':
+			i++
+		case c == '~':
+			b.WriteByte(' ')
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	text := strings.Join(strings.Fields(b.String()), " ")
+	text = strings.ReplaceAll(text, "---", "—")
+	return strings.ReplaceAll(text, "--", "–")
+}
+
+// accentTarget returns the letter an accent applies to, from "o", "{o}",
+// " o" or "{\i}", and how many bytes of s it took
+func accentTarget(s string) (string, int) {
+	switch {
+	case strings.HasPrefix(s, `{\i}`):
+		return "i", 4
+	case strings.HasPrefix(s, `\i`):
+		return "i", 2
+	case len(s) >= 3 && s[0] == '{' && s[2] == '}':
+		return s[1:2], 3
+	case len(s) >= 2 && s[0] == ' ' && isLetter(s[1]):
+		return s[1:2], 2
+	case len(s) >= 1 && isLetter(s[0]):
+		return s[:1], 1
+	}
+	return "", 0
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// toLaTeX escapes the characters that mean something to LaTeX.  Braces
+// are dropped, an unbalanced brace would break the whole entry.
+func toLaTeX(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '&', '%', '$', '#', '_':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '{', '}':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package bib
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Name is an author name split the way the citation styles need it
+type Name struct {
+	Given  string
+	Family string
+}
+
+// ParseName splits an author, written either "Mitchell, Brian S." or
+// "Brian S. Mitchell".  Without a comma the last word is the family name.
+func ParseName(author string) Name {
+	author = strings.Join(strings.Fields(author), " ")
+	if family, given, ok := strings.Cut(author, ","); ok {
+		return Name{Given: strings.TrimSpace(given), Family: strings.TrimSpace(family)}
+	}
+	i := strings.LastIndexByte(author, ' ')
+	if i < 0 {
+		return Name{Family: author}
+	}
+	return Name{Given: author[:i], Family: author[i+1:]}
+}
+
+// Initials shortens the given names, "Brian S." becomes "B. S." and
+// "Jean-Paul" becomes "J.-P."
+func (n Name) Initials() string {
+	words := strings.Fields(n.Given)
+	for i, word := range words {
+		parts := strings.Split(word, "-")
+		for j, part := range parts {
+			if r, _ := utf8.DecodeRuneInString(part); r != utf8.RuneError {
+				parts[j] = string(r) + "."
+			}
+		}
+		words[i] = strings.Join(parts, "-")
+	}
+	return strings.Join(words, " ")
+}
+
+// splitAuthors splits a BibTeX author field, "A and B and C"
+func splitAuthors(field string) []string {
+	var authors []string
+	words := strings.Fields(field)
+	start := 0
+	for i, word := range words {
+		if word == "and" {
+			authors = append(authors, strings.Join(words[start:i], " "))
+			start = i + 1
+		}
+	}
+	authors = append(authors, strings.Join(words[start:], " "))
+
+	nonEmpty := authors[:0]
+	for _, a := range authors {
+		if a != "" {
+			nonEmpty = append(nonEmpty, a)
+		}
+	}
+	return nonEmpty
+}
@@ -0,0 +1,159 @@
+package bib
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"architectingsoftware.com/pub-api/schema"
+)
+
+// ErrUnknownStyle is returned by Format for a style it does not know
+var ErrUnknownStyle = errors.New("unknown citation style")
+
+// styles are the citation styles Format renders.  They are plain text,
+// the italics of the real styles are left out.
+var styles = map[string]func(schema.Publication) string{
+	"apa":  apa,
+	"ieee": ieee,
+}
+
+// Styles returns the names of the citation styles, sorted
+func Styles() []string {
+	names := make([]string, 0, len(styles))
+	for name := range styles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Format renders the citation of pub in style, eg "apa".  A publication
+// without authors only has its free text citation, which is returned
+// as it is for every style.
+func Format(pub schema.Publication, style string) (string, error) {
+	render, ok := styles[strings.ToLower(style)]
+	if !ok {
+		return "", fmt.Errorf("%w %q, use one of %s", ErrUnknownStyle, style, strings.Join(Styles(), ", "))
+	}
+	if len(pub.Authors) == 0 {
+		return pub.Cite, nil
+	}
+	return render(pub), nil
+}
+
+// apa renders the APA 7 reference, eg
+//
+//	Mitchell, B. S., & Mancoridis, S. (2008). On the evaluation of the
+//	Bunch algorithm. Soft Computing, 77-93. https://doi.org/10.1007/x
+func apa(pub schema.Publication) string {
+	names := make([]string, 0, len(pub.Authors))
+	for _, author := range pub.Authors {
+		n := ParseName(author)
+		name := n.Family
+		if initials := n.Initials(); initials != "" {
+			name += ", " + initials
+		}
+		names = append(names, name)
+	}
+
+	var b strings.Builder
+	switch len(names) {
+	case 1:
+		b.WriteString(names[0])
+	default:
+		b.WriteString(strings.Join(names[:len(names)-1], ", "))
+		b.WriteString(", & " + names[len(names)-1])
+	}
+	if pub.Year != 0 {
+		fmt.Fprintf(&b, " (%d). ", pub.Year)
+	} else {
+		b.WriteString(" (n.d.). ")
+	}
+	b.WriteString(sentence(pub.Title))
+
+	switch {
+	case pub.Venue == "":
+	case pub.Type == "article":
+		b.WriteString(" " + pub.Venue)
+		if pub.Pages != "" {
+			b.WriteString(", " + pub.Pages)
+		}
+		b.WriteString(".")
+	case pub.Type == "inproceedings" || pub.Type == "incollection":
+		b.WriteString(" In " + pub.Venue)
+		if pub.Pages != "" {
+			b.WriteString(" (pp. " + pub.Pages + ")")
+		}
+		b.WriteString(".")
+	default:
+		b.WriteString(" " + sentence(pub.Venue))
+	}
+
+	if pub.DOI != "" {
+		b.WriteString(" https://doi.org/" + pub.DOI)
+	} else if pub.Link != "" {
+		b.WriteString(" " + pub.Link)
+	}
+	return b.String()
+}
+
+// ieee renders the IEEE reference, eg
+//
+//	B. S. Mitchell and S. Mancoridis, "On the evaluation of the Bunch
+//	algorithm," Soft Computing, pp. 77-93, 2008, doi: 10.1007/x.
+func ieee(pub schema.Publication) string {
+	names := make([]string, 0, len(pub.Authors))
+	for _, author := range pub.Authors {
+		n := ParseName(author)
+		names = append(names, strings.TrimSpace(n.Initials()+" "+n.Family))
+	}
+
+	var authors string
+	switch {
+	case len(names) > 6:
+		authors = names[0] + " et al."
+	case len(names) == 1:
+		authors = names[0]
+	case len(names) == 2:
+		authors = names[0] + " and " + names[1]
+	default:
+		authors = strings.Join(names[:len(names)-1], ", ") + ", and " + names[len(names)-1]
+	}
+
+	parts := []string{authors, `"` + strings.TrimRight(pub.Title, ".") + `,"`}
+	if pub.Venue != "" {
+		if pub.Type == "inproceedings" || pub.Type == "incollection" {
+			parts = append(parts, "in "+pub.Venue)
+		} else {
+			parts = append(parts, pub.Venue)
+		}
+	}
+	if pub.Pages != "" {
+		parts = append(parts, "pp. "+pub.Pages)
+	}
+	if pub.Year != 0 {
+		parts = append(parts, strconv.Itoa(pub.Year))
+	}
+	if pub.DOI != "" {
+		parts = append(parts, "doi: "+pub.DOI)
+	}
+
+	//the title ends with its own comma inside the quotes, or with the
+	//period when nothing follows it
+	s := parts[0] + ", " + parts[1]
+	if len(parts) == 2 {
+		return strings.TrimSuffix(s, `,"`) + `."`
+	}
+	return s + " " + strings.Join(parts[2:], ", ") + "."
+}
+
+// sentence ends s with a period, unless it already ends with a mark
+func sentence(s string) string {
+	if strings.HasSuffix(s, ".") || strings.HasSuffix(s, "?") || strings.HasSuffix(s, "!") {
+		return s
+	}
+	return s + "."
+}
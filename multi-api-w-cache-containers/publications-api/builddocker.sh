@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag architectingsoftware/cnse-pub-api:v1  -f ./dockerfile .
\ No newline at end of file
+docker build --tag architectingsoftware/cnse-pub-api:v1  --build-context config=../../config --build-context problem=../../problem --build-context validate=../../validate --build-context openapi=../../openapi -f ./dockerfile .
\ No newline at end of file
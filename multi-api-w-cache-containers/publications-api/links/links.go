@@ -0,0 +1,140 @@
+// Package links checks that the links of the publications, to the papers
+// and to the slides, still work.  It only checks the hosts on its
+// allowlist, so the api does not send requests to wherever a publication
+// happens to point.
+package links
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The Status of a checked link
+const (
+	StatusOK        = "ok"
+	StatusDead      = "dead"
+	StatusSkipped   = "skipped"   //its host is not on the allowlist
+	StatusUnchecked = "unchecked" //not checked yet
+)
+
+// DefaultTimeout bounds every check
+const DefaultTimeout = 10 * time.Second
+
+// MaxRedirects is how many redirects a check follows
+const MaxRedirects = 5
+
+// Result is what a check found out about a link.  Code is the HTTP status
+// the link answered with, Error why it could not be reached.
+type Result struct {
+	URL       string     `json:"url"`
+	Status    string     `json:"status"`
+	Code      int        `json:"code,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt *time.Time `json:"checkedAt,omitempty"`
+}
+
+// Checker checks links with HEAD requests, and with GET requests for the
+// servers that do not take HEAD
+type Checker struct {
+	allow  []string
+	client *http.Client
+}
+
+// NewChecker returns a Checker for the hosts of allowlist.  An entry also
+// allows the hosts under it, "drexel.edu" allows "www.cs.drexel.edu", and
+// "*" allows every host.
+func NewChecker(allowlist []string) *Checker {
+	var allow []string
+	for _, host := range allowlist {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			allow = append(allow, host)
+		}
+	}
+	c := &Checker{allow: allow}
+	c.client = &http.Client{Timeout: DefaultTimeout, CheckRedirect: c.checkRedirect}
+	return c
+}
+
+// checkRedirect stops at a redirect to a host that is not allowed, an
+// allowed link must not send the checker to, say, 169.254.169.254 or
+// localhost
+func (c *Checker) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= MaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", MaxRedirects)
+	}
+	if !c.Allowed(req.URL.String()) {
+		return fmt.Errorf("redirected to %s, which is not on the allowlist", req.URL.Host)
+	}
+	return nil
+}
+
+// Allowlist returns the hosts the checker checks
+func (c *Checker) Allowlist() []string {
+	return c.allow
+}
+
+// Allowed tells if link is an http(s) link to a host on the allowlist
+func (c *Checker) Allowed(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range c.allow {
+		if allowed == "*" || host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check requests link and tells if it works: it does when it answers, after
+// the redirects, with a status below 400.  A link that is not allowed is
+// skipped, and a link that redirects to a host that is not allowed, or
+// redirects more than MaxRedirects times, is dead.
+func (c *Checker) Check(ctx context.Context, link string) Result {
+	if !c.Allowed(link) {
+		return Skipped(link)
+	}
+
+	now := time.Now().UTC()
+	res := Result{URL: link, CheckedAt: &now}
+	code, err := c.request(ctx, http.MethodHead, link)
+	if err == nil && (code == http.StatusMethodNotAllowed || code == http.StatusNotImplemented) {
+		code, err = c.request(ctx, http.MethodGet, link)
+	}
+	switch {
+	case err != nil:
+		res.Status, res.Error = StatusDead, err.Error()
+	case code >= 400:
+		res.Status, res.Code, res.Error = StatusDead, code, http.StatusText(code)
+	default:
+		res.Status, res.Code = StatusOK, code
+	}
+	return res
+}
+
+// Skipped is the Result for a link that is not allowed
+func Skipped(link string) Result {
+	return Result{URL: link, Status: StatusSkipped, Error: "the host is not on the allowlist"}
+}
+
+func (c *Checker) request(ctx context.Context, method string, link string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, link, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("User-Agent", "pub-api link checker")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("could not reach the link: %w", err)
+	}
+	//the body is not needed, only the status
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
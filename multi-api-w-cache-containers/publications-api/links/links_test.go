@@ -0,0 +1,90 @@
+package links_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"architectingsoftware.com/pub-api/links"
+)
+
+func TestAllowed(t *testing.T) {
+	c := links.NewChecker([]string{" Drexel.edu ", "example.org"})
+	tests := []struct {
+		link string
+		want bool
+	}{
+		{"https://drexel.edu/paper.pdf", true},
+		{"https://www.cs.drexel.edu/paper.pdf", true},
+		{"http://EXAMPLE.org", true},
+		{"https://notdrexel.edu", false},
+		{"https://drexel.edu.evil.com", false},
+		{"ftp://drexel.edu/paper.pdf", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"not a url", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.link, func(t *testing.T) {
+			if got := c.Allowed(tt.link); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	var internalHits int
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalHits++
+	}))
+	defer internal.Close()
+	//the test servers are all on 127.0.0.1, localhost stands for a host
+	//that is not on the allowlist
+	internalURL := strings.Replace(internal.URL, "127.0.0.1", "localhost", 1)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) })
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/ok", http.StatusFound) })
+	mux.HandleFunc("/internal", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internalURL+"/latest/meta-data", http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) { http.Redirect(w, r, "/loop", http.StatusFound) })
+	allowed := httptest.NewServer(mux)
+	defer allowed.Close()
+
+	c := links.NewChecker([]string{"127.0.0.1"})
+	tests := []struct {
+		name       string
+		link       string
+		wantStatus string
+		wantCode   int
+		wantError  string
+	}{
+		{name: "a working link", link: allowed.URL + "/ok", wantStatus: links.StatusOK, wantCode: 200},
+		{name: "a missing page", link: allowed.URL + "/gone", wantStatus: links.StatusDead, wantCode: 404},
+		{name: "a server without HEAD", link: allowed.URL + "/get-only", wantStatus: links.StatusOK, wantCode: 200},
+		{name: "a redirect to an allowed host", link: allowed.URL + "/moved", wantStatus: links.StatusOK, wantCode: 200},
+		{name: "a redirect to a host that is not allowed", link: allowed.URL + "/internal", wantStatus: links.StatusDead, wantError: "not on the allowlist"},
+		{name: "a redirect loop", link: allowed.URL + "/loop", wantStatus: links.StatusDead, wantError: "stopped after 5 redirects"},
+		{name: "a host that is not allowed", link: internalURL, wantStatus: links.StatusSkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.Check(context.Background(), tt.link)
+			if got.Status != tt.wantStatus || got.Code != tt.wantCode || !strings.Contains(got.Error, tt.wantError) {
+				t.Errorf("got %+v, want status %s, code %d and an error with %q", got, tt.wantStatus, tt.wantCode, tt.wantError)
+			}
+		})
+	}
+	if internalHits != 0 {
+		t.Errorf("the host that is not allowed was requested %d times", internalHits)
+	}
+}
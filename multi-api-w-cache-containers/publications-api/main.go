@@ -1,59 +1,44 @@
 package main
 
 import (
-	"flag"
-	"fmt"
-	"os"
-	"strconv"
+	"config"
+	"time"
 
 	"architectingsoftware.com/pub-api/api"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	hostFlag string
-	portFlag uint
-	cacheURL string
-)
-
-func processCmdLineFlags() {
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.StringVar(&cacheURL, "c", "0.0.0.0:6379", "Default cache location")
-	flag.UintVar(&portFlag, "p", 2080, "Default Port")
-
-	flag.Parse()
+// Config is the configuration of the publication api, from the flags, the
+// PUBAPI_ environment variables and the -config file, see the config
+// package.  The old flags, eg -linkcheck, still work.
+type Config struct {
+	config.Server
+	Redis          config.Redis  `config:"redis"`
+	LinkCheckEvery time.Duration `config:"link-check-every" flag:"linkcheck" validate:"min=0s" usage:"How often to check the links of the publications, 0 to never"`
+	LinkAllowlist  []string      `config:"link-allowlist" flag:"linkallow" usage:"The hosts whose links are checked, separated by commas, eg www.cs.drexel.edu"`
 }
 
-func envVarOrDefault(envVar string, defaultVal string) string {
-	envVal := os.Getenv(envVar)
-	if envVal != "" {
-		return envVal
+func loadConfig() Config {
+	cfg := Config{
+		Server:         config.Server{Host: "0.0.0.0", Port: 2080},
+		Redis:          config.Redis{URL: "0.0.0.0:6379"},
+		LinkCheckEvery: api.DefaultLinkCheckEvery,
 	}
-	return defaultVal
-}
-
-func setupParms() {
-	//first process any command line flags
-	processCmdLineFlags()
-
-	//now process any environment variables
-	cacheURL = envVarOrDefault("PUBAPI_CACHE_URL", cacheURL)
-	hostFlag = envVarOrDefault("PUBAPI_HOST", hostFlag)
-	pfNew, err := strconv.Atoi(envVarOrDefault("PUBAPI_PORT", fmt.Sprintf("%d", portFlag)))
-	//only update the port if we were able to convert the env var to an int, else
-	//we will use the default we got from the command line, or command line defaults
-	if err == nil {
-		portFlag = uint(pfNew)
-	}
-
+	config.MustLoad("PUBAPI_", &cfg)
+	return cfg
 }
 
 func main() {
-	//this will allow the user to override key parameters and also setup defaults
-	setupParms()
+	//the flags, the environment and the config file can override the
+	//defaults, see loadConfig
+	cfg := loadConfig()
 
-	apiHandler, err := api.NewPubAPI(cacheURL)
+	redisClient, err := cfg.Redis.Client()
+	if err != nil {
+		panic(err)
+	}
+	apiHandler, err := api.NewPubAPI(redisClient)
 
 	if err != nil {
 		panic(err)
@@ -61,12 +46,18 @@ func main() {
 
 	r := gin.Default()
 	r.Use(cors.Default())
+	r.Use(api.ProblemMiddleware())
+
+	apiHandler.RegisterRoutes(r)
 
-	r.GET("/pubs", apiHandler.GetPublications)
-	r.GET("/pubs/:id", apiHandler.GetPublication)
+	//the links of the publications are checked in the background, see
+	//GET /pubs/:id/links
+	apiHandler.SetLinkAllowlist(cfg.LinkAllowlist)
+	if cfg.LinkCheckEvery > 0 {
+		stop := apiHandler.StartLinkChecker(cfg.LinkCheckEvery)
+		defer stop()
+	}
 
-	//For now we will just support gets
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	r.Run(cfg.Addr())
 
 }
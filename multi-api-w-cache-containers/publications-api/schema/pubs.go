@@ -1,15 +1,55 @@
 package schema
 
+import "strings"
+
+// The validation rules are declared with "binding" struct tags, the api
+// checks a publication against them before it writes it to the cache
+
 type slideLink struct {
-	Type        string `json:"type"`
-	Description string `json:"description"`
-	Link        string `json:"link"`
+	Type        string `json:"type" binding:"oneof=PDF PPT PPTX KEY VIDEO"`
+	Description string `json:"description" binding:"notblank"`
+	Link        string `json:"link" binding:"required,url"`
 }
+
+// Publication is a paper.  Cite is the free-text citation the older
+// publications only have, the bibliographic fields from Type to Key are
+// optional and let the api render the publication as BibTeX, CSL-JSON or
+// in a citation style.  Authors are written "Family, Given" or "Given
+// Family".
 type Publication struct {
-	ID       int         `json:"id"`
-	Title    string      `json:"title"`
-	Cite     string      `json:"cite"`
-	Link     string      `json:"link,omitempty"`
-	Slides   []slideLink `json:"slides,omitempty"`
+	ID       int         `json:"id" binding:"gt=0"`
+	Title    string      `json:"title" binding:"notblank"`
+	Cite     string      `json:"cite" binding:"notblank"`
+	Link     string      `json:"link,omitempty" binding:"omitempty,url"`
+	Slides   []slideLink `json:"slides,omitempty" binding:"omitempty,dive"`
 	Abstract string      `json:"abstract"`
+
+	Type    string   `json:"type,omitempty" binding:"omitempty,oneof=article inproceedings incollection book techreport phdthesis mastersthesis misc"`
+	Authors []string `json:"authors,omitempty" binding:"omitempty,dive,notblank"`
+	Venue   string   `json:"venue,omitempty"`
+	Year    int      `json:"year,omitempty" binding:"omitempty,gte=1000,lte=9999"`
+	DOI     string   `json:"doi,omitempty" binding:"omitempty,startswith=10."`
+	Pages   string   `json:"pages,omitempty"`
+	Key     string   `json:"key,omitempty"` //the BibTeX citation key
+}
+
+// Normalize trims the whitespace around the links and the bibliographic
+// fields, which is easy to pick up when they are pasted into a pubs.json
+// file, and turns a DOI url into the bare DOI
+func (p *Publication) Normalize() {
+	p.Link = strings.TrimSpace(p.Link)
+	for i := range p.Slides {
+		p.Slides[i].Link = strings.TrimSpace(p.Slides[i].Link)
+	}
+	for i := range p.Authors {
+		p.Authors[i] = strings.TrimSpace(p.Authors[i])
+	}
+	p.Type = strings.ToLower(strings.TrimSpace(p.Type))
+	p.Venue = strings.TrimSpace(p.Venue)
+	p.Pages = strings.TrimSpace(p.Pages)
+	p.Key = strings.TrimSpace(p.Key)
+	p.DOI = strings.TrimSpace(p.DOI)
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "doi:"} {
+		p.DOI = strings.TrimPrefix(p.DOI, prefix)
+	}
 }
@@ -0,0 +1,248 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"architectingsoftware.com/pub-api/schema"
+)
+
+// The fields that are searched, and how much a match in each counts
+const (
+	fieldTitle = iota
+	fieldAbstract
+	fieldCite
+	fieldCount
+)
+
+var fieldWeights = [fieldCount]float64{fieldTitle: 3, fieldAbstract: 1, fieldCite: 1}
+
+// Index is an inverted index of publications: for every word, the
+// publications and the positions in their fields where it is.  It is not
+// safe for concurrent use, Memory guards it.
+type Index struct {
+	docs     map[int]*document
+	postings map[string]map[int][]position
+	words    []string //the keys of postings, sorted, for the prefixes
+}
+
+type document struct {
+	id     int
+	texts  [fieldCount]string
+	tokens [fieldCount][]Token
+}
+
+type position struct {
+	field int
+	pos   int //the index of the word in the tokens of the field
+}
+
+// NewIndex indexes pubs
+func NewIndex(pubs []schema.Publication) *Index {
+	idx := &Index{docs: map[int]*document{}, postings: map[string]map[int][]position{}}
+	for _, pub := range pubs {
+		doc := &document{id: pub.ID}
+		doc.texts[fieldTitle] = pub.Title
+		doc.texts[fieldAbstract] = pub.Abstract
+		doc.texts[fieldCite] = pub.Cite
+		for f, text := range doc.texts {
+			doc.tokens[f] = Tokenize(text)
+			for pos, t := range doc.tokens[f] {
+				if idx.postings[t.Word] == nil {
+					idx.postings[t.Word] = map[int][]position{}
+				}
+				idx.postings[t.Word][pub.ID] = append(idx.postings[t.Word][pub.ID], position{field: f, pos: pos})
+			}
+		}
+		idx.docs[pub.ID] = doc
+	}
+	for word := range idx.postings {
+		idx.words = append(idx.words, word)
+	}
+	sort.Strings(idx.words)
+	return idx
+}
+
+// expand returns the words of the index a query word stands for, itself
+// or, for a prefix, every word that starts with it
+func (idx *Index) expand(word string, prefix bool) []string {
+	if !prefix {
+		return []string{word}
+	}
+	var words []string
+	for i := sort.SearchStrings(idx.words, word); i < len(idx.words) && strings.HasPrefix(idx.words[i], word); i++ {
+		words = append(words, idx.words[i])
+	}
+	return words
+}
+
+// match is where a clause matched in a document
+type match struct {
+	field int
+	pos   int //the position of the first word
+	n     int //how many words
+}
+
+// matches finds the clause in every document
+func (idx *Index) matches(c Clause) map[int][]match {
+	last := len(c.Words) - 1
+	found := map[int][]match{}
+
+	first := idx.expand(c.Words[0], c.Prefix && last == 0)
+	for _, word := range first {
+		for id, positions := range idx.postings[word] {
+			doc := idx.docs[id]
+			for _, p := range positions {
+				if idx.phraseAt(doc, p, c) {
+					found[id] = append(found[id], match{field: p.field, pos: p.pos, n: len(c.Words)})
+				}
+			}
+		}
+	}
+	return found
+}
+
+// phraseAt tells if the words after the first one of the clause follow it
+// at p
+func (idx *Index) phraseAt(doc *document, p position, c Clause) bool {
+	tokens := doc.tokens[p.field]
+	last := len(c.Words) - 1
+	for i := 1; i <= last; i++ {
+		if p.pos+i >= len(tokens) {
+			return false
+		}
+		word := tokens[p.pos+i].Word
+		if i == last && c.Prefix {
+			if !strings.HasPrefix(word, c.Words[i]) {
+				return false
+			}
+		} else if word != c.Words[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Search scores the documents that match every clause of q, a kind of
+// BM25: each clause adds the weight of the fields it matched in, damped
+// for repeats, times how rare the clause is
+func (idx *Index) Search(q Query, limit int) []Result {
+	n := float64(len(idx.docs))
+	scores := map[int]float64{}
+	spans := map[int][fieldCount][][2]int{}
+
+	for i, c := range q.Clauses {
+		found := idx.matches(c)
+		idf := math.Log(1 + (n-float64(len(found))+0.5)/(float64(len(found))+0.5))
+
+		for id := range scores {
+			if _, ok := found[id]; !ok {
+				delete(scores, id)
+			}
+		}
+		for id, ms := range found {
+			if _, ok := scores[id]; !ok && i > 0 {
+				continue
+			}
+			var perField [fieldCount]float64
+			s := spans[id]
+			for _, m := range ms {
+				perField[m.field]++
+				tokens := idx.docs[id].tokens[m.field]
+				s[m.field] = append(s[m.field], [2]int{tokens[m.pos].Start, tokens[m.pos+m.n-1].End})
+			}
+			spans[id] = s
+			for f, tf := range perField {
+				scores[id] += fieldWeights[f] * idf * tf / (tf + 1.2)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for id, score := range scores {
+		doc := idx.docs[id]
+		s := spans[id]
+		for f := range s {
+			s[f] = mergeSpans(s[f])
+		}
+		r := Result{ID: id, Score: math.Round(score*1000) / 1000, Title: highlight(doc.texts[fieldTitle], s[fieldTitle])}
+		switch {
+		case len(s[fieldAbstract]) > 0 || len(s[fieldCite]) == 0:
+			r.Snippet = snippet(doc.texts[fieldAbstract], doc.tokens[fieldAbstract], s[fieldAbstract])
+		default:
+			r.Snippet = snippet(doc.texts[fieldCite], doc.tokens[fieldCite], s[fieldCite])
+		}
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// mergeSpans sorts spans and joins the ones that overlap
+func mergeSpans(spans [][2]int) [][2]int {
+	sort.Slice(spans, func(i, j int) bool { return spans[i][0] < spans[j][0] })
+	merged := spans[:0]
+	for _, span := range spans {
+		if len(merged) > 0 && span[0] <= merged[len(merged)-1][1] {
+			if span[1] > merged[len(merged)-1][1] {
+				merged[len(merged)-1][1] = span[1]
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// Memory searches an Index of the publications in the cache.  Every
+// change to the publications bumps a generation counter in the cache,
+// Memory rebuilds the index when it sees a new generation, so it also
+// sees the changes made through other replicas of the api.
+type Memory struct {
+	generation func(ctx context.Context) (int64, error)
+	load       func(ctx context.Context) ([]schema.Publication, error)
+
+	mu    sync.Mutex
+	built int64
+	index *Index
+}
+
+// NewMemory returns a Memory that reads the generation counter with
+// generation, and the publications with load
+func NewMemory(generation func(ctx context.Context) (int64, error), load func(ctx context.Context) ([]schema.Publication, error)) *Memory {
+	return &Memory{generation: generation, load: load}
+}
+
+func (m *Memory) Engine() string {
+	return "memory"
+}
+
+func (m *Memory) Search(ctx context.Context, q Query, limit int) ([]Result, error) {
+	gen, err := m.generation(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.index == nil || gen != m.built {
+		pubs, err := m.load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.index = NewIndex(pubs)
+		m.built = gen
+	}
+	return m.index.Search(q, limit), nil
+}
@@ -0,0 +1,112 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrNoRediSearch is returned by NewRediSearch when the redis server does
+// not have the RediSearch module
+var ErrNoRediSearch = errors.New("redis does not have the RediSearch module")
+
+// RediSearch searches with the RediSearch module of redis.  Redis keeps
+// its index of the publication JSON documents up to date by itself.
+type RediSearch struct {
+	client *redis.Client
+	index  string
+}
+
+// NewRediSearch creates the index of the JSON documents under prefix, eg
+// "pubs:", unless it is there already
+func NewRediSearch(ctx context.Context, client *redis.Client, index string, prefix string) (*RediSearch, error) {
+	err := client.Do(ctx, "FT.CREATE", index, "ON", "JSON", "PREFIX", 1, prefix, "SCHEMA",
+		"$.title", "AS", "title", "TEXT", "WEIGHT", fieldWeights[fieldTitle],
+		"$.abstract", "AS", "abstract", "TEXT", "WEIGHT", fieldWeights[fieldAbstract],
+		"$.cite", "AS", "cite", "TEXT", "WEIGHT", fieldWeights[fieldCite],
+	).Err()
+	switch {
+	case err == nil:
+	case strings.Contains(strings.ToLower(err.Error()), "unknown command"):
+		return nil, ErrNoRediSearch
+	case strings.Contains(strings.ToLower(err.Error()), "index already exists"):
+	default:
+		return nil, err
+	}
+	return &RediSearch{client: client, index: index}, nil
+}
+
+func (r *RediSearch) Engine() string {
+	return "redisearch"
+}
+
+func (r *RediSearch) Search(ctx context.Context, q Query, limit int) ([]Result, error) {
+	reply, err := r.client.Do(ctx, "FT.SEARCH", r.index, redisQuery(q), "WITHSCORES",
+		"RETURN", 3, "title", "abstract", "cite",
+		"SUMMARIZE", "FIELDS", 2, "abstract", "cite", "FRAGS", 1, "LEN", snippetWords, "SEPARATOR", "...",
+		"HIGHLIGHT", "FIELDS", 3, "title", "abstract", "cite", "TAGS", HighlightOpen, HighlightClose,
+		"LIMIT", 0, limit,
+	).Slice()
+	if err != nil {
+		return nil, err
+	}
+	return parseSearchReply(reply)
+}
+
+// redisQuery writes q in the query syntax of RediSearch, which is ours
+// plus the @field modifiers we do not use.  The words only have letters
+// and digits, so they need no escaping.
+func redisQuery(q Query) string {
+	parts := make([]string, 0, len(q.Clauses))
+	for _, c := range q.Clauses {
+		s := strings.Join(c.Words, " ")
+		if c.Prefix {
+			s += "*"
+		}
+		if len(c.Words) > 1 {
+			s = `"` + s + `"`
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// parseSearchReply reads the reply of FT.SEARCH WITHSCORES: the number of
+// matches, then the key, the score and the fields of every match
+func parseSearchReply(reply []interface{}) ([]Result, error) {
+	if len(reply) == 0 || (len(reply)-1)%3 != 0 {
+		return nil, fmt.Errorf("unexpected FT.SEARCH reply with %d elements", len(reply))
+	}
+	results := make([]Result, 0, (len(reply)-1)/3)
+	for i := 1; i+2 < len(reply); i += 3 {
+		key, _ := reply[i].(string)
+		_, idPart, _ := strings.Cut(key, ":")
+		id, err := strconv.Atoi(idPart)
+		if err != nil {
+			return nil, fmt.Errorf("FT.SEARCH returned the key %q, which is not a publication", key)
+		}
+		r := Result{ID: id}
+		if score, ok := reply[i+1].(string); ok {
+			r.Score, _ = strconv.ParseFloat(score, 64)
+		}
+
+		fields, _ := reply[i+2].([]interface{})
+		values := map[string]string{}
+		for j := 0; j+1 < len(fields); j += 2 {
+			name, _ := fields[j].(string)
+			value, _ := fields[j+1].(string)
+			values[name] = value
+		}
+		r.Title = values["title"]
+		r.Snippet = values["abstract"]
+		if !strings.Contains(r.Snippet, HighlightOpen) && strings.Contains(values["cite"], HighlightOpen) {
+			r.Snippet = values["cite"]
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
@@ -0,0 +1,88 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedisQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "Bunch clustering", want: "bunch clustering"},
+		{query: `"Search Space" bunch`, want: `"search space" bunch`},
+		{query: "modular*", want: "modular*"},
+		{query: `"software mod*"`, want: `"software mod*"`},
+		{query: "search-based", want: `"search based"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := redisQuery(q); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   []interface{}
+		want    []Result
+		wantErr bool
+	}{
+		{
+			name:  "no matches",
+			reply: []interface{}{int64(0)},
+			want:  []Result{},
+		},
+		{
+			name: "the abstract snippet",
+			reply: []interface{}{int64(1),
+				"pubs:1", "2.5", []interface{}{"title", "<b>Bunch</b>", "abstract", "<b>Bunch</b> clusters...", "cite", "Mitchell (2006)"},
+			},
+			want: []Result{{ID: 1, Title: "<b>Bunch</b>", Snippet: "<b>Bunch</b> clusters...", Score: 2.5}},
+		},
+		{
+			name: "the citation snippet when only it matches",
+			reply: []interface{}{int64(2),
+				"pubs:3", "1", []interface{}{"title", "On Modularity", "abstract", "Space and search...", "cite", "<b>Parnas</b> (1972)"},
+				"pubs:1", "0.5", []interface{}{"title", "Bunch"},
+			},
+			want: []Result{
+				{ID: 3, Title: "On Modularity", Snippet: "<b>Parnas</b> (1972)", Score: 1},
+				{ID: 1, Title: "Bunch", Score: 0.5},
+			},
+		},
+		{
+			name:    "a key that is not a publication",
+			reply:   []interface{}{int64(1), "pubs:x", "1", []interface{}{}},
+			wantErr: true,
+		},
+		{
+			name:    "a short reply",
+			reply:   []interface{}{int64(1), "pubs:1", "1"},
+			wantErr: true,
+		},
+		{
+			name:    "an empty reply",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSearchReply(tt.reply)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want an error %t", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
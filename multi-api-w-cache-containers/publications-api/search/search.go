@@ -0,0 +1,206 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The publications are searched by their title, abstract and free text
+// citation.  Two engines do the searching: RediSearch, when the redis
+// server has the module, and Memory, an inverted index kept by the api
+// itself.  Both take the same queries:
+//
+//	bunch clustering     publications that have both words
+//	"search space"       the words next to each other, in this order
+//	modular*             any word that starts with modular
+//
+// and answer with the best matches first, the matched words wrapped in
+// <b></b> in the title and the snippet.
+
+// ErrEmptyQuery is returned for a query without a word to search for
+var ErrEmptyQuery = errors.New("the query has no words to search for")
+
+const (
+	HighlightOpen  = "<b>"
+	HighlightClose = "</b>"
+)
+
+// Searcher finds publications
+type Searcher interface {
+	// Search returns the limit best matches of q
+	Search(ctx context.Context, q Query, limit int) ([]Result, error)
+
+	// Engine names the search engine, "redisearch" or "memory"
+	Engine() string
+}
+
+// Result is one matching publication.  Title is the title with the
+// matched words highlighted, Snippet the part of the abstract, or the
+// citation, around the first match.
+type Result struct {
+	ID      int     `json:"id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet,omitempty"`
+	Score   float64 `json:"score"`
+}
+
+// Clause is a word, a prefix or a phrase of a query
+type Clause struct {
+	// Words are lower case, a phrase has more than one
+	Words []string
+
+	// Prefix tells if the last word matches every word it starts
+	Prefix bool
+}
+
+// Query is what to search for, every clause has to match
+type Query struct {
+	Clauses []Clause
+}
+
+// ParseQuery reads a query: words, "quoted phrases" and prefixes ending
+// with *.  Punctuation separates words, like it does in the text that is
+// searched.
+func ParseQuery(s string) (Query, error) {
+	var q Query
+	for s != "" {
+		s = strings.TrimLeftFunc(s, unicode.IsSpace)
+		if s == "" {
+			break
+		}
+
+		if s[0] == '"' {
+			phrase, rest, _ := strings.Cut(s[1:], `"`)
+			s = rest
+			if clause, ok := clauseOf(phrase); ok {
+				q.Clauses = append(q.Clauses, clause)
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		word := s[:end]
+		s = s[end:]
+		//a word with punctuation inside, eg "search-based", is a phrase
+		//of its parts
+		if clause, ok := clauseOf(word); ok {
+			q.Clauses = append(q.Clauses, clause)
+		}
+	}
+	if len(q.Clauses) == 0 {
+		return q, ErrEmptyQuery
+	}
+	return q, nil
+}
+
+func clauseOf(text string) (Clause, bool) {
+	prefix := strings.HasSuffix(strings.TrimSpace(text), "*")
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return Clause{}, false
+	}
+	words := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		words = append(words, t.Word)
+	}
+	return Clause{Words: words, Prefix: prefix}, true
+}
+
+// Token is a word of a text, Start and End are its byte offsets
+type Token struct {
+	Word       string
+	Start, End int
+}
+
+// Tokenize splits text into lower case words of letters and digits
+func Tokenize(text string) []Token {
+	var tokens []Token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			tokens = append(tokens, Token{Word: strings.ToLower(text[start:i]), Start: start, End: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, Token{Word: strings.ToLower(text[start:]), Start: start, End: len(text)})
+	}
+	return tokens
+}
+
+// highlight wraps the byte ranges spans of text in the highlight tags,
+// the spans are sorted and do not overlap
+func highlight(text string, spans [][2]int) string {
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		b.WriteString(text[last:span[0]])
+		b.WriteString(HighlightOpen + text[span[0]:span[1]] + HighlightClose)
+		last = span[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// snippetWords is how many words a snippet has
+const snippetWords = 30
+
+// snippet cuts the words around the first span out of text, and
+// highlights the spans in it.  Without spans it is the start of text.
+func snippet(text string, tokens []Token, spans [][2]int) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	first := 0
+	if len(spans) > 0 {
+		for i, t := range tokens {
+			if t.Start >= spans[0][0] {
+				first = i
+				break
+			}
+		}
+	}
+	from := first - snippetWords/3
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetWords
+	if to > len(tokens) {
+		to = len(tokens)
+	}
+
+	start, end := tokens[from].Start, tokens[to-1].End
+	if to < len(tokens) {
+		//keep the punctuation that ends the last word, eg a period
+		if r, size := utf8.DecodeRuneInString(text[end:]); r != utf8.RuneError && unicode.IsPunct(r) {
+			end += size
+		}
+	} else {
+		end = len(text)
+	}
+
+	var inside [][2]int
+	for _, span := range spans {
+		if span[0] >= start && span[1] <= end {
+			inside = append(inside, [2]int{span[0] - start, span[1] - start})
+		}
+	}
+	s := highlight(text[start:end], inside)
+	if from > 0 {
+		s = "..." + s
+	}
+	if to < len(tokens) {
+		s += "..."
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,156 @@
+package search_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"architectingsoftware.com/pub-api/schema"
+	"architectingsoftware.com/pub-api/search"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []search.Clause
+		wantErr error
+	}{
+		{query: "bunch", want: []search.Clause{{Words: []string{"bunch"}}}},
+		{query: "  Bunch  Clustering ", want: []search.Clause{{Words: []string{"bunch"}}, {Words: []string{"clustering"}}}},
+		{query: `"Search Space" bunch`, want: []search.Clause{{Words: []string{"search", "space"}}, {Words: []string{"bunch"}}}},
+		{query: `"search space`, want: []search.Clause{{Words: []string{"search", "space"}}}},
+		{query: "modular*", want: []search.Clause{{Words: []string{"modular"}, Prefix: true}}},
+		{query: `"software mod*"`, want: []search.Clause{{Words: []string{"software", "mod"}, Prefix: true}}},
+		{query: "search-based", want: []search.Clause{{Words: []string{"search", "based"}}}},
+		{query: "", wantErr: search.ErrEmptyQuery},
+		{query: `* -- ""`, wantErr: search.ErrEmptyQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := search.ParseQuery(tt.query)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(q.Clauses, tt.want) {
+				t.Errorf("got %+v, want %+v", q.Clauses, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := search.Tokenize("Über-Graph, v2.")
+	want := []search.Token{{Word: "über", Start: 0, End: 5}, {Word: "graph", Start: 6, End: 11}, {Word: "v2", Start: 13, End: 15}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+var pubs = []schema.Publication{
+	{ID: 1, Title: "Bunch: A Clustering Tool", Abstract: "Bunch clusters the modules of a system.", Cite: "Mitchell (2006)"},
+	{ID: 2, Title: "Search Based Software Engineering", Abstract: "The search space of modular structures.", Cite: "Harman (2001)"},
+	{ID: 3, Title: "On Modularity", Abstract: "Space and search, in that order.", Cite: "Parnas (1972), see Bunch"},
+}
+
+func TestIndexSearch(t *testing.T) {
+	tests := []struct {
+		query     string
+		limit     int
+		wantIDs   []int
+		wantTitle string // the title of the best match
+	}{
+		{query: "bunch", limit: 10, wantIDs: []int{1, 3}, wantTitle: "<b>Bunch</b>: A Clustering Tool"},
+		{query: "bunch", limit: 1, wantIDs: []int{1}, wantTitle: "<b>Bunch</b>: A Clustering Tool"},
+		{query: "search space", limit: 10, wantIDs: []int{2, 3}, wantTitle: "<b>Search</b> Based Software Engineering"},
+		{query: `"search space"`, limit: 10, wantIDs: []int{2}, wantTitle: "Search Based Software Engineering"},
+		{query: "modul*", limit: 10, wantIDs: []int{3, 1, 2}, wantTitle: "On <b>Modularity</b>"},
+		{query: "bunch modularity", limit: 10, wantIDs: []int{3}, wantTitle: "On <b>Modularity</b>"},
+		{query: "search-based", limit: 10, wantIDs: []int{2}, wantTitle: "<b>Search Based</b> Software Engineering"},
+		{query: "parnas", limit: 10, wantIDs: []int{3}, wantTitle: "On Modularity"},
+		{query: "cobol", limit: 10},
+	}
+	idx := search.NewIndex(pubs)
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := search.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results := idx.Search(q, tt.limit)
+			var ids []int
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Fatalf("got %v, want %v", ids, tt.wantIDs)
+			}
+			if len(results) > 0 && results[0].Title != tt.wantTitle {
+				t.Errorf("got title %q, want %q", results[0].Title, tt.wantTitle)
+			}
+		})
+	}
+}
+
+func TestIndexSearchSnippet(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"clusters", "Bunch <b>clusters</b> the modules of a system."},
+		{"tool", "Bunch clusters the modules of a system."},
+		{`"search space"`, "The <b>search space</b> of modular structures."},
+		{"parnas", "<b>Parnas</b> (1972), see Bunch"},
+	}
+	idx := search.NewIndex(pubs)
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			q, err := search.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results := idx.Search(q, 1)
+			if len(results) != 1 || results[0].Snippet != tt.want {
+				t.Errorf("got %+v, want the snippet %q", results, tt.want)
+			}
+		})
+	}
+}
+
+// TestMemoryRebuilds checks that Memory loads the publications again only
+// when the generation changes
+func TestMemoryRebuilds(t *testing.T) {
+	gen, loads := int64(1), 0
+	current := pubs[:1]
+	m := search.NewMemory(
+		func(context.Context) (int64, error) { return gen, nil },
+		func(context.Context) ([]schema.Publication, error) {
+			loads++
+			return current, nil
+		})
+	q, _ := search.ParseQuery("search")
+
+	for i, step := range []struct {
+		gen       int64
+		pubs      []schema.Publication
+		wantIDs   []int
+		wantLoads int
+	}{
+		{gen: 1, pubs: pubs[:1], wantLoads: 1},
+		{gen: 1, pubs: pubs, wantLoads: 1},
+		{gen: 2, pubs: pubs, wantIDs: []int{2, 3}, wantLoads: 2},
+	} {
+		gen, current = step.gen, step.pubs
+		results, err := m.Search(context.Background(), q, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, r := range results {
+			ids = append(ids, r.ID)
+		}
+		if !reflect.DeepEqual(ids, step.wantIDs) || loads != step.wantLoads {
+			t.Errorf("step %d: got %v after %d loads, want %v after %d", i, ids, loads, step.wantIDs, step.wantLoads)
+		}
+	}
+}
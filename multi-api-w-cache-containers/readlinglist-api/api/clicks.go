@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// GET /publists/:id/:idx/paper redirects to the paper of a publication.
+// The redirect is temporary by default, a browser caches a 301 for good
+// and would miss a new link.  Every redirect is counted under
+// pub-clicks:<pub id>, a hash of the total, the time of the last click and
+// the clicks from every list, eg list:2, which GET /pubs/:id/links of the
+// publication api reports.
+
+const (
+	DefaultRedirectStatus = http.StatusFound
+	clickKeyPrefix        = "pub-clicks:"
+)
+
+// SetRedirectStatus sets the status of the redirects to the papers, one of
+// 301, 302, 303, 307 or 308
+func (r *ReadingListAPI) SetRedirectStatus(status int) error {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		r.redirectStatus = status
+		return nil
+	default:
+		return fmt.Errorf("%d is not a redirect status, use 301, 302, 303, 307 or 308", status)
+	}
+}
+
+// recordClick counts a redirect to the paper of publication pubId from
+// the list rlId.  The redirect does not wait for a failure to be fixed, it
+// is only logged.
+func (r *ReadingListAPI) recordClick(rlId string, pubId int) {
+	key := clickKeyPrefix + strconv.Itoa(pubId)
+	_, err := r.client.Pipelined(r.context, func(pipe redis.Pipeliner) error {
+		pipe.HIncrBy(r.context, key, "total", 1)
+		pipe.HIncrBy(r.context, key, "list:"+rlId, 1)
+		pipe.HSet(r.context, key, "last", time.Now().UTC().Format(time.RFC3339))
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error counting the click on publication %d: %s", pubId, err)
+	}
+}
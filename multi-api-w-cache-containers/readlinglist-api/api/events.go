@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/go-redis/redis/v8"
+)
+
+// The publication api publishes every change to the publications on the
+// pub-events redis stream, with the fields
+//
+//	type   created, updated, deleted or moved
+//	id     the id of the publication
+//	path   its path, eg /pubs/10
+//	to     for moved, the new path
+//
+// The api reads them in the readinglist-api consumer group, so only one
+// replica handles an event, and keeps the items of its lists in order:
+// a moved publication has its items rewritten to the new path, a deleted
+// one has them marked as dangling, or pruned from the lists, and a
+// dangling item that gets its publication back is unmarked.
+const (
+	pubEventStream = "pub-events"
+	pubEventGroup  = "readinglist-api"
+	eventBatch     = 100
+	eventBlock     = 5 * time.Second
+	eventRetry     = 5 * time.Second
+)
+
+// What to do with the items of a deleted publication
+const (
+	OnDeleteMark  = "mark"
+	OnDeletePrune = "prune"
+)
+
+// StartEventConsumer reads the change events of the publication api until
+// the returned function is called.  onDelete is OnDeleteMark or
+// OnDeletePrune.
+func (r *ReadingListAPI) StartEventConsumer(onDelete string) (stop func(), err error) {
+	if onDelete != OnDeleteMark && onDelete != OnDeletePrune {
+		return nil, fmt.Errorf("on delete %q is neither %s nor %s", onDelete, OnDeleteMark, OnDeletePrune)
+	}
+
+	//a new group starts with the oldest event the stream has, the lists
+	//may have been loaded before the publications changed
+	err = r.client.XGroupCreateMkStream(r.context, pubEventStream, pubEventGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil, err
+	}
+
+	consumer, err := os.Hostname()
+	if err != nil {
+		consumer = "readinglist-api"
+	}
+
+	ctx, cancel := context.WithCancel(r.context)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.consumeEvents(ctx, consumer, onDelete)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
+
+func (r *ReadingListAPI) consumeEvents(ctx context.Context, consumer string, onDelete string) {
+	log.Printf("Reading the publication events as %s/%s", pubEventGroup, consumer)
+
+	//"0" reads the events this consumer was handed before but did not
+	//acknowledge, eg because it stopped, ">" the new ones
+	start := "0"
+	for ctx.Err() == nil {
+		streams, err := r.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    pubEventGroup,
+			Consumer: consumer,
+			Streams:  []string{pubEventStream, start},
+			Count:    eventBatch,
+			Block:    eventBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue //nothing new within eventBlock
+		}
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Println("Error reading the publication events: ", err)
+				sleepCtx(ctx, eventRetry)
+			}
+			continue
+		}
+
+		var messages []redis.XMessage
+		if len(streams) > 0 {
+			messages = streams[0].Messages
+		}
+		if start == "0" && len(messages) == 0 {
+			start = ">"
+			continue
+		}
+		for _, msg := range messages {
+			if err := r.applyEvent(msg.Values, onDelete); err != nil {
+				//left unacknowledged, it is read again with the
+				//other pending events
+				log.Printf("Error handling the publication event %s: %s", msg.ID, err)
+				start = "0"
+				sleepCtx(ctx, eventRetry)
+				break
+			}
+			if err := r.client.XAck(r.context, pubEventStream, pubEventGroup, msg.ID).Err(); err != nil {
+				log.Printf("Error acknowledging the publication event %s: %s", msg.ID, err)
+			}
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// applyEvent brings the items that point to the publication of an event
+// in line with it.  Every list is changed with updateReadingList, so an
+// edit a user makes while the events are handled is not overwritten.
+func (r *ReadingListAPI) applyEvent(values map[string]interface{}, onDelete string) error {
+	eventType, _ := values["type"].(string)
+	path, _ := values["path"].(string)
+	to, _ := values["to"].(string)
+	switch {
+	case path == "":
+		return nil //not an event we know, nothing to do
+	case eventType == "moved" && to == "":
+		return nil
+	}
+	r.pubs.forget(path)
+
+	lists, err := r.loadReadingLists()
+	if err != nil {
+		return err
+	}
+	for _, rl := range lists {
+		if !pointsTo(rl, path) {
+			continue
+		}
+		_, err := r.updateReadingList(rl.ID, func(rl *schema.ReadingList) error {
+			if !applyToItems(rl, eventType, path, to, onDelete) {
+				return errUnchanged
+			}
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			continue //deleted since it was read
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Reading list %d: publication %s %s", rl.ID, path, eventType)
+	}
+	return nil
+}
+
+// pointsTo tells if an item of rl has path
+func pointsTo(rl schema.ReadingList, path string) bool {
+	for _, item := range rl.Items {
+		if item.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// applyToItems changes the items of rl with path for an event, it tells
+// if any of them changed
+func applyToItems(rl *schema.ReadingList, eventType, path, to, onDelete string) bool {
+	changed := false
+	//backwards, so pruning an item does not skip the next one
+	for i := len(rl.Items) - 1; i >= 0; i-- {
+		item := &rl.Items[i]
+		if item.Path != path {
+			continue
+		}
+		switch {
+		case (eventType == "created" || eventType == "updated") && item.Dangling:
+			item.Dangling = false
+		case eventType == "deleted" && onDelete == OnDeletePrune:
+			rl.Items = append(rl.Items[:i], rl.Items[i+1:]...)
+		case eventType == "deleted" && !item.Dangling:
+			item.Dangling = true
+		case eventType == "moved":
+			item.Path = to
+			item.Dangling = false
+		default:
+			continue
+		}
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestApplyEvent(t *testing.T) {
+	lists := map[string]string{
+		"publist:1": `{"id":1,"description":"one","items":[{"key":"a","path":"/pubs/1"},{"key":"b","path":"/pubs/2"},{"key":"c","path":"/pubs/1"}]}`,
+		"publist:2": `{"id":2,"description":"two","items":[{"key":"d","path":"/pubs/2","dangling":true}]}`,
+		"publist:3": `{"id":3,"description":"legacy","items":{"e":"/pubs/1"}}`,
+	}
+	tests := []struct {
+		name     string
+		event    map[string]interface{}
+		onDelete string
+		want     map[int][]string
+	}{
+		{
+			name:     "a deleted publication marks its items",
+			event:    map[string]interface{}{"type": "deleted", "id": "1", "path": "/pubs/1"},
+			onDelete: OnDeleteMark,
+			want: map[int][]string{
+				1: {"a /pubs/1 dangling", "b /pubs/2", "c /pubs/1 dangling"},
+				2: {"d /pubs/2 dangling"},
+				3: {"e /pubs/1 dangling"},
+			},
+		},
+		{
+			name:     "a deleted publication prunes its items",
+			event:    map[string]interface{}{"type": "deleted", "id": "1", "path": "/pubs/1"},
+			onDelete: OnDeletePrune,
+			want: map[int][]string{
+				1: {"b /pubs/2"},
+				2: {"d /pubs/2 dangling"},
+				3: {},
+			},
+		},
+		{
+			name:     "a moved publication moves its items",
+			event:    map[string]interface{}{"type": "moved", "id": "2", "path": "/pubs/2", "to": "/pubs/20"},
+			onDelete: OnDeleteMark,
+			want: map[int][]string{
+				1: {"a /pubs/1", "b /pubs/20", "c /pubs/1"},
+				2: {"d /pubs/20"},
+				3: {"e /pubs/1"},
+			},
+		},
+		{
+			name:     "a publication that is back unmarks its items",
+			event:    map[string]interface{}{"type": "created", "id": "2", "path": "/pubs/2"},
+			onDelete: OnDeleteMark,
+			want: map[int][]string{
+				1: {"a /pubs/1", "b /pubs/2", "c /pubs/1"},
+				2: {"d /pubs/2"},
+				3: {"e /pubs/1"},
+			},
+		},
+		{
+			name:     "an event without a path changes nothing",
+			event:    map[string]interface{}{"type": "deleted"},
+			onDelete: OnDeletePrune,
+			want: map[int][]string{
+				1: {"a /pubs/1", "b /pubs/2", "c /pubs/1"},
+				2: {"d /pubs/2 dangling"},
+				3: {"e /pubs/1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, lists)
+			if err := a.applyEvent(tt.event, tt.onDelete); err != nil {
+				t.Fatal(err)
+			}
+			for id, want := range tt.want {
+				if got := keysOf(a.list(t, id)); !reflect.DeepEqual(got, want) {
+					t.Errorf("list %d: got %q, want %q", id, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestEventConsumer publishes an event on the stream and waits for the
+// consumer to apply and acknowledge it
+func TestEventConsumer(t *testing.T) {
+	a := newTestAPI(t, map[string]string{"publist:1": twoItems})
+	stop, err := a.StartEventConsumer(OnDeleteMark)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	err = a.client.XAdd(a.context, &redis.XAddArgs{
+		Stream: pubEventStream,
+		Values: map[string]interface{}{"type": "deleted", "id": "2", "path": "/pubs/2"},
+	}).Err()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"pub1 /pubs/1", "pub2 /pubs/2 dangling"}
+	deadline := time.Now().Add(5 * time.Second)
+	for !reflect.DeepEqual(keysOf(a.list(t, 1)), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q, want %q", keysOf(a.list(t, 1)), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for {
+		pending, err := a.client.XPending(a.context, pubEventStream, pubEventGroup).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pending.Count == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d events are not acknowledged", pending.Count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
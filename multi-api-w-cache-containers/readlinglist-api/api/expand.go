@@ -0,0 +1,211 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /publists/:id?expand=pubs answers with the publication of every
+// item, fetched from the publication api in parallel, at most
+// maxConcurrentFetches at a time.  An item whose publication could not be
+// fetched gets an error instead, the other items are still answered.
+
+const (
+	maxConcurrentFetches = 8
+	DefaultPubCacheTTL   = time.Minute
+)
+
+// ExpandedReadingList is a reading list with the publications of its items
+type ExpandedReadingList struct {
+	ID          int            `json:"id"`
+	Description string         `json:"description"`
+	Items       []ExpandedItem `json:"items"`
+}
+
+// ExpandedItem is an item with its publication, or with the error that
+// kept it from being fetched
+type ExpandedItem struct {
+	schema.ReadingListItem
+	Publication *schema.Publication `json:"publication,omitempty"`
+	Error       *ItemError          `json:"error,omitempty"`
+}
+
+// ItemError tells why the publication of an item is missing, Status is
+// 404 when the publication api does not have it and 502 when the
+// publication api failed
+type ItemError struct {
+	Status int    `json:"status"`
+	Detail string `json:"detail"`
+}
+
+func (r *ReadingListAPI) expandReadingList(rl schema.ReadingList) ExpandedReadingList {
+	paths := make([]string, 0, len(rl.Items))
+	for _, item := range rl.Items {
+		paths = append(paths, item.Path)
+	}
+	fetched := r.fetchPublications(paths, r.cachedPublication)
+
+	expanded := ExpandedReadingList{ID: rl.ID, Description: rl.Description, Items: make([]ExpandedItem, 0, len(rl.Items))}
+	for _, item := range rl.Items {
+		ei := ExpandedItem{ReadingListItem: item}
+		res := fetched[item.Path]
+		if ei.Error = itemError(res.err); ei.Error == nil {
+			pub := res.pub
+			ei.Publication = &pub
+		}
+		expanded.Items = append(expanded.Items, ei)
+	}
+	return expanded
+}
+
+// itemError is the ItemError for the error of fetching a publication, nil
+// if there was none
+func itemError(err error) *ItemError {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrNotFound):
+		return &ItemError{Status: http.StatusNotFound, Detail: err.Error()}
+	default:
+		return &ItemError{Status: http.StatusBadGateway, Detail: err.Error()}
+	}
+}
+
+type fetchResult struct {
+	pub schema.Publication
+	err error
+}
+
+// fetchPublications gets the publications at paths with fetch, every path
+// once, with at most maxConcurrentFetches requests to the publication api
+// at a time
+func (r *ReadingListAPI) fetchPublications(paths []string, fetch func(path string) (schema.Publication, error)) map[string]fetchResult {
+	unique := uniquePaths(paths)
+	//every goroutine fills in its own element, they need no lock
+	fetched := make([]fetchResult, len(unique))
+	inParallel(unique, func(i int, path string) {
+		fetched[i].pub, fetched[i].err = fetch(path)
+	})
+
+	results := make(map[string]fetchResult, len(unique))
+	for i, path := range unique {
+		results[path] = fetched[i]
+	}
+	return results
+}
+
+// uniquePaths returns paths without the repeats, in their order
+func uniquePaths(paths []string) []string {
+	var unique []string
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if !seen[path] {
+			seen[path] = true
+			unique = append(unique, path)
+		}
+	}
+	return unique
+}
+
+// inParallel calls do for every path, at most maxConcurrentFetches at a
+// time, and returns when they are all done
+func inParallel(paths []string, do func(i int, path string)) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, maxConcurrentFetches)
+	for i, path := range paths {
+		wg.Add(1)
+		go func(i int, path string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			do(i, path)
+		}(i, path)
+	}
+	wg.Wait()
+}
+
+// cachedPublication is getPublication with the pubCache in front of it,
+// only the publications that were found are cached
+func (r *ReadingListAPI) cachedPublication(path string) (schema.Publication, error) {
+	if pub, ok := r.pubs.get(path); ok {
+		return pub, nil
+	}
+	pub, err := r.getPublication(path)
+	if err != nil {
+		return pub, err
+	}
+	r.pubs.put(path, pub)
+	return pub, nil
+}
+
+// pubCache keeps the publications fetched from the publication api for
+// ttl, a ttl of 0 turns it off
+type pubCache struct {
+	mu   sync.Mutex
+	ttl  time.Duration
+	pubs map[string]cachedPub
+}
+
+type cachedPub struct {
+	pub     schema.Publication
+	expires time.Time
+}
+
+func newPubCache(ttl time.Duration) *pubCache {
+	return &pubCache{ttl: ttl, pubs: make(map[string]cachedPub)}
+}
+
+func (pc *pubCache) get(path string) (schema.Publication, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	cached, ok := pc.pubs[path]
+	if !ok {
+		return schema.Publication{}, false
+	}
+	if time.Now().After(cached.expires) {
+		delete(pc.pubs, path)
+		return schema.Publication{}, false
+	}
+	return cached.pub, true
+}
+
+func (pc *pubCache) put(path string, pub schema.Publication) {
+	if pc.ttl <= 0 {
+		return
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	now := time.Now()
+	for p, cached := range pc.pubs {
+		if now.After(cached.expires) {
+			delete(pc.pubs, p)
+		}
+	}
+	pc.pubs[path] = cachedPub{pub: pub, expires: now.Add(pc.ttl)}
+}
+
+func (pc *pubCache) forget(path string) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	delete(pc.pubs, path)
+}
+
+// expandParam reads ?expand=, which only knows pubs
+func expandParam(c *gin.Context) (bool, error) {
+	switch c.Query("expand") {
+	case "":
+		return false, nil
+	case "pubs":
+		return true, nil
+	default:
+		return false, fmt.Errorf("expand %q is not supported, only expand=pubs is", c.Query("expand"))
+	}
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"architectingsoftware.com/reading-list-api/schema"
+)
+
+// mixed has the publications 1 and 2, 9 which the publication api does not
+// have, and 1 again
+const mixed = `{"id":1,"description":"mixed","items":[{"key":"a","path":"/pubs/1"},{"key":"b","path":"/pubs/2"},{"key":"c","path":"/pubs/9"},{"key":"d","path":"/pubs/1"}]}`
+
+// expandedOf sums up the items of an expanded list, the title of the
+// publication or the status of the error, eg "a Bunch" or "c 404"
+func expandedOf(rl ExpandedReadingList) []string {
+	items := []string{}
+	for _, item := range rl.Items {
+		switch {
+		case item.Publication != nil:
+			items = append(items, item.Key+" "+item.Publication.Title)
+		case item.Error != nil:
+			items = append(items, item.Key+" "+http.StatusText(item.Error.Status))
+		default:
+			items = append(items, item.Key)
+		}
+	}
+	return items
+}
+
+func TestExpandReadingList(t *testing.T) {
+	tests := []struct {
+		name         string
+		fail         bool
+		wantItems    []string
+		wantRequests int
+	}{
+		{
+			name:         "a missing publication",
+			wantItems:    []string{"a Bunch", "b Search Based", "c Not Found", "d Bunch"},
+			wantRequests: 3,
+		},
+		{
+			name:         "a failing publication api",
+			fail:         true,
+			wantItems:    []string{"a Bad Gateway", "b Bad Gateway", "c Bad Gateway", "d Bad Gateway"},
+			wantRequests: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, map[string]string{"publist:1": mixed})
+			a.pubs.set(tt.fail)
+
+			//the items that could be fetched are answered, the others
+			//carry their error
+			var expanded ExpandedReadingList
+			expect(t, a.do(t, http.MethodGet, "/publists/1?expand=pubs", nil), http.StatusOK, &expanded)
+			if got := expandedOf(expanded); !reflect.DeepEqual(got, tt.wantItems) {
+				t.Errorf("got %q, want %q", got, tt.wantItems)
+			}
+			if got := a.pubs.count(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestExpandParam(t *testing.T) {
+	a := newTestAPI(t, map[string]string{"publist:1": mixed})
+	expect(t, a.do(t, http.MethodGet, "/publists/1?expand=authors", nil), http.StatusBadRequest, nil)
+
+	var rl schema.ReadingList
+	expect(t, a.do(t, http.MethodGet, "/publists/1", nil), http.StatusOK, &rl)
+	if len(rl.Items) != 4 || a.pubs.count() != 0 {
+		t.Errorf("got %+v after %d requests", rl, a.pubs.count())
+	}
+}
+
+// TestExpandCache expands a list again, the publications that were found
+// come from the cache until their ttl is over
+func TestExpandCache(t *testing.T) {
+	a := newTestAPI(t, map[string]string{"publist:1": mixed})
+	var expanded ExpandedReadingList
+	expect(t, a.do(t, http.MethodGet, "/publists/1?expand=pubs", nil), http.StatusOK, &expanded)
+
+	//the publication api forgets 2, which is still cached, 9 is asked for
+	//again
+	a.pubs.set(false, 2)
+	expanded = ExpandedReadingList{}
+	expect(t, a.do(t, http.MethodGet, "/publists/1?expand=pubs", nil), http.StatusOK, &expanded)
+	if got, want := expandedOf(expanded), []string{"a Bunch", "b Search Based", "c Not Found", "d Bunch"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := a.pubs.count(); got != 4 {
+		t.Errorf("got %d requests, want 4", got)
+	}
+
+	//once the ttl is over 2 is gone
+	cache := a.ReadingListAPI.pubs
+	for path, cached := range cache.pubs {
+		cached.expires = time.Now().Add(-time.Second)
+		cache.pubs[path] = cached
+	}
+	expanded = ExpandedReadingList{}
+	expect(t, a.do(t, http.MethodGet, "/publists/1?expand=pubs", nil), http.StatusOK, &expanded)
+	if got, want := expandedOf(expanded), []string{"a Bunch", "b Not Found", "c Not Found", "d Bunch"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPubCache(t *testing.T) {
+	pub := schema.Publication{ID: 1, Title: "Bunch"}
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		age    time.Duration // how long ago the publication was put
+		wantOk bool
+	}{
+		{name: "fresh", ttl: time.Minute, wantOk: true},
+		{name: "expired", ttl: time.Minute, age: 2 * time.Minute, wantOk: false},
+		{name: "turned off", ttl: 0, wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc := newPubCache(tt.ttl)
+			pc.put("/pubs/1", pub)
+			if cached, ok := pc.pubs["/pubs/1"]; ok {
+				cached.expires = cached.expires.Add(-tt.age)
+				pc.pubs["/pubs/1"] = cached
+			}
+
+			got, ok := pc.get("/pubs/1")
+			if ok != tt.wantOk || (ok && !reflect.DeepEqual(got, pub)) {
+				t.Errorf("got %+v, %t, want %t", got, ok, tt.wantOk)
+			}
+			if !tt.wantOk && len(pc.pubs) != 0 {
+				t.Errorf("still holds %d publications", len(pc.pubs))
+			}
+		})
+	}
+}
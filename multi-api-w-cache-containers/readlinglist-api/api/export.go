@@ -0,0 +1,302 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"problem"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"architectingsoftware.com/reading-list-api/pdf"
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /publists/:id/export?format= writes a reading list as a
+// bibliography, in the order of its items: the title, the citation, the
+// link to the paper and the links to the slides of every publication, and
+// the note of the item.  An item whose publication is missing is noted as
+// missing, any other failure of the publication api fails the export.
+// The BibTeX entries come from the publication api, which has the BibTeX
+// writer, the other formats are rendered here.
+
+const bibTeXContentType = "application/x-bibtex"
+
+type exportFormat struct {
+	contentType string
+	extension   string
+	render      func(r *ReadingListAPI, rl schema.ReadingList) ([]byte, error)
+}
+
+var exportFormats = map[string]exportFormat{
+	"bibtex":   {contentType: bibTeXContentType + "; charset=utf-8", extension: "bib", render: (*ReadingListAPI).exportBibTeX},
+	"markdown": {contentType: "text/markdown; charset=utf-8", extension: "md", render: (*ReadingListAPI).exportMarkdown},
+	"html":     {contentType: "text/html; charset=utf-8", extension: "html", render: (*ReadingListAPI).exportHTML},
+	"pdf":      {contentType: "application/pdf", extension: "pdf", render: (*ReadingListAPI).exportPDF},
+}
+
+// ExportReadingList answers with the reading list as a document in the
+// format of ?format=, as an attachment named after the list
+func (r *ReadingListAPI) ExportReadingList(c *gin.Context) {
+	format, ok := exportFormats[c.Query("format")]
+	if !ok {
+		names := make([]string, 0, len(exportFormats))
+		for name := range exportFormats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		problem.AbortBadRequest(c, fmt.Errorf("format %q is not one of %s", c.Query("format"), strings.Join(names, ", ")))
+		return
+	}
+
+	rl, ok := r.readingListFromPath(c)
+	if !ok {
+		return
+	}
+
+	doc, err := format.render(r, rl)
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, exportName(rl), format.extension))
+	c.Data(http.StatusOK, format.contentType, doc)
+}
+
+// exportName is the file name of an exported list, its description in
+// lower case ASCII with dashes, eg cloud-native-papers
+func exportName(rl schema.ReadingList) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(rl.Description) {
+		switch {
+		case (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9'):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		case c > unicode.MaxASCII && unicode.IsLetter(c):
+			//left out, eg the ü of Müller, which is not a word break
+		default:
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "publist-" + strconv.Itoa(rl.ID)
+	}
+	return b.String()
+}
+
+// exportEntry is an item of the list with its publication, nil when the
+// publication api does not have it
+type exportEntry struct {
+	Item schema.ReadingListItem
+	Pub  *schema.Publication
+}
+
+// exportEntries fetches the publication of every item
+func (r *ReadingListAPI) exportEntries(rl schema.ReadingList) ([]exportEntry, error) {
+	paths := make([]string, 0, len(rl.Items))
+	for _, item := range rl.Items {
+		paths = append(paths, item.Path)
+	}
+	fetched := r.fetchPublications(paths, r.cachedPublication)
+
+	entries := make([]exportEntry, 0, len(rl.Items))
+	for _, item := range rl.Items {
+		res := fetched[item.Path]
+		switch {
+		case res.err == nil:
+			pub := res.pub
+			entries = append(entries, exportEntry{Item: item, Pub: &pub})
+		case errors.Is(res.err, ErrNotFound):
+			entries = append(entries, exportEntry{Item: item})
+		default:
+			return nil, res.err
+		}
+	}
+	return entries, nil
+}
+
+func (r *ReadingListAPI) exportBibTeX(rl schema.ReadingList) ([]byte, error) {
+	var paths []string
+	for _, item := range rl.Items {
+		paths = append(paths, item.Path)
+	}
+	//a publication that is in the list twice is in the file once, BibTeX
+	//does not allow the same key twice
+	paths = uniquePaths(paths)
+	entries := make([]string, len(paths))
+	errs := make([]error, len(paths))
+	inParallel(paths, func(i int, path string) {
+		entries[i], errs[i] = r.getPublicationBibTeX(path)
+	})
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%% %s\n%% reading list %d\n", bibTeXComment(rl.Description), rl.ID)
+	for i, path := range paths {
+		b.WriteString("\n")
+		switch {
+		case errs[i] == nil:
+			b.WriteString(strings.TrimSpace(entries[i]) + "\n")
+		case errors.Is(errs[i], ErrNotFound):
+			fmt.Fprintf(&b, "%% missing publication %s\n", path)
+		default:
+			return nil, errs[i]
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// bibTeXComment keeps text on its comment line
+func bibTeXComment(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func (r *ReadingListAPI) exportMarkdown(rl schema.ReadingList) ([]byte, error) {
+	entries, err := r.exportEntries(rl)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n\n", markdownEscape(rl.Description))
+	for i, e := range entries {
+		//the lines of an item are indented under its number, and end with
+		//two spaces, a line break in markdown
+		var lines []string
+		if e.Pub == nil {
+			lines = append(lines, fmt.Sprintf("*Missing publication %s*", e.Item.Path))
+		} else {
+			lines = append(lines, "**"+markdownEscape(e.Pub.Title)+"**")
+			if e.Pub.Cite != "" {
+				lines = append(lines, markdownEscape(e.Pub.Cite))
+			}
+			if e.Pub.Link != "" {
+				lines = append(lines, fmt.Sprintf("[Paper](<%s>)", e.Pub.Link))
+			}
+			if len(e.Pub.Slides) > 0 {
+				slides := make([]string, 0, len(e.Pub.Slides))
+				for _, s := range e.Pub.Slides {
+					slides = append(slides, fmt.Sprintf("[%s](<%s>)", markdownEscape(slideText(s.Type, s.Description)), s.Link))
+				}
+				lines = append(lines, "Slides: "+strings.Join(slides, ", "))
+			}
+		}
+		if e.Item.Note != "" {
+			lines = append(lines, "*Note: "+markdownEscape(e.Item.Note)+"*")
+		}
+
+		prefix := fmt.Sprintf("%d. ", i+1)
+		indent := strings.Repeat(" ", len(prefix))
+		b.WriteString(prefix + strings.Join(lines, "  \n"+indent) + "\n")
+	}
+	return b.Bytes(), nil
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`, `>`, `\>`, `#`, `\#`,
+)
+
+func markdownEscape(text string) string {
+	return markdownEscaper.Replace(strings.Join(strings.Fields(text), " "))
+}
+
+func slideText(slideType string, description string) string {
+	if description == "" {
+		return slideType
+	}
+	return slideType + ": " + description
+}
+
+var exportTemplate = template.Must(template.New("export").Funcs(template.FuncMap{"slideText": slideText}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; line-height: 1.4; }
+li { margin-bottom: 1em; }
+.cite { color: #444; }
+.note { font-style: italic; }
+.missing { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ol>
+{{- range .Entries}}
+<li>
+{{- if .Pub}}
+<strong>{{.Pub.Title}}</strong>
+{{- if .Pub.Cite}}<br><span class="cite">{{.Pub.Cite}}</span>{{end}}
+{{- if .Pub.Link}}<br><a href="{{.Pub.Link}}">Paper</a>{{end}}
+{{- if .Pub.Slides}}<br>Slides: {{range $i, $s := .Pub.Slides}}{{if $i}}, {{end}}<a href="{{$s.Link}}">{{slideText $s.Type $s.Description}}</a>{{end}}{{end}}
+{{- else}}
+<span class="missing">Missing publication {{.Item.Path}}</span>
+{{- end}}
+{{- if .Item.Note}}<br><span class="note">Note: {{.Item.Note}}</span>{{end}}
+</li>
+{{- end}}
+</ol>
+</body>
+</html>
+`))
+
+func (r *ReadingListAPI) exportHTML(rl schema.ReadingList) ([]byte, error) {
+	entries, err := r.exportEntries(rl)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	err = exportTemplate.Execute(&b, struct {
+		Title   string
+		Entries []exportEntry
+	}{rl.Description, entries})
+	return b.Bytes(), err
+}
+
+func (r *ReadingListAPI) exportPDF(rl schema.ReadingList) ([]byte, error) {
+	entries, err := r.exportEntries(rl)
+	if err != nil {
+		return nil, err
+	}
+
+	const indent = 18
+	doc := pdf.New(rl.Description)
+	doc.Paragraph(rl.Description, pdf.Style{Size: 18, Bold: true})
+	doc.Space(12)
+	for i, e := range entries {
+		number := strconv.Itoa(i+1) + ". "
+		if e.Pub == nil {
+			doc.Paragraph(number+"Missing publication "+e.Item.Path, pdf.Style{Size: 11})
+		} else {
+			doc.Paragraph(number+e.Pub.Title, pdf.Style{Size: 11, Bold: true})
+			if e.Pub.Cite != "" {
+				doc.Paragraph(e.Pub.Cite, pdf.Style{Size: 10, Indent: indent})
+			}
+			if e.Pub.Link != "" {
+				doc.Paragraph("Paper: "+e.Pub.Link, pdf.Style{Size: 10, Indent: indent, URL: e.Pub.Link})
+			}
+			for _, s := range e.Pub.Slides {
+				doc.Paragraph("Slides, "+slideText(s.Type, s.Description), pdf.Style{Size: 10, Indent: indent, URL: s.Link})
+			}
+		}
+		if e.Item.Note != "" {
+			doc.Paragraph("Note: "+e.Item.Note, pdf.Style{Size: 10, Indent: indent})
+		}
+		doc.Space(8)
+	}
+
+	var b bytes.Buffer
+	_, err = doc.WriteTo(&b)
+	return b.Bytes(), err
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"architectingsoftware.com/reading-list-api/schema"
+)
+
+// noted has the publications 1 and 3, 9 which the publication api does not
+// have, and 1 again, with a note on the first item
+const noted = `{"id":4,"description":"Cloud <native> papers","items":[{"key":"a","path":"/pubs/1","note":"read *first*"},{"key":"b","path":"/pubs/3"},{"key":"c","path":"/pubs/9"},{"key":"d","path":"/pubs/1"}]}`
+
+func TestExportReadingList(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		fail            bool
+		wantStatus      int
+		wantContentType string
+		wantFile        string
+		want            []string // in this order
+		notWant         []string
+	}{
+		{
+			name:            "bibtex",
+			path:            "/publists/4/export?format=bibtex",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/x-bibtex; charset=utf-8",
+			wantFile:        "cloud-native-papers.bib",
+			want:            []string{"% Cloud <native> papers\n% reading list 4\n", "@article{pub1,", "@article{pub3,", "% missing publication /pubs/9"},
+		},
+		{
+			name:            "markdown",
+			path:            "/publists/4/export?format=markdown",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/markdown; charset=utf-8",
+			wantFile:        "cloud-native-papers.md",
+			want: []string{
+				"# Cloud \\<native\\> papers\n",
+				"1. **Bunch**  \n   Mitchell (2006)  \n   [Paper](<https://example.org/bunch.pdf>)  \n   *Note: read \\*first\\**\n",
+				"2. **Modularity**",
+				"3. *Missing publication /pubs/9*\n",
+				"4. **Bunch**",
+			},
+		},
+		{
+			name:            "html",
+			path:            "/publists/4/export?format=html",
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantFile:        "cloud-native-papers.html",
+			want: []string{
+				"<title>Cloud &lt;native&gt; papers</title>",
+				"<strong>Bunch</strong>",
+				`<a href="https://example.org/bunch.pdf">Paper</a>`,
+				`<span class="note">Note: read *first*</span>`,
+				"<strong>Modularity</strong>",
+				`<span class="missing">Missing publication /pubs/9</span>`,
+			},
+			notWant: []string{"<native>"},
+		},
+		{
+			name:            "pdf",
+			path:            "/publists/4/export?format=pdf",
+			wantStatus:      http.StatusOK,
+			wantContentType: "application/pdf",
+			wantFile:        "cloud-native-papers.pdf",
+			want:            []string{"%PDF-", "%%EOF"},
+		},
+		{
+			name:       "an unknown format",
+			path:       "/publists/4/export?format=docx",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "a missing list",
+			path:       "/publists/7/export?format=markdown",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "a failing publication api",
+			path:       "/publists/4/export?format=markdown",
+			fail:       true,
+			wantStatus: http.StatusInternalServerError,
+		},
+		{
+			name:       "a failing publication api for bibtex",
+			path:       "/publists/4/export?format=bibtex",
+			fail:       true,
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, map[string]string{"publist:4": noted})
+			a.pubs.set(tt.fail)
+
+			w := a.do(t, http.MethodGet, tt.path, nil)
+			expect(t, w, tt.wantStatus, nil)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("got Content-Type %q, want %q", got, tt.wantContentType)
+			}
+			if got, want := w.Header().Get("Content-Disposition"), `attachment; filename="`+tt.wantFile+`"`; got != want {
+				t.Errorf("got Content-Disposition %q, want %q", got, want)
+			}
+			doc := w.Body.String()
+			at := 0
+			for _, want := range tt.want {
+				i := strings.Index(doc[at:], want)
+				if i < 0 {
+					t.Fatalf("%q is missing after byte %d of\n%s", want, at, doc)
+				}
+				at += i + len(want)
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(doc, notWant) {
+					t.Errorf("%q is in\n%s", notWant, doc)
+				}
+			}
+		})
+	}
+}
+
+// TestExportBibTeXOnce has a publication that is in the list twice once in
+// the BibTeX file, the same key twice is an error in BibTeX
+func TestExportBibTeXOnce(t *testing.T) {
+	a := newTestAPI(t, map[string]string{"publist:4": noted})
+	w := a.do(t, http.MethodGet, "/publists/4/export?format=bibtex", nil)
+	expect(t, w, http.StatusOK, nil)
+	if n := strings.Count(w.Body.String(), "@article{pub1,"); n != 1 {
+		t.Errorf("pub1 is in the file %d times\n%s", n, w.Body.String())
+	}
+}
+
+func TestExportName(t *testing.T) {
+	tests := []struct {
+		description string
+		want        string
+	}{
+		{"Cloud Native Papers", "cloud-native-papers"},
+		{"  SE -- 2024!  ", "se-2024"},
+		{"Müller's reading", "mller-s-reading"},
+		{"???", "publist-3"},
+		{"", "publist-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if got := exportName(schema.ReadingList{ID: 3, Description: tt.description}); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
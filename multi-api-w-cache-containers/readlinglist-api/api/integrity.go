@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IntegrityReport is what GET /publists/:id/integrity answers, the items
+// of the list whose publication the publication api does not serve
+type IntegrityReport struct {
+	ID      int          `json:"id"`
+	Checked int          `json:"checked"`
+	Broken  []BrokenItem `json:"broken"`
+}
+
+// BrokenItem is an item whose publication could not be fetched, see
+// ItemError for the status
+type BrokenItem struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+	ItemError
+}
+
+// GetReadingListIntegrity asks the publication api for the publication of
+// every item of a list, past the cache of the api, and reports the items
+// that are broken.  The change events mark most of them as dangling, this
+// also finds the ones that broke while the api did not listen.
+func (r *ReadingListAPI) GetReadingListIntegrity(c *gin.Context) {
+	rl, ok := r.readingListFromPath(c)
+	if !ok {
+		return
+	}
+
+	paths := make([]string, 0, len(rl.Items))
+	for _, item := range rl.Items {
+		paths = append(paths, item.Path)
+	}
+	fetched := r.fetchPublications(paths, r.getPublication)
+
+	report := IntegrityReport{ID: rl.ID, Checked: len(rl.Items), Broken: []BrokenItem{}}
+	for _, item := range rl.Items {
+		if ie := itemError(fetched[item.Path].err); ie != nil {
+			report.Broken = append(report.Broken, BrokenItem{Key: item.Key, Path: item.Path, ItemError: *ie})
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
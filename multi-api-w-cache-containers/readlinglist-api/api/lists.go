@@ -0,0 +1,522 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"problem"
+	"strconv"
+	"strings"
+	"validate"
+
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/nitishm/go-rejson/v4/rjs"
+)
+
+// ItemPatch is the body of PATCH /publists/:id/items/:key, the fields that
+// are left out keep their value
+type ItemPatch struct {
+	Note *string `json:"note"`
+	Read *bool   `json:"read"`
+}
+
+// Order is the body of PUT /publists/:id/order, the keys of every item of
+// the list in their new order
+type Order struct {
+	Keys []string `json:"keys"`
+}
+
+// CreateReadingList adds a new reading list, the id is handed out by the
+// api.  Every item has to point to a publication the publication api has.
+func (r *ReadingListAPI) CreateReadingList(c *gin.Context) {
+	var rl schema.ReadingList
+	if err := json.NewDecoder(c.Request.Body).Decode(&rl); err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+	if err := r.checkReadingList(&rl); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	//the counter is past the lists there were when the api started, see
+	//migrateReadingLists, lists loaded with the scripts since then can
+	//still have taken the next ids
+	for tries := 0; ; tries++ {
+		id, err := r.client.Incr(r.context, readingListIdKey).Result()
+		if err != nil {
+			problem.Abort(c, err)
+			return
+		}
+		rl.ID = int(id)
+		res, err := r.helper.JSONSet(readingListKey(rl.ID), ".", rl, rjs.SetOptionNX)
+		if err != nil {
+			problem.Abort(c, err)
+			return
+		}
+		//JSONSet returns nil instead of "OK" when NX prevented the write
+		if res != nil {
+			break
+		}
+		if tries == maxUpdateTries {
+			problem.Abort(c, fmt.Errorf("could not find a free reading list id after %d", rl.ID))
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, rl)
+}
+
+// UpdateReadingList replaces the description and the items of a reading
+// list.  The id in the body may be left out, if it is there it has to
+// match the id in the path.
+func (r *ReadingListAPI) UpdateReadingList(c *gin.Context) {
+	id, err := readingListIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	var rl schema.ReadingList
+	if err := json.NewDecoder(c.Request.Body).Decode(&rl); err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+	if rl.ID == 0 {
+		rl.ID = id
+	}
+	if rl.ID != id {
+		problem.Abort(c, &validate.ValidationError{Fields: []validate.FieldError{
+			{Field: "id", Message: fmt.Sprintf("must match the id in the path, %d", id)},
+		}})
+		return
+	}
+	if err := r.checkReadingList(&rl); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	res, err := r.helper.JSONSet(readingListKey(id), ".", rl, rjs.SetOptionXX)
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+	//JSONSet returns nil instead of "OK" when XX prevented the write
+	if res == nil {
+		problem.Abort(c, fmt.Errorf("%w: could not find reading list with id=%d", ErrNotFound, id))
+		return
+	}
+
+	c.JSON(http.StatusOK, rl)
+}
+
+// DeleteReadingList removes a reading list, the publications stay
+func (r *ReadingListAPI) DeleteReadingList(c *gin.Context) {
+	id, err := readingListIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	n, err := r.client.Del(r.context, readingListKey(id)).Result()
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+	if n == 0 {
+		problem.Abort(c, fmt.Errorf("%w: could not find reading list with id=%d", ErrNotFound, id))
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AddReadingListItem appends an item to a reading list and answers with
+// the list.  The key may be left out, it defaults to pub<id>.
+func (r *ReadingListAPI) AddReadingListItem(c *gin.Context) {
+	rl, ok := r.readingListFromPath(c)
+	if !ok {
+		return
+	}
+
+	var item schema.ReadingListItem
+	if err := json.NewDecoder(c.Request.Body).Decode(&item); err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+	item = normalizeItem(item)
+	if err := validate.Struct(item); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+	//checked before the write too, a duplicate does not cost a request
+	//to the publication api
+	if rl.IndexOf(item.Key) >= 0 {
+		problem.Abort(c, fmt.Errorf("%w: reading list %d already has an item %s", ErrConflict, rl.ID, item.Key))
+		return
+	}
+	if err := r.checkPaths([]schema.ReadingListItem{item}, ""); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	rl, err := r.updateReadingList(rl.ID, func(rl *schema.ReadingList) error {
+		if rl.IndexOf(item.Key) >= 0 {
+			return fmt.Errorf("%w: reading list %d already has an item %s", ErrConflict, rl.ID, item.Key)
+		}
+		rl.Items = append(rl.Items, item)
+		return nil
+	})
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, rl)
+}
+
+// UpdateReadingListItem changes the note or the read status of an item
+func (r *ReadingListAPI) UpdateReadingListItem(c *gin.Context) {
+	id, err := readingListIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	var patch ItemPatch
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	var item schema.ReadingListItem
+	_, err = r.updateReadingList(id, func(rl *schema.ReadingList) error {
+		i, err := itemIndex(*rl, c.Param("key"))
+		if err != nil {
+			return err
+		}
+		if patch.Note != nil {
+			rl.Items[i].Note = strings.TrimSpace(*patch.Note)
+		}
+		if patch.Read != nil {
+			rl.Items[i].Read = *patch.Read
+		}
+		item = rl.Items[i]
+		return nil
+	})
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteReadingListItem removes an item from a reading list
+func (r *ReadingListAPI) DeleteReadingListItem(c *gin.Context) {
+	id, err := readingListIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	_, err = r.updateReadingList(id, func(rl *schema.ReadingList) error {
+		i, err := itemIndex(*rl, c.Param("key"))
+		if err != nil {
+			return err
+		}
+		rl.Items = append(rl.Items[:i], rl.Items[i+1:]...)
+		return nil
+	})
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ReorderReadingList puts the items of a reading list in the order of the
+// keys in the body, which has to name every item once
+func (r *ReadingListAPI) ReorderReadingList(c *gin.Context) {
+	id, err := readingListIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	var order Order
+	if err := json.NewDecoder(c.Request.Body).Decode(&order); err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	rl, err := r.updateReadingList(id, func(rl *schema.ReadingList) error {
+		ve := &validate.ValidationError{}
+		reordered := make([]schema.ReadingListItem, 0, len(order.Keys))
+		seen := map[string]bool{}
+		for i, key := range order.Keys {
+			item, ok := rl.Item(key)
+			switch {
+			case !ok:
+				ve.Fields = append(ve.Fields, validate.FieldError{Field: fmt.Sprintf("keys[%d]", i), Message: "is not an item of the list"})
+			case seen[key]:
+				ve.Fields = append(ve.Fields, validate.FieldError{Field: fmt.Sprintf("keys[%d]", i), Message: "is in the order twice"})
+			default:
+				reordered = append(reordered, item)
+			}
+			seen[key] = true
+		}
+		for _, item := range rl.Items {
+			if !seen[item.Key] {
+				ve.Fields = append(ve.Fields, validate.FieldError{Field: "keys", Message: "is missing the item " + item.Key})
+			}
+		}
+		if len(ve.Fields) > 0 {
+			return ve
+		}
+		rl.Items = reordered
+		return nil
+	})
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rl)
+}
+
+func readingListKey(id int) string {
+	return readingListKeyPrefix + strconv.Itoa(id)
+}
+
+func readingListIdFromPath(c *gin.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("reading list id %q is not a positive number", c.Param("id"))
+	}
+	return id, nil
+}
+
+// readingListFromPath reads the reading list the path names, or records
+// the error and returns false
+func (r *ReadingListAPI) readingListFromPath(c *gin.Context) (schema.ReadingList, bool) {
+	var rl schema.ReadingList
+	id, err := readingListIdFromPath(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return rl, false
+	}
+	if err := r.getItemFromRedis(readingListKey(id), &rl); err != nil {
+		problem.Abort(c, err)
+		return rl, false
+	}
+	return rl, true
+}
+
+// itemIndex finds the item of rl with key
+func itemIndex(rl schema.ReadingList, key string) (int, error) {
+	i := rl.IndexOf(key)
+	if i < 0 {
+		return 0, fmt.Errorf("%w: reading list %d has no item %s", ErrNotFound, rl.ID, key)
+	}
+	return i, nil
+}
+
+// maxUpdateTries bounds how often updateReadingList starts over because
+// the list changed under it, and how many taken ids CreateReadingList
+// skips
+const maxUpdateTries = 10
+
+// errUnchanged tells updateReadingList that change left the list as it
+// was, there is nothing to write
+var errUnchanged = errors.New("the reading list is unchanged")
+
+// updateReadingList reads the reading list id, lets change edit it and
+// writes it back.  The key is WATCHed from before the read to the write,
+// when someone else changes or deletes the list in between the write is
+// dropped and it starts over with the list as it is then.  change can run
+// more than once, it must not do anything but edit the list.  It returns
+// the list as it was written.
+func (r *ReadingListAPI) updateReadingList(id int, change func(rl *schema.ReadingList) error) (schema.ReadingList, error) {
+	key := readingListKey(id)
+	var rl schema.ReadingList
+	for tries := 0; tries < maxUpdateTries; tries++ {
+		err := r.client.Watch(r.context, func(tx *redis.Tx) error {
+			//the read does not have to be on the connection of the
+			//transaction, WATCH sees every change made after it
+			rl = schema.ReadingList{}
+			if err := r.getItemFromRedis(key, &rl); err != nil {
+				return err
+			}
+			if err := change(&rl); err != nil {
+				return err
+			}
+			doc, err := json.Marshal(rl)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(r.context, func(pipe redis.Pipeliner) error {
+				pipe.Do(r.context, "JSON.SET", key, ".", string(doc), "XX")
+				return nil
+			})
+			//XX did not write, the list is gone
+			if err == redis.Nil {
+				return fmt.Errorf("%w: could not find reading list with id=%d", ErrNotFound, id)
+			}
+			return err
+		}, key)
+		switch {
+		case errors.Is(err, errUnchanged):
+			return rl, nil
+		case !errors.Is(err, redis.TxFailedErr):
+			return rl, err
+		}
+	}
+	return rl, fmt.Errorf("%w: reading list %d kept changing while it was updated, try again", ErrConflict, id)
+}
+
+// normalizeItem trims the item and gives it its default key, pub<id> for
+// the path /pubs/<id>.  Dangling is up to the api, the path of the item
+// is checked before it is written.
+func normalizeItem(item schema.ReadingListItem) schema.ReadingListItem {
+	item.Dangling = false
+	item.Key = strings.TrimSpace(item.Key)
+	item.Path = strings.TrimSpace(item.Path)
+	item.Note = strings.TrimSpace(item.Note)
+	if item.Key == "" && strings.HasPrefix(item.Path, "/pubs/") {
+		item.Key = "pub" + strings.TrimPrefix(item.Path, "/pubs/")
+	}
+	return item
+}
+
+// checkReadingList normalizes and validates a whole reading list: its
+// fields, that no two items have the same key, and that every item points
+// to a publication
+func (r *ReadingListAPI) checkReadingList(rl *schema.ReadingList) error {
+	rl.Description = strings.TrimSpace(rl.Description)
+	for i := range rl.Items {
+		rl.Items[i] = normalizeItem(rl.Items[i])
+	}
+	if rl.Items == nil {
+		rl.Items = []schema.ReadingListItem{}
+	}
+
+	ve := &validate.ValidationError{}
+	var fields *validate.ValidationError
+	if err := validate.Struct(*rl); errors.As(err, &fields) {
+		ve.Fields = append(ve.Fields, fields.Fields...)
+	} else if err != nil {
+		return err
+	}
+	seen := map[string]int{}
+	for i, item := range rl.Items {
+		if first, ok := seen[item.Key]; ok && item.Key != "" {
+			ve.Fields = append(ve.Fields, validate.FieldError{
+				Field:   fmt.Sprintf("items[%d].key", i),
+				Message: fmt.Sprintf("duplicates the key of items[%d]", first),
+			})
+			continue
+		}
+		seen[item.Key] = i
+	}
+	if len(ve.Fields) > 0 {
+		return ve
+	}
+	return r.checkPaths(rl.Items, "items")
+}
+
+// checkPaths asks the publication api for the publication of every item.
+// The items whose publication is missing are validation errors, their
+// fields are named after prefix, eg "items[3].path".  Any other failure of
+// the publication api is returned as it is.
+func (r *ReadingListAPI) checkPaths(items []schema.ReadingListItem, prefix string) error {
+	ve := &validate.ValidationError{}
+	for i, item := range items {
+		_, err := r.getPublication(item.Path)
+		if errors.Is(err, ErrNotFound) {
+			field := "path"
+			if prefix != "" {
+				field = fmt.Sprintf("%s[%d].path", prefix, i)
+			}
+			ve.Fields = append(ve.Fields, validate.FieldError{Field: field, Message: "is not a publication of the publication api"})
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(ve.Fields) > 0 {
+		return ve
+	}
+	return nil
+}
+
+// migrateReadingLists rewrites the reading lists that still have their
+// items as an object in the array form, and moves the id counter past the
+// lists that are there.  It runs when the api starts.
+func (r *ReadingListAPI) migrateReadingLists() error {
+	lists, err := r.loadReadingLists()
+	if err != nil {
+		return err
+	}
+
+	highest, migrated := 0, 0
+	for _, rl := range lists {
+		if rl.ID > highest {
+			highest = rl.ID
+		}
+		if !rl.Legacy() {
+			continue
+		}
+		_, err := r.updateReadingList(rl.ID, func(rl *schema.ReadingList) error {
+			if !rl.Legacy() {
+				return errUnchanged
+			}
+			return nil
+		})
+		if errors.Is(err, ErrNotFound) {
+			continue //deleted since it was read
+		}
+		if err != nil {
+			return err
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		log.Printf("Migrated %d reading list(s) to ordered items", migrated)
+	}
+
+	return r.raiseReadingListId(highest)
+}
+
+// raiseReadingListId moves the id counter up to highest if it is behind,
+// a counter that is further already stays where it is.  The counter is
+// WATCHed from the GET to the SET, so an id CreateReadingList hands out
+// in between is never handed out again.
+func (r *ReadingListAPI) raiseReadingListId(highest int) error {
+	for tries := 0; tries < maxUpdateTries; tries++ {
+		err := r.client.Watch(r.context, func(tx *redis.Tx) error {
+			current, err := tx.Get(r.context, readingListIdKey).Int()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if current >= highest {
+				return nil
+			}
+			_, err = tx.TxPipelined(r.context, func(pipe redis.Pipeliner) error {
+				pipe.Set(r.context, readingListIdKey, highest, 0)
+				return nil
+			})
+			return err
+		}, readingListIdKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("%w: the reading list id counter kept changing", ErrConflict)
+}
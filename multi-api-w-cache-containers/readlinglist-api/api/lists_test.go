@@ -0,0 +1,260 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+
+	"architectingsoftware.com/reading-list-api/schema"
+)
+
+// twoItems is a list with the publications 1 and 2
+const twoItems = `{"id":1,"description":"reading","items":[{"key":"pub1","path":"/pubs/1","read":false},{"key":"pub2","path":"/pubs/2","read":false}]}`
+
+func TestReadingListItems(t *testing.T) {
+	note, read := "  chapter 3 ", true
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       any
+		wantStatus int
+		wantKeys   []string // the items of list 1 afterwards
+	}{
+		{
+			name: "add an item", method: http.MethodPost, path: "/publists/1/items",
+			body:       schema.ReadingListItem{Path: "/pubs/3"},
+			wantStatus: http.StatusCreated,
+			wantKeys:   []string{"pub1 /pubs/1", "pub2 /pubs/2", "pub3 /pubs/3"},
+		},
+		{
+			name: "add an item twice", method: http.MethodPost, path: "/publists/1/items",
+			body:       schema.ReadingListItem{Path: "/pubs/2"},
+			wantStatus: http.StatusConflict,
+			wantKeys:   []string{"pub1 /pubs/1", "pub2 /pubs/2"},
+		},
+		{
+			name: "add a publication that is missing", method: http.MethodPost, path: "/publists/1/items",
+			body:       schema.ReadingListItem{Path: "/pubs/9"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantKeys:   []string{"pub1 /pubs/1", "pub2 /pubs/2"},
+		},
+		{
+			name: "add to a missing list", method: http.MethodPost, path: "/publists/7/items",
+			body:       schema.ReadingListItem{Path: "/pubs/3"},
+			wantStatus: http.StatusNotFound,
+			wantKeys:   []string{"pub1 /pubs/1", "pub2 /pubs/2"},
+		},
+		{
+			name: "mark an item read", method: http.MethodPatch, path: "/publists/1/items/pub2",
+			body:       ItemPatch{Note: &note, Read: &read},
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"pub1 /pubs/1", "pub2 /pubs/2 read"},
+		},
+		{
+			name: "patch a missing item", method: http.MethodPatch, path: "/publists/1/items/pub9",
+			body:       ItemPatch{Read: &read},
+			wantStatus: http.StatusNotFound,
+			wantKeys:   []string{"pub1 /pubs/1", "pub2 /pubs/2"},
+		},
+		{
+			name: "delete an item", method: http.MethodDelete, path: "/publists/1/items/pub1",
+			wantStatus: http.StatusNoContent,
+			wantKeys:   []string{"pub2 /pubs/2"},
+		},
+		{
+			name: "reorder the items", method: http.MethodPut, path: "/publists/1/order",
+			body:       Order{Keys: []string{"pub2", "pub1"}},
+			wantStatus: http.StatusOK,
+			wantKeys:   []string{"pub2 /pubs/2", "pub1 /pubs/1"},
+		},
+		{
+			name: "reorder without every item", method: http.MethodPut, path: "/publists/1/order",
+			body:       Order{Keys: []string{"pub2", "pub2"}},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantKeys:   []string{"pub1 /pubs/1", "pub2 /pubs/2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, map[string]string{"publist:1": twoItems})
+			expect(t, a.do(t, tt.method, tt.path, tt.body), tt.wantStatus, nil)
+			if got := keysOf(a.list(t, 1)); !reflect.DeepEqual(got, tt.wantKeys) {
+				t.Errorf("got %q, want %q", got, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestReadingListCRUD(t *testing.T) {
+	a := newTestAPI(t, nil)
+
+	var created schema.ReadingList
+	expect(t, a.do(t, http.MethodPost, "/publists", schema.ReadingList{
+		Description: " papers ", Items: []schema.ReadingListItem{{Path: "/pubs/1"}},
+	}), http.StatusCreated, &created)
+	if created.ID != 1 || created.Description != "papers" || created.Items[0].Key != "pub1" {
+		t.Fatalf("got %+v", created)
+	}
+
+	expect(t, a.do(t, http.MethodPut, "/publists/1", schema.ReadingList{
+		Description: "more papers", Items: []schema.ReadingListItem{{Path: "/pubs/2"}, {Key: "x", Path: "/pubs/3"}},
+	}), http.StatusOK, nil)
+	expect(t, a.do(t, http.MethodPut, "/publists/1", schema.ReadingList{ID: 2, Description: "d"}), http.StatusUnprocessableEntity, nil)
+	expect(t, a.do(t, http.MethodPut, "/publists/5", schema.ReadingList{Description: "d"}), http.StatusNotFound, nil)
+
+	var lists []schema.ReadingList
+	expect(t, a.do(t, http.MethodGet, "/publists", nil), http.StatusOK, &lists)
+	if len(lists) != 1 || !reflect.DeepEqual(keysOf(lists[0]), []string{"pub2 /pubs/2", "x /pubs/3"}) {
+		t.Fatalf("got %+v", lists)
+	}
+
+	expect(t, a.do(t, http.MethodDelete, "/publists/1", nil), http.StatusNoContent, nil)
+	expect(t, a.do(t, http.MethodDelete, "/publists/1", nil), http.StatusNotFound, nil)
+	expect(t, a.do(t, http.MethodGet, "/publists/1", nil), http.StatusNotFound, nil)
+}
+
+// TestConcurrentAdds adds items from many requests at once, none may be
+// lost
+func TestConcurrentAdds(t *testing.T) {
+	a := newTestAPI(t, map[string]string{"publist:1": `{"id":1,"description":"d","items":[]}`})
+
+	const n = 8
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := a.do(t, http.MethodPost, "/publists/1/items", schema.ReadingListItem{Key: fmt.Sprint("k", i), Path: "/pubs/1"})
+			if w.Code != http.StatusCreated {
+				t.Errorf("add %d: got %d %s", i, w.Code, w.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+	if got := len(a.list(t, 1).Items); got != n {
+		t.Errorf("got %d items, want %d", got, n)
+	}
+}
+
+func TestUpdateReadingList(t *testing.T) {
+	t.Run("a change made in between is kept", func(t *testing.T) {
+		a := newTestAPI(t, map[string]string{"publist:1": twoItems})
+		calls := 0
+		rl, err := a.updateReadingList(1, func(rl *schema.ReadingList) error {
+			calls++
+			if calls == 1 {
+				//someone else marks pub1 read between the read and the write
+				if _, err := a.updateReadingList(1, func(rl *schema.ReadingList) error {
+					rl.Items[0].Read = true
+					return nil
+				}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rl.Items = rl.Items[1:2]
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if calls != 2 {
+			t.Errorf("change ran %d times, want 2", calls)
+		}
+		want := []string{"pub2 /pubs/2"}
+		if !reflect.DeepEqual(keysOf(rl), want) || !reflect.DeepEqual(keysOf(a.list(t, 1)), want) {
+			t.Errorf("got %q, stored %q, want %q", keysOf(rl), keysOf(a.list(t, 1)), want)
+		}
+	})
+
+	t.Run("a list deleted in between stays deleted", func(t *testing.T) {
+		a := newTestAPI(t, map[string]string{"publist:1": twoItems})
+		_, err := a.updateReadingList(1, func(rl *schema.ReadingList) error {
+			a.redis.Del(readingListKey(1))
+			rl.Items = append(rl.Items, schema.ReadingListItem{Key: "pub3", Path: "/pubs/3"})
+			return nil
+		})
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("got %v, want ErrNotFound", err)
+		}
+		if a.redis.Exists(readingListKey(1)) {
+			t.Error("the deleted list is back")
+		}
+	})
+
+	t.Run("an error of change is returned and nothing is written", func(t *testing.T) {
+		a := newTestAPI(t, map[string]string{"publist:1": twoItems})
+		_, err := a.updateReadingList(1, func(rl *schema.ReadingList) error {
+			rl.Items = nil
+			return ErrValidation
+		})
+		if !errors.Is(err, ErrValidation) || len(a.list(t, 1).Items) != 2 {
+			t.Errorf("got %v and %d items, want ErrValidation and 2 items", err, len(a.list(t, 1).Items))
+		}
+	})
+
+	t.Run("a list that keeps changing is a conflict", func(t *testing.T) {
+		a := newTestAPI(t, map[string]string{"publist:1": twoItems})
+		_, err := a.updateReadingList(1, func(rl *schema.ReadingList) error {
+			a.redis.Set(readingListKey(1), twoItems)
+			return nil
+		})
+		if !errors.Is(err, ErrConflict) {
+			t.Errorf("got %v, want ErrConflict", err)
+		}
+	})
+}
+
+// TestMigrateReadingLists starts the api on lists from before, with their
+// items as an object, and an id counter that may be behind them
+func TestMigrateReadingLists(t *testing.T) {
+	legacy := func(id int) string {
+		return fmt.Sprintf(`{"id":%d,"description":"old","items":{"SB01":"/pubs/2","B06":"/pubs/1"}}`, id)
+	}
+	tests := []struct {
+		name      string
+		counter   string // "" for none
+		lists     []int
+		wantNewId int
+	}{
+		{name: "no lists", wantNewId: 1},
+		{name: "no counter", lists: []int{3, 7}, wantNewId: 8},
+		{name: "a counter behind the lists", counter: "2", lists: []int{3, 7}, wantNewId: 8},
+		{name: "a counter past the lists", counter: "10", lists: []int{3, 7}, wantNewId: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := map[string]string{}
+			for _, id := range tt.lists {
+				before[readingListKey(id)] = legacy(id)
+			}
+			if tt.counter != "" {
+				before[readingListIdKey] = tt.counter
+			}
+			a := newTestAPI(t, before)
+
+			for _, id := range tt.lists {
+				rl := a.list(t, id)
+				if rl.Legacy() {
+					t.Errorf("list %d was not migrated", id)
+				}
+				if got, want := keysOf(rl), []string{"SB01 /pubs/2", "B06 /pubs/1"}; !reflect.DeepEqual(got, want) {
+					t.Errorf("list %d has %q, want %q", id, got, want)
+				}
+			}
+
+			//the first new list gets the next free id, without skipping
+			var created schema.ReadingList
+			expect(t, a.do(t, http.MethodPost, "/publists", schema.ReadingList{Description: "new"}), http.StatusCreated, &created)
+			if created.ID != tt.wantNewId {
+				t.Errorf("got id %d, want %d", created.ID, tt.wantNewId)
+			}
+			if got, _ := a.redis.Get(readingListIdKey); got != fmt.Sprint(tt.wantNewId) {
+				t.Errorf("the counter is at %s, want %d", got, tt.wantNewId)
+			}
+		})
+	}
+}
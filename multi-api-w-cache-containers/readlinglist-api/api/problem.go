@@ -0,0 +1,42 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"problem"
+	"validate"
+
+	"github.com/gin-gonic/gin"
+)
+
+// These are the errors the handlers report.  They are wrapped with more
+// details, so check for them with errors.Is(), the ProblemMiddleware
+// uses them to pick the right HTTP status code
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = validate.ErrValidation
+)
+
+// problems maps our errors to HTTP status codes
+var problems = problem.Options{
+	Statuses: []problem.Status{
+		{Err: ErrNotFound, Code: http.StatusNotFound},
+		{Err: ErrConflict, Code: http.StatusConflict},
+		{Err: ErrValidation, Code: http.StatusUnprocessableEntity},
+	},
+	Extend: func(p *problem.Problem, err error) {
+		var ve *validate.ValidationError
+		if errors.As(err, &ve) {
+			for _, f := range ve.Fields {
+				p.Errors = append(p.Errors, problem.FieldError(f))
+			}
+		}
+	},
+}
+
+// ProblemMiddleware renders the errors the handlers record with
+// problem.Abort as problem+json, see the problem package
+func ProblemMiddleware() gin.HandlerFunc {
+	return problem.Middleware(problems)
+}
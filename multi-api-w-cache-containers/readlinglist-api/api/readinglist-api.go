@@ -1,10 +1,16 @@
 package api
 
 import (
+	"config"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"problem"
+	"sort"
+	"time"
 
 	"architectingsoftware.com/reading-list-api/schema"
 	"github.com/gin-gonic/gin"
@@ -14,27 +20,43 @@ import (
 	"github.com/go-resty/resty/v2"
 )
 
+// readingListKeyPrefix is the prefix of the cache keys of the reading
+// lists, a list is stored as JSON under publist:<id>.  readingListIdKey
+// holds the last id handed out to a new list.
+const (
+	readingListKeyPrefix = "publist:"
+	readingListIdKey     = "publist-id"
+)
+
 type cache struct {
-	client  *redis.Client
+	client  redis.UniversalClient
 	helper  *rejson.Handler
 	context context.Context
 }
 
+// keys returns the keys that match pattern, see config.Keys
+func (c *cache) keys(ctx context.Context, pattern string) ([]string, error) {
+	return config.Keys(ctx, c.client, pattern)
+}
+
+// pubAPITimeout bounds every request to the publication api
+const pubAPITimeout = 5 * time.Second
+
 type ReadingListAPI struct {
 	cache
-	pubAPIURL string
-	apiClient *resty.Client
+	pubAPIURL      string
+	apiClient      *resty.Client
+	pubs           *pubCache
+	redirectStatus int
 }
 
-func NewReadingListAPI(location string, pubAPIurl string) (*ReadingListAPI, error) {
-
-	apiClient := resty.New()
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
+// NewReadingListAPI keeps the reading lists with client, which can be a
+// single server, a sentinel or a cluster client.  The publications fetched
+// from the publication api at pubAPIurl are kept for pubCacheTTL, see
+// expand.go.
+func NewReadingListAPI(client redis.UniversalClient, pubAPIurl string, pubCacheTTL time.Duration) (*ReadingListAPI, error) {
 
+	apiClient := resty.New().SetTimeout(pubAPITimeout)
 	//We use this context to coordinate betwen our go code and
 	//the redis operaitons
 	ctx := context.Background()
@@ -56,77 +78,86 @@ func NewReadingListAPI(location string, pubAPIurl string) (*ReadingListAPI, erro
 	jsonHelper := rejson.NewReJSONHandler()
 	jsonHelper.SetGoRedisClientWithContext(ctx, client)
 
-	//Return a pointer to a new ToDo struct
-	return &ReadingListAPI{
+	r := &ReadingListAPI{
 		cache: cache{
 			client:  client,
 			helper:  jsonHelper,
 			context: ctx,
 		},
-		pubAPIURL: pubAPIurl,
-		apiClient: apiClient,
-	}, nil
+		pubAPIURL:      pubAPIurl,
+		apiClient:      apiClient,
+		pubs:           newPubCache(pubCacheTTL),
+		redirectStatus: DefaultRedirectStatus,
+	}
+
+	//lists loaded from older data files have their items as an object
+	if err := r.migrateReadingLists(); err != nil {
+		log.Println("Error migrating the reading lists: " + err.Error())
+		return nil, err
+	}
+
+	return r, nil
 }
 
 func (r *ReadingListAPI) GetReadingList(c *gin.Context) {
 
 	rlId := c.Param("id")
 	if rlId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication ID provided"})
+		problem.AbortBadRequest(c, errors.New("no reading list id provided"))
 		return
 	}
 
-	cacheKey := "publist:" + rlId
-	rlBytes, err := r.helper.JSONGet(cacheKey, ".")
+	expand, err := expandParam(c)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find reading list in cache with id=" + cacheKey})
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
+	cacheKey := readingListKeyPrefix + rlId
 	var rl schema.ReadingList
-	err = json.Unmarshal(rlBytes.([]byte), &rl)
+	err = r.getItemFromRedis(cacheKey, &rl)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "cached data seems to be wrong type"})
+		problem.Abort(c, err)
 		return
 	}
 
+	if expand {
+		c.JSON(http.StatusOK, r.expandReadingList(rl))
+		return
+	}
 	c.JSON(http.StatusOK, rl)
 }
 
 func (r *ReadingListAPI) GetPubFromReadingList(c *gin.Context) {
 	rlId := c.Param("id")
 	if rlId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication ID provided"})
+		problem.AbortBadRequest(c, errors.New("no reading list id provided"))
 		return
 	}
 
 	rlIdxKey := c.Param("idx")
 	if rlIdxKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication incdex provided"})
+		problem.AbortBadRequest(c, errors.New("no publication index provided"))
 		return
 	}
 
-	cacheKey := "publist:" + rlId
+	cacheKey := readingListKeyPrefix + rlId
 	var rl schema.ReadingList
 	err := r.getItemFromRedis(cacheKey, &rl)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find reading list in cache with id=" + cacheKey})
+		problem.Abort(c, err)
 		return
 	}
 
-	pubItemLocation, ok := rl.Items[rlIdxKey]
+	item, ok := rl.Item(rlIdxKey)
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find publication in reading list with id=" + rlIdxKey})
+		problem.Abort(c, fmt.Errorf("%w: could not find publication in reading list with id=%s", ErrNotFound, rlIdxKey))
 		return
 	}
 
-	pubURL := r.pubAPIURL + pubItemLocation
-	var pub schema.Publication
-
-	_, err = r.apiClient.R().SetResult(&pub).Get(pubURL)
+	pub, err := r.cachedPublication(item.Path)
 	if err != nil {
-		emsg := "Could not get publication from API: (" + pubURL + ")" + err.Error()
-		c.JSON(http.StatusNotFound, gin.H{"error": emsg})
+		problem.Abort(c, err)
 		return
 	}
 
@@ -136,65 +167,80 @@ func (r *ReadingListAPI) GetPubFromReadingList(c *gin.Context) {
 func (r *ReadingListAPI) RedirectWithPublication(c *gin.Context) {
 	rlId := c.Param("id")
 	if rlId == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication ID provided"})
+		problem.AbortBadRequest(c, errors.New("no reading list id provided"))
 		return
 	}
 
 	rlIdxKey := c.Param("idx")
 	if rlIdxKey == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No publication incdex provided"})
+		problem.AbortBadRequest(c, errors.New("no publication index provided"))
 		return
 	}
 
-	cacheKey := "publist:" + rlId
+	cacheKey := readingListKeyPrefix + rlId
 	var rl schema.ReadingList
 	err := r.getItemFromRedis(cacheKey, &rl)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find reading list in cache with id=" + cacheKey})
+		problem.Abort(c, err)
 		return
 	}
 
-	pubItemLocation, ok := rl.Items[rlIdxKey]
+	item, ok := rl.Item(rlIdxKey)
 	if !ok {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not find publication in reading list with id=" + rlIdxKey})
+		problem.Abort(c, fmt.Errorf("%w: could not find publication in reading list with id=%s", ErrNotFound, rlIdxKey))
 		return
 	}
 
-	pubURL := r.pubAPIURL + pubItemLocation
-	var pub schema.Publication
-
-	_, err = r.apiClient.R().SetResult(&pub).Get(pubURL)
+	pub, err := r.cachedPublication(item.Path)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Could not get publication from API"})
+		problem.Abort(c, err)
 		return
 	}
 
 	if pub.Link == "" {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Publication does not have a link"})
+		problem.Abort(c, fmt.Errorf("%w: publication %d does not have a link", ErrNotFound, pub.ID))
 		return
 	}
 
-	c.Redirect(http.StatusMovedPermanently, pub.Link)
+	r.recordClick(rlId, pub.ID)
+	c.Redirect(r.redirectStatus, pub.Link)
 }
 
 func (r *ReadingListAPI) GetReadingLists(c *gin.Context) {
+	readList, err := r.loadReadingLists()
+	if err != nil {
+		problem.Abort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, readList)
+}
 
-	var readList []schema.ReadingList
-	var readItem schema.ReadingList
+// loadReadingLists reads every reading list from the cache, ordered by id
+func (r *ReadingListAPI) loadReadingLists() ([]schema.ReadingList, error) {
+	readList := []schema.ReadingList{}
 
 	//Lets query redis for all of the items
-	pattern := "publist:*"
-	ks, _ := r.client.Keys(r.context, pattern).Result()
+	pattern := readingListKeyPrefix + "*"
+	ks, err := r.keys(r.context, pattern)
+	if err != nil {
+		return nil, err
+	}
 	for _, key := range ks {
+		//a fresh struct for every list, the items of one list must not
+		//leak into the next
+		var readItem schema.ReadingList
 		err := r.getItemFromRedis(key, &readItem)
+		if errors.Is(err, ErrNotFound) {
+			continue //deleted since SCAN listed it
+		}
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not find reading list in cache with id=" + key})
-			return
+			return nil, err
 		}
 		readList = append(readList, readItem)
 	}
 
-	c.JSON(http.StatusOK, readList)
+	sort.Slice(readList, func(i, j int) bool { return readList[i].ID < readList[j].ID })
+	return readList, nil
 }
 
 // Helper to return a ToDoItem from redis provided a key
@@ -204,6 +250,9 @@ func (r *ReadingListAPI) getItemFromRedis(key string, rl *schema.ReadingList) er
 	//json structure, the second parameter "." means return the entire
 	//json structure
 	itemObject, err := r.helper.JSONGet(key, ".")
+	if err == redis.Nil {
+		return fmt.Errorf("%w: could not find reading list in cache with id=%s", ErrNotFound, key)
+	}
 	if err != nil {
 		return err
 	}
@@ -214,8 +263,49 @@ func (r *ReadingListAPI) getItemFromRedis(key string, rl *schema.ReadingList) er
 	//it into our ToDoItem struct
 	err = json.Unmarshal(itemObject.([]byte), rl)
 	if err != nil {
-		return err
+		return fmt.Errorf("cached data for %s seems to be wrong type: %w", key, err)
 	}
 
 	return nil
 }
+
+// Helper to fetch a publication from the publication API, location is
+// the path stored in the reading list, eg "/pubs/1".  A 404 from the
+// publication API is reported as ErrNotFound, any other failure is
+// treated as a server error
+func (r *ReadingListAPI) getPublication(location string) (schema.Publication, error) {
+	pubURL := r.pubAPIURL + location
+	var pub schema.Publication
+
+	resp, err := r.apiClient.R().SetResult(&pub).Get(pubURL)
+	if err != nil {
+		return pub, fmt.Errorf("could not get publication from API (%s): %w", pubURL, err)
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return pub, fmt.Errorf("%w: publication API has no publication at %s", ErrNotFound, location)
+	}
+	if resp.IsError() {
+		return pub, fmt.Errorf("could not get publication from API (%s): %s", pubURL, resp.Status())
+	}
+
+	return pub, nil
+}
+
+// getPublicationBibTeX is getPublication for the BibTeX entry of the
+// publication, which the publication api writes
+func (r *ReadingListAPI) getPublicationBibTeX(location string) (string, error) {
+	pubURL := r.pubAPIURL + location
+
+	resp, err := r.apiClient.R().SetHeader("Accept", bibTeXContentType).Get(pubURL)
+	if err != nil {
+		return "", fmt.Errorf("could not get publication from API (%s): %w", pubURL, err)
+	}
+	if resp.StatusCode() == http.StatusNotFound {
+		return "", fmt.Errorf("%w: publication API has no publication at %s", ErrNotFound, location)
+	}
+	if resp.IsError() {
+		return "", fmt.Errorf("could not get publication from API (%s): %s", pubURL, resp.Status())
+	}
+
+	return resp.String(), nil
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// testRedis runs miniredis for the length of the test.  miniredis does not
+// know the RedisJSON commands, a hook turns JSON.SET and JSON.GET on the
+// root path into SET and GET of a string key.  As they become plain redis
+// commands they take part in MULTI and WATCH like on a RedisJSON server.
+func testRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	m := miniredis.RunT(t)
+	srv := m.Server()
+	srv.SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+		switch cmd {
+		case "JSON.SET":
+			if len(args) < 3 || len(args) > 4 || args[1] != "." || !json.Valid([]byte(args[2])) {
+				c.WriteError("ERR only JSON.SET <key> . <json> [NX|XX] is supported")
+				return true
+			}
+			set := []string{"SET", args[0], args[2]}
+			if len(args) == 4 {
+				set = append(set, strings.ToUpper(args[3]))
+			}
+			srv.Dispatch(c, set)
+			return true
+		case "JSON.GET":
+			if len(args) != 2 || args[1] != "." {
+				c.WriteError("ERR only JSON.GET <key> . is supported")
+				return true
+			}
+			srv.Dispatch(c, []string{"GET", args[0]})
+			return true
+		}
+		return false
+	})
+	return m
+}
+
+// pubAPI stands in for the publication api, it serves the publications
+// it has at /pubs/<id> and counts the requests
+type pubAPI struct {
+	*httptest.Server
+	mu       sync.Mutex
+	pubs     map[int]schema.Publication
+	requests int
+	fail     bool // answer every request with a 500
+}
+
+func newPubAPI(t *testing.T, pubs ...schema.Publication) *pubAPI {
+	t.Helper()
+	p := &pubAPI{pubs: map[int]schema.Publication{}}
+	for _, pub := range pubs {
+		p.pubs[pub.ID] = pub
+	}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.serve))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *pubAPI) serve(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests++
+	if p.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/pubs/"))
+	pub, ok := p.pubs[id]
+	if err != nil || !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if r.Header.Get("Accept") == bibTeXContentType {
+		w.Header().Set("Content-Type", bibTeXContentType)
+		w.Write([]byte("@article{pub" + strconv.Itoa(id) + ",\n  title = {" + pub.Title + "},\n}\n"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pub)
+}
+
+// set changes what the publication api answers, remove drops publications
+func (p *pubAPI) set(fail bool, remove ...int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fail = fail
+	for _, id := range remove {
+		delete(p.pubs, id)
+	}
+}
+
+func (p *pubAPI) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests
+}
+
+// testAPI is the reading list api on miniredis and a pubAPI, with its
+// routes
+type testAPI struct {
+	*ReadingListAPI
+	redis  *miniredis.Miniredis
+	pubs   *pubAPI
+	router *gin.Engine
+}
+
+// newTestAPI starts the api with the publications 1 to 3 and the lists
+// that are in the cache already
+func newTestAPI(t *testing.T, lists map[string]string) *testAPI {
+	t.Helper()
+	m := testRedis(t)
+	for key, doc := range lists {
+		m.Set(key, doc)
+	}
+	pubs := newPubAPI(t,
+		schema.Publication{ID: 1, Title: "Bunch", Cite: "Mitchell (2006)", Link: "https://example.org/bunch.pdf", Authors: []string{"Brian S. Mitchell"}, Year: 2006},
+		schema.Publication{ID: 2, Title: "Search Based", Cite: "Harman (2001)", Year: 2001},
+		schema.Publication{ID: 3, Title: "Modularity", Cite: "Parnas (1972)", Year: 1972},
+	)
+
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	r, err := NewReadingListAPI(client, pubs.URL, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(ProblemMiddleware())
+	r.RegisterRoutes(router)
+	return &testAPI{ReadingListAPI: r, redis: m, pubs: pubs, router: router}
+}
+
+// do sends a request with body, if it is not nil, as json
+func (a *testAPI) do(t *testing.T, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect checks the status of w and decodes its body into v, if v is not
+// nil
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got %d %s, want %d", w.Code, w.Body.String(), status)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%v in %s", err, w.Body.String())
+		}
+	}
+}
+
+// list reads a reading list straight from the cache
+func (a *testAPI) list(t *testing.T, id int) schema.ReadingList {
+	t.Helper()
+	var rl schema.ReadingList
+	if err := a.getItemFromRedis(readingListKey(id), &rl); err != nil {
+		t.Fatal(err)
+	}
+	return rl
+}
+
+// keysOf lists the keys of the items of rl, with their flags, eg
+// "pub1 dangling"
+func keysOf(rl schema.ReadingList) []string {
+	keys := []string{}
+	for _, item := range rl.Items {
+		k := item.Key + " " + item.Path
+		if item.Read {
+			k += " read"
+		}
+		if item.Dangling {
+			k += " dangling"
+		}
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"net/http"
+	"openapi"
+	"problem"
+
+	"architectingsoftware.com/reading-list-api/schema"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes is the route table of the reading list api, RegisterRoutes
+// serves it and the OpenAPI document generated from it
+func (r *ReadingListAPI) Routes() []openapi.Route {
+	return []openapi.Route{
+		{Method: http.MethodGet, Path: "/publists", Summary: "List all reading lists", Handler: r.GetReadingLists,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []schema.ReadingList{})}},
+		{Method: http.MethodPost, Path: "/publists", Summary: "Create a reading list", Handler: r.CreateReadingList,
+			Body:      schema.ReadingList{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusCreated, schema.ReadingList{})}},
+		{Method: http.MethodGet, Path: "/publists/:id", Summary: "Get a reading list, with ?expand=pubs the publications of its items too", Handler: r.GetReadingList,
+			Query:     []string{"expand"},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, schema.ReadingList{})}},
+		{Method: http.MethodPut, Path: "/publists/:id", Summary: "Replace the description and items of a reading list", Handler: r.UpdateReadingList,
+			Body:      schema.ReadingList{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, schema.ReadingList{})}},
+		{Method: http.MethodDelete, Path: "/publists/:id", Summary: "Delete a reading list", Handler: r.DeleteReadingList,
+			Responses: []openapi.Response{openapi.Reply(http.StatusNoContent, nil)}},
+		{Method: http.MethodPost, Path: "/publists/:id/items", Summary: "Add a publication to the end of a reading list", Handler: r.AddReadingListItem,
+			Body:      schema.ReadingListItem{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusCreated, schema.ReadingList{})}},
+		{Method: http.MethodPatch, Path: "/publists/:id/items/:key", Summary: "Change the note or read status of an item", Handler: r.UpdateReadingListItem,
+			Body:      ItemPatch{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, schema.ReadingListItem{})}},
+		{Method: http.MethodDelete, Path: "/publists/:id/items/:key", Summary: "Remove an item from a reading list", Handler: r.DeleteReadingListItem,
+			Responses: []openapi.Response{openapi.Reply(http.StatusNoContent, nil)}},
+		{Method: http.MethodPut, Path: "/publists/:id/order", Summary: "Reorder the items of a reading list", Handler: r.ReorderReadingList,
+			Body:      Order{},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, schema.ReadingList{})}},
+		{Method: http.MethodGet, Path: "/publists/:id/integrity", Summary: "Report the items of a reading list whose publication is missing", Handler: r.GetReadingListIntegrity,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, IntegrityReport{})}},
+		{Method: http.MethodGet, Path: "/publists/:id/export", Summary: "Export a reading list as BibTeX, markdown, html or pdf", Handler: r.ExportReadingList,
+			Query:     []string{"format"},
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, nil)}},
+		{Method: http.MethodGet, Path: "/publists/:id/:idx", Summary: "Get a publication from a reading list", Handler: r.GetPubFromReadingList,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, schema.Publication{})}},
+		{Method: http.MethodGet, Path: "/publists/:id/:idx/paper", Summary: "Redirect to the paper of a publication", Handler: r.RedirectWithPublication,
+			Responses: []openapi.Response{openapi.Reply(http.StatusFound, nil)}},
+	}
+}
+
+// RegisterRoutes adds the routes to router, serves the OpenAPI document at
+// /openapi.json and returns the document
+func (r *ReadingListAPI) RegisterRoutes(router gin.IRouter) *openapi.Document {
+	routes := r.Routes()
+	doc := openapi.New(openapi.Info{Title: "readinglist-api", Version: "1.0.0"}, routes, problem.Problem{}, problem.ContentType)
+	openapi.Register(router, doc, routes)
+	return doc
+}
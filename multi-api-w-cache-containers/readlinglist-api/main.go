@@ -1,83 +1,78 @@
 package main
 
 import (
-	"flag"
-	"fmt"
+	"config"
 	"log"
-	"os"
-	"strconv"
+	"time"
 
 	"architectingsoftware.com/reading-list-api/api"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-var (
-	hostFlag  string
-	portFlag  uint
-	cacheURL  string
-	pubAPIURL string
-)
-
-func processCmdLineFlags() {
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.StringVar(&pubAPIURL, "pubapi", "http://localhost:2080", "Default endpoint for publication API")
-	flag.StringVar(&cacheURL, "c", "0.0.0.0:6379", "Default cache location")
-	flag.UintVar(&portFlag, "p", 3080, "Default Port")
-
-	flag.Parse()
-}
-
-func envVarOrDefault(envVar string, defaultVal string) string {
-	envVal := os.Getenv(envVar)
-	if envVal != "" {
-		return envVal
-	}
-	return defaultVal
+// Config is the configuration of the reading list api, from the flags, the
+// RLAPI_ environment variables and the -config file, see the config
+// package.  The old flags, eg -pubapi, still work.
+type Config struct {
+	config.Server
+	Redis          config.Redis  `config:"redis"`
+	PubAPIURL      string        `config:"pub-api-url" flag:"pubapi" validate:"required" usage:"The endpoint of the publication API"`
+	PubCacheTTL    time.Duration `config:"pub-cache-ttl" flag:"pubttl" validate:"min=0s" usage:"How long to keep the publications fetched from the publication API, 0 to not keep them"`
+	OnDelete       string        `config:"on-delete" flag:"ondelete" validate:"oneof=mark prune" usage:"What to do with the items of a deleted publication, mark them as dangling or prune them"`
+	RedirectStatus int           `config:"redirect-status" flag:"redirect" validate:"oneof=301 302 303 307 308" usage:"The status of the redirects to the papers"`
 }
 
-func setupParms() {
-	//first process any command line flags
-	processCmdLineFlags()
-
-	//now process any environment variables
-	cacheURL = envVarOrDefault("RLAPI_CACHE_URL", cacheURL)
-	pubAPIURL = envVarOrDefault("RLAPI_PUB_API_URL", pubAPIURL)
-	hostFlag = envVarOrDefault("RLAPI_HOST", hostFlag)
-
-	pfNew, err := strconv.Atoi(envVarOrDefault("RLAPI_PORT", fmt.Sprintf("%d", portFlag)))
-	//only update the port if we were able to convert the env var to an int, else
-	//we will use the default we got from the command line, or command line defaults
-	if err == nil {
-		portFlag = uint(pfNew)
+func loadConfig() Config {
+	cfg := Config{
+		Server:         config.Server{Host: "0.0.0.0", Port: 3080},
+		Redis:          config.Redis{URL: "0.0.0.0:6379"},
+		PubAPIURL:      "http://localhost:2080",
+		PubCacheTTL:    api.DefaultPubCacheTTL,
+		OnDelete:       api.OnDeleteMark,
+		RedirectStatus: api.DefaultRedirectStatus,
 	}
-
+	config.MustLoad("RLAPI_", &cfg)
+	return cfg
 }
 
 func main() {
-	//this will allow the user to override key parameters and also setup defaults
-	setupParms()
-	log.Println("Init/cacheURL: " + cacheURL)
-	log.Println("Init/pubAPIURL: " + pubAPIURL)
-	log.Println("Init/hostFlag: " + hostFlag)
-	log.Printf("Init/portFlag: %d", portFlag)
-
-	apiHandler, err := api.NewReadingListAPI(cacheURL, pubAPIURL)
+	//the flags, the environment and the config file can override the
+	//defaults, see loadConfig
+	cfg := loadConfig()
+	log.Println("Init/cacheURL: " + cfg.Redis.Redacted())
+	log.Println("Init/pubAPIURL: " + cfg.PubAPIURL)
+	log.Println("Init/address: " + cfg.Addr())
+	log.Printf("Init/pubTTL: %s", cfg.PubCacheTTL)
+	log.Println("Init/onDelete: " + cfg.OnDelete)
+	log.Printf("Init/redirect: %d", cfg.RedirectStatus)
+
+	redisClient, err := cfg.Redis.Client()
+	if err != nil {
+		panic(err)
+	}
+	apiHandler, err := api.NewReadingListAPI(redisClient, cfg.PubAPIURL, cfg.PubCacheTTL)
 
 	if err != nil {
 		panic(err)
 	}
+	if err := apiHandler.SetRedirectStatus(cfg.RedirectStatus); err != nil {
+		panic(err)
+	}
 
 	r := gin.Default()
 	r.Use(cors.Default())
+	r.Use(api.ProblemMiddleware())
 
-	r.GET("/publists", apiHandler.GetReadingLists)
-	r.GET("/publists/:id", apiHandler.GetReadingList)
-	r.GET("/publists/:id/:idx", apiHandler.GetPubFromReadingList)
-	r.GET("/publists/:id/:idx/paper", apiHandler.RedirectWithPublication)
+	apiHandler.RegisterRoutes(r)
+
+	//the publication api publishes its changes on a redis stream, the
+	//consumer keeps the items of the lists pointing to the right place
+	stop, err := apiHandler.StartEventConsumer(cfg.OnDelete)
+	if err != nil {
+		panic(err)
+	}
+	defer stop()
 
-	//For now we will just support gets
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	r.Run(cfg.Addr())
 
 }
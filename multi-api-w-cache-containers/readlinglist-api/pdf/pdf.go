@@ -0,0 +1,323 @@
+// Package pdf writes simple PDF documents: paragraphs of wrapped text in
+// the Helvetica fonts every PDF reader has, over as many pages as they
+// need, with clickable links.  It is just enough to export a reading list,
+// and needs nothing but the standard library.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The page is US Letter, in points
+const (
+	pageWidth  = 612
+	pageHeight = 792
+	margin     = 54
+	lineFactor = 1.25 //the line height for a font size
+)
+
+// Style is how a paragraph looks.  A paragraph with a URL is a link, every
+// line of it can be clicked.
+type Style struct {
+	Size   float64
+	Bold   bool
+	Indent float64
+	URL    string
+}
+
+// Document is a PDF document being written
+type Document struct {
+	title string
+	pages []*page
+	y     float64 //where the next line goes, from the bottom of the page
+}
+
+type page struct {
+	content bytes.Buffer
+	links   []link
+}
+
+type link struct {
+	x0, y0, x1, y1 float64
+	url            string
+}
+
+// New starts a document with title, which PDF readers show in their title
+// bar
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// Paragraph adds text, wrapped to the width of the page less the indent
+// of the style.  It starts a new page when the current one is full.
+func (d *Document) Paragraph(text string, style Style) {
+	if style.Size <= 0 {
+		style.Size = 11
+	}
+	width := pageWidth - 2*margin - style.Indent
+	lineHeight := style.Size * lineFactor
+
+	for _, line := range wrap(encode(text), style, width) {
+		p := d.room(lineHeight)
+		d.y -= lineHeight
+		x := margin + style.Indent
+
+		font := "F1"
+		if style.Bold {
+			font = "F2"
+		}
+		if style.URL != "" {
+			//links are blue, like on the web
+			fmt.Fprintf(&p.content, "0 0 0.6 rg\n")
+		}
+		fmt.Fprintf(&p.content, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, style.Size, x, d.y, escape(line))
+		if style.URL != "" {
+			fmt.Fprintf(&p.content, "0 0 0 rg\n")
+			p.links = append(p.links, link{
+				x0: x, y0: d.y - style.Size*0.25,
+				x1: x + textWidth(line, style.Bold)*style.Size/1000, y1: d.y + style.Size,
+				url: style.URL,
+			})
+		}
+	}
+}
+
+// Space leaves h points of empty space, or starts a new page
+func (d *Document) Space(h float64) {
+	if len(d.pages) == 0 || d.y-h < margin {
+		return //the next line starts a new page anyway
+	}
+	d.y -= h
+}
+
+// room returns the page that has room for a line of height h, a new one
+// if the current page is full
+func (d *Document) room(h float64) *page {
+	if len(d.pages) == 0 || d.y-h < margin {
+		d.pages = append(d.pages, &page{})
+		d.y = pageHeight - margin
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// WriteTo writes the document as a PDF file
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.room(0)
+	}
+
+	var b bytes.Buffer
+	var offsets []int
+	//objects are numbered from 1 in the order they are written, obj
+	//returns the number of the next one
+	obj := func() int {
+		offsets = append(offsets, b.Len())
+		n := len(offsets)
+		fmt.Fprintf(&b, "%d 0 obj\n", n)
+		return n
+	}
+
+	b.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	//1 the catalog, 2 the page tree, 3 and 4 the fonts, 5 the info, then
+	//every page with its content and links
+	pageIds := make([]int, len(d.pages))
+	next := 6
+	for i, p := range d.pages {
+		pageIds[i] = next
+		next += 2 + len(p.links)
+	}
+
+	obj()
+	b.WriteString("<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	obj()
+	kids := make([]string, len(pageIds))
+	for i, id := range pageIds {
+		kids[i] = fmt.Sprintf("%d 0 R", id)
+	}
+	fmt.Fprintf(&b, "<< /Type /Pages /Kids [%s] /Count %d >>\nendobj\n", strings.Join(kids, " "), len(pageIds))
+	obj()
+	b.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\nendobj\n")
+	obj()
+	b.WriteString("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>\nendobj\n")
+	obj()
+	fmt.Fprintf(&b, "<< /Title (%s) /Producer (reading-list-api) /CreationDate (D:%s) >>\nendobj\n",
+		escape(encode(d.title)), time.Now().UTC().Format("20060102150405Z"))
+
+	for _, p := range d.pages {
+		id := obj()
+		annots := make([]string, len(p.links))
+		for j := range p.links {
+			annots[j] = fmt.Sprintf("%d 0 R", id+2+j)
+		}
+		fmt.Fprintf(&b, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R /Annots [%s] >>\nendobj\n",
+			pageWidth, pageHeight, id+1, strings.Join(annots, " "))
+
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(p.content.Bytes())
+		zw.Close()
+		obj()
+		fmt.Fprintf(&b, "<< /Length %d /Filter /FlateDecode >>\nstream\n", z.Len())
+		b.Write(z.Bytes())
+		b.WriteString("\nendstream\nendobj\n")
+
+		for _, l := range p.links {
+			obj()
+			fmt.Fprintf(&b, "<< /Type /Annot /Subtype /Link /Rect [%.2f %.2f %.2f %.2f] /Border [0 0 0] /A << /S /URI /URI (%s) >> >>\nendobj\n",
+				l.x0, l.y0, l.x1, l.y1, escape(encode(l.url)))
+		}
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return b.WriteTo(w)
+}
+
+// wrap breaks text, already encoded, into lines no wider than width.  A
+// word wider than a line, eg a long url, is broken where it has to be.
+func wrap(text []byte, style Style, width float64) [][]byte {
+	maxUnits := width * 1000 / style.Size
+	var lines [][]byte
+	var line []byte
+	for _, word := range bytes.Fields(text) {
+		candidate := word
+		if len(line) > 0 {
+			candidate = append(append(append([]byte{}, line...), ' '), word...)
+		}
+		if textWidth(candidate, style.Bold) <= maxUnits {
+			line = candidate
+			continue
+		}
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+		for textWidth(word, style.Bold) > maxUnits {
+			n := 1
+			for n < len(word) && textWidth(word[:n+1], style.Bold) <= maxUnits {
+				n++
+			}
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		line = word
+	}
+	if len(line) > 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// textWidth is the width of text in thousandths of the font size
+func textWidth(text []byte, bold bool) float64 {
+	widths := &helvetica
+	if bold {
+		widths = &helveticaBold
+	}
+	w := 0.0
+	for _, c := range text {
+		switch {
+		case c >= 32 && c <= 126:
+			w += float64(widths[c-32])
+		default:
+			w += float64(winAnsiWidths[c])
+		}
+	}
+	return w
+}
+
+// escape makes text a PDF string literal, without the parentheses
+func escape(text []byte) string {
+	var b strings.Builder
+	for _, c := range text {
+		switch c {
+		case '\\', '(', ')':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// encode converts text to WinAnsi, the encoding of the fonts.  The
+// characters it does not have become '?'.
+func encode(text string) []byte {
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			out = append(out, ' ')
+		case r < 32:
+		case r < 127 || (r >= 0xa0 && r <= 0xff):
+			out = append(out, byte(r))
+		default:
+			if c, ok := winAnsiExtra[r]; ok {
+				out = append(out, c)
+			} else if unicode.IsSpace(r) {
+				out = append(out, ' ')
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
+
+// winAnsiExtra are the characters WinAnsi has in 0x80-0x9f
+var winAnsiExtra = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'‰': 0x89, '‹': 0x8b, 'Œ': 0x8c, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99, '›': 0x9b,
+	'œ': 0x9c,
+}
+
+// The widths of the printable ASCII characters, space to tilde, from the
+// font metrics of Helvetica and Helvetica-Bold
+var helvetica = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+var helveticaBold = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+	975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+	333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+	611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+}
+
+// winAnsiWidths are the widths of the characters above ASCII, close
+// enough for both fonts: the accented letters are as wide as the letters
+// they are made of, which are mostly 556 wide
+var winAnsiWidths = func() [256]int {
+	var w [256]int
+	for c := 0x80; c < 256; c++ {
+		w[c] = 556
+	}
+	for c, width := range map[byte]int{
+		0x85: 1000, 0x89: 1000, 0x91: 222, 0x92: 222, 0x93: 333, 0x94: 333,
+		0x95: 350, 0x97: 1000, 0x99: 1000, 0xa0: 278, 0xa9: 737, 0xae: 737,
+		0xc6: 1000, 0xe6: 889, 0x8c: 1000, 0x9c: 944,
+	} {
+		w[c] = width
+	}
+	return w
+}()
@@ -1,5 +1,11 @@
 package schema
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
 type slideLink struct {
 	Type        string `json:"type"`
 	Description string `json:"description"`
@@ -12,10 +18,109 @@ type Publication struct {
 	Link     string      `json:"link,omitempty"`
 	Slides   []slideLink `json:"slides,omitempty"`
 	Abstract string      `json:"abstract"`
+
+	Type    string   `json:"type,omitempty"`
+	Authors []string `json:"authors,omitempty"`
+	Venue   string   `json:"venue,omitempty"`
+	Year    int      `json:"year,omitempty"`
+	DOI     string   `json:"doi,omitempty"`
+	Pages   string   `json:"pages,omitempty"`
+	Key     string   `json:"key,omitempty"`
 }
 
+// ReadingList is an ordered list of publications.  The validation rules
+// are declared with "binding" struct tags, the api checks a list against
+// them before it writes it to the cache.
 type ReadingList struct {
 	ID          int               `json:"id"`
-	Description string            `json:"description"`
-	Items       map[string]string `json:"items,omitempty"`
+	Description string            `json:"description" binding:"notblank"`
+	Items       []ReadingListItem `json:"items" binding:"dive"`
+
+	legacy bool
+}
+
+// ReadingListItem is a publication in a reading list.  Key names the item
+// in the routes, eg /publists/1/JSC07, so it can not be the name of
+// another route, integrity or export.  Path is where the publication api
+// serves the publication, eg "/pubs/10".  Dangling is set by the api when
+// the publication api deleted the publication.
+type ReadingListItem struct {
+	Key      string `json:"key" binding:"notblank,excludesall=/?#,ne=integrity,ne=export"`
+	Path     string `json:"path" binding:"required,startswith=/pubs/"`
+	Note     string `json:"note,omitempty"`
+	Read     bool   `json:"read"`
+	Dangling bool   `json:"dangling,omitempty"`
+}
+
+// Item returns the item of the list with key
+func (rl *ReadingList) Item(key string) (ReadingListItem, bool) {
+	if i := rl.IndexOf(key); i >= 0 {
+		return rl.Items[i], true
+	}
+	return ReadingListItem{}, false
+}
+
+// IndexOf returns the position of the item with key, -1 if the list does
+// not have it
+func (rl *ReadingList) IndexOf(key string) int {
+	for i, item := range rl.Items {
+		if item.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Legacy tells if the list was read from the object form of the items
+func (rl *ReadingList) Legacy() bool {
+	return rl.legacy
+}
+
+// UnmarshalJSON reads the items both as an array and as the object the
+// first reading lists were stored with, {"JSC07": "/pubs/10", ...}.  The
+// items of an object keep the order they were written in.
+func (rl *ReadingList) UnmarshalJSON(data []byte) error {
+	type plain ReadingList //without this method
+	var raw struct {
+		plain
+		Items json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*rl = ReadingList(raw.plain)
+
+	items := bytes.TrimSpace(raw.Items)
+	switch {
+	case len(items) == 0 || bytes.Equal(items, []byte("null")):
+		rl.Items = nil
+	case items[0] == '{':
+		rl.legacy = true
+		return rl.unmarshalLegacyItems(items)
+	default:
+		return json.Unmarshal(items, &rl.Items)
+	}
+	return nil
+}
+
+func (rl *ReadingList) unmarshalLegacyItems(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil { //the opening brace
+		return err
+	}
+	rl.Items = []ReadingListItem{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := tok.(string)
+		var path string
+		if err := dec.Decode(&path); err != nil {
+			return fmt.Errorf("item %q of reading list %d: %w", key, rl.ID, err)
+		}
+		rl.Items = append(rl.Items, ReadingListItem{Key: key, Path: path})
+	}
+	_, err := dec.Token() //the closing brace
+	return err
 }
@@ -0,0 +1,206 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const Version = "3.0.3"
+
+// Route describes one endpoint of a service.  The services keep their
+// routes in a table of these, Register wires the table into gin and New
+// generates the OpenAPI document from the same table, so the document
+// cannot drift away from the routes that are actually served.
+type Route struct {
+	Method  string
+	Path    string // gin syntax, eg "/polls/:id"
+	Summary string
+	Handler gin.HandlerFunc
+
+	// Query lists the names of the optional query parameters
+	Query []string
+
+	// Body is a value of the request body type, nil if the route does
+	// not take a body
+	Body any
+
+	Responses []Response
+}
+
+// Response is one of the success responses of a Route.  Body is a value
+// of the response body type, nil if the response has no body.
+type Response struct {
+	Status int
+	Body   any
+}
+
+func Reply(status int, body any) Response {
+	return Response{Status: status, Body: body}
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]ReplySpec `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type ReplySpec struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// New generates the document for routes.  Every operation also gets a
+// default response with errorBody, served as errorContentType, which is
+// how the services report failures.
+func New(info Info, routes []Route, errorBody any, errorContentType string) *Document {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      make(map[string]map[string]Operation),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+
+	errorSchema := doc.schemaOf(reflect.TypeOf(errorBody))
+	for _, rt := range routes {
+		path, params := openAPIPath(rt.Path)
+		op := Operation{
+			Summary:     rt.Summary,
+			OperationID: operationID(rt.Method, rt.Path),
+			Responses:   make(map[string]ReplySpec),
+		}
+		for _, name := range params {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+		for _, name := range rt.Query {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:   name,
+				In:     "query",
+				Schema: &Schema{Type: "string"},
+			})
+		}
+		if rt.Body != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  jsonContent("application/json", doc.schemaOf(reflect.TypeOf(rt.Body))),
+			}
+		}
+		for _, resp := range rt.Responses {
+			spec := ReplySpec{Description: http.StatusText(resp.Status)}
+			if resp.Body != nil {
+				spec.Content = jsonContent("application/json", doc.schemaOf(reflect.TypeOf(resp.Body)))
+			}
+			op.Responses[strconv.Itoa(resp.Status)] = spec
+		}
+		op.Responses["default"] = ReplySpec{
+			Description: "Error",
+			Content:     jsonContent(errorContentType, errorSchema),
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]Operation)
+		}
+		doc.Paths[path][strings.ToLower(rt.Method)] = op
+	}
+
+	doc.Paths["/openapi.json"] = map[string]Operation{
+		"get": {
+			Summary:     "Get this document",
+			OperationID: "getOpenapiJson",
+			Responses: map[string]ReplySpec{
+				"200": {Description: "OK", Content: jsonContent("application/json", &Schema{Type: "object"})},
+			},
+		},
+	}
+
+	return doc
+}
+
+// Register adds every route to r, and serves doc at /openapi.json
+func Register(r gin.IRouter, doc *Document, routes []Route) {
+	for _, rt := range routes {
+		r.Handle(rt.Method, rt.Path, rt.Handler)
+	}
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	})
+}
+
+func jsonContent(contentType string, s *Schema) map[string]MediaType {
+	return map[string]MediaType{contentType: {Schema: s}}
+}
+
+// openAPIPath turns a gin path into an OpenAPI path and returns the names
+// of its parameters, eg "/polls/:id" becomes "/polls/{id}"
+func openAPIPath(ginPath string) (string, []string) {
+	segments := strings.Split(ginPath, "/")
+	params := make([]string, 0)
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			name := seg[1:]
+			params = append(params, name)
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID builds a stable id from the method and path, eg
+// "GET /polls/:id/options" becomes "getPollsIdOptions"
+func operationID(method string, ginPath string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, seg := range strings.Split(ginPath, "/") {
+		seg = strings.TrimLeft(seg, ":*")
+		if seg == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(seg[:1]) + seg[1:])
+	}
+	if strings.HasSuffix(ginPath, "/") && ginPath != "/" {
+		b.WriteString("Slash")
+	}
+	return b.String()
+}
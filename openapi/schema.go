@@ -0,0 +1,137 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI schema object that we generate
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+const refPrefix = "#/components/schemas/"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// componentName is the name of t in the components.  Instances of generic
+// types are named after their type arguments without the package paths,
+// eg "Tombstone[poll-api/poll.Poll]" becomes "TombstonePoll".
+func componentName(t reflect.Type) string {
+	name, args, ok := strings.Cut(t.Name(), "[")
+	if !ok {
+		return name
+	}
+	for _, arg := range strings.Split(strings.TrimSuffix(args, "]"), ",") {
+		if i := strings.LastIndex(arg, "."); i >= 0 {
+			arg = arg[i+1:]
+		}
+		name += arg
+	}
+	return name
+}
+
+// schemaOf returns the schema for t.  Named struct types are added to the
+// components once and referenced from everywhere else.
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := componentName(t)
+		if _, ok := d.Components.Schemas[name]; !ok {
+			//reserve the name first, so recursive types terminate
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + name}
+	case t.Kind() == reflect.Struct:
+		return d.structSchema(t)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: "integer", Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaOf(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	default:
+		//interfaces and anything else we can not describe accept any value
+		return &Schema{}
+	}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		fs := d.schemaOf(f.Type)
+		rules := strings.Split(f.Tag.Get("binding"), ",")
+		for _, rule := range rules {
+			key, param, _ := strings.Cut(rule, "=")
+			n, err := strconv.Atoi(param)
+			switch {
+			case key == "required" || key == "notblank":
+				s.Required = append(s.Required, name)
+			case key == "min" && fs.Type == "array" && err == nil:
+				fs.MinItems = &n
+			case key == "len" && fs.Type == "array" && err == nil:
+				fs.MinItems, fs.MaxItems = &n, &n
+			}
+		}
+		if fs.MinItems != nil && *fs.MinItems > 0 {
+			//the validator counts a missing or null list as empty
+			s.Required = append(s.Required, name)
+			fs.Nullable = false
+		}
+		if fs.Type == "array" && strings.Contains(opts, "omitempty") {
+			//omitempty drops nil slices instead of sending null
+			fs.Nullable = false
+		}
+		s.Properties[name] = fs
+	}
+	return s
+}
@@ -0,0 +1,175 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// ValidateResponse checks a response against the document.  The path is
+// the concrete request path, eg "/polls/2", it is matched against the
+// templates in the document the same way gin would match it: literal
+// segments win over parameters.
+func (d *Document) ValidateResponse(method, path string, status int, contentType string, body []byte) error {
+	template, ok := d.matchPath(path)
+	if !ok {
+		return fmt.Errorf("%s %s: path is not in the document", method, path)
+	}
+	op, ok := d.Paths[template][strings.ToLower(method)]
+	if !ok {
+		return fmt.Errorf("%s %s: operation is not in the document", method, template)
+	}
+
+	spec, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		spec, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("%s %s: status %d is not documented", method, template, status)
+	}
+
+	if len(spec.Content) == 0 {
+		if len(bytes.TrimSpace(body)) != 0 {
+			return fmt.Errorf("%s %s: status %d should not have a body", method, template, status)
+		}
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("%s %s: bad content type %q: %w", method, template, contentType, err)
+	}
+	media, ok := spec.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("%s %s: status %d is not documented as %s", method, template, status, mediaType)
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("%s %s: body is not json: %w", method, template, err)
+	}
+	if err := d.validate(media.Schema, value, "body"); err != nil {
+		return fmt.Errorf("%s %s: status %d: %w", method, template, status, err)
+	}
+	return nil
+}
+
+func (d *Document) matchPath(path string) (string, bool) {
+	want := strings.Split(path, "/")
+	best, bestLiterals := "", -1
+	for template := range d.Paths {
+		have := strings.Split(template, "/")
+		if len(have) != len(want) {
+			continue
+		}
+		literals := 0
+		for i := range have {
+			if strings.HasPrefix(have[i], "{") {
+				if want[i] == "" {
+					literals = -1
+					break
+				}
+				continue
+			}
+			if have[i] != want[i] {
+				literals = -1
+				break
+			}
+			literals++
+		}
+		if literals > bestLiterals {
+			best, bestLiterals = template, literals
+		}
+	}
+	return best, bestLiterals >= 0
+}
+
+// validate checks a decoded json value against s, at is the location of
+// the value used in error messages
+func (d *Document) validate(s *Schema, value any, at string) error {
+	if s.Ref != "" {
+		ref, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+		if !ok {
+			return fmt.Errorf("%s: unknown schema %s", at, s.Ref)
+		}
+		return d.validate(ref, value, at)
+	}
+	if value == nil {
+		if s.Type == "" || s.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%s: is null, want %s", at, s.Type)
+	}
+
+	switch s.Type {
+	case "":
+		return nil
+	case "string":
+		if _, ok := value.(string); !ok {
+			return fmt.Errorf("%s: want a string, got %T", at, value)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: want a boolean, got %T", at, value)
+		}
+	case "integer", "number":
+		n, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s: want a %s, got %T", at, s.Type, value)
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("%s: want an integer, got %v", at, n)
+		}
+		if s.Minimum != nil && n < *s.Minimum {
+			return fmt.Errorf("%s: %v is less than %v", at, n, *s.Minimum)
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want an array, got %T", at, value)
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return fmt.Errorf("%s: has %d items, want at least %d", at, len(items), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return fmt.Errorf("%s: has %d items, want at most %d", at, len(items), *s.MaxItems)
+		}
+		for i, item := range items {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want an object, got %T", at, value)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s: missing required property %q", at, name)
+			}
+		}
+		for name, v := range obj {
+			ps, ok := s.Properties[name]
+			if !ok {
+				ps = s.AdditionalProperties
+			}
+			if ps == nil {
+				if s.Properties != nil {
+					return fmt.Errorf("%s: unexpected property %q", at, name)
+				}
+				continue
+			}
+			if err := d.validate(ps, v, at+"."+name); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported schema type %s", at, s.Type)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package problem renders the errors of the apis as RFC 7807 problem
+// details documents, so that clients only need to handle one error format.
+//
+// The handlers record an error with Abort or AbortBadRequest.  Middleware
+// picks the status code for it with the Options of the api and writes the
+// document.  Gin collects the errors in c.Errors, which keeps the error
+// formatting in this one place instead of in every handler.
+package problem
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// ContentType is the media type RFC 7807 defines for error responses
+const ContentType = "application/problem+json"
+
+// ServerErrorDetail is the detail of every 5xx problem.  The error itself
+// only goes to the log, it can tell a client more about the server than it
+// should know.
+const ServerErrorDetail = "the server could not complete the request"
+
+// Problem is an RFC 7807 problem details document.  We only use the
+// "about:blank" type, which means the title is always the standard text
+// for the HTTP status code.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Errors lists the fields that failed validation, it is only
+	// filled in for 422 responses
+	Errors []FieldError `json:"errors,omitempty"`
+
+	// Confirm is the token that confirms a bulk delete, it is only
+	// filled in for 428 responses.  Repeat the request with
+	// ?confirm=<token>
+	Confirm string `json:"confirm,omitempty"`
+}
+
+// FieldError is a field of a request that failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func New(status int, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Status maps the errors that are Err, in the sense of errors.Is, to the
+// HTTP status code Code
+type Status struct {
+	Err  error
+	Code int
+}
+
+// Options are how Middleware turns the errors of an api into problems
+type Options struct {
+	// Statuses are tried in order, the first one that matches wins
+	Statuses []Status
+
+	// Convert, if it is set, replaces an error before it is matched, eg
+	// the validator's own error type with the one of the api
+	Convert func(err error) error
+
+	// Extend, if it is set, fills in the members of p that err adds, such
+	// as Errors and Confirm.  It is not called for 5xx problems.
+	Extend func(p *Problem, err error)
+}
+
+// StatusOf maps err to the first of the Statuses that matches.  Errors
+// that were recorded as bind errors (bad JSON, bad path parameters) are
+// client errors, anything else is a server error.
+func (o Options) StatusOf(err *gin.Error) int {
+	for _, s := range o.Statuses {
+		if errors.Is(err.Err, s.Err) {
+			return s.Code
+		}
+	}
+	if err.IsType(gin.ErrorTypeBind) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// Middleware runs the handler and, if the handler recorded an error with
+// Abort or AbortBadRequest, renders the last one as problem+json.  It does
+// nothing if the handler already wrote a response.
+func Middleware(opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last()
+		if opts.Convert != nil {
+			err.Err = opts.Convert(err.Err)
+		}
+		p := New(opts.StatusOf(err), err.Error())
+		p.Instance = c.Request.URL.Path
+		if p.Status >= http.StatusInternalServerError {
+			log.Printf("%s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+			p.Detail = ServerErrorDetail
+		} else if opts.Extend != nil {
+			opts.Extend(&p, err.Err)
+		}
+
+		c.Header("Content-Type", ContentType)
+		c.JSON(p.Status, p)
+	}
+}
+
+// Abort records err on the context and stops the handler chain, the
+// middleware picks the status code from the error
+func Abort(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// AbortBadRequest is like Abort, but for errors caused by a malformed
+// request, such as a path parameter that is not a number
+func AbortBadRequest(c *gin.Context, err error) {
+	c.Error(err).SetType(gin.ErrorTypeBind)
+	c.Abort()
+}
+
+// RegisterValidators hands the validator gin uses when it binds request
+// bodies to register, which adds the custom validation rules of an api.
+// Call it before starting the server.
+func RegisterValidators(register func(v *validator.Validate) error) error {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin is not using the go-playground validator")
+	}
+	return register(v)
+}
@@ -0,0 +1,114 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"problem"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errNotFound = errors.New("not found")
+	errConfirm  = errors.New("confirmation required")
+)
+
+var opts = problem.Options{
+	Statuses: []problem.Status{
+		{Err: errNotFound, Code: http.StatusNotFound},
+		{Err: errConfirm, Code: http.StatusPreconditionRequired},
+	},
+	Extend: func(p *problem.Problem, err error) {
+		if errors.Is(err, errConfirm) {
+			p.Confirm = "token"
+		}
+	},
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name        string
+		abort       func(c *gin.Context)
+		wantStatus  int
+		wantDetail  string
+		wantConfirm string
+	}{
+		{
+			name:       "a mapped error",
+			abort:      func(c *gin.Context) { problem.Abort(c, fmt.Errorf("%w: item 1", errNotFound)) },
+			wantStatus: http.StatusNotFound,
+			wantDetail: "not found: item 1",
+		},
+		{
+			name:       "a mapped error wins over bind",
+			abort:      func(c *gin.Context) { problem.AbortBadRequest(c, errNotFound) },
+			wantStatus: http.StatusNotFound,
+			wantDetail: "not found",
+		},
+		{
+			name:       "a bind error",
+			abort:      func(c *gin.Context) { problem.AbortBadRequest(c, errors.New("id is not a number")) },
+			wantStatus: http.StatusBadRequest,
+			wantDetail: "id is not a number",
+		},
+		{
+			name:        "extended",
+			abort:       func(c *gin.Context) { problem.Abort(c, errConfirm) },
+			wantStatus:  http.StatusPreconditionRequired,
+			wantDetail:  "confirmation required",
+			wantConfirm: "token",
+		},
+		{
+			name:       "a server error hides its detail",
+			abort:      func(c *gin.Context) { problem.Abort(c, errors.New("dial tcp 10.0.0.7:6379: connection refused")) },
+			wantStatus: http.StatusInternalServerError,
+			wantDetail: problem.ServerErrorDetail,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(problem.Middleware(opts))
+			r.GET("/items/1", tt.abort)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+
+			if got := w.Header().Get("Content-Type"); got != problem.ContentType {
+				t.Errorf("got content type %q, want %q", got, problem.ContentType)
+			}
+			var p problem.Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+				t.Fatal(err)
+			}
+			want := problem.New(tt.wantStatus, tt.wantDetail)
+			want.Instance = "/items/1"
+			want.Confirm = tt.wantConfirm
+			if w.Code != tt.wantStatus || fmt.Sprint(p) != fmt.Sprint(want) {
+				t.Errorf("got %d %+v, want %+v", w.Code, p, want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareLeavesWrittenResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(problem.Middleware(opts))
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusAccepted, "done")
+		problem.Abort(c, errNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "done" {
+		t.Errorf("got %d %q, want the handler's response", w.Code, w.Body.String())
+	}
+}
@@ -1,27 +1,32 @@
 package api
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 
 	"drexel.edu/todo/db"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 // The api package creates and maintains a reference to the data handler
 // this is a good design practice
 type ToDoAPI struct {
 	db *db.ToDo
+
+	//tokens that confirm DELETE /todo, see DeleteAllToDo
+	confirmations *db.Confirmations
 }
 
-func New() (*ToDoAPI, error) {
-	dbHandler, err := db.New()
+func New(client redis.UniversalClient) (*ToDoAPI, error) {
+	dbHandler, err := db.New(client)
 	if err != nil {
 		return nil, err
 	}
 
-	return &ToDoAPI{db: dbHandler}, nil
+	return &ToDoAPI{db: dbHandler, confirmations: db.NewConfirmations()}, nil
 }
 
 //Below we implement the API functions.  Some of the framework
@@ -212,9 +217,23 @@ func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 }
 
 // implementation for DELETE /todo
-// deletes all todos
+// deletes all todos, once confirmed
 func (td *ToDoAPI) DeleteAllToDo(c *gin.Context) {
 
+	//the first call answers 428 with a token, the items are only
+	//deleted when the call is repeated with ?confirm=<token>
+	err := td.confirmations.Confirm("delete all items", c.Query("confirm"))
+	var ce *db.ConfirmationError
+	if errors.As(err, &ce) {
+		c.AbortWithStatusJSON(http.StatusPreconditionRequired, gin.H{"error": ce.Error(), "confirm": ce.Token})
+		return
+	}
+	if err != nil {
+		log.Println("Error confirming the delete: ", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
 	if err := td.db.DeleteAll(); err != nil {
 		log.Println("Error deleting all items: ", err)
 		c.AbortWithStatus(http.StatusBadRequest)
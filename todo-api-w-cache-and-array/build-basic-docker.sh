@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag todo-api-basic:v1  -f ./dockerfile.basic .
+docker build --tag todo-api-basic:v1  --build-context config=../config -f ./dockerfile.basic .
@@ -1,2 +1,2 @@
 #!/bin/bash
-docker build --tag todo-api-basic:v2  -f ./dockerfile.better .
+docker build --tag todo-api-basic:v2  --build-context config=../config -f ./dockerfile.better .
@@ -1,12 +1,12 @@
 package db
 
 import (
+	"config"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
@@ -32,11 +32,36 @@ const (
 )
 
 type cache struct {
-	cacheClient *redis.Client
+	cacheClient redis.UniversalClient
 	jsonHelper  *rejson.Handler
 	context     context.Context
 }
 
+// keys returns the keys that match pattern, see config.Keys
+func (c *cache) keys(pattern string) ([]string, error) {
+	return config.Keys(c.context, c.cacheClient, pattern)
+}
+
+// del deletes the keys one by one in a pipeline, a DEL of many keys fails
+// in a cluster when they are in different slots.  It returns how many it
+// deleted.
+func (c *cache) del(keys ...string) (int64, error) {
+	cmds, err := c.cacheClient.Pipelined(c.context, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(c.context, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, cmd := range cmds {
+		n += cmd.(*redis.IntCmd).Val()
+	}
+	return n, nil
+}
+
 // ToDo is the struct that represents the main object of our
 // todo app.  It contains a reference to a cache object
 type ToDo struct {
@@ -46,30 +71,21 @@ type ToDo struct {
 	cache
 }
 
-// New is a constructor function that returns a pointer to a new
-// ToDo struct.  If this is called it uses the default Redis URL
-// with the companion constructor NewWithCacheInstance.
-func New() (*ToDo, error) {
-	//We will use an override if the REDIS_URL is provided as an environment
-	//variable, which is the preferred way to wire up a docker container
-	redisUrl := os.Getenv("REDIS_URL")
-	//This handles the default condition
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
-	}
-	return NewWithCacheInstance(redisUrl)
-}
-
 // NewWithCacheInstance is a constructor function that returns a pointer to a new
 // ToDo struct.  It accepts a string that represents the location of the redis
-// cache.
+// cache, use New for a sentinel or a cluster.
 func NewWithCacheInstance(location string) (*ToDo, error) {
+	return New(redis.NewClient(&redis.Options{Addr: location}))
+}
 
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
+// New is a constructor function that returns a pointer to a new
+// ToDo struct.  It keeps the todos with client, which main connects
+// from its configuration to a single server, a sentinel or a cluster, or
+// at RedisDefaultLocation when client is nil.
+func New(client redis.UniversalClient) (*ToDo, error) {
+	if client == nil {
+		client = redis.NewClient(&redis.Options{Addr: RedisDefaultLocation})
+	}
 
 	//We use this context to coordinate betwen our go code and
 	//the redis operaitons
@@ -209,11 +225,13 @@ func (t *ToDo) DeleteItem(id int) error {
 func (t *ToDo) DeleteAll() error {
 
 	pattern := RedisKeyPrefix + "*"
-	ks, _ := t.cacheClient.Keys(t.context, pattern).Result()
-	//Note delete can take a collection of keys.  In go we can
-	//expand a slice into individual arguments by using the ...
-	//operator
-	numDeleted, err := t.cacheClient.Del(t.context, ks...).Result()
+	ks, err := t.keys(pattern)
+	if err != nil {
+		return err
+	}
+	//Note del takes a collection of keys.  In go we can expand a
+	//slice into individual arguments by using the ... operator
+	numDeleted, err := t.del(ks...)
 	if err != nil {
 		return err
 	}
@@ -331,7 +349,10 @@ func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
 
 	//Lets query redis for all of the items
 	pattern := RedisKeyPrefix + "*"
-	ks, _ := t.cacheClient.Keys(t.context, pattern).Result()
+	ks, err := t.keys(pattern)
+	if err != nil {
+		return nil, err
+	}
 	for _, key := range ks {
 		err := t.getItemFromRedis(key, &toDoItem)
 		if err != nil {
@@ -3,25 +3,34 @@ package api
 import (
 	"log"
 	"net/http"
+	"problem"
 	"strconv"
+	"time"
 
 	"drexel.edu/todo/db"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
 // The api package creates and maintains a reference to the data handler
 // this is a good design practice
 type ToDoAPI struct {
 	db *db.ToDo
+
+	//tokens that confirm the bulk deletes, see DeleteAllToDo
+	confirmations *db.Confirmations
 }
 
-func New() (*ToDoAPI, error) {
-	dbHandler, err := db.New()
+// New keeps the todos with client, the deleted todos stay in the trash
+// for retention
+func New(client redis.UniversalClient, retention time.Duration) (*ToDoAPI, error) {
+	dbHandler, err := db.New(client)
 	if err != nil {
 		return nil, err
 	}
+	dbHandler.SetRetention(retention)
 
-	return &ToDoAPI{db: dbHandler}, nil
+	return &ToDoAPI{db: dbHandler, confirmations: db.NewConfirmations()}, nil
 }
 
 //Below we implement the API functions.  Some of the framework
@@ -32,17 +41,25 @@ func New() (*ToDoAPI, error) {
 //   3) How to return JSON and a correctly formed HTTP status code
 //	  for example, 200 for OK, 404 for not found, etc.  This is done
 //	  using the c.JSON() function
-//   4) How to return an error code and abort the request.  This is
-//	  done using the c.AbortWithStatus() function
+//   4) How to return an error and abort the request.  This is
+//	  done using the problem.Abort() helper, the problem middleware
+//	  then turns the error into a problem+json response with the
+//	  right status code (see problem.go)
+//   5) Every todo handler starts with td.store(), which returns the list
+//	  of the user the request is for (see lists.go)
 
 // implementation for GET /todo
 // returns all todos
 func (td *ToDoAPI) ListAllTodos(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
 
-	todoList, err := td.db.GetAllItems()
+	todoList, err := list.GetAllItems()
 	if err != nil {
 		log.Println("Error Getting All Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 	//Note that the database returns a nil slice if there are no items
@@ -63,11 +80,16 @@ func (td *ToDoAPI) ListAllTodos(c *gin.Context) {
 // todos that are done.  Note you can have multiple
 // query parameters, for example /v2/todo?done=true&foo=bar
 func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
+
 	//lets first load the data
-	todoList, err := td.db.GetAllItems()
+	todoList, err := list.GetAllItems()
 	if err != nil {
 		log.Println("Error Getting Database Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 	//If the database is empty, make an empty slice so that the
@@ -92,7 +114,7 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 	done, err := strconv.ParseBool(doneS)
 	if err != nil {
 		log.Println("Error converting done to bool: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -120,6 +142,10 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 // implementation for GET /todo/:id
 // returns a single todo
 func (td *ToDoAPI) GetToDo(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
 
 	//Note go is minimalistic, so we have to get the
 	//id parameter using the Param() function, and then
@@ -128,16 +154,16 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 	id64, err := strconv.ParseInt(idS, 10, 32)
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	//Note that ParseInt always returns an int64, so we have to
 	//convert it to an int before we can use it.
-	todoItem, err := td.db.GetItem(int(id64))
+	todoItem, err := list.GetItem(int(id64))
 	if err != nil {
 		log.Println("Item not found: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -147,8 +173,31 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 }
 
 // implementation for POST /todo
-// adds a new todo
+// adds a new todo, the server picks the id and returns it in the
+// Location header.  If the client sends an Idempotency-Key header
+// a retried request returns the item created the first time
+// instead of adding a duplicate
 func (td *ToDoAPI) AddToDo(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
+
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey != "" {
+		todoItem, ok, err := list.RecallItem(idempotencyKey)
+		if err != nil {
+			log.Println("Error recalling idempotency key: ", err)
+			problem.Abort(c, err)
+			return
+		}
+		if ok {
+			c.Header("Location", itemLocation(c, todoItem.Id))
+			c.JSON(http.StatusCreated, todoItem)
+			return
+		}
+	}
+
 	var todoItem db.ToDoItem
 
 	//With HTTP based APIs, a POST request will usually
@@ -164,47 +213,113 @@ func (td *ToDoAPI) AddToDo(c *gin.Context) {
 	//the struct we are binding to.
 	if err := c.ShouldBindJSON(&todoItem); err != nil {
 		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
-	if err := td.db.AddItem(todoItem); err != nil {
+	//Any id in the body is ignored, the database assigns the next one
+	todoItem, _, err := list.CreateItemOnce(idempotencyKey, todoItem)
+	if err != nil {
 		log.Println("Error adding item: ", err)
-		c.AbortWithStatus(http.StatusConflict)
+		problem.Abort(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, todoItem)
+	//201 Created plus a Location header is how REST APIs tell the
+	//client where the new resource lives
+	c.Header("Location", itemLocation(c, todoItem.Id))
+	c.JSON(http.StatusCreated, todoItem)
+}
+
+// itemLocation is the path of the item id in the list of the request,
+// /todo/<id> or /lists/<listId>/todo/<id>
+func itemLocation(c *gin.Context, id int) string {
+	if listId := c.Param("listId"); listId != "" {
+		return "/lists/" + listId + "/todo/" + strconv.Itoa(id)
+	}
+	return "/todo/" + strconv.Itoa(id)
 }
 
 // implementation for PUT /todo
 // Web api standards use PUT for Updates
 func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
+
 	var todoItem db.ToDoItem
 	if err := c.ShouldBindJSON(&todoItem); err != nil {
 		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
-	if err := td.db.UpdateItem(todoItem); err != nil {
+	//A version in the body must match the stored one, 409 otherwise
+	todoItem, err := list.UpdateItem(todoItem)
+	if err != nil {
 		log.Println("Error updating item: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, todoItem)
 }
 
+// implementation for GET /todo/:id/children
+// returns the subtasks of a todo
+func (td *ToDoAPI) GetToDoChildren(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
+
+	idS := c.Param("id")
+	id64, err := strconv.ParseInt(idS, 10, 32)
+	if err != nil {
+		log.Println("Error converting id to int64: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	children, err := list.GetSubtasks(int(id64))
+	if err != nil {
+		log.Println("Error getting subtasks: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, children)
+}
+
 // implementation for DELETE /todo/:id
-// deletes a todo
+// moves a todo to the trash.  The cascade query parameter says what happens to
+// its subtasks: restrict (the default) refuses with 409, orphan moves
+// them up to the parent of the todo and delete deletes them too
 func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
+
 	idS := c.Param("id")
-	id64, _ := strconv.ParseInt(idS, 10, 32)
+	id64, err := strconv.ParseInt(idS, 10, 32)
+	if err != nil {
+		log.Println("Error converting id to int64: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	cascade, err := db.ParseCascade(c.Query("cascade"))
+	if err != nil {
+		log.Println("Error parsing cascade: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
 
-	if err := td.db.DeleteItem(int(id64)); err != nil {
+	if err := list.DeleteItem(int(id64), cascade); err != nil {
 		log.Println("Error deleting item: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -212,12 +327,23 @@ func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 }
 
 // implementation for DELETE /todo
-// deletes all todos
+// moves all todos to the trash, once confirmed
 func (td *ToDoAPI) DeleteAllToDo(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
+
+	//the first call answers 428 with a token, the items are only
+	//deleted when the call is repeated with ?confirm=<token>
+	if err := td.confirmations.Confirm("delete all items of "+list.ListId(), c.Query("confirm")); err != nil {
+		problem.Abort(c, err)
+		return
+	}
 
-	if err := td.db.DeleteAll(); err != nil {
+	if err := list.DeleteAll(); err != nil {
 		log.Println("Error deleting all items: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"problem"
+
+	"drexel.edu/todo/db"
+	"github.com/gin-gonic/gin"
+)
+
+// UserHeader names the user a request is made for.  The API trusts it,
+// it is meant to be set by an authenticating proxy in front of the API.
+// Requests without it are made for db.DefaultUser.
+const UserHeader = "X-User"
+
+// NewList is the body of POST /lists
+type NewList struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// Membership is the body of PUT /lists/:listId/members/:user
+type Membership struct {
+	Role db.Role `json:"role" binding:"required,oneof=read write"`
+}
+
+// userOf returns the user the request is made for
+func userOf(c *gin.Context) (string, error) {
+	user := c.GetHeader(UserHeader)
+	if user == "" {
+		return db.DefaultUser, nil
+	}
+	if err := db.CheckName("user", user); err != nil {
+		return "", err
+	}
+	return user, nil
+}
+
+// store returns the items of the list the request is for: the list in
+// the listId path parameter, or the default list of the user for the
+// /todo routes.  Requests other than GET need a write member.  If the
+// user may not use the list the request is aborted and ok is false.
+func (td *ToDoAPI) store(c *gin.Context) (list *db.ToDo, ok bool) {
+	user, err := userOf(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return nil, false
+	}
+	listId := c.Param("listId")
+	if listId == "" {
+		return td.db.List(user, db.DefaultList), true
+	}
+
+	info, ok := td.listInfo(c, user, listId)
+	if !ok {
+		return nil, false
+	}
+	if err := info.Allows(user, c.Request.Method != http.MethodGet); err != nil {
+		problem.Abort(c, err)
+		return nil, false
+	}
+	return td.db.List(info.Owner, info.Name), true
+}
+
+// listInfo reads the list listId, the request is aborted if it fails
+func (td *ToDoAPI) listInfo(c *gin.Context, user string, listId string) (db.ListInfo, bool) {
+	owner, name, err := db.ParseListId(listId, user)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return db.ListInfo{}, false
+	}
+	info, err := td.db.GetList(owner, name)
+	if err != nil {
+		log.Println("Error getting list: ", err)
+		problem.Abort(c, err)
+		return db.ListInfo{}, false
+	}
+	return info, true
+}
+
+// memberList is like listInfo, but only the owner and the members of the
+// list get it
+func (td *ToDoAPI) memberList(c *gin.Context) (string, db.ListInfo, bool) {
+	user, err := userOf(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return "", db.ListInfo{}, false
+	}
+	info, ok := td.listInfo(c, user, c.Param("listId"))
+	if !ok {
+		return "", db.ListInfo{}, false
+	}
+	if err := info.Allows(user, false); err != nil {
+		problem.Abort(c, err)
+		return "", db.ListInfo{}, false
+	}
+	return user, info, true
+}
+
+// implementation for GET /lists
+// returns the lists the user owns or is a member of
+func (td *ToDoAPI) ListLists(c *gin.Context) {
+	user, err := userOf(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	lists, err := td.db.ListsOf(user)
+	if err != nil {
+		log.Println("Error getting lists: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, lists)
+}
+
+// implementation for POST /lists
+// creates a list owned by the user
+func (td *ToDoAPI) CreateList(c *gin.Context) {
+	user, err := userOf(c)
+	if err != nil {
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	var newList NewList
+	if err := c.ShouldBindJSON(&newList); err != nil {
+		log.Println("Error binding JSON: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	info, err := td.db.CreateList(user, newList.Name)
+	if err != nil {
+		log.Println("Error creating list: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	c.Header("Location", "/lists/"+info.Id)
+	c.JSON(http.StatusCreated, info)
+}
+
+// implementation for GET /lists/:listId
+// returns the list and its members
+func (td *ToDoAPI) GetList(c *gin.Context) {
+	_, info, ok := td.memberList(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+// implementation for DELETE /lists/:listId
+// deletes the list and its items, only the owner can
+func (td *ToDoAPI) DeleteList(c *gin.Context) {
+	user, info, ok := td.memberList(c)
+	if !ok {
+		return
+	}
+	if info.RoleOf(user) != db.RoleOwner {
+		problem.Abort(c, fmt.Errorf("%w: only %s can delete list %s", db.ErrForbidden, info.Owner, info.Id))
+		return
+	}
+	if err := td.confirmations.Confirm("delete list "+info.Id, c.Query("confirm")); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
+	if err := td.db.DeleteList(info.Owner, info.Name); err != nil {
+		log.Println("Error deleting list: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// implementation for PUT /lists/:listId/members/:user
+// shares the list with a user, or changes their role, only the owner can
+func (td *ToDoAPI) ShareList(c *gin.Context) {
+	user, info, ok := td.memberList(c)
+	if !ok {
+		return
+	}
+	if info.RoleOf(user) != db.RoleOwner {
+		problem.Abort(c, fmt.Errorf("%w: only %s can share list %s", db.ErrForbidden, info.Owner, info.Id))
+		return
+	}
+
+	var membership Membership
+	if err := c.ShouldBindJSON(&membership); err != nil {
+		log.Println("Error binding JSON: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	info, err := td.db.ShareList(info.Owner, info.Name, c.Param("user"), membership.Role)
+	if err != nil {
+		log.Println("Error sharing list: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// implementation for DELETE /lists/:listId/members/:user
+// stops sharing the list with a user.  The owner can remove anybody, a
+// member can leave the list
+func (td *ToDoAPI) UnshareList(c *gin.Context) {
+	user, info, ok := td.memberList(c)
+	if !ok {
+		return
+	}
+	member := c.Param("user")
+	if info.RoleOf(user) != db.RoleOwner && member != user {
+		problem.Abort(c, fmt.Errorf("%w: only %s can remove other members from list %s", db.ErrForbidden, info.Owner, info.Id))
+		return
+	}
+
+	info, err := td.db.UnshareList(info.Owner, info.Name, member)
+	if err != nil {
+		log.Println("Error unsharing list: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
@@ -0,0 +1,71 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"drexel.edu/todo/api"
+	"drexel.edu/todo/db"
+)
+
+func TestAddToDoLocation(t *testing.T) {
+	tests := []struct {
+		name         string
+		path         string
+		wantLocation string
+	}{
+		{name: "the default list", path: "/todo", wantLocation: "/todo/1"},
+		{name: "a list of the user", path: "/lists/work/todo", wantLocation: "/lists/work/todo/1"},
+		{name: "a list with its owner", path: "/lists/alice:work/todo", wantLocation: "/lists/alice:work/todo/1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t)
+			expect(t, a.do(t, "alice", http.MethodPost, "/lists", api.NewList{Name: "work"}), http.StatusCreated, nil)
+
+			w := a.do(t, "alice", http.MethodPost, tt.path, db.ToDoItem{Title: "write the report"})
+			expect(t, w, http.StatusCreated, nil)
+			location := w.Header().Get("Location")
+			if location != tt.wantLocation {
+				t.Fatalf("got Location %q, want %q", location, tt.wantLocation)
+			}
+
+			//the Location is where the new item is
+			var item db.ToDoItem
+			expect(t, a.do(t, "alice", http.MethodGet, location, nil), http.StatusOK, &item)
+			if item.Title != "write the report" {
+				t.Errorf("got %+v", item)
+			}
+		})
+	}
+}
+
+func TestSharedList(t *testing.T) {
+	a := newTestAPI(t)
+	expect(t, a.do(t, "alice", http.MethodPost, "/lists", api.NewList{Name: "work"}), http.StatusCreated, nil)
+	expect(t, a.do(t, "alice", http.MethodPost, "/lists/work/todo", db.ToDoItem{Title: "plan"}), http.StatusCreated, nil)
+
+	//a list that is not shared with bob does not exist for him
+	expect(t, a.do(t, "bob", http.MethodGet, "/lists/alice:work/todo", nil), http.StatusNotFound, nil)
+
+	expect(t, a.do(t, "alice", http.MethodPut, "/lists/work/members/bob", api.Membership{Role: db.RoleRead}), http.StatusOK, nil)
+	var items []db.ToDoItem
+	expect(t, a.do(t, "bob", http.MethodGet, "/lists/alice:work/todo", nil), http.StatusOK, &items)
+	if len(items) != 1 || items[0].Title != "plan" {
+		t.Fatalf("got %+v", items)
+	}
+	expect(t, a.do(t, "bob", http.MethodPost, "/lists/alice:work/todo", db.ToDoItem{Title: "more"}), http.StatusForbidden, nil)
+
+	expect(t, a.do(t, "alice", http.MethodPut, "/lists/work/members/bob", api.Membership{Role: db.RoleWrite}), http.StatusOK, nil)
+	w := a.do(t, "bob", http.MethodPost, "/lists/alice:work/todo", db.ToDoItem{Title: "more"})
+	expect(t, w, http.StatusCreated, nil)
+	if got, want := w.Header().Get("Location"), "/lists/alice:work/todo/2"; got != want {
+		t.Errorf("got Location %q, want %q", got, want)
+	}
+
+	//the default lists of alice and bob are their own
+	expect(t, a.do(t, "bob", http.MethodGet, "/todo", nil), http.StatusOK, &items)
+	if len(items) != 0 {
+		t.Errorf("bob's default list has %+v", items)
+	}
+}
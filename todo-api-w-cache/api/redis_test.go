@@ -0,0 +1,117 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/api"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if err := api.RegisterValidators(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// testRedis runs miniredis for the length of the test.  miniredis does not
+// know the RedisJSON commands, a hook turns JSON.SET and JSON.GET on the
+// root path into SET and GET of a string key.  As they become plain redis
+// commands they take part in MULTI and WATCH like on a RedisJSON server.
+func testRedis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	m := miniredis.RunT(t)
+	srv := m.Server()
+	srv.SetPreHook(func(c *server.Peer, cmd string, args ...string) bool {
+		switch strings.ToUpper(cmd) {
+		case "JSON.SET":
+			if len(args) < 3 || len(args) > 4 || args[1] != "." || !json.Valid([]byte(args[2])) {
+				c.WriteError("ERR only JSON.SET <key> . <json> [NX|XX] is supported")
+				return true
+			}
+			set := []string{"SET", args[0], args[2]}
+			if len(args) == 4 {
+				set = append(set, strings.ToUpper(args[3]))
+			}
+			srv.Dispatch(c, set)
+			return true
+		case "JSON.GET":
+			if len(args) != 2 || args[1] != "." {
+				c.WriteError("ERR only JSON.GET <key> . is supported")
+				return true
+			}
+			srv.Dispatch(c, []string{"GET", args[0]})
+			return true
+		}
+		return false
+	})
+	return m
+}
+
+// testAPI is the todo api on miniredis, with its routes
+type testAPI struct {
+	*api.ToDoAPI
+	redis  *miniredis.Miniredis
+	router *gin.Engine
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	m := testRedis(t)
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	td, err := api.New(client, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(api.ProblemMiddleware())
+	td.RegisterRoutes(router)
+	return &testAPI{ToDoAPI: td, redis: m, router: router}
+}
+
+// do sends a request for user with body, if it is not nil, as json
+func (a *testAPI) do(t *testing.T, user, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set(api.UserHeader, user)
+	}
+	w := httptest.NewRecorder()
+	a.router.ServeHTTP(w, req)
+	return w
+}
+
+// expect checks the status of w and decodes its body into v, if v is not
+// nil
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got %d %s, want %d", w.Code, w.Body.String(), status)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("%v in %s", err, w.Body.String())
+		}
+	}
+}
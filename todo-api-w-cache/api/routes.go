@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"openapi"
+	"problem"
+
+	"drexel.edu/todo/db"
+	"github.com/gin-gonic/gin"
+)
+
+// Routes is the route table of the todo api.  Instead of calling r.GET()
+// and friends one by one in main.go, we describe every route here, with
+// the types of its request and response bodies.  RegisterRoutes uses
+// the table both to set up gin and to generate the OpenAPI document
+// that is served at /openapi.json
+func (td *ToDoAPI) Routes() []openapi.Route {
+	var (
+		ok       = openapi.Reply(http.StatusOK, nil)
+		item     = db.ToDoItem{}
+		itemList = []db.ToDoItem{}
+	)
+
+	//The todo routes work on the default list of the user, and on any
+	//list under /lists/:listId
+	todoRoutes := []openapi.Route{
+		{Method: http.MethodGet, Path: "/todo", Summary: "List all todos", Handler: td.ListAllTodos,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, itemList)}},
+		{Method: http.MethodPost, Path: "/todo", Summary: "Create a todo, the server assigns the id", Handler: td.AddToDo,
+			Body: item, Responses: []openapi.Response{openapi.Reply(http.StatusCreated, item)}},
+		{Method: http.MethodPut, Path: "/todo", Summary: "Update a todo, a version in the body must be the current one", Handler: td.UpdateToDo,
+			Body: item, Responses: []openapi.Response{openapi.Reply(http.StatusOK, item)}},
+		{Method: http.MethodDelete, Path: "/todo", Summary: "Move all todos to the trash, confirmed with a token", Handler: td.DeleteAllToDo,
+			Query: []string{"confirm"}, Responses: []openapi.Response{ok}},
+		{Method: http.MethodDelete, Path: "/todo/:id", Summary: "Move a todo to the trash, cascade is restrict, orphan or delete for its subtasks", Handler: td.DeleteToDo,
+			Query: []string{"cascade"}, Responses: []openapi.Response{ok}},
+		{Method: http.MethodGet, Path: "/todo/:id", Summary: "Get a todo", Handler: td.GetToDo,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, item)}},
+		{Method: http.MethodGet, Path: "/todo/:id/children", Summary: "List the subtasks of a todo", Handler: td.GetToDoChildren,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, itemList)}},
+		{Method: http.MethodGet, Path: "/trash", Summary: "List the deleted todos that can still be restored", Handler: td.ListTrash,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []db.Tombstone{})}},
+		{Method: http.MethodPost, Path: "/trash/:id/restore", Summary: "Restore a deleted todo", Handler: td.RestoreToDo,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, item)}},
+	}
+
+	routes := append([]openapi.Route{}, todoRoutes...)
+	for _, rt := range todoRoutes {
+		rt.Path = "/lists/:listId" + rt.Path
+		rt.Summary += ", in a list"
+		routes = append(routes, rt)
+	}
+
+	return append(routes, []openapi.Route{
+		{Method: http.MethodGet, Path: "/lists", Summary: "List the lists of the user, owned and shared", Handler: td.ListLists,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, []db.ListInfo{})}},
+		{Method: http.MethodPost, Path: "/lists", Summary: "Create a list owned by the user", Handler: td.CreateList,
+			Body: NewList{}, Responses: []openapi.Response{openapi.Reply(http.StatusCreated, db.ListInfo{})}},
+		{Method: http.MethodGet, Path: "/lists/:listId", Summary: "Get a list and its members", Handler: td.GetList,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, db.ListInfo{})}},
+		{Method: http.MethodDelete, Path: "/lists/:listId", Summary: "Delete a list and its todos for good, confirmed with a token", Handler: td.DeleteList,
+			Query: []string{"confirm"}, Responses: []openapi.Response{ok}},
+		{Method: http.MethodPut, Path: "/lists/:listId/members/:user", Summary: "Share a list with a user, as a read or a write member", Handler: td.ShareList,
+			Body: Membership{}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, db.ListInfo{})}},
+		{Method: http.MethodDelete, Path: "/lists/:listId/members/:user", Summary: "Stop sharing a list with a user", Handler: td.UnshareList,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, db.ListInfo{})}},
+
+		{Method: http.MethodGet, Path: "/crash", Summary: "Simulate a crash", Handler: td.CrashSim},
+		{Method: http.MethodGet, Path: "/health", Summary: "Report the health of the api", Handler: td.HealthCheck,
+			Responses: []openapi.Response{openapi.Reply(http.StatusOK, map[string]any{})}},
+
+		//Version 2 of the api, it supports a done query parameter
+		{Method: http.MethodGet, Path: "/v2/todo", Summary: "List todos, optionally filtered by done status", Handler: td.ListSelectTodos,
+			Query: []string{"done"}, Responses: []openapi.Response{openapi.Reply(http.StatusOK, itemList)}},
+	}...)
+}
+
+// RegisterRoutes adds the routes to r, serves the OpenAPI document at
+// /openapi.json and returns the document
+func (td *ToDoAPI) RegisterRoutes(r gin.IRouter) *openapi.Document {
+	routes := td.Routes()
+	doc := openapi.New(openapi.Info{Title: "todo-api", Version: "1.0.0"}, routes, problem.Problem{}, problem.ContentType)
+	openapi.Register(r, doc, routes)
+	return doc
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"problem"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// implementation for GET /trash
+// returns the deleted todos that can still be restored
+func (td *ToDoAPI) ListTrash(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
+
+	trash, err := list.GetTrash()
+	if err != nil {
+		log.Println("Error getting the trash: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, trash)
+}
+
+// implementation for POST /trash/:id/restore
+// moves a deleted todo back, 409 if a new todo took its id meanwhile
+func (td *ToDoAPI) RestoreToDo(c *gin.Context) {
+	list, ok := td.store(c)
+	if !ok {
+		return
+	}
+
+	idS := c.Param("id")
+	id64, err := strconv.ParseInt(idS, 10, 32)
+	if err != nil {
+		log.Println("Error converting id to int64: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	item, err := list.RestoreItem(int(id64))
+	if err != nil {
+		log.Println("Error restoring item: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// StartPurger drops the todos whose retention period is over, from every
+// list, every interval until the returned function is called
+func (td *ToDoAPI) StartPurger(every time.Duration) (stop func()) {
+	return td.db.StartPurger(every)
+}
@@ -0,0 +1,143 @@
+package api_test
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"drexel.edu/todo/api"
+	"drexel.edu/todo/db"
+	"problem"
+)
+
+// confirmToken asks for the bulk delete path without a token and returns
+// the one the 428 hands out
+func confirmToken(t *testing.T, a *testAPI, path string) string {
+	t.Helper()
+	var p problem.Problem
+	expect(t, a.do(t, "alice", http.MethodDelete, path, nil), http.StatusPreconditionRequired, &p)
+	if p.Confirm == "" {
+		t.Fatalf("the 428 has no token: %+v", p)
+	}
+	return p.Confirm
+}
+
+func (a *testAPI) titles(t *testing.T, path string) []string {
+	t.Helper()
+	var items []db.ToDoItem
+	expect(t, a.do(t, "alice", http.MethodGet, path, nil), http.StatusOK, &items)
+	titles := []string{}
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+	return titles
+}
+
+func TestTrashRestore(t *testing.T) {
+	a := newTestAPI(t)
+	expect(t, a.do(t, "alice", http.MethodPost, "/todo", db.ToDoItem{Title: "plan"}), http.StatusCreated, nil)
+	expect(t, a.do(t, "alice", http.MethodPost, "/todo", db.ToDoItem{Title: "build"}), http.StatusCreated, nil)
+
+	expect(t, a.do(t, "alice", http.MethodDelete, "/todo/1", nil), http.StatusOK, nil)
+	expect(t, a.do(t, "alice", http.MethodGet, "/todo/1", nil), http.StatusNotFound, nil)
+	var trash []db.Tombstone
+	expect(t, a.do(t, "alice", http.MethodGet, "/trash", nil), http.StatusOK, &trash)
+	if len(trash) != 1 || trash[0].Item.Title != "plan" || !trash[0].PurgeAt.After(trash[0].DeletedAt) {
+		t.Fatalf("got the trash %+v", trash)
+	}
+
+	var restored db.ToDoItem
+	expect(t, a.do(t, "alice", http.MethodPost, "/trash/1/restore", nil), http.StatusOK, &restored)
+	if restored.Id != 1 || restored.Title != "plan" || restored.Version != 2 {
+		t.Errorf("got %+v", restored)
+	}
+	expect(t, a.do(t, "alice", http.MethodGet, "/trash", nil), http.StatusOK, &trash)
+	if len(trash) != 0 {
+		t.Errorf("the trash still has %+v", trash)
+	}
+	expect(t, a.do(t, "alice", http.MethodPost, "/trash/1/restore", nil), http.StatusNotFound, nil)
+	expect(t, a.do(t, "alice", http.MethodPost, "/trash/x/restore", nil), http.StatusBadRequest, nil)
+}
+
+func TestDeleteAllConfirmation(t *testing.T) {
+	tests := []struct {
+		name       string
+		confirm    func(t *testing.T, a *testAPI) string // the confirm query
+		wantStatus int
+		wantTitles []string
+	}{
+		{
+			name:       "no token",
+			confirm:    func(t *testing.T, a *testAPI) string { return "" },
+			wantStatus: http.StatusPreconditionRequired,
+			wantTitles: []string{"plan", "build"},
+		},
+		{
+			name:       "a made up token",
+			confirm:    func(t *testing.T, a *testAPI) string { return "?confirm=0123456789abcdef" },
+			wantStatus: http.StatusPreconditionRequired,
+			wantTitles: []string{"plan", "build"},
+		},
+		{
+			name:       "the token",
+			confirm:    func(t *testing.T, a *testAPI) string { return "?confirm=" + confirmToken(t, a, "/todo") },
+			wantStatus: http.StatusOK,
+			wantTitles: []string{},
+		},
+		{
+			name: "a token that was used",
+			confirm: func(t *testing.T, a *testAPI) string {
+				token := confirmToken(t, a, "/todo")
+				expect(t, a.do(t, "alice", http.MethodDelete, "/todo?confirm="+token, nil), http.StatusOK, nil)
+				expect(t, a.do(t, "alice", http.MethodPost, "/todo", db.ToDoItem{Title: "again"}), http.StatusCreated, nil)
+				return "?confirm=" + token
+			},
+			wantStatus: http.StatusPreconditionRequired,
+			wantTitles: []string{"again"},
+		},
+		{
+			name:       "the token of another list",
+			confirm:    func(t *testing.T, a *testAPI) string { return "?confirm=" + confirmToken(t, a, "/lists/work/todo") },
+			wantStatus: http.StatusPreconditionRequired,
+			wantTitles: []string{"plan", "build"},
+		},
+		{
+			name:       "the token of deleting the list",
+			confirm:    func(t *testing.T, a *testAPI) string { return "?confirm=" + confirmToken(t, a, "/lists/work") },
+			wantStatus: http.StatusPreconditionRequired,
+			wantTitles: []string{"plan", "build"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t)
+			expect(t, a.do(t, "alice", http.MethodPost, "/lists", api.NewList{Name: "work"}), http.StatusCreated, nil)
+			expect(t, a.do(t, "alice", http.MethodPost, "/todo", db.ToDoItem{Title: "plan"}), http.StatusCreated, nil)
+			expect(t, a.do(t, "alice", http.MethodPost, "/todo", db.ToDoItem{Title: "build"}), http.StatusCreated, nil)
+
+			expect(t, a.do(t, "alice", http.MethodDelete, "/todo"+tt.confirm(t, a), nil), tt.wantStatus, nil)
+			if got := a.titles(t, "/todo"); !reflect.DeepEqual(got, tt.wantTitles) {
+				t.Errorf("got %q, want %q", got, tt.wantTitles)
+			}
+		})
+	}
+}
+
+// TestDeleteAllToTrash has the items a confirmed DELETE /todo took in the
+// trash, from where they can be restored
+func TestDeleteAllToTrash(t *testing.T) {
+	a := newTestAPI(t)
+	expect(t, a.do(t, "alice", http.MethodPost, "/todo", db.ToDoItem{Title: "plan"}), http.StatusCreated, nil)
+	expect(t, a.do(t, "alice", http.MethodPost, "/todo", db.ToDoItem{Title: "build"}), http.StatusCreated, nil)
+	expect(t, a.do(t, "alice", http.MethodDelete, "/todo?confirm="+confirmToken(t, a, "/todo"), nil), http.StatusOK, nil)
+
+	var trash []db.Tombstone
+	expect(t, a.do(t, "alice", http.MethodGet, "/trash", nil), http.StatusOK, &trash)
+	if len(trash) != 2 {
+		t.Fatalf("got the trash %+v", trash)
+	}
+	expect(t, a.do(t, "alice", http.MethodPost, "/trash/2/restore", nil), http.StatusOK, nil)
+	if got, want := a.titles(t, "/todo"), []string{"build"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
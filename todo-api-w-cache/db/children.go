@@ -0,0 +1,122 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// Every parent has a set with the ids of its subtasks,
+// todo-children:<owner>:<list>:<parent>, so that GetItem and GetSubtasks
+// read the subtasks instead of the whole list.  An id is added to the set
+// before the item is written with that parent and taken off after the item
+// left it, so the set can hold an id too many but never misses one.  The
+// ids are checked against the items when they are read.
+
+const (
+	RedisChildrenPrefix = "todo-children:"
+
+	//childrenIndexedKey says that the items stored before there were
+	//children sets were added to them, see indexChildren
+	childrenIndexedKey = "todo-children-indexed"
+)
+
+// childrenPrefix is the part of the children keys that names the list,
+// todo-children:<owner>:<list>:
+func (t *ToDo) childrenPrefix() string {
+	return RedisChildrenPrefix + t.owner + ":" + t.list + ":"
+}
+
+func (t *ToDo) childrenKey(parent int) string {
+	return fmt.Sprintf("%s%d", t.childrenPrefix(), parent)
+}
+
+// addChild adds item to the set of its parent, if it has one
+func (t *ToDo) addChild(item ToDoItem) error {
+	if item.ParentId == 0 {
+		return nil
+	}
+	return t.cacheClient.SAdd(t.context, t.childrenKey(item.ParentId), item.Id).Err()
+}
+
+// removeChild takes id off the set of parent
+func (t *ToDo) removeChild(parent int, id int) error {
+	if parent == 0 {
+		return nil
+	}
+	return t.cacheClient.SRem(t.context, t.childrenKey(parent), id).Err()
+}
+
+// children reads the subtasks of id into a map by id
+func (t *ToDo) children(id int) (map[int]ToDoItem, error) {
+	members, err := t.cacheClient.SMembers(t.context, t.childrenKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	items := map[int]ToDoItem{}
+	for _, member := range members {
+		childId, err := strconv.Atoi(member)
+		if err != nil {
+			return nil, fmt.Errorf("%s holds %q, not an id", t.childrenKey(id), member)
+		}
+		var item ToDoItem
+		if err := t.getItemFromRedis(t.redisKeyFromId(childId), &item); err != nil {
+			//deleted since
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		//moved to another parent since
+		if item.ParentId != id {
+			continue
+		}
+		items[item.Id] = item
+	}
+	return items, nil
+}
+
+// indexChildren adds the items of every list that were stored before
+// there were children sets to the set of their parent.  It runs once,
+// SADD makes it safe to run it twice.
+func (t *ToDo) indexChildren() error {
+	n, err := t.cacheClient.Exists(t.context, childrenIndexedKey).Result()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	ks, err := t.keys(RedisKeyPrefix + "*")
+	if err != nil {
+		return err
+	}
+	indexed := 0
+	for _, key := range ks {
+		owner, rest, _ := strings.Cut(strings.TrimPrefix(key, RedisKeyPrefix), ":")
+		list, _, ok := strings.Cut(rest, ":")
+		if !ok {
+			//not in a list, see migrateLegacyKeys
+			continue
+		}
+		var item ToDoItem
+		if err := t.getItemFromRedis(key, &item); err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return err
+		}
+		if item.ParentId == 0 {
+			continue
+		}
+		if err := t.List(owner, list).addChild(item); err != nil {
+			return err
+		}
+		indexed++
+	}
+
+	if indexed > 0 {
+		log.Printf("Added %d subtasks to the sets of their parents", indexed)
+	}
+	return t.cacheClient.Set(t.context, childrenIndexedKey, 1, 0).Err()
+}
@@ -0,0 +1,163 @@
+package db_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/alicebob/miniredis/v2"
+)
+
+// plan is 1 with the subtasks 2 and 3, of which 3 is done, and 4 is a
+// subtask of 2
+var plan = []db.ToDoItem{
+	{Id: 1, Title: "project"},
+	{Id: 2, Title: "design", ParentId: 1},
+	{Id: 3, Title: "budget", ParentId: 1, IsDone: true},
+	{Id: 4, Title: "sketch", ParentId: 2},
+}
+
+// subtasksOf returns the progress of id and the ids of its subtasks
+func subtasksOf(t *testing.T, todo *db.ToDo, id int) (*db.Progress, []int) {
+	t.Helper()
+	item, err := todo.GetItem(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	children, err := todo.GetSubtasks(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, child := range children {
+		ids = append(ids, child.Id)
+	}
+	return item.Progress, ids
+}
+
+func TestSubtasks(t *testing.T) {
+	tests := []struct {
+		name         string
+		change       func(todo *db.ToDo) error
+		wantProgress *db.Progress
+		wantIds      []int
+	}{
+		{
+			name:         "the plan",
+			change:       func(todo *db.ToDo) error { return nil },
+			wantProgress: &db.Progress{Done: 1, Total: 2},
+			wantIds:      []int{2, 3},
+		},
+		{
+			name: "a new subtask",
+			change: func(todo *db.ToDo) error {
+				_, err := todo.CreateItem(db.ToDoItem{Title: "review", ParentId: 1})
+				return err
+			},
+			wantProgress: &db.Progress{Done: 1, Total: 3},
+			wantIds:      []int{2, 3, 5},
+		},
+		{
+			name: "a subtask moves to another parent",
+			change: func(todo *db.ToDo) error {
+				_, err := todo.UpdateItem(db.ToDoItem{Id: 3, Title: "budget", ParentId: 2, IsDone: true})
+				return err
+			},
+			wantProgress: &db.Progress{Done: 0, Total: 1},
+			wantIds:      []int{2},
+		},
+		{
+			name: "a subtask is no subtask anymore",
+			change: func(todo *db.ToDo) error {
+				_, err := todo.UpdateItem(db.ToDoItem{Id: 2, Title: "design"})
+				return err
+			},
+			wantProgress: &db.Progress{Done: 1, Total: 1},
+			wantIds:      []int{3},
+		},
+		{
+			name:         "the subtasks of a deleted subtask move up",
+			change:       func(todo *db.ToDo) error { return todo.DeleteItem(2, db.CascadeOrphan) },
+			wantProgress: &db.Progress{Done: 1, Total: 2},
+			wantIds:      []int{3, 4},
+		},
+		{
+			name:         "a deleted subtask with its subtasks",
+			change:       func(todo *db.ToDo) error { return todo.DeleteItem(2, db.CascadeDelete) },
+			wantProgress: &db.Progress{Done: 1, Total: 1},
+			wantIds:      []int{3},
+		},
+		{
+			name: "a restored subtask",
+			change: func(todo *db.ToDo) error {
+				if err := todo.DeleteItem(3, db.CascadeRestrict); err != nil {
+					return err
+				}
+				_, err := todo.RestoreItem(3)
+				return err
+			},
+			wantProgress: &db.Progress{Done: 1, Total: 2},
+			wantIds:      []int{2, 3},
+		},
+		{
+			name: "the id of a deleted parent is taken again",
+			change: func(todo *db.ToDo) error {
+				if err := todo.DeleteItem(1, db.CascadeDelete); err != nil {
+					return err
+				}
+				return todo.AddItem(db.ToDoItem{Id: 1, Title: "another project"})
+			},
+			wantProgress: nil,
+			wantIds:      []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := newToDo(t)
+			for _, item := range plan {
+				if err := todo.AddItem(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.change(todo); err != nil {
+				t.Fatal(err)
+			}
+
+			progress, ids := subtasksOf(t, todo, 1)
+			if !reflect.DeepEqual(progress, tt.wantProgress) {
+				t.Errorf("got progress %+v, want %+v", progress, tt.wantProgress)
+			}
+			if !reflect.DeepEqual(ids, tt.wantIds) {
+				t.Errorf("got the subtasks %v, want %v", ids, tt.wantIds)
+			}
+		})
+	}
+}
+
+// TestIndexChildren starts on items that were stored before there were
+// children sets, in a list and from before there were lists
+func TestIndexChildren(t *testing.T) {
+	m := miniredis.RunT(t)
+	for _, item := range plan {
+		doc, err := json.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := db.RedisKeyPrefix + db.DefaultUser + ":" + db.DefaultList + ":"
+		if item.Id == 3 {
+			key = db.RedisKeyPrefix
+		}
+		m.Set(key+strconv.Itoa(item.Id), string(doc))
+	}
+	todo := newToDoOn(t, m)
+
+	progress, ids := subtasksOf(t, todo, 1)
+	if want := (&db.Progress{Done: 1, Total: 2}); !reflect.DeepEqual(progress, want) {
+		t.Errorf("got progress %+v, want %+v", progress, want)
+	}
+	if want := []int{2, 3}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got the subtasks %v, want %v", ids, want)
+	}
+}
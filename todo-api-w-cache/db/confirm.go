@@ -0,0 +1,83 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DeleteAll and DeleteList take every item of a list with them, so the
+// api has them confirmed first, see Confirmations
+
+const ConfirmationTTL = 5 * time.Minute
+
+// ErrConfirmationRequired is returned when a bulk delete was not
+// confirmed, the api answers 428 Precondition Required
+var ErrConfirmationRequired = errors.New("confirmation required")
+
+// ConfirmationError is returned when a bulk delete has to be confirmed.
+// The caller repeats the request with Token, which is good for one use
+// within ConfirmationTTL.  It wraps ErrConfirmationRequired.
+type ConfirmationError struct {
+	Action string
+	Token  string
+}
+
+func (e *ConfirmationError) Error() string {
+	return fmt.Sprintf("%s: repeat the request with confirm=%s to %s", ErrConfirmationRequired, e.Token, e.Action)
+}
+
+func (e *ConfirmationError) Unwrap() error {
+	return ErrConfirmationRequired
+}
+
+// Confirmations hands out and checks the tokens that confirm bulk deletes.
+// A token only confirms the action it was handed out for, eg "delete all
+// items of bob:work", and only once.
+type Confirmations struct {
+	mu     sync.Mutex
+	ttl    time.Duration
+	tokens map[string]pendingConfirmation
+}
+
+type pendingConfirmation struct {
+	action  string
+	expires time.Time
+}
+
+func NewConfirmations() *Confirmations {
+	return &Confirmations{
+		ttl:    ConfirmationTTL,
+		tokens: make(map[string]pendingConfirmation),
+	}
+}
+
+// Confirm returns nil if token confirms action, and uses the token up.
+// Otherwise it returns a *ConfirmationError with a new token for action.
+func (c *Confirmations) Confirm(action string, token string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for t, pending := range c.tokens {
+		if now.After(pending.expires) {
+			delete(c.tokens, t)
+		}
+	}
+
+	if pending, ok := c.tokens[token]; ok && pending.action == action {
+		delete(c.tokens, token)
+		return nil
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	fresh := hex.EncodeToString(buf)
+	c.tokens[fresh] = pendingConfirmation{action: action, expires: now.Add(c.ttl)}
+	return &ConfirmationError{Action: action, Token: fresh}
+}
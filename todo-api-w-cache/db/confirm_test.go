@@ -0,0 +1,39 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		action  string // the action the token is used for
+		wantErr bool
+	}{
+		{name: "the action of the token", ttl: time.Minute, action: "delete all items of alice:work"},
+		{name: "another action", ttl: time.Minute, action: "delete list alice:work", wantErr: true},
+		{name: "an expired token", ttl: -time.Second, action: "delete all items of alice:work", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConfirmations()
+			c.ttl = tt.ttl
+			var ce *ConfirmationError
+			if err := c.Confirm("delete all items of alice:work", ""); !errors.As(err, &ce) || !errors.Is(err, ErrConfirmationRequired) {
+				t.Fatalf("got %v, want a ConfirmationError", err)
+			}
+
+			err := c.Confirm(tt.action, ce.Token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want an error %t", err, tt.wantErr)
+			}
+			//a token is good for one use
+			if err == nil && c.Confirm(tt.action, ce.Token) == nil {
+				t.Error("the token confirmed twice")
+			}
+		})
+	}
+}
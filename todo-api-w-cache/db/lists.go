@@ -0,0 +1,303 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nitishm/go-rejson/v4/rjs"
+)
+
+// Every user has lists of items.  A list belongs to the user that created
+// it, its owner, who can share it with other users as a read or a write
+// member.  A list is named by its owner and its name, "<owner>:<name>",
+// and its items are kept under todo:<owner>:<name>:<id>.  Every user has
+// a list named "default", which is there without being created and can
+// not be deleted, the /todo routes work on it.
+
+const (
+	DefaultUser = "default"
+	DefaultList = "default"
+)
+
+// ErrForbidden is returned when a user may read a list, but not do what
+// they asked with it
+var ErrForbidden = errors.New("forbidden")
+
+// Role is what a user can do with a list
+type Role string
+
+const (
+	RoleOwner Role = "owner" // everything, including sharing and deleting the list
+	RoleWrite Role = "write" // read and change the items
+	RoleRead  Role = "read"  // read the items
+)
+
+// ListInfo describes a list, Members maps the users the list is shared
+// with to their role
+type ListInfo struct {
+	Id        string          `json:"id"`
+	Owner     string          `json:"owner"`
+	Name      string          `json:"name"`
+	Members   map[string]Role `json:"members,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// RoleOf returns the role of user, "" if the list is not shared with them
+func (l ListInfo) RoleOf(user string) Role {
+	if user == l.Owner {
+		return RoleOwner
+	}
+	return l.Members[user]
+}
+
+// Allows checks that user may read the list, or change its items if write
+// is true.  A user that is not a member gets ErrNotFound, so nobody can
+// learn which lists exist.
+func (l ListInfo) Allows(user string, write bool) error {
+	switch role := l.RoleOf(user); {
+	case role == "":
+		return fmt.Errorf("%w: list %s does not exist", ErrNotFound, l.Id)
+	case write && role == RoleRead:
+		return fmt.Errorf("%w: %s can only read list %s", ErrForbidden, user, l.Id)
+	}
+	return nil
+}
+
+// names end up in redis keys and KEYS patterns, so they can not hold a
+// ':' or any of the pattern characters
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// CheckName checks a user or a list name, what is "user" or "list" for the
+// error message
+func CheckName(what string, name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("%w: %s name %q must be 1 to 64 letters, digits, '.', '_' or '-'", ErrValidation, what, name)
+	}
+	return nil
+}
+
+// ParseListId splits a list id into the owner and the name of the list.
+// An id without an owner, eg "groceries", is a list of user.
+func ParseListId(id string, user string) (owner string, name string, err error) {
+	owner, name, ok := strings.Cut(id, ":")
+	if !ok {
+		owner, name = user, id
+	}
+	if err := CheckName("user", owner); err != nil {
+		return "", "", err
+	}
+	if err := CheckName("list", name); err != nil {
+		return "", "", err
+	}
+	return owner, name, nil
+}
+
+func listKey(owner string, name string) string {
+	return RedisListPrefix + owner + ":" + name
+}
+
+// List returns a ToDo that works on the items of the list owner:name.  It
+// shares the redis connection with t, and does not check that the list
+// exists or who may use it, see GetList and ListInfo.Allows.
+func (t *ToDo) List(owner string, name string) *ToDo {
+	list := *t
+	list.owner, list.list = owner, name
+	return &list
+}
+
+// ListId is the id of the list t works on, owner:name
+func (t *ToDo) ListId() string {
+	return t.owner + ":" + t.list
+}
+
+// CreateList adds the list name for owner, ErrConflict if owner already
+// has a list with that name
+func (t *ToDo) CreateList(owner string, name string) (ListInfo, error) {
+	if err := CheckName("user", owner); err != nil {
+		return ListInfo{}, err
+	}
+	if err := CheckName("list", name); err != nil {
+		return ListInfo{}, err
+	}
+
+	info := ListInfo{Id: owner + ":" + name, Owner: owner, Name: name, CreatedAt: time.Now().UTC()}
+	res, err := t.jsonHelper.JSONSet(listKey(owner, name), ".", info, rjs.SetOptionNX)
+	if err != nil {
+		return ListInfo{}, err
+	}
+	if res == nil {
+		return ListInfo{}, fmt.Errorf("%w: list %s already exists", ErrConflict, info.Id)
+	}
+	return info, nil
+}
+
+// GetList returns the list name of owner.  The default list is created
+// the first time it is asked for.
+func (t *ToDo) GetList(owner string, name string) (ListInfo, error) {
+	obj, err := t.jsonHelper.JSONGet(listKey(owner, name), ".")
+	if err != nil {
+		if !isRedisNilError(err) {
+			return ListInfo{}, err
+		}
+		if name != DefaultList {
+			return ListInfo{}, fmt.Errorf("%w: list %s:%s does not exist", ErrNotFound, owner, name)
+		}
+		info, err := t.CreateList(owner, name)
+		if errors.Is(err, ErrConflict) {
+			//created by another request just now
+			return t.GetList(owner, name)
+		}
+		return info, err
+	}
+
+	var info ListInfo
+	if err := json.Unmarshal(obj.([]byte), &info); err != nil {
+		return ListInfo{}, err
+	}
+	return info, nil
+}
+
+// ListsOf returns the lists user owns or is a member of, by id
+func (t *ToDo) ListsOf(user string) ([]ListInfo, error) {
+	//make sure the default list is there
+	if _, err := t.GetList(user, DefaultList); err != nil {
+		return nil, err
+	}
+
+	ks, err := t.keys(RedisListPrefix + "*")
+	if err != nil {
+		return nil, err
+	}
+	lists := []ListInfo{}
+	for _, key := range ks {
+		owner, name, _ := strings.Cut(strings.TrimPrefix(key, RedisListPrefix), ":")
+		info, err := t.GetList(owner, name)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.RoleOf(user) != "" {
+			lists = append(lists, info)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].Id < lists[j].Id })
+	return lists, nil
+}
+
+// DeleteList deletes the list name of owner with all its items, and its
+// trash.  This can not be undone.  The default list can not be deleted.
+func (t *ToDo) DeleteList(owner string, name string) error {
+	if name == DefaultList {
+		return fmt.Errorf("%w: the default list can not be deleted, delete its items instead", ErrConflict)
+	}
+	if _, err := t.GetList(owner, name); err != nil {
+		return err
+	}
+
+	//a list created later with the same name starts again at id 1, the
+	//old idempotency keys would point at its items
+	list := t.List(owner, name)
+	var keys []string
+	for _, pattern := range []string{list.keyPrefix() + "*", list.trashPrefix() + "*", list.childrenPrefix() + "*", list.idempotencyKey("*")} {
+		ks, err := t.keys(pattern)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, ks...)
+	}
+	keys = append(keys, list.counterKey(), listKey(owner, name))
+	return t.del(keys...)
+}
+
+// ShareList makes member a read or a write member of the list name of
+// owner, or changes their role
+func (t *ToDo) ShareList(owner string, name string, member string, role Role) (ListInfo, error) {
+	if err := CheckName("user", member); err != nil {
+		return ListInfo{}, err
+	}
+	if role != RoleRead && role != RoleWrite {
+		return ListInfo{}, fmt.Errorf("%w: role must be read or write, not %q", ErrValidation, role)
+	}
+	info, err := t.GetList(owner, name)
+	if err != nil {
+		return ListInfo{}, err
+	}
+	if member == owner {
+		return ListInfo{}, fmt.Errorf("%w: %s owns list %s", ErrConflict, member, info.Id)
+	}
+
+	if info.Members == nil {
+		info.Members = map[string]Role{}
+	}
+	info.Members[member] = role
+	return info, t.saveList(info)
+}
+
+// UnshareList takes member off the list name of owner
+func (t *ToDo) UnshareList(owner string, name string, member string) (ListInfo, error) {
+	info, err := t.GetList(owner, name)
+	if err != nil {
+		return ListInfo{}, err
+	}
+	if _, ok := info.Members[member]; !ok {
+		return ListInfo{}, fmt.Errorf("%w: %s is not a member of list %s", ErrNotFound, member, info.Id)
+	}
+
+	delete(info.Members, member)
+	return info, t.saveList(info)
+}
+
+// saveList writes the list back.  Like UpdateItem this is a read and a
+// write, two members changed at the same time can lose one change.
+func (t *ToDo) saveList(info ListInfo) error {
+	_, err := t.jsonHelper.JSONSet(listKey(info.Owner, info.Name), ".", info)
+	return err
+}
+
+// migrateLegacyKeys moves the items of the single list we had before
+// there were users, todo:<id>, and its id counter to the list t works on
+func (t *ToDo) migrateLegacyKeys() error {
+	ks, err := t.keys(RedisKeyPrefix + "*")
+	if err != nil {
+		return err
+	}
+	moved := 0
+	for _, key := range ks {
+		id := strings.TrimPrefix(key, RedisKeyPrefix)
+		if _, err := strconv.Atoi(id); err != nil {
+			//already in a list
+			continue
+		}
+		ok, err := t.renameNX(key, t.keyPrefix()+id)
+		if err != nil {
+			return err
+		}
+		if ok {
+			moved++
+		}
+	}
+
+	n, err := t.cacheClient.Exists(t.context, RedisIdCounterKey).Result()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		if _, err := t.renameNX(RedisIdCounterKey, t.counterKey()); err != nil {
+			return err
+		}
+	}
+
+	if moved > 0 {
+		log.Printf("Moved %d items to the list %s:%s", moved, t.owner, t.list)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package db_test
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/alicebob/miniredis/v2/server"
+	"github.com/go-redis/redis/v8"
+)
+
+// newToDo returns a ToDo on the default list, kept in a miniredis server
+// that lives as long as the test
+func newToDo(t *testing.T) *db.ToDo {
+	t.Helper()
+	return newToDoOn(t, miniredis.RunT(t))
+}
+
+// newToDoOn is newToDo on m, which can hold keys from before already
+func newToDoOn(t *testing.T, m *miniredis.Miniredis) *db.ToDo {
+	t.Helper()
+	registerJSON(t, m)
+
+	client := redis.NewClient(&redis.Options{Addr: m.Addr()})
+	t.Cleanup(func() { client.Close() })
+	todo, err := db.New(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
+
+// registerJSON teaches miniredis JSON.SET and JSON.GET on the root path,
+// which is all the db package uses.  The json is kept as a string key, so
+// SCAN and DEL work on it the way they do on a RedisJSON server.
+func registerJSON(t *testing.T, m *miniredis.Miniredis) {
+	t.Helper()
+	var mu sync.Mutex // makes NX and XX atomic
+	set := func(c *server.Peer, cmd string, args []string) {
+		if len(args) < 3 || len(args) > 4 || args[1] != "." || !json.Valid([]byte(args[2])) {
+			c.WriteError("ERR only JSON.SET <key> . <json> [NX|XX] is supported")
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if len(args) == 4 && (args[3] == "NX") == m.Exists(args[0]) {
+			c.WriteNull()
+			return
+		}
+		if err := m.Set(args[0], args[2]); err != nil {
+			c.WriteError("ERR " + err.Error())
+			return
+		}
+		c.WriteOK()
+	}
+	get := func(c *server.Peer, cmd string, args []string) {
+		if len(args) != 2 || args[1] != "." {
+			c.WriteError("ERR only JSON.GET <key> . is supported")
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !m.Exists(args[0]) {
+			c.WriteNull()
+			return
+		}
+		value, err := m.Get(args[0])
+		if err != nil {
+			c.WriteError("ERR " + err.Error())
+			return
+		}
+		c.WriteBulk(value)
+	}
+	if err := m.Server().Register("JSON.SET", set); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Server().Register("JSON.GET", get); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,69 +1,147 @@
 package db
 
 import (
+	"config"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
-	"os"
+	"strconv"
+	"strings"
+	"time"
+	"validate"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/nitishm/go-rejson/v4"
+	"github.com/nitishm/go-rejson/v4/rjs"
 )
 
-// ToDoItem is the struct that represents a single ToDo item
+// ToDoItem is the struct that represents a single ToDo item.  Version
+// and UpdatedAt are set by the database on every add and update, clients
+// use them to tell which items changed, see UpdateItem.  ParentId makes
+// the item a subtask, and BlockedBy lists the items that must be done
+// first, see relations.go.  Progress is filled in when the item is read
+// and has subtasks.
 type ToDoItem struct {
-	Id     int    `json:"id"`
-	Title  string `json:"title"`
-	IsDone bool   `json:"done"`
+	Id        int       `json:"id"`
+	Title     string    `json:"title" binding:"notblank"`
+	IsDone    bool      `json:"done"`
+	ParentId  int       `json:"parentId,omitempty" binding:"gte=0"`
+	BlockedBy []int     `json:"blockedBy,omitempty" binding:"dive,gt=0"`
+	Progress  *Progress `json:"progress,omitempty"`
+	Version   int       `json:"version,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// The items of a list are kept under todo:<owner>:<list>:<id>, every list
+// also has its own id counter and idempotency keys, see lists.go
 const (
 	RedisNilError        = "redis: nil"
 	RedisDefaultLocation = "0.0.0.0:6379"
 	RedisKeyPrefix       = "todo:"
+
+	//The id counter, idempotency keys, list records, the trash and the
+	//children sets deliberately do not start with RedisKeyPrefix,
+	//otherwise GetAllItems would pick them up as items
+	RedisIdCounterKey      = "todo-nextid"
+	RedisIdempotencyPrefix = "todo-idempotency:"
+	RedisListPrefix        = "todo-list:"
+	IdempotencyKeyTTL      = 24 * time.Hour
+
+	//IdempotencyClaimTTL bounds how long an Idempotency-Key stays taken by
+	//a request that died before it created its item
+	IdempotencyClaimTTL = time.Minute
+	idempotencyPending  = "pending"
+)
+
+// These are the errors the database reports back to its callers.  They
+// are wrapped with more details, so check for them with errors.Is(), the
+// api package uses them to pick the right HTTP status code
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = validate.ErrValidation
+
+	errIdempotencyPending = fmt.Errorf("%w: a request with this Idempotency-Key is still running", ErrConflict)
 )
 
 type cache struct {
-	cacheClient *redis.Client
+	cacheClient redis.UniversalClient
 	jsonHelper  *rejson.Handler
 	context     context.Context
 }
 
+// keys returns the keys that match pattern, see config.Keys
+func (c *cache) keys(pattern string) ([]string, error) {
+	return config.Keys(c.context, c.cacheClient, pattern)
+}
+
+// del deletes the keys one by one in a pipeline, a DEL of many keys fails
+// in a cluster when they are in different slots
+func (c *cache) del(keys ...string) error {
+	_, err := c.cacheClient.Pipelined(c.context, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(c.context, key)
+		}
+		return nil
+	})
+	return err
+}
+
+// renameNX renames from to to, unless to is there already.  A cluster only
+// renames within a slot, so there the key is moved with DUMP and RESTORE.
+func (c *cache) renameNX(from string, to string) (bool, error) {
+	if _, ok := c.cacheClient.(*redis.ClusterClient); !ok {
+		return c.cacheClient.RenameNX(c.context, from, to).Result()
+	}
+	data, err := c.cacheClient.Dump(c.context, from).Result()
+	if err != nil {
+		return false, err
+	}
+	err = c.cacheClient.Restore(c.context, to, 0, data).Err()
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYKEY") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, c.cacheClient.Del(c.context, from).Err()
+}
+
 // ToDo is the struct that represents the main object of our
-// todo app.  It contains a reference to a cache object
+// todo app.  It contains a reference to a cache object, and the list
+// whose items it works on
 type ToDo struct {
 	//more things would be included in a real implementation
 
 	//Redis cache connections
 	cache
-}
 
-// New is a constructor function that returns a pointer to a new
-// ToDo struct.  If this is called it uses the default Redis URL
-// with the companion constructor NewWithCacheInstance.
-func New() (*ToDo, error) {
-	//We will use an override if the REDIS_URL is provided as an environment
-	//variable, which is the preferred way to wire up a docker container
-	redisUrl := os.Getenv("REDIS_URL")
-	//This handles the default condition
-	if redisUrl == "" {
-		redisUrl = RedisDefaultLocation
-	}
-	return NewWithCacheInstance(redisUrl)
+	//The owner and the name of the list, see List()
+	owner string
+	list  string
+
+	//How long deleted items stay in the trash, see trash.go
+	retention time.Duration
 }
 
 // NewWithCacheInstance is a constructor function that returns a pointer to a new
 // ToDo struct.  It accepts a string that represents the location of the redis
-// cache.
+// cache, use New for a sentinel or a cluster.
 func NewWithCacheInstance(location string) (*ToDo, error) {
+	return New(redis.NewClient(&redis.Options{Addr: location}))
+}
 
-	//Connect to redis.  Other options can be provided, but the
-	//defaults are OK
-	client := redis.NewClient(&redis.Options{
-		Addr: location,
-	})
+// New is a constructor function that returns a pointer to a new
+// ToDo struct.  It keeps the todos with client, which main connects
+// from its configuration to a single server, a sentinel or a cluster, or
+// at RedisDefaultLocation when client is nil.  It works on the default list of the default user, use
+// List() for the others.
+func New(client redis.UniversalClient) (*ToDo, error) {
+	if client == nil {
+		client = redis.NewClient(&redis.Options{Addr: RedisDefaultLocation})
+	}
 
 	//We use this context to coordinate betwen our go code and
 	//the redis operaitons
@@ -87,13 +165,27 @@ func NewWithCacheInstance(location string) (*ToDo, error) {
 	jsonHelper.SetGoRedisClientWithContext(ctx, client)
 
 	//Return a pointer to a new ToDo struct
-	return &ToDo{
+	t := &ToDo{
 		cache: cache{
 			cacheClient: client,
 			jsonHelper:  jsonHelper,
 			context:     ctx,
 		},
-	}, nil
+		owner:     DefaultUser,
+		list:      DefaultList,
+		retention: DefaultRetention,
+	}
+
+	//Items stored before there were lists belong to the default list
+	if err := t.migrateLegacyKeys(); err != nil {
+		log.Println("Error moving the items to the default list" + err.Error())
+		return nil, err
+	}
+	if err := t.indexChildren(); err != nil {
+		log.Println("Error adding the subtasks to their parents" + err.Error())
+		return nil, err
+	}
+	return t, nil
 }
 
 //------------------------------------------------------------
@@ -106,10 +198,21 @@ func isRedisNilError(err error) bool {
 }
 
 // In redis, our keys will be strings, they will look like
-// todo:<number>.  This function will take an integer and
+// todo:<owner>:<list>:<number>.  This function will take an integer and
 // return a string that can be used as a key in redis
-func redisKeyFromId(id int) string {
-	return fmt.Sprintf("%s%d", RedisKeyPrefix, id)
+func (t *ToDo) redisKeyFromId(id int) string {
+	return fmt.Sprintf("%s%d", t.keyPrefix(), id)
+}
+
+// counterKey is the key of the counter CreateItem takes the ids from
+func (t *ToDo) counterKey() string {
+	return RedisIdCounterKey + ":" + t.owner + ":" + t.list
+}
+
+// keyPrefix is the part of the keys of the items that names the list,
+// todo:<owner>:<list>:
+func (t *ToDo) keyPrefix() string {
+	return RedisKeyPrefix + t.owner + ":" + t.list + ":"
 }
 
 // Helper to return a ToDoItem from redis provided a key
@@ -120,6 +223,9 @@ func (t *ToDo) getItemFromRedis(key string, item *ToDoItem) error {
 	//json structure
 	itemObject, err := t.jsonHelper.JSONGet(key, ".")
 	if err != nil {
+		if isRedisNilError(err) {
+			return fmt.Errorf("%w: %s does not exist", ErrNotFound, key)
+		}
 		return err
 	}
 
@@ -135,6 +241,42 @@ func (t *ToDo) getItemFromRedis(key string, item *ToDoItem) error {
 	return nil
 }
 
+// Helper to store a ToDoItem only if its key is not taken yet.  The NX
+// option makes the check and the write a single redis operation, so two
+// requests can never both create the same id
+func (t *ToDo) setItemIfAbsent(item ToDoItem) (bool, error) {
+	res, err := t.jsonHelper.JSONSet(t.redisKeyFromId(item.Id), ".", item, rjs.SetOptionNX)
+	if err != nil {
+		return false, err
+	}
+
+	//JSONSet returns nil instead of "OK" when NX prevented the write
+	return res != nil, nil
+}
+
+// Helper to read every item into a map by id, the relations between the
+// items are checked against it
+func (t *ToDo) loadItems() (map[int]ToDoItem, error) {
+	items := map[int]ToDoItem{}
+	pattern := t.keyPrefix() + "*"
+	ks, err := t.keys(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range ks {
+		var item ToDoItem
+		if err := t.getItemFromRedis(key, &item); err != nil {
+			//deleted since SCAN listed it
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		items[item.Id] = item
+	}
+	return items, nil
+}
+
 //------------------------------------------------------------
 // THESE ARE THE PUBLIC FUNCTIONS THAT SUPPORT OUR TODO APP
 //------------------------------------------------------------
@@ -153,25 +295,179 @@ func (t *ToDo) getItemFromRedis(key string, item *ToDoItem) error {
 //		(2) The DB file will be saved with the item added
 //		(3) If there is an error, it will be returned
 func (t *ToDo) AddItem(item ToDoItem) error {
-
-	//Before we add an item to the DB, lets make sure
-	//it does not exist, if it does, return an error
-	redisKey := redisKeyFromId(item.Id)
-	var existingItem ToDoItem
-	if err := t.getItemFromRedis(redisKey, &existingItem); err == nil {
-		return errors.New("item already exists")
+	if err := t.checkRelations(item); err != nil {
+		return err
 	}
+	item.Progress = nil
+	item.Version = 1
+	item.UpdatedAt = time.Now().UTC()
 
-	//Add item to database with JSON Set
-	if _, err := t.jsonHelper.JSONSet(redisKey, ".", item); err != nil {
+	//Add item to database with JSON Set, but only if an item
+	//with this id does not exist yet
+	if err := t.addChild(item); err != nil {
+		return err
+	}
+	ok, err := t.setItemIfAbsent(item)
+	if err != nil {
 		return err
 	}
+	if !ok {
+		return fmt.Errorf("%w: item %d already exists", ErrConflict, item.Id)
+	}
 
 	//If everything is ok, return nil for the error
 	return nil
 }
 
-// DeleteItem accepts an item id and removes it from the DB.
+// CreateItem accepts a ToDoItem, assigns it the next free id and adds
+// it to the DB.  The ids come from a redis counter (INCR is atomic), so
+// concurrent callers always get different ids.  Ids that were already
+// used through AddItem are skipped.
+//
+// Postconditions:
+//
+//	 (1) The item will be added to the DB under a new id
+//		(2) The stored item, including its id, will be returned
+//		(3) If there is an error, it will be returned
+func (t *ToDo) CreateItem(item ToDoItem) (ToDoItem, error) {
+	item.Id = 0
+	if err := t.checkRelations(item); err != nil {
+		return ToDoItem{}, err
+	}
+	item.Progress = nil
+	item.Version = 1
+	item.UpdatedAt = time.Now().UTC()
+	for {
+		nextId, err := t.cacheClient.Incr(t.context, t.counterKey()).Result()
+		if err != nil {
+			return ToDoItem{}, err
+		}
+
+		item.Id = int(nextId)
+		if err := t.addChild(item); err != nil {
+			return ToDoItem{}, err
+		}
+		ok, err := t.setItemIfAbsent(item)
+		if err != nil {
+			return ToDoItem{}, err
+		}
+		if ok {
+			return item, nil
+		}
+	}
+}
+
+// RecallItem returns the item that was created with the provided
+// Idempotency-Key.  The bool result is false if the key is unknown,
+// has expired, or the item was deleted in the meantime.
+func (t *ToDo) RecallItem(idempotencyKey string) (ToDoItem, bool, error) {
+	idS, err := t.cacheClient.Get(t.context, t.idempotencyKey(idempotencyKey)).Result()
+	if err != nil {
+		if isRedisNilError(err) {
+			return ToDoItem{}, false, nil
+		}
+		return ToDoItem{}, false, err
+	}
+
+	if idS == idempotencyPending {
+		return ToDoItem{}, false, nil
+	}
+	return t.recalledItem(idS)
+}
+
+// recalledItem returns the item whose id an Idempotency-Key holds, if it
+// still exists
+func (t *ToDo) recalledItem(idS string) (ToDoItem, bool, error) {
+	id, err := strconv.Atoi(idS)
+	if err != nil {
+		return ToDoItem{}, false, err
+	}
+
+	var item ToDoItem
+	if err := t.getItemFromRedis(t.redisKeyFromId(id), &item); err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return ToDoItem{}, false, nil
+		}
+		return ToDoItem{}, false, err
+	}
+
+	return item, true, nil
+}
+
+// idempotencyKey is the redis key that remembers an Idempotency-Key, the
+// ids it points to are the ids of this list
+func (t *ToDo) idempotencyKey(key string) string {
+	return RedisIdempotencyPrefix + t.owner + ":" + t.list + ":" + key
+}
+
+// CreateItemOnce is CreateItem for a request with an Idempotency-Key.
+// It claims the key before it creates the item, so of two requests with
+// the same key only one creates an item.  The bool result is true when an
+// earlier request already created the item, which is returned instead.
+// While that request is still running it fails with ErrConflict.  The
+// key is remembered for IdempotencyKeyTTL.  Without a key it is
+// CreateItem.
+func (t *ToDo) CreateItemOnce(idempotencyKey string, item ToDoItem) (ToDoItem, bool, error) {
+	if idempotencyKey == "" {
+		item, err := t.CreateItem(item)
+		return item, false, err
+	}
+
+	key := t.idempotencyKey(idempotencyKey)
+	earlier, ok, err := t.claimIdempotencyKey(key)
+	if err != nil || ok {
+		return earlier, ok, err
+	}
+
+	item, err = t.CreateItem(item)
+	if err != nil {
+		//free the key, so that a retry can create the item
+		t.cacheClient.Del(t.context, key)
+		return ToDoItem{}, false, err
+	}
+	if err := t.cacheClient.Set(t.context, key, item.Id, IdempotencyKeyTTL).Err(); err != nil {
+		log.Println("Error remembering idempotency key: ", err)
+	}
+	return item, false, nil
+}
+
+// claimIdempotencyKey takes key for a request that is about to create an
+// item, unless an earlier request created one with it that is still
+// there.  WATCH makes sure that only one request gets the key.
+func (t *ToDo) claimIdempotencyKey(key string) (ToDoItem, bool, error) {
+	var earlier ToDoItem
+	found := false
+	err := t.cacheClient.Watch(t.context, func(tx *redis.Tx) error {
+		idS, err := tx.Get(t.context, key).Result()
+		switch {
+		case err == nil && idS == idempotencyPending:
+			return errIdempotencyPending
+		case err == nil:
+			earlier, found, err = t.recalledItem(idS)
+			if err != nil || found {
+				return err
+			}
+			//its item was deleted since, the key is free again
+		case !isRedisNilError(err):
+			return err
+		}
+		_, err = tx.TxPipelined(t.context, func(pipe redis.Pipeliner) error {
+			pipe.Set(t.context, key, idempotencyPending, IdempotencyClaimTTL)
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, redis.TxFailedErr) {
+		//another request took the key between GET and SET
+		err = errIdempotencyPending
+	}
+	return earlier, found, err
+}
+
+// DeleteItem accepts an item id and moves it from the DB to the trash,
+// see trash.go.  cascade says what happens to the subtasks of the item,
+// see Cascade, and the item is taken off the BlockedBy list of the items
+// it blocked.
 // Preconditions:   (1) The database file must exist and be a valid
 //
 //					(2) The item must exist in the DB
@@ -181,42 +477,60 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 //
 // Postconditions:
 //
-//	 (1) The item will be removed from the DB
+//	 (1) The item will be moved to the trash
 //		(2) The DB file will be saved with the item removed
 //		(3) If there is an error, it will be returned
-func (t *ToDo) DeleteItem(id int) error {
-
-	pattern := redisKeyFromId(id)
-	numDeleted, err := t.cacheClient.Del(t.context, pattern).Result()
+//
+// Like UpdateItem this reads the items and then writes them, a change
+// made by someone else in between can be lost.
+func (t *ToDo) DeleteItem(id int, cascade Cascade) error {
+	items, err := t.loadItems()
 	if err != nil {
 		return err
 	}
-	if numDeleted == 0 {
-		return errors.New("attempted to delete non-existent item")
+	deleted, changed, err := deletePlan(items, id, cascade)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	for _, item := range changed {
+		item.Version++
+		item.UpdatedAt = time.Now().UTC()
+		if err := t.addChild(item); err != nil {
+			return err
+		}
+		if _, err := t.jsonHelper.JSONSet(t.redisKeyFromId(item.Id), ".", item); err != nil {
+			return err
+		}
+		if parent := items[item.Id].ParentId; parent != item.ParentId {
+			if err := t.removeChild(parent, item.Id); err != nil {
+				return err
+			}
+		}
+	}
+
+	//the deleted items go to the trash as they were, before their
+	//blockers were taken off
+	gone := make([]ToDoItem, 0, len(deleted))
+	for _, id := range deleted {
+		gone = append(gone, items[id])
+	}
+	return t.moveToTrash(gone)
 }
 
-// DeleteAll removes all items from the DB.
+// DeleteAll moves all items from the DB to the trash.
 // It will be exposed via a DELETE /todo endpoint
 func (t *ToDo) DeleteAll() error {
-
-	pattern := RedisKeyPrefix + "*"
-	ks, _ := t.cacheClient.Keys(t.context, pattern).Result()
-	//Note delete can take a collection of keys.  In go we can
-	//expand a slice into individual arguments by using the ...
-	//operator
-	numDeleted, err := t.cacheClient.Del(t.context, ks...).Result()
+	items, err := t.loadItems()
 	if err != nil {
 		return err
 	}
 
-	if numDeleted != int64(len(ks)) {
-		return errors.New("one or more items could not be deleted")
+	all := make([]ToDoItem, 0, len(items))
+	for _, item := range items {
+		all = append(all, item)
 	}
-
-	return nil
+	return t.moveToTrash(all)
 }
 
 // UpdateItem accepts a ToDoItem and updates it in the DB.
@@ -229,27 +543,49 @@ func (t *ToDo) DeleteAll() error {
 //
 // Postconditions:
 //
-//	 (1) The item will be updated in the DB
-//		(2) The DB file will be saved with the item updated
+//	 (1) The item will be updated in the DB, with the next version
+//		(2) The stored item will be returned
 //		(3) If there is an error, it will be returned
-func (t *ToDo) UpdateItem(item ToDoItem) error {
+//
+// If item.Version is not 0 it must be the version in the DB, otherwise
+// someone else changed the item since the caller read it and ErrConflict
+// is returned.  The check and the write are two redis calls, so this
+// catches stale updates, not two updates racing each other.
+func (t *ToDo) UpdateItem(item ToDoItem) (ToDoItem, error) {
 
 	//Before we add an item to the DB, lets make sure
 	//it does not exist, if it does, return an error
-	redisKey := redisKeyFromId(item.Id)
+	redisKey := t.redisKeyFromId(item.Id)
 	var existingItem ToDoItem
 	if err := t.getItemFromRedis(redisKey, &existingItem); err != nil {
-		return errors.New("item does not exist")
+		return ToDoItem{}, err
+	}
+	if item.Version != 0 && item.Version != existingItem.Version {
+		return ToDoItem{}, fmt.Errorf("%w: item %d is at version %d, not %d", ErrConflict, item.Id, existingItem.Version, item.Version)
 	}
+	if err := t.checkRelations(item); err != nil {
+		return ToDoItem{}, err
+	}
+	item.Progress = nil
+	item.Version = existingItem.Version + 1
+	item.UpdatedAt = time.Now().UTC()
 
 	//Add item to database with JSON Set.  Note there is no update
 	//functionality, so we just overwrite the existing item
+	if err := t.addChild(item); err != nil {
+		return ToDoItem{}, err
+	}
 	if _, err := t.jsonHelper.JSONSet(redisKey, ".", item); err != nil {
-		return err
+		return ToDoItem{}, err
+	}
+	if existingItem.ParentId != item.ParentId {
+		if err := t.removeChild(existingItem.ParentId, item.Id); err != nil {
+			return ToDoItem{}, err
+		}
 	}
 
 	//If everything is ok, return nil for the error
-	return nil
+	return item, nil
 }
 
 // GetItem accepts an item id and returns the item from the DB.
@@ -272,13 +608,41 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 	// this is a good practice, return an error if the
 	// item does not exist
 	var item ToDoItem
-	pattern := redisKeyFromId(id)
+	pattern := t.redisKeyFromId(id)
 	err := t.getItemFromRedis(pattern, &item)
 	if err != nil {
 		return ToDoItem{}, err
 	}
 
-	return item, nil
+	//the progress is worked out from the subtasks
+	children, err := t.children(id)
+	if err != nil {
+		return ToDoItem{}, err
+	}
+	return withProgress(item, children), nil
+}
+
+// GetSubtasks returns the items whose parent is id, ordered by id.  It
+// returns ErrNotFound if there is no item id.
+func (t *ToDo) GetSubtasks(id int) ([]ToDoItem, error) {
+	var item ToDoItem
+	if err := t.getItemFromRedis(t.redisKeyFromId(id), &item); err != nil {
+		return nil, err
+	}
+	items, err := t.children(id)
+	if err != nil {
+		return nil, err
+	}
+
+	children := subtasks(items, id)
+	for i := range children {
+		grandchildren, err := t.children(children[i].Id)
+		if err != nil {
+			return nil, err
+		}
+		children[i] = withProgress(children[i], grandchildren)
+	}
+	return children, nil
 }
 
 // ChangeItemDoneStatus accepts an item id and a boolean status.
@@ -319,24 +683,35 @@ func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
 //		(3) The database file will not be modified
 func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
 
+	//Lets query redis for all of the items, loadItems reads every
+	//todo:<id> key into a map
+	items, err := t.loadItems()
+	if err != nil {
+		return nil, err
+	}
+
 	//Now that we have the DB loaded, lets crate a slice
 	var toDoList []ToDoItem
-	var toDoItem ToDoItem
-
-	//Lets query redis for all of the items
-	pattern := RedisKeyPrefix + "*"
-	ks, _ := t.cacheClient.Keys(t.context, pattern).Result()
-	for _, key := range ks {
-		err := t.getItemFromRedis(key, &toDoItem)
-		if err != nil {
-			return nil, err
-		}
-		toDoList = append(toDoList, toDoItem)
+	for _, item := range items {
+		toDoList = append(toDoList, withProgress(item, items))
 	}
 
 	return toDoList, nil
 }
 
+// checkRelations checks the parent and the blockers of item against the
+// items in the DB, see relations.go
+func (t *ToDo) checkRelations(item ToDoItem) error {
+	if item.ParentId == 0 && len(item.BlockedBy) == 0 {
+		return nil
+	}
+	items, err := t.loadItems()
+	if err != nil {
+		return err
+	}
+	return checkRelations(item, items)
+}
+
 // PrintItem accepts a ToDoItem and prints it to the console
 // in a JSON pretty format. As some help, look at the
 // json.MarshalIndent() function from our in class go tutorial.
@@ -357,7 +732,8 @@ func (t *ToDo) PrintAllItems(itemList []ToDoItem) {
 // JsonToItem accepts a json string and returns a ToDoItem
 // This is helpful because the CLI accepts todo items for insertion
 // and updates in JSON format.  We need to convert it to a ToDoItem
-// struct to perform any operations on it.
+// struct to perform any operations on it.  The item is checked with
+// ValidateItem, the same rules the API applies to request bodies.
 func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
 	var item ToDoItem
 	err := json.Unmarshal([]byte(jsonString), &item)
@@ -365,5 +741,9 @@ func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
 		return ToDoItem{}, err
 	}
 
+	if err := ValidateItem(item); err != nil {
+		return ToDoItem{}, err
+	}
+
 	return item, nil
 }
@@ -0,0 +1,152 @@
+package db_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"drexel.edu/todo/db"
+)
+
+func TestCreateItemIds(t *testing.T) {
+	tests := []struct {
+		name  string
+		added []int
+		want  []int
+	}{
+		{"counts up from 1", nil, []int{1, 2, 3}},
+		{"skips added ids", []int{2, 3}, []int{1, 4, 5}},
+		{"ignores the id of the item", nil, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := newToDo(t)
+			for _, id := range tt.added {
+				if err := todo.AddItem(db.ToDoItem{Id: id, Title: "added"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, want := range tt.want {
+				item, err := todo.CreateItem(db.ToDoItem{Id: 7, Title: "created"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item.Id != want {
+					t.Fatalf("got id %d, want %d", item.Id, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateItemIdsPerList(t *testing.T) {
+	todo := newToDo(t)
+	for _, list := range []*db.ToDo{todo, todo.List("alice", "work")} {
+		item, err := list.CreateItem(db.ToDoItem{Title: "first"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Id != 1 {
+			t.Fatalf("list %s: got id %d, want 1", list.ListId(), item.Id)
+		}
+	}
+}
+
+func TestCreateItemOnce(t *testing.T) {
+	type create struct {
+		key          string
+		wantId       int
+		wantReplayed bool
+	}
+	tests := []struct {
+		name    string
+		creates []create
+		deleted int
+		after   create
+	}{
+		{
+			name:    "no key creates every time",
+			creates: []create{{"", 1, false}, {"", 2, false}},
+		},
+		{
+			name:    "the same key replays",
+			creates: []create{{"a", 1, false}, {"a", 1, true}, {"a", 1, true}},
+		},
+		{
+			name:    "other keys create",
+			creates: []create{{"a", 1, false}, {"b", 2, false}, {"a", 1, true}},
+		},
+		{
+			name:    "a key whose item is gone creates again",
+			creates: []create{{"a", 1, false}},
+			deleted: 1,
+			after:   create{"a", 2, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := newToDo(t)
+			check := func(c create) {
+				t.Helper()
+				item, replayed, err := todo.CreateItemOnce(c.key, db.ToDoItem{Title: "key " + c.key})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item.Id != c.wantId || replayed != c.wantReplayed {
+					t.Fatalf("key %q: got id %d replayed %v, want id %d replayed %v",
+						c.key, item.Id, replayed, c.wantId, c.wantReplayed)
+				}
+				recalled, ok, err := todo.RecallItem(c.key)
+				if c.key != "" && (err != nil || !ok || recalled.Id != item.Id) {
+					t.Fatalf("recall %q: got %+v, %v, %v, want id %d", c.key, recalled, ok, err, item.Id)
+				}
+			}
+			for _, c := range tt.creates {
+				check(c)
+			}
+			if tt.deleted != 0 {
+				if err := todo.DeleteItem(tt.deleted, db.CascadeRestrict); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok, err := todo.RecallItem(tt.after.key); ok || err != nil {
+					t.Fatalf("recall after delete: got %v, %v", ok, err)
+				}
+				check(tt.after)
+			}
+		})
+	}
+}
+
+// TestCreateItemOnceConcurrent sends the same request many times at
+// once, of which exactly one may create the item.  The others replay it,
+// or are told that it is still being created.
+func TestCreateItemOnceConcurrent(t *testing.T) {
+	const requests = 20
+	todo := newToDo(t)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := todo.CreateItemOnce("retried", db.ToDoItem{Title: "once"})
+			if err != nil && !errors.Is(err, db.ErrConflict) {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	items, err := todo.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1: %+v", len(items), items)
+	}
+}
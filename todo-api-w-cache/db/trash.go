@@ -0,0 +1,220 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// DeleteItem and DeleteAll do not throw the items away, they move them to
+// the trash of their list, todo-trash:<owner>:<list>:<id>.  An item stays
+// there for the retention period, until RestoreItem brings it back or
+// PurgeTrash drops it for good.  The trash keeps the last deleted item of
+// every id.
+
+const (
+	RedisTrashPrefix  = "todo-trash:"
+	DefaultRetention  = 7 * 24 * time.Hour
+	DefaultPurgeEvery = time.Hour
+)
+
+// Tombstone is an item in the trash
+type Tombstone struct {
+	Item      ToDoItem  `json:"item"`
+	DeletedAt time.Time `json:"deletedAt"`
+	PurgeAt   time.Time `json:"purgeAt"`
+}
+
+// SetRetention sets how long the deleted items stay in the trash, the
+// lists from List() keep it.  It is DefaultRetention unless it is set to
+// a positive duration.
+func (t *ToDo) SetRetention(retention time.Duration) {
+	if retention > 0 {
+		t.retention = retention
+	}
+}
+
+// trashPrefix is the part of the trash keys that names the list,
+// todo-trash:<owner>:<list>:
+func (t *ToDo) trashPrefix() string {
+	return RedisTrashPrefix + t.owner + ":" + t.list + ":"
+}
+
+func (t *ToDo) trashKeyFromId(id int) string {
+	return fmt.Sprintf("%s%d", t.trashPrefix(), id)
+}
+
+// moveToTrash writes the tombstones of items before deleting them, so an
+// item is never lost when redis fails in between
+func (t *ToDo) moveToTrash(items []ToDoItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		item.Progress = nil
+		tomb := Tombstone{Item: item, DeletedAt: now, PurgeAt: now.Add(t.retention)}
+		if _, err := t.jsonHelper.JSONSet(t.trashKeyFromId(item.Id), ".", tomb); err != nil {
+			return err
+		}
+		keys = append(keys, t.redisKeyFromId(item.Id), t.childrenKey(item.Id))
+	}
+	if err := t.del(keys...); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := t.removeChild(item.ParentId, item.Id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *ToDo) getTombstoneFromRedis(key string) (Tombstone, error) {
+	var tomb Tombstone
+	obj, err := t.jsonHelper.JSONGet(key, ".")
+	if err != nil {
+		if isRedisNilError(err) {
+			return tomb, fmt.Errorf("%w: %s is not in the trash", ErrNotFound, key)
+		}
+		return tomb, err
+	}
+	return tomb, json.Unmarshal(obj.([]byte), &tomb)
+}
+
+// GetTrash returns the deleted items of the list, ordered by id
+func (t *ToDo) GetTrash() ([]Tombstone, error) {
+	ks, err := t.keys(t.trashPrefix() + "*")
+	if err != nil {
+		return nil, err
+	}
+	trash := []Tombstone{}
+	for _, key := range ks {
+		tomb, err := t.getTombstoneFromRedis(key)
+		if errors.Is(err, ErrNotFound) {
+			continue //purged since KEYS listed it
+		}
+		if err != nil {
+			return nil, err
+		}
+		trash = append(trash, tomb)
+	}
+	sort.Slice(trash, func(i, j int) bool { return trash[i].Item.Id < trash[j].Item.Id })
+	return trash, nil
+}
+
+// RestoreItem moves the item id from the trash back into the list, with
+// the next version.  It returns ErrConflict if another item took the id
+// meanwhile.  A parent or blockers that are gone by now are dropped, so
+// restore a parent before its subtasks.  The items the deleted item
+// blocked are not blocked by it again.
+func (t *ToDo) RestoreItem(id int) (ToDoItem, error) {
+	trashKey := t.trashKeyFromId(id)
+	tomb, err := t.getTombstoneFromRedis(trashKey)
+	if err != nil {
+		return ToDoItem{}, err
+	}
+
+	items, err := t.loadItems()
+	if err != nil {
+		return ToDoItem{}, err
+	}
+	item := tomb.Item
+	if _, ok := items[item.Id]; ok {
+		return ToDoItem{}, fmt.Errorf("%w: item %d already exists, delete it before restoring the old one", ErrConflict, item.Id)
+	}
+	if _, ok := items[item.ParentId]; !ok {
+		item.ParentId = 0
+	}
+	var blockers []int
+	for _, blocker := range item.BlockedBy {
+		if _, ok := items[blocker]; ok {
+			blockers = append(blockers, blocker)
+		}
+	}
+	item.BlockedBy = blockers
+	if err := checkRelations(item, items); err != nil {
+		return ToDoItem{}, err
+	}
+
+	item.Version++
+	item.UpdatedAt = time.Now().UTC()
+	if err := t.addChild(item); err != nil {
+		return ToDoItem{}, err
+	}
+	ok, err := t.setItemIfAbsent(item)
+	if err != nil {
+		return ToDoItem{}, err
+	}
+	if !ok {
+		return ToDoItem{}, fmt.Errorf("%w: item %d already exists, delete it before restoring the old one", ErrConflict, item.Id)
+	}
+	if err := t.cacheClient.Del(t.context, trashKey).Err(); err != nil {
+		return ToDoItem{}, err
+	}
+	return withProgress(item, items), nil
+}
+
+// PurgeTrash drops the tombstones whose retention period is over at now,
+// from the trash of every list, and returns how many it dropped
+func (t *ToDo) PurgeTrash(now time.Time) (int, error) {
+	ks, err := t.keys(RedisTrashPrefix + "*")
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, key := range ks {
+		tomb, err := t.getTombstoneFromRedis(key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		if tomb.PurgeAt.After(now) {
+			continue
+		}
+		if err := t.cacheClient.Del(t.context, key).Err(); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// StartPurger calls PurgeTrash every interval, until the returned function
+// is called
+func (t *ToDo) StartPurger(every time.Duration) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			n, err := t.PurgeTrash(time.Now())
+			if err != nil {
+				log.Println("Error purging the trash: ", err)
+			} else if n > 0 {
+				log.Printf("Purged %d item(s) from the trash", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,149 @@
+package db_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// trashIds lists the ids of the items in the trash
+func trashIds(t *testing.T, todo *db.ToDo) []int {
+	t.Helper()
+	trash, err := todo.GetTrash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int{}
+	for _, tomb := range trash {
+		ids = append(ids, tomb.Item.Id)
+	}
+	return ids
+}
+
+func TestRestoreItem(t *testing.T) {
+	tests := []struct {
+		name       string
+		deleted    []int // deleted in this order, with CascadeOrphan
+		restore    int
+		before     func(todo *db.ToDo) error
+		wantErr    error
+		want       db.ToDoItem
+		wantTrash  []int
+		wantParent int
+	}{
+		{
+			name: "a deleted item", deleted: []int{3}, restore: 3,
+			want:      db.ToDoItem{Id: 3, Title: "budget", ParentId: 1, IsDone: true, Version: 2},
+			wantTrash: []int{},
+		},
+		{
+			name: "a subtask of a deleted parent", deleted: []int{2, 1}, restore: 2,
+			want:      db.ToDoItem{Id: 2, Title: "design", Version: 2},
+			wantTrash: []int{1},
+		},
+		{
+			name: "the parent first", deleted: []int{1}, restore: 1,
+			want:      db.ToDoItem{Id: 1, Title: "project", Version: 2},
+			wantTrash: []int{},
+		},
+		{
+			name: "an item that is not in the trash", restore: 3,
+			wantErr:   db.ErrNotFound,
+			wantTrash: []int{},
+		},
+		{
+			name: "an id that was taken again", deleted: []int{3}, restore: 3,
+			before: func(todo *db.ToDo) error {
+				return todo.AddItem(db.ToDoItem{Id: 3, Title: "another budget"})
+			},
+			wantErr:   db.ErrConflict,
+			wantTrash: []int{3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := newToDo(t)
+			for _, item := range plan[:3] {
+				if err := todo.AddItem(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, id := range tt.deleted {
+				if err := todo.DeleteItem(id, db.CascadeOrphan); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.before != nil {
+				if err := tt.before(todo); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			restored, err := todo.RestoreItem(tt.restore)
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				restored.UpdatedAt = time.Time{}
+				if !reflect.DeepEqual(restored, tt.want) {
+					t.Errorf("got %+v, want %+v", restored, tt.want)
+				}
+				stored, err := todo.GetItem(tt.restore)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if stored.Version != tt.want.Version || stored.ParentId != tt.want.ParentId {
+					t.Errorf("stored %+v", stored)
+				}
+			}
+			if got := trashIds(t, todo); !reflect.DeepEqual(got, tt.wantTrash) {
+				t.Errorf("got the trash %v, want %v", got, tt.wantTrash)
+			}
+		})
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	todo := newToDo(t)
+	todo.SetRetention(time.Hour)
+	work := todo.List("alice", "work")
+	for _, list := range []*db.ToDo{todo, work} {
+		for _, item := range plan[:3] {
+			if err := list.AddItem(item); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := todo.DeleteItem(3, db.CascadeRestrict); err != nil {
+		t.Fatal(err)
+	}
+	if err := work.DeleteAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	//nothing is due yet
+	purged, err := todo.PurgeTrash(time.Now().Add(30 * time.Minute))
+	if err != nil || purged != 0 {
+		t.Fatalf("purged %d, %v", purged, err)
+	}
+	if got, want := trashIds(t, work), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the trash of work %v, want %v", got, want)
+	}
+
+	//the trash of every list is purged
+	purged, err = todo.PurgeTrash(time.Now().Add(2 * time.Hour))
+	if err != nil || purged != 4 {
+		t.Fatalf("purged %d, %v, want 4", purged, err)
+	}
+	for _, list := range []*db.ToDo{todo, work} {
+		if got := trashIds(t, list); len(got) != 0 {
+			t.Errorf("the trash of %s still has %v", list.ListId(), got)
+		}
+	}
+	if _, err := work.RestoreItem(1); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("restored a purged item: %v", err)
+	}
+}
@@ -1,86 +1,86 @@
 package main
 
 import (
-	"flag"
+	"config"
 	"fmt"
 	"os"
+	"time"
 
 	"drexel.edu/todo/api"
+	"drexel.edu/todo/db"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-// Global variables to hold the command line flags to drive the todo CLI
-// application
-var (
-	hostFlag string
-	portFlag uint
-)
-
-// processCmdLineFlags parses the command line flags for our CLI
-//
-// TODO: This function uses the flag package to parse the command line
-//		 flags.  The flag package is not very flexible and can lead to
-//		 some confusing code.
-
-//			 REQUIRED:     Study the code below, and make sure you understand
-//						   how it works.  Go online and readup on how the
-//						   flag package works.  Then, write a nice comment
-//				  		   block to document this function that highights that
-//						   you understand how it works.
-//
-//			 EXTRA CREDIT: The best CLI and command line processor for
-//						   go is called Cobra.  Refactor this function to
-//						   use it.  See github.com/spf13/cobra for information
-//						   on how to use it.
-//
-//	 YOUR ANSWER: <GOES HERE>
-func processCmdLineFlags() {
-
-	//Note some networking lingo, some frameworks start the server on localhost
-	//this is a local-only interface and is fine for testing but its not accessible
-	//from other machines.  To make the server accessible from other machines, we
-	//need to listen on an interface, that could be an IP address, but modern
-	//cloud servers may have multiple network interfaces for scale.  With TCP/IP
-	//the address 0.0.0.0 instructs the network stack to listen on all interfaces
-	//We set this up as a flag so that we can overwrite it on the command line if
-	//needed
-	flag.StringVar(&hostFlag, "h", "0.0.0.0", "Listen on all interfaces")
-	flag.UintVar(&portFlag, "p", 1080, "Default Port")
+// Config is the configuration of the api.  Every key comes from, the first
+// one that has a value wins, the command line flags (eg -p 8080), the
+// environment (eg PORT=8080), a YAML or TOML file named by -config, and
+// the defaults in loadConfig.  See the config package at the top of the
+// repo, -help lists the keys and -print-config shows what the api would
+// use.
+type Config struct {
+	config.Server
+	Redis          config.Redis  `config:"redis"`
+	Purge          time.Duration `config:"purge" validate:"min=0s" usage:"How often to purge the expired todos from the trash, 0 to never"`
+	TrashRetention time.Duration `config:"trash-retention" validate:"min=1s" usage:"How long the deleted todos stay in the trash"`
+}
 
-	flag.Parse()
+// loadConfig loads the Config, it exits when the configuration is not
+// valid, after printing why
+func loadConfig() Config {
+	cfg := Config{
+		//Note some networking lingo, some frameworks start the server on localhost
+		//this is a local-only interface and is fine for testing but its not accessible
+		//from other machines.  To make the server accessible from other machines, we
+		//need to listen on an interface, that could be an IP address, but modern
+		//cloud servers may have multiple network interfaces for scale.  With TCP/IP
+		//the address 0.0.0.0 instructs the network stack to listen on all interfaces
+		//We set this up as the host key so that we can overwrite it with -h, HOST
+		//or the config file if needed
+		Server:         config.Server{Host: "0.0.0.0", Port: 1080},
+		Redis:          config.Redis{URL: db.RedisDefaultLocation},
+		Purge:          db.DefaultPurgeEvery,
+		TrashRetention: db.DefaultRetention,
+	}
+	config.MustLoad("", &cfg)
+	return cfg
 }
 
-// main is the entry point for our todo API application.  It processes
-// the command line flags and then uses the db package to perform the
+// main is the entry point for our todo API application.  It loads the
+// configuration and then uses the db package to perform the
 // requested operation
 func main() {
-	processCmdLineFlags()
+	cfg := loadConfig()
 	r := gin.Default()
 	r.Use(cors.Default())
+	r.Use(api.ProblemMiddleware())
+	if err := api.RegisterValidators(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
-	apiHandler, err := api.New()
+	redisClient, err := cfg.Redis.Client()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	apiHandler, err := api.New(redisClient, cfg.TrashRetention)
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 
-	r.GET("/todo", apiHandler.ListAllTodos)
-	r.POST("/todo", apiHandler.AddToDo)
-	r.PUT("/todo", apiHandler.UpdateToDo)
-	r.DELETE("/todo", apiHandler.DeleteAllToDo)
-	r.DELETE("/todo/:id", apiHandler.DeleteToDo)
-	r.GET("/todo/:id", apiHandler.GetToDo)
-
-	r.GET("/crash", apiHandler.CrashSim)
-	r.GET("/health", apiHandler.HealthCheck)
+	//The routes are described in api/routes.go, which also generates
+	//the OpenAPI document served at /openapi.json.  Have a look at it to
+	//see how we version the api under /v2
+	apiHandler.RegisterRoutes(r)
 
-	//We will now show a common way to version an API and add a new
-	//version of an API handler under /v2.  This new API will support
-	//a path parameter to search for todos based on a status
-	v2 := r.Group("/v2")
-	v2.GET("/todo", apiHandler.ListSelectTodos)
+	//Deleted todos stay in the trash for TRASH_RETENTION (7 days by
+	//default), the purger drops them after that
+	if cfg.Purge > 0 {
+		stop := apiHandler.StartPurger(cfg.Purge)
+		defer stop()
+	}
 
-	serverPath := fmt.Sprintf("%s:%d", hostFlag, portFlag)
-	r.Run(serverPath)
+	r.Run(cfg.Addr())
 }
@@ -3,10 +3,13 @@ package api
 import (
 	"log"
 	"net/http"
+	"problem"
 	"strconv"
+	"time"
 
 	"drexel.edu/todo-events/db"
 	"drexel.edu/todo-events/events"
+	"drexel.edu/todo-events/reminders"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,6 +18,10 @@ import (
 type ToDoAPI struct {
 	db           *db.ToDo
 	eventHandler *events.ToDoEventManager
+	reminders    *reminders.Scheduler
+
+	//tokens that confirm DELETE /todo, see DeleteAllToDo
+	confirmations *db.Confirmations
 }
 
 func New() (*ToDoAPI, error) {
@@ -26,8 +33,9 @@ func New() (*ToDoAPI, error) {
 
 	//By default we will not be doing eventing
 	return &ToDoAPI{
-		db:           dbHandler,
-		eventHandler: nil,
+		db:            dbHandler,
+		eventHandler:  nil,
+		confirmations: db.NewConfirmations(),
 	}, nil
 }
 
@@ -44,6 +52,26 @@ func (td *ToDoAPI) StopEventListener() {
 	td.eventHandler.Stop()
 }
 
+// StartReminders starts the scheduler that sends due and overdue events
+// for the items, through the event listener.  It looks at the items every
+// tick, an item counts as overdue once it is overdueAfter past its due
+// time.
+func (td *ToDoAPI) StartReminders(tick time.Duration, overdueAfter time.Duration) {
+	if td.eventHandler == nil {
+		td.AddEventListener()
+	}
+	td.reminders = reminders.NewScheduler(td.db, td.eventHandler)
+	td.reminders.Tick = tick
+	td.reminders.OverdueAfter = overdueAfter
+	td.reminders.Start()
+}
+
+func (td *ToDoAPI) StopReminders() {
+	if td.reminders != nil {
+		td.reminders.Stop()
+	}
+}
+
 func (td *ToDoAPI) Notify(event *events.ToDoEvent) {
 	if td.eventHandler == nil {
 		td.eventHandler.Notify(event)
@@ -58,8 +86,10 @@ func (td *ToDoAPI) Notify(event *events.ToDoEvent) {
 //   3) How to return JSON and a correctly formed HTTP status code
 //	  for example, 200 for OK, 404 for not found, etc.  This is done
 //	  using the c.JSON() function
-//   4) How to return an error code and abort the request.  This is
-//	  done using the c.AbortWithStatus() function
+//   4) How to return an error and abort the request.  This is
+//	  done using the problem.Abort() helper, the problem middleware
+//	  then turns the error into a problem+json response with the
+//	  right status code (see problem.go)
 
 // implementation for GET /todo
 // returns all todos
@@ -68,7 +98,7 @@ func (td *ToDoAPI) ListAllTodos(c *gin.Context) {
 	todoList, err := td.db.GetAllItems()
 	if err != nil {
 		log.Println("Error Getting All Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 	//Note that the database returns a nil slice if there are no items
@@ -96,7 +126,7 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 	todoList, err := td.db.GetAllItems()
 	if err != nil {
 		log.Println("Error Getting Database Items: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 	//If the database is empty, make an empty slice so that the
@@ -121,7 +151,7 @@ func (td *ToDoAPI) ListSelectTodos(c *gin.Context) {
 	done, err := strconv.ParseBool(doneS)
 	if err != nil {
 		log.Println("Error converting done to bool: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -157,7 +187,7 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 	id64, err := strconv.ParseInt(idS, 10, 32)
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -166,7 +196,7 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 	todoItem, err := td.db.GetItem(int(id64))
 	if err != nil {
 		log.Println("Item not found: ", err)
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -178,8 +208,20 @@ func (td *ToDoAPI) GetToDo(c *gin.Context) {
 }
 
 // implementation for POST /todo
-// adds a new todo
+// adds a new todo, the server picks the id and returns it in the
+// Location header.  If the client sends an Idempotency-Key header
+// a retried request returns the item created the first time
+// instead of adding a duplicate
 func (td *ToDoAPI) AddToDo(c *gin.Context) {
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey != "" {
+		if todoItem, ok := td.db.RecallItem(idempotencyKey); ok {
+			c.Header("Location", "/todo/"+strconv.Itoa(todoItem.Id))
+			c.JSON(http.StatusCreated, todoItem)
+			return
+		}
+	}
+
 	var todoItem db.ToDoItem
 
 	//With HTTP based APIs, a POST request will usually
@@ -195,19 +237,25 @@ func (td *ToDoAPI) AddToDo(c *gin.Context) {
 	//the struct we are binding to.
 	if err := c.ShouldBindJSON(&todoItem); err != nil {
 		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
-	if err := td.db.AddItem(todoItem); err != nil {
+	//Any id in the body is ignored, the database assigns the next one
+	todoItem, replayed, err := td.db.CreateItemOnce(idempotencyKey, todoItem)
+	if err != nil {
 		log.Println("Error adding item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
-	evnt := events.NewEvent(events.ToDoAddEvent, "todoItem", todoItem)
-	td.eventHandler.Notify(evnt)
+	//the add event of a replayed item went out with the first request
+	if !replayed {
+		evnt := events.NewEvent(events.ToDoAddEvent, "todoItem", todoItem)
+		td.eventHandler.Notify(evnt)
+	}
 
-	c.JSON(http.StatusOK, todoItem)
+	c.Header("Location", "/todo/"+strconv.Itoa(todoItem.Id))
+	c.JSON(http.StatusCreated, todoItem)
 }
 
 // implementation for PUT /todo
@@ -216,13 +264,13 @@ func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
 	var todoItem db.ToDoItem
 	if err := c.ShouldBindJSON(&todoItem); err != nil {
 		log.Println("Error binding JSON: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	if err := td.db.UpdateItem(todoItem); err != nil {
 		log.Println("Error updating item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -231,15 +279,52 @@ func (td *ToDoAPI) UpdateToDo(c *gin.Context) {
 	c.JSON(http.StatusOK, todoItem)
 }
 
+// implementation for PUT /todo/:id/done/:doneFlag
+// marks a todo done or not done.  Completing a recurring todo adds its
+// next occurrence, which is returned as next, eg
+// {"todoItem": {...}, "next": {...}}
+func (td *ToDoAPI) SetToDoDone(c *gin.Context) {
+	id64, err := strconv.ParseInt(c.Param("id"), 10, 32)
+	if err != nil {
+		log.Println("Error converting id to int64: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
+	done, err := strconv.ParseBool(c.Param("doneFlag"))
+	if err != nil {
+		log.Println("Error converting done flag, must be bool: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
+
+	todoItem, next, err := td.db.SetDone(int(id64), done)
+	if err != nil {
+		log.Println("Error changing done status: ", err)
+		problem.Abort(c, err)
+		return
+	}
+
+	td.eventHandler.Notify(events.NewEvent(events.ToDoUpdateEvent, "todoItem", todoItem))
+	if next != nil {
+		td.eventHandler.Notify(events.NewEvent(events.ToDoAddEvent, "todoItem", *next))
+	}
+	c.JSON(http.StatusOK, gin.H{"todoItem": todoItem, "next": next})
+}
+
 // implementation for DELETE /todo/:id
 // deletes a todo
 func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 	idS := c.Param("id")
-	id64, _ := strconv.ParseInt(idS, 10, 32)
+	id64, err := strconv.ParseInt(idS, 10, 32)
+	if err != nil {
+		log.Println("Error converting id to int64: ", err)
+		problem.AbortBadRequest(c, err)
+		return
+	}
 
 	if err := td.db.DeleteItem(int(id64)); err != nil {
 		log.Println("Error deleting item: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -250,12 +335,19 @@ func (td *ToDoAPI) DeleteToDo(c *gin.Context) {
 }
 
 // implementation for DELETE /todo
-// deletes all todos
+// deletes all todos, once confirmed
 func (td *ToDoAPI) DeleteAllToDo(c *gin.Context) {
 
+	//the first call answers 428 with a token, the items are only
+	//deleted when the call is repeated with ?confirm=<token>
+	if err := td.confirmations.Confirm("delete all items", c.Query("confirm")); err != nil {
+		problem.Abort(c, err)
+		return
+	}
+
 	if err := td.db.DeleteAll(); err != nil {
 		log.Println("Error deleting all items: ", err)
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -301,7 +393,7 @@ func (td *ToDoAPI) EventEnabler(c *gin.Context) {
 	eFlag, err := strconv.ParseBool(enable)
 	if err != nil {
 		log.Println("Error converting enable flag, must be bool: ", err)
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
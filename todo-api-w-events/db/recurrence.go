@@ -0,0 +1,153 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is how often a recurring item comes back
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Recurrence is the subset of an iCalendar RRULE (RFC 5545) we support, it
+// is written the same way in json, eg "FREQ=WEEKLY;INTERVAL=2;COUNT=10".
+//
+// The rule is anchored on the due time of the item.  When the item is
+// completed the next occurrence is added as a new item, due Interval days,
+// weeks or months later, and the rule moves over to it.  Count is the
+// number of occurrences that are left including the current one, so it
+// goes down by one each time.  A monthly rule skips months that do not
+// have the day of the month, like RRULE does, so an item due on the 31st
+// only comes back in months with 31 days.
+type Recurrence struct {
+	Freq     Frequency
+	Interval int        // 1 if 0
+	Until    *time.Time // no occurrence is due after Until
+	Count    int        // 0 means no limit
+}
+
+const untilFormat = "20060102T150405Z"
+
+// ParseRecurrence parses the RRULE subset, eg "FREQ=DAILY;UNTIL=20261231"
+func ParseRecurrence(rule string) (Recurrence, error) {
+	var r Recurrence
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:"), ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Recurrence{}, fmt.Errorf("recurrence %q: %q is not NAME=VALUE", rule, part)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			if r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly {
+				err = fmt.Errorf("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("INTERVAL must be at least 1")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("COUNT must be at least 1")
+			}
+		case "UNTIL":
+			var until time.Time
+			until, err = time.Parse(untilFormat, strings.ToUpper(value))
+			if err != nil {
+				//a date only means the end of that day
+				until, err = time.Parse("20060102", value)
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			r.Until = &until
+		default:
+			err = fmt.Errorf("%s is not supported, use FREQ, INTERVAL, COUNT and UNTIL", name)
+		}
+		if err != nil {
+			return Recurrence{}, fmt.Errorf("recurrence %q: %w", rule, err)
+		}
+	}
+
+	if r.Freq == "" {
+		return Recurrence{}, fmt.Errorf("recurrence %q: FREQ is required", rule)
+	}
+	if r.Count > 0 && r.Until != nil {
+		return Recurrence{}, fmt.Errorf("recurrence %q: COUNT and UNTIL can not be used together", rule)
+	}
+	return r, nil
+}
+
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilFormat))
+	}
+	return strings.Join(parts, ";")
+}
+
+func (r Recurrence) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Recurrence) UnmarshalText(text []byte) error {
+	parsed, err := ParseRecurrence(string(text))
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
+
+// Next returns when the occurrence after the one due at due is due, and
+// the rule that goes with it.  ok is false if the rule has run out.
+func (r Recurrence) Next(due time.Time) (next time.Time, rule Recurrence, ok bool) {
+	if r.Count == 1 {
+		return time.Time{}, r, false
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	switch r.Freq {
+	case Daily:
+		next = due.AddDate(0, 0, interval)
+	case Weekly:
+		next = due.AddDate(0, 0, 7*interval)
+	case Monthly:
+		//AddDate would turn Jan 31 + 1 month into Mar 3, skip the
+		//months that are too short instead
+		for months := interval; ; months += interval {
+			next = due.AddDate(0, months, 0)
+			if next.Day() == due.Day() {
+				break
+			}
+		}
+	default:
+		return time.Time{}, r, false
+	}
+
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, r, false
+	}
+	rule = r
+	if rule.Count > 0 {
+		rule.Count--
+	}
+	return next, rule, true
+}
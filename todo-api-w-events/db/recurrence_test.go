@@ -0,0 +1,85 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"drexel.edu/todo-events/db"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 9, 30, 0, 0, time.UTC)
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	until := date(2026, time.March, 1)
+	tests := []struct {
+		name      string
+		rule      db.Recurrence
+		due       time.Time
+		wantNext  time.Time
+		wantCount int
+		wantOk    bool
+	}{
+		{"daily", db.Recurrence{Freq: db.Daily}, date(2026, time.January, 31), date(2026, time.February, 1), 0, true},
+		{"every 3 days", db.Recurrence{Freq: db.Daily, Interval: 3}, date(2026, time.February, 27), date(2026, time.March, 2), 0, true},
+		{"weekly", db.Recurrence{Freq: db.Weekly}, date(2026, time.December, 28), date(2027, time.January, 4), 0, true},
+		{"every 2 weeks", db.Recurrence{Freq: db.Weekly, Interval: 2}, date(2026, time.January, 1), date(2026, time.January, 15), 0, true},
+		{"monthly", db.Recurrence{Freq: db.Monthly}, date(2026, time.January, 15), date(2026, time.February, 15), 0, true},
+		{"monthly skips short months", db.Recurrence{Freq: db.Monthly}, date(2026, time.January, 31), date(2026, time.March, 31), 0, true},
+		{"every 2 months skips short months", db.Recurrence{Freq: db.Monthly, Interval: 2}, date(2026, time.May, 31), date(2026, time.July, 31), 0, true},
+		{"monthly on the 29th skips a february", db.Recurrence{Freq: db.Monthly}, date(2027, time.January, 29), date(2027, time.March, 29), 0, true},
+		{"monthly on the 29th in a leap year", db.Recurrence{Freq: db.Monthly}, date(2028, time.January, 29), date(2028, time.February, 29), 0, true},
+		{"count goes down", db.Recurrence{Freq: db.Daily, Count: 3}, date(2026, time.January, 1), date(2026, time.January, 2), 2, true},
+		{"the last of the count", db.Recurrence{Freq: db.Daily, Count: 1}, date(2026, time.January, 1), time.Time{}, 1, false},
+		{"due on until", db.Recurrence{Freq: db.Daily, Until: &until}, date(2026, time.February, 28), until, 0, true},
+		{"after until", db.Recurrence{Freq: db.Weekly, Until: &until}, date(2026, time.February, 28), time.Time{}, 0, false},
+		{"unknown frequency", db.Recurrence{Freq: "YEARLY"}, date(2026, time.January, 1), time.Time{}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next, rule, ok := tt.rule.Next(tt.due)
+			if ok != tt.wantOk || !next.Equal(tt.wantNext) {
+				t.Fatalf("got %v, %v, want %v, %v", next, ok, tt.wantNext, tt.wantOk)
+			}
+			if rule.Count != tt.wantCount {
+				t.Errorf("got count %d, want %d", rule.Count, tt.wantCount)
+			}
+			if rule.Freq != tt.rule.Freq || rule.Interval != tt.rule.Interval || rule.Until != tt.rule.Until {
+				t.Errorf("the rule changed from %v to %v", tt.rule, rule)
+			}
+		})
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    string // the rule as it is written back, "" if it does not parse
+		wantErr bool
+	}{
+		{rule: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{rule: "RRULE:freq=weekly;interval=2;count=10", want: "FREQ=WEEKLY;INTERVAL=2;COUNT=10"},
+		{rule: "FREQ=MONTHLY;INTERVAL=1", want: "FREQ=MONTHLY"},
+		{rule: "FREQ=DAILY;UNTIL=20261231T120000Z", want: "FREQ=DAILY;UNTIL=20261231T120000Z"},
+		{rule: "FREQ=DAILY;UNTIL=20261231", want: "FREQ=DAILY;UNTIL=20261231T235959Z"},
+		{rule: "INTERVAL=2", wantErr: true},
+		{rule: "FREQ=YEARLY", wantErr: true},
+		{rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{rule: "FREQ=DAILY;COUNT=0", wantErr: true},
+		{rule: "FREQ=DAILY;COUNT=2;UNTIL=20261231", wantErr: true},
+		{rule: "FREQ=DAILY;BYDAY=MO", wantErr: true},
+		{rule: "FREQ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := db.ParseRecurrence(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && r.String() != tt.want {
+				t.Errorf("got %q, want %q", r.String(), tt.want)
+			}
+		})
+	}
+}
@@ -4,15 +4,31 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
+	"validate"
 )
 
-// ToDoItem is the struct that represents a single ToDo item
+// ToDoItem is the struct that represents a single ToDo item.  An item
+// with a Recurrence comes back when it is completed, see SetDone, the
+// rule needs a Due time to start from.
 type ToDoItem struct {
-	Id     int    `json:"id"`
-	Title  string `json:"title"`
-	IsDone bool   `json:"done"`
+	Id         int         `json:"id"`
+	Title      string      `json:"title" binding:"notblank"`
+	IsDone     bool        `json:"done"`
+	Due        *time.Time  `json:"due,omitempty" binding:"required_with=Recurrence"`
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
 }
 
+// These are the errors the database reports back to its callers.  They
+// are wrapped with more details, so check for them with errors.Is(), the
+// api package uses them to pick the right HTTP status code
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = validate.ErrValidation
+)
+
 // DbMap is a type alias for a map of ToDoItems.  The key
 // will be the ToDoItem.Id and the value will be the ToDoItem
 type DbMap map[int]ToDoItem
@@ -24,8 +40,18 @@ type DbMap map[int]ToDoItem
 // This is just a mock, so we will only be managing an in memory
 // map
 type ToDo struct {
+	//mu guards toDoMap, lastId and idempotencyKeys, the reminder
+	//scheduler reads the items while requests change them
+	mu      sync.RWMutex
 	toDoMap DbMap
 	//more things would be included in a real implementation
+
+	//lastId is the last id handed out by CreateItem
+	lastId int
+
+	//idempotencyKeys maps an Idempotency-Key to the id of the item
+	//that was created with it
+	idempotencyKeys map[string]int
 }
 
 // New is a constructor function that returns a pointer to a new
@@ -38,7 +64,8 @@ func New() (*ToDo, error) {
 	//Now that we know the file exists, at at the minimum we have
 	//a valid empty DB, lets create the ToDo struct
 	toDo := &ToDo{
-		toDoMap: make(map[int]ToDoItem),
+		toDoMap:         make(map[int]ToDoItem),
+		idempotencyKeys: make(map[string]int),
 	}
 
 	// We should be all set here, the ToDo struct is ready to go
@@ -64,12 +91,14 @@ func New() (*ToDo, error) {
 //		(2) The DB file will be saved with the item added
 //		(3) If there is an error, it will be returned
 func (t *ToDo) AddItem(item ToDoItem) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
 	//Before we add an item to the DB, lets make sure
 	//it does not exist, if it does, return an error
 	_, ok := t.toDoMap[item.Id]
 	if ok {
-		return errors.New("item already exists")
+		return fmt.Errorf("%w: item %d already exists", ErrConflict, item.Id)
 	}
 
 	//Now that we know the item doesn't exist, lets add it to our map
@@ -79,6 +108,70 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 	return nil
 }
 
+// CreateItem accepts a ToDoItem, assigns it the next free id and adds
+// it to the DB.  Ids that were already taken with AddItem are skipped.
+//
+// Postconditions:
+//
+//	 (1) The item will be added to the DB under a new id
+//		(2) The stored item, including its id, will be returned
+func (t *ToDo) CreateItem(item ToDoItem) (ToDoItem, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.createItem(item), nil
+}
+
+// createItem is CreateItem for callers that hold t.mu
+func (t *ToDo) createItem(item ToDoItem) ToDoItem {
+	for {
+		t.lastId++
+		item.Id = t.lastId
+		if _, ok := t.toDoMap[item.Id]; !ok {
+			break
+		}
+	}
+
+	t.toDoMap[item.Id] = item
+	return item
+}
+
+// RecallItem returns the item that was created with the provided
+// Idempotency-Key.  The bool result is false if the key is unknown
+// or the item was deleted in the meantime.
+func (t *ToDo) RecallItem(idempotencyKey string) (ToDoItem, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	id, ok := t.idempotencyKeys[idempotencyKey]
+	if !ok {
+		return ToDoItem{}, false
+	}
+
+	item, ok := t.toDoMap[id]
+	return item, ok
+}
+
+// CreateItemOnce is CreateItem for a request with an Idempotency-Key.
+// It looks the key up and creates the item under one lock, so of two
+// requests with the same key only one creates an item.  The bool result
+// is true when an earlier request already created the item, which is
+// returned instead.  Without a key it is CreateItem.
+func (t *ToDo) CreateItemOnce(idempotencyKey string, item ToDoItem) (ToDoItem, bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if idempotencyKey == "" {
+		return t.createItem(item), false, nil
+	}
+	if id, ok := t.idempotencyKeys[idempotencyKey]; ok {
+		if earlier, ok := t.toDoMap[id]; ok {
+			return earlier, true, nil
+		}
+	}
+	item = t.createItem(item)
+	t.idempotencyKeys[idempotencyKey] = item.Id
+	return item, false, nil
+}
+
 // DeleteItem accepts an item id and removes it from the DB.
 // Preconditions:   (1) The database file must exist and be a valid
 //
@@ -93,10 +186,15 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 //		(2) The DB file will be saved with the item removed
 //		(3) If there is an error, it will be returned
 func (t *ToDo) DeleteItem(id int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 
 	// we should if item exists before trying to delete it
 	// this is a good practice, return an error if the
 	// item does not exist
+	if _, ok := t.toDoMap[id]; !ok {
+		return fmt.Errorf("%w: attempted to delete non-existent item %d", ErrNotFound, id)
+	}
 
 	//Now lets use the built-in go delete() function to remove
 	//the item from our map
@@ -108,6 +206,8 @@ func (t *ToDo) DeleteItem(id int) error {
 // DeleteAll removes all items from the DB.
 // It will be exposed via a DELETE /todo endpoint
 func (t *ToDo) DeleteAll() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	//To delete everything, we can just create a new map
 	//and assign it to our existing map.  The garbage collector
 	//will clean up the old map for us
@@ -130,13 +230,19 @@ func (t *ToDo) DeleteAll() error {
 //		(2) The DB file will be saved with the item updated
 //		(3) If there is an error, it will be returned
 func (t *ToDo) UpdateItem(item ToDoItem) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.updateItem(item)
+}
+
+func (t *ToDo) updateItem(item ToDoItem) error {
 
 	// Check if item exists before trying to update it
 	// this is a good practice, return an error if the
 	// item does not exist
 	_, ok := t.toDoMap[item.Id]
 	if !ok {
-		return errors.New("item does not exist")
+		return fmt.Errorf("%w: item %d does not exist", ErrNotFound, item.Id)
 	}
 
 	//Now that we know the item exists, lets update it
@@ -160,13 +266,19 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 //			along with an empty ToDoItem
 //		(3) The database file will not be modified
 func (t *ToDo) GetItem(id int) (ToDoItem, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.getItem(id)
+}
+
+func (t *ToDo) getItem(id int) (ToDoItem, error) {
 
 	// Check if item exists before trying to get it
 	// this is a good practice, return an error if the
 	// item does not exist
 	item, ok := t.toDoMap[id]
 	if !ok {
-		return ToDoItem{}, errors.New("item does not exist")
+		return ToDoItem{}, fmt.Errorf("%w: item %d does not exist", ErrNotFound, id)
 	}
 
 	return item, nil
@@ -193,9 +305,38 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 //			from the DB, then it should call UpdateItem() to update the
 //			item in the DB (after the status is changed).
 func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
+	_, _, err := t.SetDone(id, value)
+	return err
+}
 
-	//update was successful
-	return errors.New("not implemented")
+// SetDone is ChangeItemDoneStatus, it also returns the updated item and,
+// when a recurring item was completed, the next occurrence.  The next
+// occurrence is a new item with the same title, due when the rule says.
+// The rule moves over to it, so completing the item again, after it was
+// reopened, does not add a second one.
+func (t *ToDo) SetDone(id int, value bool) (item ToDoItem, next *ToDoItem, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	item, err = t.getItem(id)
+	if err != nil {
+		return ToDoItem{}, nil, err
+	}
+	completed := value && !item.IsDone
+	item.IsDone = value
+
+	if completed && item.Recurrence != nil && item.Due != nil {
+		if due, rule, ok := item.Recurrence.Next(*item.Due); ok {
+			created := t.createItem(ToDoItem{Title: item.Title, Due: &due, Recurrence: &rule})
+			next = &created
+		}
+		item.Recurrence = nil
+	}
+
+	if err := t.updateItem(item); err != nil {
+		return ToDoItem{}, nil, err
+	}
+	return item, next, nil
 }
 
 // GetAllItems returns all items from the DB.  If successful it
@@ -209,6 +350,8 @@ func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
 //			along with an empty slice
 //		(3) The database file will not be modified
 func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
 	//Now that we have the DB loaded, lets crate a slice
 	var toDoList []ToDoItem
@@ -242,7 +385,8 @@ func (t *ToDo) PrintAllItems(itemList []ToDoItem) {
 // JsonToItem accepts a json string and returns a ToDoItem
 // This is helpful because the CLI accepts todo items for insertion
 // and updates in JSON format.  We need to convert it to a ToDoItem
-// struct to perform any operations on it.
+// struct to perform any operations on it.  The item is checked with
+// ValidateItem, the same rules the API applies to request bodies.
 func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
 	var item ToDoItem
 	err := json.Unmarshal([]byte(jsonString), &item)
@@ -250,5 +394,9 @@ func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
 		return ToDoItem{}, err
 	}
 
+	if err := ValidateItem(item); err != nil {
+		return ToDoItem{}, err
+	}
+
 	return item, nil
 }
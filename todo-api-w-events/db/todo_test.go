@@ -0,0 +1,165 @@
+package db_test
+
+import (
+	"sync"
+	"testing"
+
+	"drexel.edu/todo-events/db"
+)
+
+func newToDo(t *testing.T) *db.ToDo {
+	t.Helper()
+	todo, err := db.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
+
+func TestCreateItemIds(t *testing.T) {
+	tests := []struct {
+		name  string
+		added []int
+		want  []int
+	}{
+		{"counts up from 1", nil, []int{1, 2, 3}},
+		{"skips added ids", []int{2, 3}, []int{1, 4, 5}},
+		{"ignores the id of the item", nil, []int{1, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := newToDo(t)
+			for _, id := range tt.added {
+				if err := todo.AddItem(db.ToDoItem{Id: id, Title: "added"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, want := range tt.want {
+				item, err := todo.CreateItem(db.ToDoItem{Id: 7, Title: "created"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item.Id != want {
+					t.Fatalf("got id %d, want %d", item.Id, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateItemConcurrent(t *testing.T) {
+	const requests = 50
+	todo := newToDo(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := todo.CreateItem(db.ToDoItem{Title: "concurrent"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	items, err := todo.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != requests {
+		t.Fatalf("got %d items, want %d", len(items), requests)
+	}
+}
+
+func TestCreateItemOnce(t *testing.T) {
+	type create struct {
+		key          string
+		wantId       int
+		wantReplayed bool
+	}
+	tests := []struct {
+		name    string
+		creates []create
+		deleted int
+		after   create
+	}{
+		{
+			name:    "no key creates every time",
+			creates: []create{{"", 1, false}, {"", 2, false}},
+		},
+		{
+			name:    "the same key replays",
+			creates: []create{{"a", 1, false}, {"a", 1, true}, {"a", 1, true}},
+		},
+		{
+			name:    "other keys create",
+			creates: []create{{"a", 1, false}, {"b", 2, false}, {"a", 1, true}},
+		},
+		{
+			name:    "a key whose item is gone creates again",
+			creates: []create{{"a", 1, false}},
+			deleted: 1,
+			after:   create{"a", 2, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := newToDo(t)
+			check := func(c create) {
+				t.Helper()
+				item, replayed, err := todo.CreateItemOnce(c.key, db.ToDoItem{Title: "key " + c.key})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if item.Id != c.wantId || replayed != c.wantReplayed {
+					t.Fatalf("key %q: got id %d replayed %v, want id %d replayed %v",
+						c.key, item.Id, replayed, c.wantId, c.wantReplayed)
+				}
+				recalled, ok := todo.RecallItem(c.key)
+				if c.key != "" && (!ok || recalled.Id != item.Id) {
+					t.Fatalf("recall %q: got %+v, %v, want id %d", c.key, recalled, ok, item.Id)
+				}
+			}
+			for _, c := range tt.creates {
+				check(c)
+			}
+			if tt.deleted != 0 {
+				if err := todo.DeleteItem(tt.deleted); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := todo.RecallItem(tt.after.key); ok {
+					t.Fatal("recall after delete: got the deleted item")
+				}
+				check(tt.after)
+			}
+		})
+	}
+}
+
+// TestCreateItemOnceConcurrent sends the same request many times at
+// once, of which exactly one may create the item
+func TestCreateItemOnceConcurrent(t *testing.T) {
+	const requests = 20
+	todo := newToDo(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := todo.CreateItemOnce("retried", db.ToDoItem{Title: "once"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	items, err := todo.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 {
+		t.Fatalf("got %d items, want 1: %+v", len(items), items)
+	}
+}
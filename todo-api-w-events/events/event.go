@@ -8,6 +8,8 @@ const (
 	ToDoUpdateEvent
 	ToDoDeleteEvent
 	ToDoErrorEvent
+	ToDoDueEvent     // an open item reached its due time
+	ToDoOverdueEvent // an open item is still not done well after its due time
 )
 
 type ToDoEvent struct {
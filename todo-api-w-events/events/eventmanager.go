@@ -4,9 +4,14 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 )
 
+// ToDoEventManager hands the events to its event loop.  It is safe to use
+// from several goroutines, the handlers and the reminder scheduler notify
+// it at the same time.
 type ToDoEventManager struct {
+	mu       sync.Mutex // guards ctx, cancel and isActive
 	ctx      context.Context
 	cancel   context.CancelFunc
 	queue    chan *ToDoEvent
@@ -23,18 +28,20 @@ func NewToDoEventManager() *ToDoEventManager {
 }
 
 func (em *ToDoEventManager) Start() {
+	em.mu.Lock()
+	defer em.mu.Unlock()
 	if !em.isActive {
 		em.ctx, em.cancel = context.WithCancel(context.Background())
 		em.isActive = true
-		go em.eventLoop()
+		go em.eventLoop(em.ctx)
 	}
 }
 
-func (em *ToDoEventManager) eventLoop() {
+func (em *ToDoEventManager) eventLoop(ctx context.Context) {
 	log.Println("Starting Event Loop...")
 	for {
 		select {
-		case <-em.ctx.Done():
+		case <-ctx.Done():
 			log.Println("Stopping Event Manager...")
 			return
 		case event := <-em.queue:
@@ -45,15 +52,28 @@ func (em *ToDoEventManager) eventLoop() {
 }
 
 func (em *ToDoEventManager) Stop() {
+	em.mu.Lock()
+	defer em.mu.Unlock()
 	if em.isActive {
 		em.cancel()
 		em.isActive = false
 	}
 }
 
+// Notify waits until the event loop takes the event.  The event is
+// dropped if the manager is not running, or stops meanwhile, so Notify
+// never blocks a caller for longer than the manager runs.
 func (em *ToDoEventManager) Notify(event *ToDoEvent) {
-	if em.isActive {
-		em.queue <- event
+	em.mu.Lock()
+	ctx, active := em.ctx, em.isActive
+	em.mu.Unlock()
+	if !active {
+		return
+	}
+
+	select {
+	case em.queue <- event:
+	case <-ctx.Done():
 	}
 }
 
@@ -69,6 +89,10 @@ func (em *ToDoEventManager) processEvent(event *ToDoEvent) {
 		em.processDeleteEvent(event)
 	case ToDoErrorEvent:
 		em.processErrorEvent(event)
+	case ToDoDueEvent:
+		em.processDueEvent(event)
+	case ToDoOverdueEvent:
+		em.processOverdueEvent(event)
 	}
 }
 
@@ -91,3 +115,11 @@ func (em *ToDoEventManager) processDeleteEvent(event *ToDoEvent) {
 func (em *ToDoEventManager) processErrorEvent(event *ToDoEvent) {
 	fmt.Println("Processing Error Event")
 }
+
+func (em *ToDoEventManager) processDueEvent(event *ToDoEvent) {
+	fmt.Println("Processing Due Event")
+}
+
+func (em *ToDoEventManager) processOverdueEvent(event *ToDoEvent) {
+	fmt.Println("Processing Overdue Event")
+}
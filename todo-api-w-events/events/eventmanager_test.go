@@ -0,0 +1,46 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestNotifyWhileStopping notifies from many goroutines while the manager
+// stops, none of them may be left waiting for an event loop that is gone
+func TestNotifyWhileStopping(t *testing.T) {
+	em := NewToDoEventManager()
+	em.Start()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				em.Notify(NewEvent(ToDoQueryEvent, "n", j))
+			}
+		}()
+	}
+	em.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Notify is still blocked after Stop")
+	}
+}
+
+func TestNotifyStopped(t *testing.T) {
+	em := NewToDoEventManager()
+	em.Notify(NewEvent(ToDoQueryEvent, "before", "start"))
+
+	em.Start()
+	em.Stop()
+	em.Notify(NewEvent(ToDoQueryEvent, "after", "stop"))
+}
@@ -2,7 +2,18 @@ module drexel.edu/todo-events
 
 go 1.20
 
-require github.com/gin-gonic/gin v1.9.1
+require (
+	config v0.0.0
+	github.com/gin-gonic/gin v1.9.1
+	problem v0.0.0
+	validate v0.0.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.1.2 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/go-redis/redis/v8 v8.11.5 // indirect
+)
 
 require (
 	github.com/bytedance/sonic v1.9.1 // indirect
@@ -31,3 +42,9 @@ require (
 	google.golang.org/protobuf v1.30.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace config => ../config
+
+replace problem => ../problem
+
+replace validate => ../validate
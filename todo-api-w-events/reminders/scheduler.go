@@ -0,0 +1,159 @@
+// Package reminders watches the due times of the todo items and reports
+// the items that become due, and later overdue, as events.
+package reminders
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"drexel.edu/todo-events/db"
+	"drexel.edu/todo-events/events"
+)
+
+const (
+	DefaultTick         = time.Minute
+	DefaultOverdueAfter = 24 * time.Hour
+)
+
+// Source is the part of db.ToDo the scheduler reads
+type Source interface {
+	GetAllItems() ([]db.ToDoItem, error)
+}
+
+// Notifier is the part of events.ToDoEventManager the scheduler needs
+type Notifier interface {
+	Notify(event *events.ToDoEvent)
+}
+
+// Scheduler looks at the items every Tick.  An open item whose due time
+// passed is reported once with a ToDoDueEvent, and once more with a
+// ToDoOverdueEvent when it is still open OverdueAfter later.  Changing the
+// due time of an item arms its events again.  An item that was already
+// overdue when the scheduler first saw it, eg after a restart, only gets
+// the overdue event.
+type Scheduler struct {
+	Tick         time.Duration
+	OverdueAfter time.Duration
+
+	source   Source
+	notifier Notifier
+	now      func() time.Time
+
+	mu       sync.Mutex
+	reported map[int]reported // by item id
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// reported remembers which events were sent for the due time of an item
+type reported struct {
+	due         time.Time
+	dueSent     bool
+	overdueSent bool
+}
+
+func NewScheduler(source Source, notifier Notifier) *Scheduler {
+	return &Scheduler{
+		Tick:         DefaultTick,
+		OverdueAfter: DefaultOverdueAfter,
+		source:       source,
+		notifier:     notifier,
+		now:          time.Now,
+		reported:     map[int]reported{},
+	}
+}
+
+// Start runs the scheduler in its own goroutine until Stop is called
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.loop(ctx, s.done)
+}
+
+// Stop ends the goroutine and waits for it
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	log.Println("Starting Reminder Scheduler...")
+
+	ticker := time.NewTicker(s.Tick)
+	defer ticker.Stop()
+	for {
+		s.Check()
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping Reminder Scheduler...")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check looks at the items once and sends the events that are due.  The
+// goroutine calls it every Tick.
+func (s *Scheduler) Check() {
+	items, err := s.source.GetAllItems()
+	if err != nil {
+		log.Println("Reminder scheduler could not read the items: ", err)
+		return
+	}
+	now := s.now()
+
+	s.mu.Lock()
+	var toSend []*events.ToDoEvent
+	seen := make(map[int]bool, len(items))
+	for _, item := range items {
+		if item.IsDone || item.Due == nil {
+			continue
+		}
+		seen[item.Id] = true
+
+		r := s.reported[item.Id]
+		if !r.due.Equal(*item.Due) {
+			r = reported{due: *item.Due}
+		}
+		switch {
+		case !r.overdueSent && !now.Before(item.Due.Add(s.OverdueAfter)):
+			r.dueSent, r.overdueSent = true, true
+			toSend = append(toSend, events.NewEvent(events.ToDoOverdueEvent, "todoItem", item))
+		case !r.dueSent && !now.Before(*item.Due):
+			r.dueSent = true
+			toSend = append(toSend, events.NewEvent(events.ToDoDueEvent, "todoItem", item))
+		}
+		s.reported[item.Id] = r
+	}
+
+	//forget items that were done, deleted or lost their due time
+	for id := range s.reported {
+		if !seen[id] {
+			delete(s.reported, id)
+		}
+	}
+	s.mu.Unlock()
+
+	//Notify blocks until the event loop takes the event, or the event
+	//manager stops, so do not hold the lock meanwhile
+	for _, event := range toSend {
+		s.notifier.Notify(event)
+	}
+}
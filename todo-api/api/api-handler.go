@@ -1,8 +1,10 @@
 package api
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"problem"
 	"strconv"
 	"time"
 
@@ -32,7 +34,7 @@ func (va *VoteAPI) ListAllVoters(c *gin.Context) {
 	if err != nil {
 		log.Println("Error Getting All Voters: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -50,14 +52,14 @@ func (va *VoteAPI) GetVoter(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	voter, err := va.voterList.GetVoter(uint(id64))
 	if err != nil {
 		log.Println("voter not found: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -71,7 +73,7 @@ func (va *VoteAPI) AddVoter(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -79,21 +81,21 @@ func (va *VoteAPI) AddVoter(c *gin.Context) {
 	if err := c.ShouldBindJSON(&voter); err != nil {
 		log.Println("Error binding JSON: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	if uint(id64) != voter.VoterID {
 		log.Println("URL parameter (id) does not match Request Body (voter.VoterID)")
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, fmt.Errorf("%w: url id %d does not match body id %d", voterList.ErrValidation, id64, voter.VoterID))
 		return
 	}
 
 	if err := va.voterList.AddVoter(voter); err != nil {
 		log.Println("Error adding voter: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -106,14 +108,14 @@ func (va *VoteAPI) UpdateVoter(c *gin.Context) {
 	if err := c.ShouldBindJSON(&v); err != nil {
 		log.Println("Error binding JSON: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	voter, err := va.voterList.UpdateVoter(v)
 	if err != nil {
 		log.Println("Error updating voter: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -127,14 +129,14 @@ func (va *VoteAPI) GetVoterHistory(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	voter, err := va.voterList.GetVoter(uint(id64))
 	if err != nil {
 		log.Println("voter not found: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -148,7 +150,7 @@ func (va *VoteAPI) GetVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	voterPollIdS := c.Param("pollid")
@@ -156,7 +158,7 @@ func (va *VoteAPI) GetVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
@@ -164,7 +166,7 @@ func (va *VoteAPI) GetVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("voter poll not found: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusNotFound)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -178,27 +180,27 @@ func (va *VoteAPI) AddVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	var v voterList.Voter
 	if err := c.ShouldBindJSON(&v); err != nil {
 		log.Println("Error binding JSON: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	if len(v.VoteHistory) != 1 {
 		log.Println("len(v.VoteHistory) != 1")
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, fmt.Errorf("%w: expected exactly one entry in history", voterList.ErrValidation))
 		return
 	}
 	vp := v.VoteHistory[0]
 	if err := va.voterList.AddVoterPoll(uint(voterId64), vp); err != nil {
 		log.Println("Error adding voter poll: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, vp)
@@ -211,27 +213,27 @@ func (va *VoteAPI) UpdateVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	var v voterList.Voter
 	if err := c.ShouldBindJSON(&v); err != nil {
 		log.Println("Error binding JSON: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	if len(v.VoteHistory) != 1 {
 		log.Println("len(v.VoteHistory) != 1")
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.Abort(c, fmt.Errorf("%w: expected exactly one entry in history", voterList.ErrValidation))
 		return
 	}
 	vp := v.VoteHistory[0]
 	if err := va.voterList.UpdateVoterPoll(uint(voterId64), vp); err != nil {
 		log.Println("Error updating voter poll: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, vp)
@@ -244,14 +246,14 @@ func (va *VoteAPI) DeleteVoter(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 
 	if err := va.voterList.DeleteVoter(uint(voterId64)); err != nil {
 		log.Println("Error deleting voter: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
@@ -265,7 +267,7 @@ func (va *VoteAPI) DeleteVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	voterPollIdS := c.Param("pollid")
@@ -273,13 +275,13 @@ func (va *VoteAPI) DeleteVoterPoll(c *gin.Context) {
 	if err != nil {
 		log.Println("Error converting id to int64: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusBadRequest)
+		problem.AbortBadRequest(c, err)
 		return
 	}
 	if err := va.voterList.DeleteVoterPoll(uint(voterId64), uint(voterPollId64)); err != nil {
 		log.Println("Error deleting voter poll: ", err)
 		va.badRequests++
-		c.AbortWithStatus(http.StatusInternalServerError)
+		problem.Abort(c, err)
 		return
 	}
 
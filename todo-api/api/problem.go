@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"problem"
+
+	"drexel.edu/todo-api/voterList"
+	"github.com/gin-gonic/gin"
+)
+
+// problems maps the errors of the voterList package to HTTP status codes
+var problems = problem.Options{
+	Statuses: []problem.Status{
+		{Err: voterList.ErrNotFound, Code: http.StatusNotFound},
+		{Err: voterList.ErrConflict, Code: http.StatusConflict},
+		{Err: voterList.ErrValidation, Code: http.StatusUnprocessableEntity},
+	},
+}
+
+// ProblemMiddleware renders the errors the handlers record with
+// problem.Abort as problem+json, see the problem package
+func ProblemMiddleware() gin.HandlerFunc {
+	return problem.Middleware(problems)
+}
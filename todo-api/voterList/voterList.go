@@ -7,6 +7,15 @@ import (
 	"time"
 )
 
+// These are the errors the voter list reports back to its callers.  They
+// are wrapped with more details, so check for them with errors.Is(), the
+// api package uses them to pick the right HTTP status code
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrValidation = errors.New("validation failed")
+)
+
 type voterPoll struct {
 	PollID   uint      `json:"id"`
 	VoteDate time.Time `json:"date"`
@@ -33,7 +42,7 @@ func NewVoterList() (*VoterList, error) {
 func (v *VoterList) AddVoter(voter Voter) error {
 	_, ok := v.Voters[voter.VoterID]
 	if ok {
-		return errors.New("voter already exists")
+		return fmt.Errorf("%w: voter %d already exists", ErrConflict, voter.VoterID)
 	}
 	v.Voters[voter.VoterID] = voter
 	return nil
@@ -42,7 +51,7 @@ func (v *VoterList) AddVoter(voter Voter) error {
 func (v *VoterList) UpdateVoter(voter Voter) (Voter, error) {
 	vt, ok := v.Voters[voter.VoterID]
 	if !ok {
-		return Voter{}, errors.New("voter does not exist")
+		return Voter{}, fmt.Errorf("%w: voter does not exist", ErrNotFound)
 	}
 	voter.VoteHistory = vt.VoteHistory // only modify resource data
 	v.Voters[voter.VoterID] = voter
@@ -52,7 +61,7 @@ func (v *VoterList) UpdateVoter(voter Voter) (Voter, error) {
 func (v *VoterList) DeleteVoter(id uint) error {
 	_, ok := v.Voters[id]
 	if !ok {
-		return errors.New("voter does not exist")
+		return fmt.Errorf("%w: voter does not exist", ErrNotFound)
 	}
 	delete(v.Voters, id)
 	return nil
@@ -66,7 +75,7 @@ func (v *VoterList) Clear() error {
 func (v *VoterList) GetVoter(id uint) (Voter, error) {
 	voter, ok := v.Voters[id]
 	if !ok {
-		return Voter{}, errors.New("voter does not exist")
+		return Voter{}, fmt.Errorf("%w: voter does not exist", ErrNotFound)
 	}
 	return voter, nil
 }
@@ -74,24 +83,24 @@ func (v *VoterList) GetVoter(id uint) (Voter, error) {
 func (v *VoterList) GetVoterPoll(voterId uint, pollid uint) (voterPoll, error) {
 	voter, ok := v.Voters[voterId]
 	if !ok {
-		return voterPoll{}, errors.New("voter does not exist")
+		return voterPoll{}, fmt.Errorf("%w: voter does not exist", ErrNotFound)
 	}
 	for _, vp := range voter.VoteHistory {
 		if vp.PollID == pollid {
 			return vp, nil
 		}
 	}
-	return voterPoll{}, errors.New("voter poll does not exist")
+	return voterPoll{}, fmt.Errorf("%w: voter poll does not exist", ErrNotFound)
 }
 
 func (v *VoterList) AddVoterPoll(voterId uint, newvp voterPoll) error {
 	voter, ok := v.Voters[voterId]
 	if !ok {
-		return errors.New("voter does not exist")
+		return fmt.Errorf("%w: voter does not exist", ErrNotFound)
 	}
 	for _, vp := range voter.VoteHistory {
 		if newvp.PollID == vp.PollID {
-			return errors.New("voter poll already exists")
+			return fmt.Errorf("%w: voter poll %d already exists", ErrConflict, newvp.PollID)
 		}
 	}
 	voter.VoteHistory = append(voter.VoteHistory, newvp)
@@ -102,7 +111,7 @@ func (v *VoterList) AddVoterPoll(voterId uint, newvp voterPoll) error {
 func (v *VoterList) UpdateVoterPoll(voterId uint, newvp voterPoll) error {
 	voter, ok := v.Voters[voterId]
 	if !ok {
-		return errors.New("voter does not exist")
+		return fmt.Errorf("%w: voter does not exist", ErrNotFound)
 	}
 	for i, vp := range voter.VoteHistory {
 		if newvp.PollID == vp.PollID {
@@ -111,13 +120,13 @@ func (v *VoterList) UpdateVoterPoll(voterId uint, newvp voterPoll) error {
 			return nil
 		}
 	}
-	return errors.New("voter poll does not exist")
+	return fmt.Errorf("%w: voter poll does not exist", ErrNotFound)
 }
 
 func (v *VoterList) DeleteVoterPoll(voterId uint, pollid uint) error {
 	voter, ok := v.Voters[voterId]
 	if !ok {
-		return errors.New("voter does not exist")
+		return fmt.Errorf("%w: voter does not exist", ErrNotFound)
 	}
 	for i, vp := range voter.VoteHistory {
 		if vp.PollID == pollid {
@@ -126,7 +135,7 @@ func (v *VoterList) DeleteVoterPoll(voterId uint, pollid uint) error {
 			return nil
 		}
 	}
-	return errors.New("voter poll does not exist")
+	return fmt.Errorf("%w: voter poll does not exist", ErrNotFound)
 }
 
 func (v *VoterList) GetAllVoters() ([]Voter, error) {
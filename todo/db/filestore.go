@@ -0,0 +1,238 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupsKept is how many backups of a database file are kept.  Every time
+// the journal is compacted the current file is first copied into the
+// backups directory next to it, and the oldest backups beyond this number
+// are removed.
+const BackupsKept = 5
+
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// ErrNoBackup is returned by Restore when there is no backup to restore
+var ErrNoBackup = errors.New("no backup found")
+
+// Backups returns the names of the backups of the database, newest first.
+// Any of them can be handed to Restore.
+func (t *ToDo) Backups() ([]string, error) {
+	paths, err := t.backupPaths()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[len(paths)-1-i] = filepath.Base(p)
+	}
+	return names, nil
+}
+
+// Restore replaces the database with one of its backups, the newest one if
+// name is empty.  The database that is replaced is backed up first, so a
+// restore can be undone by restoring again.
+func (t *ToDo) Restore(name string) error {
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	paths, err := t.backupPaths()
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return ErrNoBackup
+	}
+
+	backup := paths[len(paths)-1]
+	if name != "" {
+		backup = filepath.Join(t.backupDir(), filepath.Base(name))
+	}
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrNoBackup, name)
+		}
+		return err
+	}
+
+	//never restore something we could not load afterwards
+	var toDoList []ToDoItem
+	if err := json.Unmarshal(data, &toDoList); err != nil {
+		return fmt.Errorf("backup %s is not a valid database: %w", filepath.Base(backup), err)
+	}
+
+	//back up the database we replace once, with the changes in the journal
+	//folded in.  A database we can not load is backed up as it is.
+	if err := t.loadDB(); err == nil {
+		current, err := t.marshalDB()
+		if err != nil {
+			return err
+		}
+		err = t.writeBackup(current)
+	} else {
+		err = t.backupDB()
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(t.dbFileName, data, 0644); err != nil {
+		return err
+	}
+	return t.clearJournal()
+}
+
+// replaceDB backs up the database file and then replaces it with data.
+// It must be called with the lock held.
+func (t *ToDo) replaceDB(data []byte) error {
+	if err := t.backupDB(); err != nil {
+		return err
+	}
+	return writeFileAtomic(t.dbFileName, data, 0644)
+}
+
+// backupDB copies the current database file into the backups directory
+// and drops the oldest backups
+func (t *ToDo) backupDB() error {
+	data, err := os.ReadFile(t.dbFileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return t.writeBackup(data)
+}
+
+// writeBackup writes data as the newest backup and drops the oldest
+// backups
+func (t *ToDo) writeBackup(data []byte) error {
+	if err := os.MkdirAll(t.backupDir(), 0755); err != nil {
+		return err
+	}
+	name := filepath.Base(t.dbFileName) + "." + time.Now().UTC().Format(backupTimeFormat) + ".bak"
+	if err := writeFileAtomic(filepath.Join(t.backupDir(), name), data, 0644); err != nil {
+		return err
+	}
+
+	paths, err := t.backupPaths()
+	if err != nil {
+		return err
+	}
+	for len(paths) > BackupsKept {
+		if err := os.Remove(paths[0]); err != nil {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
+
+func (t *ToDo) backupDir() string {
+	return filepath.Join(filepath.Dir(t.dbFileName), "backups")
+}
+
+// backupPaths returns the backups of the database, oldest first.  The
+// timestamp in the names sorts in time order.
+func (t *ToDo) backupPaths() ([]string, error) {
+	prefix := filepath.Base(t.dbFileName) + "."
+	entries, err := os.ReadDir(t.backupDir())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var paths []string
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok || e.IsDir() || !strings.HasSuffix(stamp, ".bak") {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ".bak")); err != nil {
+			continue
+		}
+		paths = append(paths, filepath.Join(t.backupDir(), e.Name()))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// lock takes the advisory lock of the database, so two todo processes can
+// not load, change and save the database at the same time and lose each
+// other's changes.  Loads take it too, so they never see a snapshot and a
+// journal that do not belong together.  The lock is on a separate .lock
+// file, the database file itself is replaced on every compaction.  lock is
+// reentrant, which lets ChangeItemDoneStatus hold it around GetItem and
+// UpdateItem.
+func (t *ToDo) lock() (func(), error) {
+	if t.locks == 0 {
+		f, err := os.OpenFile(t.dbFileName+".lock", os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("could not lock %s: %w", t.dbFileName, err)
+		}
+		t.lockFile = f
+	}
+	t.locks++
+	return t.unlock, nil
+}
+
+func (t *ToDo) unlock() {
+	t.locks--
+	if t.locks == 0 {
+		unlockFile(t.lockFile)
+		t.lockFile.Close()
+		t.lockFile = nil
+	}
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory,
+// flushes it to disk and renames it over name.  A crash leaves either the
+// old or the new file behind, never a half written one.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(name)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	defer os.Remove(tmpName) // fails harmlessly once the rename is done
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, name); err != nil {
+		return err
+	}
+	//the rename itself is only durable once the directory is flushed
+	return syncDir(dir)
+}
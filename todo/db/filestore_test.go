@@ -0,0 +1,134 @@
+package db
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// backupIds returns the ids in the backup called name
+func backupIds(t *testing.T, todo *ToDo, name string) []int {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(todo.backupDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var items []ToDoItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		t.Fatal(err)
+	}
+	m := map[int]ToDoItem{}
+	for _, item := range items {
+		m[item.Id] = item
+	}
+	return ids(m)
+}
+
+func TestRestore(t *testing.T) {
+	todo := newTestDB(t, ToDoItem{Id: 1, Title: "one"}, ToDoItem{Id: 2, Title: "two"})
+	if err := todo.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := todo.AddItem(ToDoItem{Id: 3, Title: "three"}); err != nil {
+		t.Fatal(err)
+	}
+	before, err := todo.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := todo.Restore(""); err != nil {
+		t.Fatal(err)
+	}
+	//the newest backup is the empty database the compaction replaced
+	if got := ids(reopened(t, todo)); len(got) != 0 {
+		t.Errorf("got the items %v, want none", got)
+	}
+	if lines := journalLines(t, todo); lines != nil {
+		t.Errorf("the journal still has %d records", len(lines))
+	}
+
+	//one backup of what was replaced, with the change in the journal
+	after, err := todo.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before)+1 {
+		t.Fatalf("got the backups %v after %v, want one more", after, before)
+	}
+	if got := backupIds(t, todo, after[0]); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("the backup has %v, want [1 2 3]", got)
+	}
+
+	//restoring again undoes the restore
+	if err := todo.Restore(after[0]); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(reopened(t, todo)); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("got the items %v after the undo, want [1 2 3]", got)
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "todo.json")
+	if err := writeFileAtomic(name, []byte("[]"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(name, []byte(`[{"id":1}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(name)
+	if err != nil || string(data) != `[{"id":1}]` {
+		t.Fatalf("got %q, %v", data, err)
+	}
+	if info, err := os.Stat(name); err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("got %v, %v", info.Mode(), err)
+	}
+
+	//a write that can not be renamed into place fails without a trace, a
+	//directory with files in it can not be replaced
+	taken := filepath.Join(dir, "taken")
+	if err := os.MkdirAll(filepath.Join(taken, "inside"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(taken, []byte("[]"), 0644); err == nil {
+		t.Fatal("replaced a directory")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("left %d files behind, want 2", len(entries))
+	}
+}
+
+// TestFailedCompaction has a compaction that can not write its backup, the
+// database file and the journal it would replace stay as they were
+func TestFailedCompaction(t *testing.T) {
+	todo := newTestDB(t, ToDoItem{Id: 1, Title: "one"}, ToDoItem{Id: 2, Title: "two"})
+	before, err := os.ReadFile(todo.dbFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(todo.backupDir(), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := todo.Compact(); err == nil {
+		t.Fatal("compacted without a backup")
+	}
+	after, err := os.ReadFile(todo.dbFileName)
+	if err != nil || string(after) != string(before) {
+		t.Errorf("got the database %q, %v, want %q", after, err, before)
+	}
+	if got := len(journalLines(t, todo)); got != 2 {
+		t.Errorf("got %d records, want 2", got)
+	}
+	if got := ids(reopened(t, todo)); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("got the items %v, want [1 2]", got)
+	}
+}
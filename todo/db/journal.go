@@ -0,0 +1,181 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CompactAfter is how many changes the journal collects before they are
+// compacted into the database file
+const CompactAfter = 100
+
+// The database is kept in two files.  todo.json is a snapshot of all the
+// items, todo.json.journal lists the changes made since the snapshot was
+// written, one json record per line.  A change only appends one line to
+// the journal instead of rewriting every item, and every CompactAfter
+// changes the journal is folded into a new snapshot and emptied.
+//
+// Replaying a record twice has the same effect as replaying it once, so
+// a crash between writing the snapshot and emptying the journal is
+// harmless.  The changes of one operation, eg a delete that cascades to
+// the subtasks, are written as one batch record, on one line, so they are
+// replayed all or not at all.
+
+type journalOp string
+
+const (
+	opAdd    journalOp = "add"
+	opUpdate journalOp = "update"
+	opDelete journalOp = "delete"
+	opBatch  journalOp = "batch"
+)
+
+type journalRecord struct {
+	Op      journalOp       `json:"op"`
+	Id      int             `json:"id"`
+	Item    *ToDoItem       `json:"item,omitempty"`
+	Changes []journalRecord `json:"changes,omitempty"`
+}
+
+func (t *ToDo) journalFileName() string {
+	return t.dbFileName + ".journal"
+}
+
+// logChange appends the changes of one operation to the journal and
+// flushes them to disk, they are durable once it returns.  Several
+// changes become one batch record.  It must be called with the lock held,
+// after the changes were applied to the map.
+func (t *ToDo) logChange(changes ...journalRecord) error {
+	rec := changes[0]
+	if len(changes) > 1 {
+		rec = journalRecord{Op: opBatch, Changes: changes}
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	_, statErr := os.Stat(t.journalFileName())
+	f, err := os.OpenFile(t.journalFileName(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if statErr != nil {
+		//we just created the journal, make its directory entry durable too
+		if err := syncDir(filepath.Dir(t.journalFileName())); err != nil {
+			return err
+		}
+	}
+
+	t.journalLen += len(changes)
+	if t.journalLen >= CompactAfter {
+		return t.saveDB()
+	}
+	return nil
+}
+
+// Compact folds the journal into the database file and empties it.  It
+// happens on its own every CompactAfter changes.
+func (t *ToDo) Compact() error {
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err := t.loadDB(); err != nil {
+		return err
+	}
+	return t.saveDB()
+}
+
+// replayJournal applies the journal to the map that was loaded from the
+// snapshot.  A crash in the middle of logChange can leave a torn last
+// line, which is cut off, so the next change is not appended to it.
+func (t *ToDo) replayJournal() error {
+	data, err := os.ReadFile(t.journalFileName())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			t.journalLen = 0
+			return nil
+		}
+		return err
+	}
+
+	t.journalLen = 0
+	r := bufio.NewReader(bytes.NewReader(data))
+	good := 0 // length of the journal up to the last complete record
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				return os.Truncate(t.journalFileName(), int64(good))
+			}
+			return nil
+		}
+
+		var rec journalRecord
+		if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil {
+			return fmt.Errorf("%s is corrupt at byte %d, see 'todo backups' and 'todo restore': %w", t.journalFileName(), good, jsonErr)
+		}
+		if err := t.applyRecord(rec); err != nil {
+			return fmt.Errorf("%s is corrupt at byte %d: %w", t.journalFileName(), good, err)
+		}
+		good += len(line)
+		t.journalLen++
+		if rec.Op == opBatch {
+			t.journalLen += len(rec.Changes) - 1
+		}
+	}
+}
+
+func (t *ToDo) applyRecord(rec journalRecord) error {
+	switch rec.Op {
+	case opAdd, opUpdate:
+		if rec.Item == nil || rec.Item.Id != rec.Id {
+			return fmt.Errorf("%s record for id %d has no matching item", rec.Op, rec.Id)
+		}
+		t.toDoMap[rec.Id] = *rec.Item
+	case opDelete:
+		delete(t.toDoMap, rec.Id)
+	case opBatch:
+		for _, change := range rec.Changes {
+			if change.Op == opBatch {
+				return fmt.Errorf("batch record holds another batch")
+			}
+			if err := t.applyRecord(change); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown journal operation %q", rec.Op)
+	}
+	return nil
+}
+
+// clearJournal empties the journal once its changes are in the snapshot
+func (t *ToDo) clearJournal() error {
+	err := os.Remove(t.journalFileName())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	t.journalLen = 0
+	return nil
+}
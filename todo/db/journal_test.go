@@ -0,0 +1,268 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestDB returns a ToDo on a new database file in a temporary
+// directory, with items added
+func newTestDB(t *testing.T, items ...ToDoItem) *ToDo {
+	t.Helper()
+	todo, err := New(filepath.Join(t.TempDir(), "todo.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range items {
+		if err := todo.AddItem(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return todo
+}
+
+// reopened reads the database of todo again from its files, the way the
+// next todo command does
+func reopened(t *testing.T, todo *ToDo) map[int]ToDoItem {
+	t.Helper()
+	again, err := New(todo.dbFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := again.loadDB(); err != nil {
+		t.Fatal(err)
+	}
+	return again.toDoMap
+}
+
+func journalLines(t *testing.T, todo *ToDo) []string {
+	t.Helper()
+	data, err := os.ReadFile(todo.journalFileName())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func ids(items map[int]ToDoItem) []int {
+	ids := []int{}
+	for id := range items {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func TestDeleteItemJournal(t *testing.T) {
+	tests := []struct {
+		name    string
+		cascade Cascade
+		wantIds []int
+		check   func(t *testing.T, items map[int]ToDoItem)
+	}{
+		{
+			name:    "the subtasks are deleted",
+			cascade: CascadeDelete,
+			wantIds: []int{4},
+			check: func(t *testing.T, items map[int]ToDoItem) {
+				if len(items[4].BlockedBy) != 0 {
+					t.Errorf("4 is still blocked by %v", items[4].BlockedBy)
+				}
+			},
+		},
+		{
+			name:    "the subtasks move up",
+			cascade: CascadeOrphan,
+			wantIds: []int{2, 3, 4},
+			check: func(t *testing.T, items map[int]ToDoItem) {
+				if items[2].ParentId != 0 || items[3].ParentId != 2 {
+					t.Errorf("got the parents %d and %d", items[2].ParentId, items[3].ParentId)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := newTestDB(t,
+				ToDoItem{Id: 1, Title: "project"},
+				ToDoItem{Id: 2, Title: "design", ParentId: 1},
+				ToDoItem{Id: 3, Title: "sketch", ParentId: 2},
+				ToDoItem{Id: 4, Title: "ship", BlockedBy: []int{1}},
+			)
+			before := len(journalLines(t, todo))
+			if err := todo.DeleteItem(1, tt.cascade); err != nil {
+				t.Fatal(err)
+			}
+
+			//one record for the whole delete
+			if got := len(journalLines(t, todo)) - before; got != 1 {
+				t.Errorf("the delete wrote %d records, want 1", got)
+			}
+			items := reopened(t, todo)
+			if got := ids(items); !reflect.DeepEqual(got, tt.wantIds) {
+				t.Errorf("got the items %v, want %v", got, tt.wantIds)
+			}
+			tt.check(t, items)
+		})
+	}
+}
+
+func TestReplayJournal(t *testing.T) {
+	const (
+		add    = `{"op":"add","id":2,"item":{"id":2,"title":"two"}}` + "\n"
+		update = `{"op":"update","id":1,"item":{"id":1,"title":"one changed"}}` + "\n"
+		remove = `{"op":"delete","id":1}` + "\n"
+		batch  = `{"op":"batch","id":0,"changes":[{"op":"update","id":2,"item":{"id":2,"title":"two moved"}},{"op":"delete","id":1}]}` + "\n"
+	)
+	tests := []struct {
+		name        string
+		journal     string
+		wantTitles  map[int]string
+		wantJournal string // the journal after the replay
+		wantErr     bool
+	}{
+		{
+			name:        "an empty journal",
+			wantTitles:  map[int]string{1: "one"},
+			wantJournal: "",
+		},
+		{
+			name:        "the changes in order",
+			journal:     add + update,
+			wantTitles:  map[int]string{1: "one changed", 2: "two"},
+			wantJournal: add + update,
+		},
+		{
+			name:        "a delete",
+			journal:     add + remove,
+			wantTitles:  map[int]string{2: "two"},
+			wantJournal: add + remove,
+		},
+		{
+			name:        "a batch",
+			journal:     add + batch,
+			wantTitles:  map[int]string{2: "two moved"},
+			wantJournal: add + batch,
+		},
+		{
+			name:        "a replayed journal changes nothing",
+			journal:     add + batch + add + batch,
+			wantTitles:  map[int]string{2: "two moved"},
+			wantJournal: add + batch + add + batch,
+		},
+		{
+			name:        "a torn record is cut off",
+			journal:     add + update[:20],
+			wantTitles:  map[int]string{1: "one", 2: "two"},
+			wantJournal: add,
+		},
+		{
+			name:        "a torn batch is cut off as a whole",
+			journal:     add + batch[:len(batch)-10],
+			wantTitles:  map[int]string{1: "one", 2: "two"},
+			wantJournal: add,
+		},
+		{
+			name:    "a corrupt record",
+			journal: update[:20] + "\n" + add,
+			wantErr: true,
+		},
+		{
+			name:    "an unknown operation",
+			journal: `{"op":"rename","id":1}` + "\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			todo := newTestDB(t, ToDoItem{Id: 1, Title: "one"})
+			if err := todo.Compact(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.journal != "" {
+				if err := os.WriteFile(todo.journalFileName(), []byte(tt.journal), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			err := todo.loadDB()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("the corrupt journal was replayed")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			titles := map[int]string{}
+			for id, item := range todo.toDoMap {
+				titles[id] = item.Title
+			}
+			if !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("got %v, want %v", titles, tt.wantTitles)
+			}
+			data, _ := os.ReadFile(todo.journalFileName())
+			if string(data) != tt.wantJournal {
+				t.Errorf("got the journal %q, want %q", data, tt.wantJournal)
+			}
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	todo := newTestDB(t)
+	for id := 1; id < CompactAfter; id++ {
+		if err := todo.AddItem(ToDoItem{Id: id, Title: "item"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := len(journalLines(t, todo)); got != CompactAfter-1 {
+		t.Fatalf("got %d records, want %d", got, CompactAfter-1)
+	}
+
+	//the change that fills the journal folds it into the database file
+	if err := todo.AddItem(ToDoItem{Id: CompactAfter, Title: "item"}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := journalLines(t, todo); lines != nil {
+		t.Errorf("the journal still has %d records", len(lines))
+	}
+	if got := len(reopened(t, todo)); got != CompactAfter {
+		t.Errorf("got %d items, want %d", got, CompactAfter)
+	}
+	backups, err := todo.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("got the backups %v, want 1", backups)
+	}
+
+	//a batch counts with all its changes
+	if err := todo.AddItem(ToDoItem{Id: CompactAfter + 1, Title: "parent"}); err != nil {
+		t.Fatal(err)
+	}
+	for id := 1; id < CompactAfter; id++ {
+		if err := todo.UpdateItem(ToDoItem{Id: id, Title: "item", ParentId: CompactAfter + 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := todo.DeleteItem(CompactAfter+1, CascadeDelete); err != nil {
+		t.Fatal(err)
+	}
+	if lines := journalLines(t, todo); lines != nil {
+		t.Errorf("the journal still has %d records after the cascade", len(lines))
+	}
+	if got := ids(reopened(t, todo)); !reflect.DeepEqual(got, []int{CompactAfter}) {
+		t.Errorf("got the items %v, want [%d]", got, CompactAfter)
+	}
+}
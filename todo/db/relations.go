@@ -0,0 +1,215 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// An item can be a subtask of another item, its ParentId, and it can be
+// blocked by other items, its BlockedBy.  Neither may form a cycle, and
+// an item can only be done once every item blocking it is done.  These
+// rules are checked by checkRelations whenever an item is written.
+
+// Progress is how many of the subtasks of an item are done.  It is worked
+// out when an item is read, and never stored.
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Cascade says what DeleteItem does with the subtasks of the item
+type Cascade string
+
+const (
+	// CascadeRestrict refuses to delete an item that has subtasks
+	CascadeRestrict Cascade = "restrict"
+	// CascadeOrphan moves the subtasks up to the parent of the item
+	CascadeOrphan Cascade = "orphan"
+	// CascadeDelete deletes the subtasks, and theirs, with the item
+	CascadeDelete Cascade = "delete"
+)
+
+// ParseCascade parses restrict, orphan or delete, an empty string is
+// CascadeRestrict
+func ParseCascade(s string) (Cascade, error) {
+	switch c := Cascade(strings.ToLower(s)); c {
+	case "":
+		return CascadeRestrict, nil
+	case CascadeRestrict, CascadeOrphan, CascadeDelete:
+		return c, nil
+	}
+	return "", fmt.Errorf("%w: cascade must be restrict, orphan or delete, not %q", ErrValidation, s)
+}
+
+// checkRelations checks the parent and the blockers of item, which is
+// about to be written next to items.  An older version of item in items
+// is ignored.
+func checkRelations(item ToDoItem, items map[int]ToDoItem) error {
+	get := func(id int) (ToDoItem, bool) {
+		if id == item.Id {
+			return item, true
+		}
+		other, ok := items[id]
+		return other, ok
+	}
+
+	if item.ParentId != 0 {
+		if item.ParentId == item.Id {
+			return fmt.Errorf("%w: item %d can not be its own parent", ErrValidation, item.Id)
+		}
+		if _, ok := get(item.ParentId); !ok {
+			return fmt.Errorf("%w: parent %d does not exist", ErrValidation, item.ParentId)
+		}
+		//walk up to the top, we must not come across item on the way
+		seen := map[int]bool{}
+		for id := item.ParentId; id != 0 && !seen[id]; {
+			if id == item.Id {
+				return fmt.Errorf("%w: item %d can not be a subtask of its own subtask %d", ErrValidation, item.Id, item.ParentId)
+			}
+			seen[id] = true
+			parent, _ := get(id)
+			id = parent.ParentId
+		}
+	}
+
+	var open []int
+	for _, id := range item.BlockedBy {
+		if id == item.Id {
+			return fmt.Errorf("%w: item %d can not block itself", ErrValidation, item.Id)
+		}
+		blocker, ok := get(id)
+		if !ok {
+			return fmt.Errorf("%w: blocker %d does not exist", ErrValidation, id)
+		}
+		if waitsFor(id, item.Id, get) {
+			return fmt.Errorf("%w: item %d can not be blocked by %d, which already waits for it", ErrValidation, item.Id, id)
+		}
+		if !blocker.IsDone {
+			open = append(open, id)
+		}
+	}
+
+	if item.IsDone && len(open) > 0 {
+		return fmt.Errorf("%w: item %d can not be done, it is blocked by the open items %v", ErrConflict, item.Id, open)
+	}
+	return nil
+}
+
+// waitsFor tells if from is blocked by target, directly or through other
+// items
+func waitsFor(from int, target int, get func(int) (ToDoItem, bool)) bool {
+	seen := map[int]bool{}
+	todo := []int{from}
+	for len(todo) > 0 {
+		id := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		item, _ := get(id)
+		for _, blocker := range item.BlockedBy {
+			if blocker == target {
+				return true
+			}
+			todo = append(todo, blocker)
+		}
+	}
+	return false
+}
+
+// subtasks returns the items whose parent is id, by id
+func subtasks(items map[int]ToDoItem, id int) []ToDoItem {
+	children := []ToDoItem{}
+	for _, item := range items {
+		if item.ParentId == id {
+			children = append(children, item)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].Id < children[j].Id })
+	return children
+}
+
+// withProgress returns item with the progress of its subtasks, if it has
+// any
+func withProgress(item ToDoItem, items map[int]ToDoItem) ToDoItem {
+	item.Progress = nil
+	for _, other := range items {
+		if other.ParentId != item.Id {
+			continue
+		}
+		if item.Progress == nil {
+			item.Progress = &Progress{}
+		}
+		item.Progress.Total++
+		if other.IsDone {
+			item.Progress.Done++
+		}
+	}
+	return item
+}
+
+// deletePlan works out what deleting id with cascade does to items: the
+// ids to delete, and the items that have to be rewritten because their
+// parent or one of their blockers goes away
+func deletePlan(items map[int]ToDoItem, id int, cascade Cascade) (deleted []int, changed []ToDoItem, err error) {
+	item, ok := items[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: attempted to delete non-existent item %d", ErrNotFound, id)
+	}
+
+	children := subtasks(items, id)
+	gone := map[int]bool{id: true}
+	deleted = []int{id}
+	moved := map[int]bool{}
+	switch {
+	case len(children) == 0:
+	case cascade == CascadeDelete:
+		for i := 0; i < len(deleted); i++ {
+			for _, child := range subtasks(items, deleted[i]) {
+				if !gone[child.Id] {
+					gone[child.Id] = true
+					deleted = append(deleted, child.Id)
+				}
+			}
+		}
+	case cascade == CascadeOrphan:
+		for _, child := range children {
+			moved[child.Id] = true
+		}
+	default:
+		subtasks := "1 subtask"
+		if len(children) > 1 {
+			subtasks = fmt.Sprintf("%d subtasks", len(children))
+		}
+		return nil, nil, fmt.Errorf("%w: item %d has %s, delete them with it (cascade delete) or keep them (cascade orphan)", ErrConflict, id, subtasks)
+	}
+
+	for _, other := range items {
+		if gone[other.Id] {
+			continue
+		}
+		rewrite := false
+		if moved[other.Id] {
+			other.ParentId = item.ParentId
+			rewrite = true
+		}
+		//a deleted item can not be done anymore, so it stops blocking
+		var blockers []int
+		for _, blocker := range other.BlockedBy {
+			if gone[blocker] {
+				rewrite = true
+			} else {
+				blockers = append(blockers, blocker)
+			}
+		}
+		if rewrite {
+			other.BlockedBy = blockers
+			changed = append(changed, other)
+		}
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Id < changed[j].Id })
+	return deleted, changed, nil
+}
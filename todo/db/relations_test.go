@@ -0,0 +1,114 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// project is a small plan: 1 has the subtasks 2 and 3, 3 can only start
+// once 2 is done, 4 waits for 3, and 5 is done
+func project() map[int]ToDoItem {
+	items := map[int]ToDoItem{}
+	for _, item := range []ToDoItem{
+		{Id: 1, Title: "project"},
+		{Id: 2, Title: "design", ParentId: 1},
+		{Id: 3, Title: "build", ParentId: 1, BlockedBy: []int{2}},
+		{Id: 4, Title: "ship", BlockedBy: []int{3}},
+		{Id: 5, Title: "budget", IsDone: true},
+	} {
+		items[item.Id] = item
+	}
+	return items
+}
+
+func TestCheckRelations(t *testing.T) {
+	tests := []struct {
+		name    string
+		item    ToDoItem
+		wantErr error
+	}{
+		{"a new subtask", ToDoItem{Id: 6, ParentId: 1, BlockedBy: []int{2, 5}}, nil},
+		{"no relations", ToDoItem{Id: 6}, nil},
+		{"its own parent", ToDoItem{Id: 6, ParentId: 6}, ErrValidation},
+		{"a missing parent", ToDoItem{Id: 6, ParentId: 9}, ErrValidation},
+		{"a subtask of its subtask", ToDoItem{Id: 1, ParentId: 2}, ErrValidation},
+		{"moved under another item", ToDoItem{Id: 3, ParentId: 4, BlockedBy: []int{2}}, nil},
+		{"blocking itself", ToDoItem{Id: 6, BlockedBy: []int{6}}, ErrValidation},
+		{"a missing blocker", ToDoItem{Id: 6, BlockedBy: []int{9}}, ErrValidation},
+		{"blocked by what waits for it", ToDoItem{Id: 2, ParentId: 1, BlockedBy: []int{3}}, ErrValidation},
+		{"blocked by what waits for it through another item", ToDoItem{Id: 2, ParentId: 1, BlockedBy: []int{4}}, ErrValidation},
+		{"its old blockers are ignored", ToDoItem{Id: 3, ParentId: 1}, nil},
+		{"done while blocked by an open item", ToDoItem{Id: 3, ParentId: 1, BlockedBy: []int{2}, IsDone: true}, ErrConflict},
+		{"done once its blockers are done", ToDoItem{Id: 6, BlockedBy: []int{5}, IsDone: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkRelations(tt.item, project())
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDeletePlan(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          int
+		cascade     Cascade
+		wantDeleted []int
+		wantChanged []string
+		wantErr     error
+	}{
+		{name: "a missing item", id: 9, cascade: CascadeDelete, wantErr: ErrNotFound},
+		{name: "an item without subtasks", id: 4, cascade: CascadeRestrict, wantDeleted: []int{4}},
+		{name: "restrict keeps an item with subtasks", id: 1, cascade: CascadeRestrict, wantErr: ErrConflict},
+		{
+			name: "delete takes the subtasks along", id: 1, cascade: CascadeDelete,
+			wantDeleted: []int{1, 2, 3},
+			wantChanged: []string{"4 parent=0 blockedBy=[]"},
+		},
+		{
+			name: "orphan moves the subtasks up", id: 1, cascade: CascadeOrphan,
+			wantDeleted: []int{1},
+			wantChanged: []string{"2 parent=0 blockedBy=[]", "3 parent=0 blockedBy=[2]"},
+		},
+		{
+			name: "a deleted item stops blocking", id: 2, cascade: CascadeRestrict,
+			wantDeleted: []int{2},
+			wantChanged: []string{"3 parent=1 blockedBy=[]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted, changed, err := deletePlan(project(), tt.id, tt.cascade)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(deleted, tt.wantDeleted) {
+				t.Errorf("got deleted %v, want %v", deleted, tt.wantDeleted)
+			}
+			var got []string
+			for _, item := range changed {
+				got = append(got, fmt.Sprintf("%d parent=%d blockedBy=%v", item.Id, item.ParentId, item.BlockedBy))
+			}
+			if !reflect.DeepEqual(got, tt.wantChanged) {
+				t.Errorf("got changed %q, want %q", got, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestWithProgress(t *testing.T) {
+	items := project()
+	items[2] = ToDoItem{Id: 2, Title: "design", ParentId: 1, IsDone: true}
+
+	if got := withProgress(items[1], items).Progress; got == nil || *got != (Progress{Done: 1, Total: 2}) {
+		t.Errorf("project: got progress %+v, want 1 of 2 done", got)
+	}
+	if got := withProgress(items[4], items).Progress; got != nil {
+		t.Errorf("ship: got progress %+v, want none", got)
+	}
+}
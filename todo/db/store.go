@@ -0,0 +1,17 @@
+package db
+
+// Store is what the todo CLI needs from a database.  ToDo keeps the items
+// in a local file, remote.Client keeps them in the todo API.
+type Store interface {
+	AddItem(item ToDoItem) error
+	DeleteItem(id int, cascade Cascade) error
+	UpdateItem(item ToDoItem) error
+	GetItem(id int) (ToDoItem, error)
+	GetAllItems() ([]ToDoItem, error)
+	GetSubtasks(id int) ([]ToDoItem, error)
+	ChangeItemDoneStatus(id int, value bool) error
+	PrintItem(item ToDoItem)
+	JsonToItem(jsonString string) (ToDoItem, error)
+}
+
+var _ Store = (*ToDo)(nil)
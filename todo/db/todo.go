@@ -5,15 +5,42 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 )
 
-// ToDoItem is the struct that represents a single ToDo item
+// ToDoItem is the struct that represents a single ToDo item.  Version
+// and UpdatedAt are set by the store on every add and update, whatever
+// the caller puts in them, "todo sync" uses them to tell which items
+// changed.  ParentId makes the item a subtask, and BlockedBy lists the
+// items that must be done first, see relations.go.  Progress is filled
+// in when the item is read and has subtasks.
 type ToDoItem struct {
-	Id     int    `json:"id"`
-	Title  string `json:"title"`
-	IsDone bool   `json:"done"`
+	Id        int       `json:"id"`
+	Title     string    `json:"title" binding:"notblank"`
+	IsDone    bool      `json:"done"`
+	ParentId  int       `json:"parentId,omitempty" binding:"gte=0"`
+	BlockedBy []int     `json:"blockedBy,omitempty" binding:"dive,gt=0"`
+	Progress  *Progress `json:"progress,omitempty"`
+	Version   int       `json:"version,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// SameContent tells if both items have the same title and status,
+// whatever their ids and versions
+func (item ToDoItem) SameContent(other ToDoItem) bool {
+	return item.Title == other.Title && item.IsDone == other.IsDone
+}
+
+// ErrNotFound is returned when an item does not exist.  ErrConflict is
+// returned when its id is already taken, when it can not be done because
+// of its open blockers, or can not be deleted because of its subtasks; the
+// todo API answers 409 for all of these.  Every Store returns them,
+// possibly wrapped with more details, so check for them with errors.Is
+var (
+	ErrNotFound = errors.New("id not existed")
+	ErrConflict = errors.New("conflict")
+)
+
 // DbMap is a type alias for a map of ToDoItems.  The key
 // will be the ToDoItem.Id and the value will be the ToDoItem
 type DbMap map[int]ToDoItem
@@ -35,9 +62,18 @@ type DbMap map[int]ToDoItem
 // improve the security and robustness of our program. Plus, since all the
 // operators(methods) must be created inside the package, it also improves
 // the readability of our code.
+//
+// Changes are appended to a journal next to the database file, which is
+// only ever replaced as a whole when the journal is compacted (see
+// journal.go and saveDB).  Every change happens under an advisory file
+// lock, so several todo processes can share one database.  A single ToDo
+// is not safe for use by several goroutines at once.
 type ToDo struct {
 	toDoMap    DbMap
 	dbFileName string
+	lockFile   *os.File
+	locks      int
+	journalLen int
 }
 
 // New is a constructor function that returns a pointer to a new
@@ -46,6 +82,18 @@ type ToDo struct {
 // If the file doesn't exist, it will be created.  If the file
 // does exist, it will be loaded into the ToDo struct.
 func New(dbFile string) (*ToDo, error) {
+	toDo := &ToDo{
+		toDoMap:    make(map[int]ToDoItem),
+		dbFileName: dbFile,
+	}
+
+	//Another todo process could be creating the file right now, so we
+	//check and create it under the lock
+	unlock, err := toDo.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	//Check if the database file exists, if not use initDB to create it
 	//In go, you use the os.Stat function to get information about a file
@@ -59,13 +107,6 @@ func New(dbFile string) (*ToDo, error) {
 		}
 	}
 
-	//Now that we know the file exists, at at the minimum we have
-	//a valid empty DB, lets create the ToDo struct
-	toDo := &ToDo{
-		toDoMap:    make(map[int]ToDoItem),
-		dbFileName: dbFile,
-	}
-
 	// We should be all set here, the ToDo struct is ready to go
 	// so we can support the public database operations
 	return toDo, nil
@@ -100,6 +141,12 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 	//If everything there are no errors, this function should return nil
 	//at the end to indicate that the item was properly added to the
 	//database.
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	loadDBError := t.loadDB()
 	if loadDBError != nil {
 		return loadDBError
@@ -107,18 +154,26 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 	newId := item.Id
 	for id := range t.toDoMap {
 		if newId == id {
-			return errors.New("id already existed")
+			return fmt.Errorf("%w: id %d already existed", ErrConflict, newId)
 		}
 	}
+	if err := checkRelations(item, t.toDoMap); err != nil {
+		return err
+	}
+	item.Progress = nil
+	item.Version = 1
+	item.UpdatedAt = time.Now().UTC()
 	t.toDoMap[item.Id] = item
-	saveDBError := t.saveDB()
+	saveDBError := t.logChange(journalRecord{Op: opAdd, Id: item.Id, Item: &item})
 	if saveDBError != nil {
 		return saveDBError
 	}
 	return nil
 }
 
-// DeleteItem accepts an item id and removes it from the DB.
+// DeleteItem accepts an item id and removes it from the DB.  cascade
+// says what happens to the subtasks of the item, see Cascade, and the
+// item is taken off the BlockedBy list of the items it blocked.
 // Preconditions:   (1) The database file must exist and be a valid
 //
 //					(2) The item must exist in the DB
@@ -131,7 +186,7 @@ func (t *ToDo) AddItem(item ToDoItem) error {
 //	 (1) The item will be removed from the DB
 //		(2) The DB file will be saved with the item removed
 //		(3) If there is an error, it will be returned
-func (t *ToDo) DeleteItem(id int) error {
+func (t *ToDo) DeleteItem(id int, cascade Cascade) error {
 	//TODO: Implement this function
 	//Like the add item function, start by loading the database into the
 	//private map in our struct.  Then make sure the item we want to delete
@@ -143,21 +198,35 @@ func (t *ToDo) DeleteItem(id int) error {
 	//appropriate.  If everything there are no errors, this function should
 	//return nil at the end to indicate that the item was properly deleted
 	//from the database.
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	loadDBError := t.loadDB()
 	if loadDBError != nil {
 		return loadDBError
 	}
-	for existedId := range t.toDoMap {
-		if id == existedId {
-			delete(t.toDoMap, id)
-			saveDBError := t.saveDB()
-			if saveDBError != nil {
-				return saveDBError
-			}
-			return nil
-		}
+	deleted, changed, err := deletePlan(t.toDoMap, id, cascade)
+	if err != nil {
+		return err
+	}
+	//the subtasks and blockers that change go into the journal with the
+	//deleted items, as one record
+	var changes []journalRecord
+	for _, item := range changed {
+		item := item
+		item.Version++
+		item.UpdatedAt = time.Now().UTC()
+		t.toDoMap[item.Id] = item
+		changes = append(changes, journalRecord{Op: opUpdate, Id: item.Id, Item: &item})
+	}
+	for _, id := range deleted {
+		delete(t.toDoMap, id)
+		changes = append(changes, journalRecord{Op: opDelete, Id: id})
 	}
-	return errors.New("id not existed")
+	return t.logChange(changes...)
 }
 
 // UpdateItem accepts a ToDoItem and updates it in the DB.
@@ -185,6 +254,12 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 	//any errors, return them, as appropriate.  If everything there are
 	//no errors, this function should return nil at the end to indicate
 	//that the item was properly updated in the database.
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	loadDBError := t.loadDB()
 	if loadDBError != nil {
 		return loadDBError
@@ -192,15 +267,21 @@ func (t *ToDo) UpdateItem(item ToDoItem) error {
 	id := item.Id
 	for existedId := range t.toDoMap {
 		if id == existedId {
+			if err := checkRelations(item, t.toDoMap); err != nil {
+				return err
+			}
+			item.Progress = nil
+			item.Version = t.toDoMap[id].Version + 1
+			item.UpdatedAt = time.Now().UTC()
 			t.toDoMap[id] = item
-			updateDBError := t.saveDB()
+			updateDBError := t.logChange(journalRecord{Op: opUpdate, Id: id, Item: &item})
 			if updateDBError != nil {
 				return updateDBError
 			}
 			return nil
 		}
 	}
-	return errors.New("id not existed")
+	return ErrNotFound
 }
 
 // GetItem accepts an item id and returns the item from the DB.
@@ -235,10 +316,10 @@ func (t *ToDo) GetItem(id int) (ToDoItem, error) {
 	}
 	for existedId := range t.toDoMap {
 		if id == existedId {
-			return t.toDoMap[id], nil
+			return withProgress(t.toDoMap[id], t.toDoMap), nil
 		}
 	}
-	return ToDoItem{}, errors.New("id not existed")
+	return ToDoItem{}, ErrNotFound
 }
 
 // GetAllItems returns all items from the DB.  If successful it
@@ -268,11 +349,27 @@ func (t *ToDo) GetAllItems() ([]ToDoItem, error) {
 	}
 	items := make([]ToDoItem, 0, len(t.toDoMap))
 	for _, item := range t.toDoMap {
-		items = append(items, item)
+		items = append(items, withProgress(item, t.toDoMap))
 	}
 	return items, nil
 }
 
+// GetSubtasks returns the items whose parent is id, ordered by id.  It
+// returns ErrNotFound if there is no item id.
+func (t *ToDo) GetSubtasks(id int) ([]ToDoItem, error) {
+	if err := t.loadDB(); err != nil {
+		return nil, err
+	}
+	if _, ok := t.toDoMap[id]; !ok {
+		return nil, ErrNotFound
+	}
+	children := subtasks(t.toDoMap, id)
+	for i := range children {
+		children[i] = withProgress(children[i], t.toDoMap)
+	}
+	return children, nil
+}
+
 // PrintItem accepts a ToDoItem and prints it to the console
 // in a JSON pretty format. As some help, look at the
 // json.MarshalIndent() function from our in class go tutorial.
@@ -293,7 +390,8 @@ func (t *ToDo) PrintAllItems(itemList []ToDoItem) {
 // JsonToItem accepts a json string and returns a ToDoItem
 // This is helpful because the CLI accepts todo items for insertion
 // and updates in JSON format.  We need to convert it to a ToDoItem
-// struct to perform any operations on it.
+// struct to perform any operations on it.  The item is checked with
+// ValidateItem, the same rules the todo APIs apply to request bodies.
 func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
 	var item ToDoItem
 	err := json.Unmarshal([]byte(jsonString), &item)
@@ -301,6 +399,10 @@ func (t *ToDo) JsonToItem(jsonString string) (ToDoItem, error) {
 		return ToDoItem{}, err
 	}
 
+	if err := ValidateItem(item); err != nil {
+		return ToDoItem{}, err
+	}
+
 	return item, nil
 }
 
@@ -332,6 +434,14 @@ func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
 	//in the DB (after the status is changed).  If there are any
 	//errors along the way, return them.  If everything is successful
 	//return nil at the end to indicate that the item was properly
+	//updated.  The lock is held across both calls, so nobody can change
+	//the item between reading and writing it.
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	item, getItemError := t.GetItem(id)
 	if getItemError != nil {
 		return getItemError
@@ -355,29 +465,14 @@ func (t *ToDo) ChangeItemDoneStatus(id int, value bool) error {
 // exist.  Notice this function does not have a receiver as its
 // used by New() to create the DB file
 func initDB(dbFileName string) error {
-	f, err := os.Create(dbFileName)
-	if err != nil {
-		return err
-	}
-
 	// Given we are working with a json array as our DB structure
 	// we should initialize the file with an empty array, which
 	// in json is represented as "[]
-	_, err = f.Write([]byte("[]"))
-	if err != nil {
-		return err
-	}
-
-	f.Close()
-
-	return nil
+	return writeFileAtomic(dbFileName, []byte("[]"), 0644)
 }
 
-func (t *ToDo) saveDB() error {
-	//1. Convert our map into a slice
-	//2. Marshal the slice into json
-	//3. Write the json to our file
-
+// marshalDB returns the map as the json of the database file
+func (t *ToDo) marshalDB() ([]byte, error) {
 	//1. Convert our map into a slice
 	var toDoList []ToDoItem
 	for _, item := range t.toDoMap {
@@ -386,21 +481,40 @@ func (t *ToDo) saveDB() error {
 
 	//2. Marshal the slice into json, lets pretty print it, but
 	//   this is not required
-	data, err := json.MarshalIndent(toDoList, "", "  ")
+	return json.MarshalIndent(toDoList, "", "  ")
+}
+
+// saveDB writes the map to the database file and empties the journal, the
+// compaction step of journal.go.  It must be called with the lock held,
+// after a loadDB.
+func (t *ToDo) saveDB() error {
+	//1. Marshal the map into json
+	//2. Back up the old file and replace it with the json
+
+	//1. Marshal the map into json
+	data, err := t.marshalDB()
 	if err != nil {
 		return err
 	}
 
-	//3. Write the json to our file
-	err = os.WriteFile(t.dbFileName, data, 0644)
-	if err != nil {
+	//2. Back up the old file and replace it with the json.  os.WriteFile
+	//   would truncate the file first, a crash half way through the write
+	//   would leave us with a corrupt database
+	if err := t.replaceDB(data); err != nil {
 		return err
 	}
-
-	return nil
+	return t.clearJournal()
 }
 
 func (t *ToDo) loadDB() error {
+	//The snapshot and the journal have to be read together, without a
+	//compaction in between
+	unlock, err := t.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	data, err := os.ReadFile(t.dbFileName)
 	if err != nil {
 		return err
@@ -410,13 +524,17 @@ func (t *ToDo) loadDB() error {
 	var toDoList []ToDoItem
 	err = json.Unmarshal(data, &toDoList)
 	if err != nil {
-		return err
+		return fmt.Errorf("%s is not a valid database, see 'todo backups' and 'todo restore': %w", t.dbFileName, err)
 	}
 
-	//Now let's iterate over our slice and add each item to our map
+	//Now let's iterate over our slice and add each item to our map.  We
+	//start from an empty map, another todo process may have deleted items
+	//since our last load
+	t.toDoMap = make(DbMap, len(toDoList))
 	for _, item := range toDoList {
 		t.toDoMap[item.Id] = item
 	}
 
-	return nil
+	//Finally replay the changes made since the snapshot was written
+	return t.replayJournal()
 }
@@ -0,0 +1,19 @@
+package db
+
+import "validate"
+
+// The validation rules for a ToDoItem are declared with "binding" struct
+// tags on the ToDoItem itself, the validate package checks them the same
+// way gin does when it binds a request body.  That way the APIs and the
+// CLI (via JsonToItem) check items with exactly the same rules.
+
+// ErrValidation is wrapped by every *validate.ValidationError, check for it
+// with errors.Is()
+var ErrValidation = validate.ErrValidation
+
+// ValidateItem checks a ToDoItem against its binding tags.  It returns a
+// *validate.ValidationError listing the fields that failed, or nil if the
+// item is valid
+func ValidateItem(item ToDoItem) error {
+	return validate.Struct(item)
+}
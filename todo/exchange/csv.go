@@ -0,0 +1,91 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"drexel.edu/todo/db"
+)
+
+// The csv format has a header row, then one row per item.  We write the
+// columns id, title and done.  When reading, the columns may come in any
+// order, only title is required, and done also accepts yes/no and x.
+
+func writeCSV(w io.Writer, items []db.ToDoItem) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "title", "done"}); err != nil {
+		return err
+	}
+	for _, item := range items {
+		row := []string{strconv.Itoa(item.Id), item.Title, strconv.FormatBool(item.IsDone)}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func readCSV(r io.Reader) ([]db.ToDoItem, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("csv: the header row has no title column")
+	}
+	field := func(row []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var items []db.ToDoItem
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		item := db.ToDoItem{Title: field(row, "title")}
+		if id := field(row, "id"); id != "" {
+			if item.Id, err = strconv.Atoi(id); err != nil {
+				return nil, fmt.Errorf("csv line %d: id %q is not a number", line, id)
+			}
+		}
+		if item.IsDone, err = parseDone(field(row, "done")); err != nil {
+			return nil, fmt.Errorf("csv line %d: %w", line, err)
+		}
+		items = append(items, item)
+	}
+}
+
+func parseDone(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "", "false", "no", "0":
+		return false, nil
+	case "true", "yes", "1", "x":
+		return true, nil
+	}
+	return false, fmt.Errorf("done %q is not true or false", s)
+}
@@ -0,0 +1,110 @@
+// Package exchange moves todo items between the todo database and files
+// other tools understand: CSV, Markdown checklists, iCalendar VTODO and
+// the json of the database itself.
+package exchange
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"drexel.edu/todo/db"
+)
+
+type Format string
+
+const (
+	JSON     Format = "json"
+	CSV      Format = "csv"
+	Markdown Format = "md"
+	ICal     Format = "ics"
+)
+
+// Formats lists every supported format
+var Formats = []Format{JSON, CSV, Markdown, ICal}
+
+// ParseFormat accepts the name of a format, as used by the --format flag
+func ParseFormat(name string) (Format, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "."))
+	switch name {
+	case "markdown":
+		return Markdown, nil
+	case "ical", "icalendar":
+		return ICal, nil
+	}
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q, use one of %s", name, formatNames())
+}
+
+// FormatOfFile guesses the format from the extension of fileName
+func FormatOfFile(fileName string) (Format, error) {
+	ext := filepath.Ext(fileName)
+	if ext == "" {
+		return "", fmt.Errorf("can not tell the format of %q, use --format", fileName)
+	}
+	return ParseFormat(ext)
+}
+
+func formatNames() string {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Export writes items to w in format, ordered by id
+func Export(w io.Writer, format Format, items []db.ToDoItem) error {
+	sorted := append([]db.ToDoItem{}, items...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+
+	switch format {
+	case JSON:
+		return writeJSON(w, sorted)
+	case CSV:
+		return writeCSV(w, sorted)
+	case Markdown:
+		return writeMarkdown(w, sorted)
+	case ICal:
+		return writeICal(w, sorted)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// Read parses the items in r.  Formats written by other tools may not
+// carry our ids, those items get id 0 and a new id when they are
+// imported.  Every item is checked with db.ValidateItem.
+func Read(r io.Reader, format Format) ([]db.ToDoItem, error) {
+	var (
+		items []db.ToDoItem
+		err   error
+	)
+	switch format {
+	case JSON:
+		items, err = readJSON(r)
+	case CSV:
+		items, err = readCSV(r)
+	case Markdown:
+		items, err = readMarkdown(r)
+	case ICal:
+		items, err = readICal(r)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		if err := db.ValidateItem(item); err != nil {
+			return nil, fmt.Errorf("item %d (id %d): %w", i+1, item.Id, err)
+		}
+	}
+	return items, nil
+}
@@ -0,0 +1,166 @@
+package exchange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+// The ics format is an iCalendar (RFC 5545) calendar with one VTODO per
+// item, which calendar and task apps can import.  The id is kept in the
+// UID as "todo-<id>@todo-cli".  When reading, VTODOs with any other UID get
+// a new id, and everything but SUMMARY and STATUS is ignored.
+
+const (
+	icalProdID    = "-//drexel.edu//todo cli//EN"
+	icalUIDPrefix = "todo-"
+	icalUIDSuffix = "@todo-cli"
+	icalMaxLine   = 75 // octets, longer lines are folded
+)
+
+func writeICal(w io.Writer, items []db.ToDoItem) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+
+	writeICalLine(bw, "BEGIN:VCALENDAR")
+	writeICalLine(bw, "VERSION:2.0")
+	writeICalLine(bw, "PRODID:"+icalProdID)
+	for _, item := range items {
+		status := "NEEDS-ACTION"
+		if item.IsDone {
+			status = "COMPLETED"
+		}
+		writeICalLine(bw, "BEGIN:VTODO")
+		writeICalLine(bw, "UID:"+icalUIDPrefix+strconv.Itoa(item.Id)+icalUIDSuffix)
+		writeICalLine(bw, "DTSTAMP:"+stamp)
+		writeICalLine(bw, "SUMMARY:"+escapeICalText(item.Title))
+		writeICalLine(bw, "STATUS:"+status)
+		writeICalLine(bw, "END:VTODO")
+	}
+	writeICalLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeICalLine ends line with CRLF and folds it so no line is longer
+// than 75 octets, without splitting a utf-8 character
+func writeICalLine(w *bufio.Writer, line string) {
+	limit := icalMaxLine
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = icalMaxLine - 1 // the leading space counts
+	}
+	w.WriteString(line + "\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICalText(s string) string {
+	return icalEscaper.Replace(s)
+}
+
+func unescapeICalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func readICal(r io.Reader) ([]db.ToDoItem, error) {
+	lines, err := unfoldICal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		items []db.ToDoItem
+		item  *db.ToDoItem
+	)
+	for n, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		//drop parameters, eg "SUMMARY;LANGUAGE=en"
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VTODO"):
+			if item != nil {
+				return nil, fmt.Errorf("ics line %d: VTODO inside a VTODO", n+1)
+			}
+			item = &db.ToDoItem{}
+		case item == nil:
+			continue
+		case name == "END" && strings.EqualFold(value, "VTODO"):
+			items = append(items, *item)
+			item = nil
+		case name == "UID":
+			item.Id = idFromUID(value)
+		case name == "SUMMARY":
+			item.Title = unescapeICalText(value)
+		case name == "STATUS":
+			item.IsDone = strings.EqualFold(value, "COMPLETED")
+		}
+	}
+	if item != nil {
+		return nil, fmt.Errorf("ics: VTODO is not closed with END:VTODO")
+	}
+	return items, nil
+}
+
+// idFromUID returns the id from a UID we wrote, 0 for any other UID
+func idFromUID(uid string) int {
+	s, ok := strings.CutPrefix(uid, icalUIDPrefix)
+	if !ok {
+		return 0
+	}
+	s, ok = strings.CutSuffix(s, icalUIDSuffix)
+	if !ok {
+		return 0
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
+// unfoldICal splits r into content lines, joining folded lines again
+func unfoldICal(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
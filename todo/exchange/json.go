@@ -0,0 +1,28 @@
+package exchange
+
+import (
+	"encoding/json"
+	"io"
+
+	"drexel.edu/todo/db"
+)
+
+// The json format is the format of the database file, a json array of
+// items
+
+func writeJSON(w io.Writer, items []db.ToDoItem) error {
+	data, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func readJSON(r io.Reader) ([]db.ToDoItem, error) {
+	var items []db.ToDoItem
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,60 @@
+package exchange
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"drexel.edu/todo/db"
+)
+
+// The md format is a Markdown task list:
+//
+//	- [ ] Learn Kubernetes <!-- id:2 -->
+//	- [x] Learn Go / GoLang <!-- id:1 -->
+//
+// The id is kept in an html comment, which Markdown renderers hide.  When
+// reading, every other line is ignored, as are list items that are not
+// tasks, and tasks without an id comment get a new id.
+
+var (
+	taskLine  = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*)$`)
+	idComment = regexp.MustCompile(`\s*<!--\s*id:\s*(\d+)\s*-->\s*$`)
+)
+
+func writeMarkdown(w io.Writer, items []db.ToDoItem) error {
+	bw := bufio.NewWriter(w)
+	for _, item := range items {
+		box := " "
+		if item.IsDone {
+			box = "x"
+		}
+		//a line break would end the list item
+		title := strings.Join(strings.Fields(item.Title), " ")
+		fmt.Fprintf(bw, "- [%s] %s <!-- id:%d -->\n", box, title, item.Id)
+	}
+	return bw.Flush()
+}
+
+func readMarkdown(r io.Reader) ([]db.ToDoItem, error) {
+	var items []db.ToDoItem
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := taskLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+
+		item := db.ToDoItem{IsDone: m[1] != " ", Title: m[2]}
+		if id := idComment.FindStringSubmatch(item.Title); id != nil {
+			item.Id, _ = strconv.Atoi(id[1])
+			item.Title = idComment.ReplaceAllString(item.Title, "")
+		}
+		item.Title = strings.TrimSpace(item.Title)
+		items = append(items, item)
+	}
+	return items, scanner.Err()
+}
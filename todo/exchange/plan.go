@@ -0,0 +1,234 @@
+package exchange
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"drexel.edu/todo/db"
+)
+
+// Policy decides what happens to an imported item whose id is already
+// taken by a different item in the database
+type Policy string
+
+const (
+	Skip      Policy = "skip"      // keep the item in the database
+	Overwrite Policy = "overwrite" // replace it with the imported item
+	Renumber  Policy = "renumber"  // add the imported item under a new id
+)
+
+var Policies = []Policy{Skip, Overwrite, Renumber}
+
+func ParsePolicy(name string) (Policy, error) {
+	for _, p := range Policies {
+		if string(p) == strings.ToLower(name) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("unknown conflict policy %q, use skip, overwrite or renumber", name)
+}
+
+type Action string
+
+const (
+	ActionAdd       Action = "add"
+	ActionUpdate    Action = "update"
+	ActionRenumber  Action = "renumber"
+	ActionSkip      Action = "skip"
+	ActionUnchanged Action = "unchanged"
+)
+
+// Change is what an import does with one item.  Item is the item as it
+// will be stored, with its final id.  Old is the item in the database that
+// has the id from the file, if there is one.  FromId is the id in the
+// file, which differs from Item.Id when the item was renumbered or had no
+// id.
+type Change struct {
+	Action Action
+	Item   db.ToDoItem
+	Old    *db.ToDoItem
+	FromId int
+}
+
+// Plan lists the changes of an import, in the order of the file.  It can
+// be shown as a diff before it is applied.
+type Plan struct {
+	Changes []Change
+}
+
+// Store is the part of db.ToDo that Apply needs
+type Store interface {
+	AddItem(item db.ToDoItem) error
+	UpdateItem(item db.ToDoItem) error
+}
+
+// NewPlan works out how to import incoming into a database that holds
+// existing.  Items without an id (id 0) always get the next free id, an
+// item that is identical to the one in the database is left alone, any
+// other item with a taken id is handled by policy.
+func NewPlan(existing []db.ToDoItem, incoming []db.ToDoItem, policy Policy) Plan {
+	stored := make(map[int]db.ToDoItem, len(existing))
+	nextId := 1
+	for _, item := range existing {
+		stored[item.Id] = item
+		if item.Id >= nextId {
+			nextId = item.Id + 1
+		}
+	}
+	for _, item := range incoming {
+		if item.Id >= nextId {
+			nextId = item.Id + 1
+		}
+	}
+	newId := func() int {
+		nextId++
+		return nextId - 1
+	}
+
+	var plan Plan
+	added := map[int]int{} // id -> index of the change that adds it
+	for _, item := range incoming {
+		change := Change{Item: item, FromId: item.Id}
+
+		old, inDB := stored[item.Id]
+		addIndex, inPlan := added[item.Id]
+		switch {
+		case item.Id <= 0:
+			change.Action = ActionAdd
+			change.Item.Id = newId()
+		case !inDB && !inPlan:
+			change.Action = ActionAdd
+		case inPlan && policy == Overwrite:
+			//the file has the id twice, the last one wins
+			plan.Changes[addIndex].Item = item
+			continue
+		case inPlan && policy == Skip:
+			change.Action = ActionSkip
+			first := plan.Changes[addIndex].Item
+			change.Old = &first
+		case inPlan:
+			change.Action = ActionRenumber
+			change.Item.Id = newId()
+		case old.SameContent(item):
+			change.Action = ActionUnchanged
+			change.Old = &old
+		case policy == Overwrite:
+			change.Action = ActionUpdate
+			change.Old = &old
+		case policy == Renumber:
+			change.Action = ActionRenumber
+			change.Old = &old
+			change.Item.Id = newId()
+		default:
+			change.Action = ActionSkip
+			change.Old = &old
+		}
+
+		if change.Action == ActionAdd || change.Action == ActionRenumber {
+			added[change.Item.Id] = len(plan.Changes)
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+
+	//parents and blockers in the file are ids in the file, follow the
+	//items that were given a new id
+	renumbered := map[int]int{}
+	for _, c := range plan.Changes {
+		if c.Action == ActionRenumber {
+			renumbered[c.FromId] = c.Item.Id
+		}
+	}
+	if len(renumbered) > 0 {
+		for i := range plan.Changes {
+			item := &plan.Changes[i].Item
+			if id, ok := renumbered[item.ParentId]; ok {
+				item.ParentId = id
+			}
+			if len(item.BlockedBy) == 0 {
+				continue
+			}
+			blockers := make([]int, len(item.BlockedBy))
+			for j, blocker := range item.BlockedBy {
+				if id, ok := renumbered[blocker]; ok {
+					blocker = id
+				}
+				blockers[j] = blocker
+			}
+			item.BlockedBy = blockers
+		}
+	}
+	return plan
+}
+
+// Apply makes the changes of the plan in store.  It stops at the first
+// change that fails, the changes before it stay.
+func (p Plan) Apply(store Store) error {
+	for _, c := range p.Changes {
+		var err error
+		switch c.Action {
+		case ActionAdd, ActionRenumber:
+			err = store.AddItem(c.Item)
+		case ActionUpdate:
+			err = store.UpdateItem(c.Item)
+		}
+		if err != nil {
+			return fmt.Errorf("%s item %d: %w", c.Action, c.Item.Id, err)
+		}
+	}
+	return nil
+}
+
+// Count returns how many changes have action
+func (p Plan) Count(action Action) int {
+	n := 0
+	for _, c := range p.Changes {
+		if c.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary counts the changes by action, eg "2 added, 1 updated, ..."
+func (p Plan) Summary() string {
+	return fmt.Sprintf("%d added, %d updated, %d renumbered, %d skipped, %d unchanged",
+		p.Count(ActionAdd), p.Count(ActionUpdate), p.Count(ActionRenumber), p.Count(ActionSkip), p.Count(ActionUnchanged))
+}
+
+// WriteDiff writes one line per change.  The line starts with a mark for
+// the action, then the id and the item, eg "~ 2  [ ] Learn Go -> [x] Learn
+// Go 1.21".  The marks are + add, ~ update, > renumber, ! skip and
+// = unchanged.
+func (p Plan) WriteDiff(w io.Writer) error {
+	for _, c := range p.Changes {
+		var line string
+		switch c.Action {
+		case ActionAdd:
+			id := fmt.Sprint(c.Item.Id)
+			if c.FromId != c.Item.Id {
+				id += " (new)"
+			}
+			line = fmt.Sprintf("+ %-10s %s", id, describe(c.Item))
+		case ActionUpdate:
+			line = fmt.Sprintf("~ %-10d %s -> %s", c.Item.Id, describe(*c.Old), describe(c.Item))
+		case ActionRenumber:
+			line = fmt.Sprintf("> %-10s %s", fmt.Sprintf("%d -> %d", c.FromId, c.Item.Id), describe(c.Item))
+		case ActionSkip:
+			line = fmt.Sprintf("! %-10d %s (kept %s)", c.FromId, describe(c.Item), describe(*c.Old))
+		case ActionUnchanged:
+			line = fmt.Sprintf("= %-10d %s", c.Item.Id, describe(c.Item))
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func describe(item db.ToDoItem) string {
+	if item.IsDone {
+		return "[x] " + item.Title
+	}
+	return "[ ] " + item.Title
+}
@@ -0,0 +1,168 @@
+package exchange_test
+
+import (
+	"reflect"
+	"testing"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/exchange"
+)
+
+// change is the part of an exchange.Change that the tests look at
+type change struct {
+	action    exchange.Action
+	fromId    int
+	id        int
+	title     string
+	parentId  int
+	blockedBy []int
+	oldTitle  string // "" when there is no old item
+}
+
+func TestNewPlan(t *testing.T) {
+	learn := db.ToDoItem{Id: 1, Title: "Learn Go"}
+	tests := []struct {
+		name     string
+		existing []db.ToDoItem
+		incoming []db.ToDoItem
+		policy   exchange.Policy
+		want     []change
+	}{
+		{
+			name:     "free ids are added, items without an id get the next free id",
+			incoming: []db.ToDoItem{{Id: 1, Title: "a"}, {Title: "b"}, {Id: 5, Title: "c"}},
+			policy:   exchange.Skip,
+			want: []change{
+				{action: exchange.ActionAdd, fromId: 1, id: 1, title: "a"},
+				{action: exchange.ActionAdd, fromId: 0, id: 6, title: "b"},
+				{action: exchange.ActionAdd, fromId: 5, id: 5, title: "c"},
+			},
+		},
+		{
+			name:     "an identical item is unchanged",
+			existing: []db.ToDoItem{learn},
+			incoming: []db.ToDoItem{{Id: 1, Title: "Learn Go", Version: 3}},
+			policy:   exchange.Overwrite,
+			want: []change{
+				{action: exchange.ActionUnchanged, fromId: 1, id: 1, title: "Learn Go", oldTitle: "Learn Go"},
+			},
+		},
+		{
+			name:     "skip keeps the item in the database",
+			existing: []db.ToDoItem{learn},
+			incoming: []db.ToDoItem{{Id: 1, Title: "Learn Rust"}},
+			policy:   exchange.Skip,
+			want: []change{
+				{action: exchange.ActionSkip, fromId: 1, id: 1, title: "Learn Rust", oldTitle: "Learn Go"},
+			},
+		},
+		{
+			name:     "overwrite replaces the item in the database",
+			existing: []db.ToDoItem{learn},
+			incoming: []db.ToDoItem{{Id: 1, Title: "Learn Rust"}},
+			policy:   exchange.Overwrite,
+			want: []change{
+				{action: exchange.ActionUpdate, fromId: 1, id: 1, title: "Learn Rust", oldTitle: "Learn Go"},
+			},
+		},
+		{
+			name:     "renumber adds the item past every id in the database and the file",
+			existing: []db.ToDoItem{learn},
+			incoming: []db.ToDoItem{{Id: 1, Title: "Learn Rust"}, {Id: 4, Title: "d"}},
+			policy:   exchange.Renumber,
+			want: []change{
+				{action: exchange.ActionRenumber, fromId: 1, id: 5, title: "Learn Rust", oldTitle: "Learn Go"},
+				{action: exchange.ActionAdd, fromId: 4, id: 4, title: "d"},
+			},
+		},
+		{
+			name:     "overwrite keeps the last of an id given twice in the file",
+			incoming: []db.ToDoItem{{Id: 2, Title: "first"}, {Id: 2, Title: "second"}},
+			policy:   exchange.Overwrite,
+			want: []change{
+				{action: exchange.ActionAdd, fromId: 2, id: 2, title: "second"},
+			},
+		},
+		{
+			name:     "skip keeps the first of an id given twice in the file",
+			incoming: []db.ToDoItem{{Id: 2, Title: "first"}, {Id: 2, Title: "second"}},
+			policy:   exchange.Skip,
+			want: []change{
+				{action: exchange.ActionAdd, fromId: 2, id: 2, title: "first"},
+				{action: exchange.ActionSkip, fromId: 2, id: 2, title: "second", oldTitle: "first"},
+			},
+		},
+		{
+			name:     "renumber adds both of an id given twice in the file",
+			incoming: []db.ToDoItem{{Id: 2, Title: "first"}, {Id: 2, Title: "second"}},
+			policy:   exchange.Renumber,
+			want: []change{
+				{action: exchange.ActionAdd, fromId: 2, id: 2, title: "first"},
+				{action: exchange.ActionRenumber, fromId: 2, id: 3, title: "second"},
+			},
+		},
+		{
+			name:     "parents and blockers follow renumbered items",
+			existing: []db.ToDoItem{learn},
+			incoming: []db.ToDoItem{
+				{Id: 1, Title: "Learn Rust"},
+				{Id: 2, Title: "Read the book", ParentId: 1, BlockedBy: []int{1, 7}},
+			},
+			policy: exchange.Renumber,
+			want: []change{
+				{action: exchange.ActionRenumber, fromId: 1, id: 3, title: "Learn Rust", oldTitle: "Learn Go"},
+				{action: exchange.ActionAdd, fromId: 2, id: 2, title: "Read the book", parentId: 3, blockedBy: []int{3, 7}},
+			},
+		},
+		{
+			name:     "parents and blockers are left alone without renumbering",
+			existing: []db.ToDoItem{learn},
+			incoming: []db.ToDoItem{
+				{Id: 1, Title: "Learn Rust"},
+				{Id: 2, Title: "Read the book", ParentId: 1, BlockedBy: []int{1}},
+			},
+			policy: exchange.Overwrite,
+			want: []change{
+				{action: exchange.ActionUpdate, fromId: 1, id: 1, title: "Learn Rust", oldTitle: "Learn Go"},
+				{action: exchange.ActionAdd, fromId: 2, id: 2, title: "Read the book", parentId: 1, blockedBy: []int{1}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := exchange.NewPlan(tt.existing, tt.incoming, tt.policy)
+
+			got := make([]change, 0, len(plan.Changes))
+			for _, c := range plan.Changes {
+				g := change{
+					action:    c.Action,
+					fromId:    c.FromId,
+					id:        c.Item.Id,
+					title:     c.Item.Title,
+					parentId:  c.Item.ParentId,
+					blockedBy: c.Item.BlockedBy,
+				}
+				if c.Old != nil {
+					g.oldTitle = c.Old.Title
+				}
+				got = append(got, g)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got changes\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNewPlanKeepsFile checks that renumbering does not change the items
+// that were passed in
+func TestNewPlanKeepsFile(t *testing.T) {
+	incoming := []db.ToDoItem{
+		{Id: 1, Title: "Learn Rust"},
+		{Id: 2, Title: "Read the book", ParentId: 1, BlockedBy: []int{1}},
+	}
+	exchange.NewPlan([]db.ToDoItem{{Id: 1, Title: "Learn Go"}}, incoming, exchange.Renumber)
+	if incoming[1].ParentId != 1 || incoming[1].BlockedBy[0] != 1 {
+		t.Errorf("the incoming items were changed: %+v", incoming[1])
+	}
+}
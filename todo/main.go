@@ -1,13 +1,19 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"drexel.edu/todo/db"
+	"drexel.edu/todo/exchange"
+	"drexel.edu/todo/reconcile"
+	"drexel.edu/todo/remote"
+	"drexel.edu/todo/tui"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +27,16 @@ var (
 	addFlag        string
 	updateFlag     string
 	deleteFlag     int
+	restoreFlag    string
+	fileFlag       string
+	formatFlag     string
+	conflictFlag   string
+	dryRunFlag     bool
+	remoteFlag     string
+	profileFlag    string
+	configFlag     string
+	strategyFlag   string
+	cascadeFlag    string
 )
 
 type AppOptType int
@@ -36,6 +52,15 @@ const (
 	UPDATE_DB_ITEM
 	DELETE_DB_ITEM
 	CHANGE_ITEM_STATUS
+	LIST_BACKUPS
+	RESTORE_DB
+	COMPACT_DB
+	EXPORT_DB
+	IMPORT_DB
+	RUN_TUI
+	LIST_QUEUE
+	SYNC_DB
+	LIST_SUBTASKS
 	NOT_IMPLEMENTED
 	INVALID_APP_OPT
 )
@@ -147,6 +172,9 @@ func processCmdLineFlags() (AppOptType, error) {
 	var rootCmd = &cobra.Command{Use: "app"}
 
 	rootCmd.PersistentFlags().StringVar(&dbFileNameFlag, "db", "./data/todo.json", "Name of the database file")
+	rootCmd.PersistentFlags().StringVar(&remoteFlag, "remote", "", "Use the todo API at this url, eg http://localhost:1080, instead of the database file")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "Use a profile from the config file")
+	rootCmd.PersistentFlags().StringVar(&configFlag, "config", remote.DefaultConfigFile(), "Name of the config file")
 
 	var appOpt AppOptType = INVALID_APP_OPT
 
@@ -200,6 +228,21 @@ func processCmdLineFlags() (AppOptType, error) {
 		},
 	}
 
+	deleteCmd.Flags().StringVar(&cascadeFlag, "cascade", "restrict", "what to do with the subtasks of the item: restrict (refuse to delete it), orphan (move them up) or delete")
+
+	var subtasksCmd = &cobra.Command{
+		Use:   "subtasks <id>",
+		Short: "List the subtasks of an item",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = LIST_SUBTASKS
+			_, err := fmt.Sscan(args[0], &queryFlag)
+			if err != nil {
+				fmt.Println("Error: ", errors.New("subtasks need a valid id"))
+			}
+		},
+	}
+
 	var statusCmd = &cobra.Command{
 		Use:   "s",
 		Short: "Change item 'done' status to true or false",
@@ -224,11 +267,97 @@ func processCmdLineFlags() (AppOptType, error) {
 
 	// itemStatusCmd.Flags().IntVar(&queryFlag, "q", 0, "Query an item in the database")
 
-	rootCmd.AddCommand(listCmd, queryCmd, addCmd, updateCmd, deleteCmd, statusCmd)
+	var backupsCmd = &cobra.Command{
+		Use:   "backups",
+		Short: "List the backups of the database, newest first",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = LIST_BACKUPS
+		},
+	}
+
+	var restoreCmd = &cobra.Command{
+		Use:   "restore [backup]",
+		Short: "Restore the database from a backup, the newest one by default",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = RESTORE_DB
+			if len(args) == 1 {
+				restoreFlag = args[0]
+			}
+		},
+	}
+
+	var compactCmd = &cobra.Command{
+		Use:   "compact",
+		Short: "Fold the journal of changes into the database file",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = COMPACT_DB
+		},
+	}
+
+	var exportCmd = &cobra.Command{
+		Use:   "export [file]",
+		Short: "Export all the items, to standard output if no file is given",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = EXPORT_DB
+			if len(args) == 1 {
+				fileFlag = args[0]
+			}
+		},
+	}
+	exportCmd.Flags().StringVar(&formatFlag, "format", "", "csv, md, ics or json (default: from the file name, or json)")
+
+	var importCmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import items from a file, - reads standard input",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = IMPORT_DB
+			fileFlag = args[0]
+		},
+	}
+	importCmd.Flags().StringVar(&formatFlag, "format", "", "csv, md, ics or json (default: from the file name)")
+	importCmd.Flags().StringVar(&conflictFlag, "on-conflict", "skip", "what to do when an id is taken: skip, overwrite or renumber")
+	importCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "only show what the import would change")
+
+	var tuiCmd = &cobra.Command{
+		Use:   "tui",
+		Short: "Browse and change the items in a full screen list, press ? for the keys",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = RUN_TUI
+		},
+	}
+
+	var queueCmd = &cobra.Command{
+		Use:   "queue",
+		Short: "List the changes waiting for the todo API to be reachable again",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = LIST_QUEUE
+		},
+	}
+
+	var syncCmd = &cobra.Command{
+		Use:   "sync",
+		Short: "Bring the database file and the todo API from --remote or the profile to the same items",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			appOpt = SYNC_DB
+		},
+	}
+	syncCmd.Flags().StringVar(&strategyFlag, "strategy", "last-writer-wins", "who wins when both sides changed an item: last-writer-wins, prefer-local, prefer-remote or interactive")
+	syncCmd.Flags().BoolVar(&dryRunFlag, "dry-run", false, "only show what the sync would change")
+
+	rootCmd.AddCommand(listCmd, queryCmd, addCmd, updateCmd, deleteCmd, statusCmd, subtasksCmd, backupsCmd, restoreCmd, compactCmd, exportCmd, importCmd, tuiCmd, queueCmd, syncCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		return appOpt, err
 	}
+	dbFlagSet = rootCmd.PersistentFlags().Changed("db")
 
 	if appOpt == INVALID_APP_OPT || appOpt == NOT_IMPLEMENTED {
 		fmt.Println("Invalid option set or the desired option is not currently implemented")
@@ -251,12 +380,32 @@ func main() {
 		os.Exit(1)
 	}
 
-	//Create a new db object
-	todo, err := db.New(dbFileNameFlag)
+	//sync needs both the database file and the todo API
+	if opts == SYNC_DB {
+		fmt.Println("Running SYNC_DB...")
+		if err := syncItems(); err != nil {
+			fmt.Println("Error: ", err)
+			return
+		}
+		fmt.Println("Ok")
+		return
+	}
+
+	//Create a new db object, a local file or the todo API
+	todo, err := openStore()
 	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	if client, ok := todo.(*remote.Client); ok {
+		flushQueue(client)
+		queued := len(client.Queue().Pending())
+		defer func() {
+			if waiting := len(client.Queue().Pending()); waiting > queued {
+				fmt.Println("THE TODO API IS NOT REACHABLE,", waiting, "CHANGES ARE QUEUED, SEE 'todo queue'")
+			}
+		}()
+	}
 
 	//Switch over the command line flags and call the appropriate
 	//function in the db package
@@ -291,7 +440,7 @@ func main() {
 			fmt.Println("Error: ", err)
 			break
 		}
-		if err := todo.AddItem(item); err != nil {
+		if err := addItem(todo, item); err != nil {
 			fmt.Println("Error: ", err)
 			break
 		}
@@ -311,7 +460,12 @@ func main() {
 		fmt.Println("Ok")
 	case DELETE_DB_ITEM:
 		fmt.Println("Running DELETE_DB_ITEM...")
-		err := todo.DeleteItem(deleteFlag)
+		cascade, err := db.ParseCascade(cascadeFlag)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		err = todo.DeleteItem(deleteFlag, cascade)
 		if err != nil {
 			fmt.Println("Error: ", err)
 			break
@@ -328,7 +482,371 @@ func main() {
 			break
 		}
 		fmt.Println("Ok")
+	case LIST_BACKUPS:
+		fmt.Println("Running LIST_BACKUPS...")
+		local, err := localStore(todo)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		backups, err := local.Backups()
+		if err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		for _, name := range backups {
+			fmt.Println(name)
+		}
+		fmt.Println("THERE ARE", len(backups), "BACKUPS OF THE DB")
+		fmt.Println("Ok")
+	case RESTORE_DB:
+		fmt.Println("Running RESTORE_DB...")
+		local, err := localStore(todo)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		if err := local.Restore(restoreFlag); err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		fmt.Println("Ok")
+	case COMPACT_DB:
+		fmt.Println("Running COMPACT_DB...")
+		local, err := localStore(todo)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		if err := local.Compact(); err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		fmt.Println("Ok")
+	case EXPORT_DB:
+		//the items may go to standard output, so we talk on standard error
+		fmt.Fprintln(os.Stderr, "Running EXPORT_DB...")
+		if err := exportItems(todo); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: ", err)
+			break
+		}
+		fmt.Fprintln(os.Stderr, "Ok")
+	case IMPORT_DB:
+		fmt.Println("Running IMPORT_DB...")
+		if err := importItems(todo); err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		fmt.Println("Ok")
+	case RUN_TUI:
+		//the tui takes over the whole terminal, so there is nothing to
+		//print unless it fails
+		if err := tui.Run(todo, storeName(todo)); err != nil {
+			fmt.Println("Error: ", err)
+		}
+	case LIST_SUBTASKS:
+		fmt.Println("Running LIST_SUBTASKS...")
+		subtasks, err := todo.GetSubtasks(queryFlag)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			break
+		}
+		for _, item := range subtasks {
+			todo.PrintItem(item)
+		}
+		fmt.Println("ITEM", queryFlag, "HAS", len(subtasks), "SUBTASKS")
+		fmt.Println("Ok")
+	case LIST_QUEUE:
+		fmt.Println("Running LIST_QUEUE...")
+		client, ok := todo.(*remote.Client)
+		if !ok {
+			fmt.Println("Error: ", errors.New("queue needs --remote or a profile with a remote"))
+			break
+		}
+		pending := client.Queue().Pending()
+		for _, change := range pending {
+			fmt.Println(change.QueuedAt.Local().Format("2006-01-02 15:04:05"), change)
+		}
+		fmt.Println("THERE ARE", len(pending), "CHANGES WAITING FOR", client.BaseURL())
+		fmt.Println("Ok")
 	default:
 		fmt.Println("INVALID_APP_OPT")
 	}
 }
+
+// exportItems writes every item to fileFlag, or standard output, in the
+// format from formatFlag or the file name
+func exportItems(todo db.Store) error {
+	format := exchange.JSON
+	var err error
+	switch {
+	case formatFlag != "":
+		format, err = exchange.ParseFormat(formatFlag)
+	case fileFlag != "":
+		format, err = exchange.FormatOfFile(fileFlag)
+	}
+	if err != nil {
+		return err
+	}
+
+	items, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+
+	if fileFlag == "" {
+		return exchange.Export(os.Stdout, format, items)
+	}
+	f, err := os.Create(fileFlag)
+	if err != nil {
+		return err
+	}
+	if err := exchange.Export(f, format, items); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "EXPORTED", len(items), "ITEMS TO", fileFlag)
+	return nil
+}
+
+// importItems reads the items in fileFlag, shows what importing them would
+// change and, unless this is a dry run, imports them
+func importItems(todo db.Store) error {
+	policy, err := exchange.ParsePolicy(conflictFlag)
+	if err != nil {
+		return err
+	}
+	var format exchange.Format
+	if formatFlag != "" {
+		format, err = exchange.ParseFormat(formatFlag)
+	} else {
+		format, err = exchange.FormatOfFile(fileFlag)
+	}
+	if err != nil {
+		return err
+	}
+
+	in := os.Stdin
+	if fileFlag != "-" {
+		if in, err = os.Open(fileFlag); err != nil {
+			return err
+		}
+		defer in.Close()
+	}
+	incoming, err := exchange.Read(in, format)
+	if err != nil {
+		return err
+	}
+
+	existing, err := todo.GetAllItems()
+	if err != nil {
+		return err
+	}
+	plan := exchange.NewPlan(existing, incoming, policy)
+	if err := plan.WriteDiff(os.Stdout); err != nil {
+		return err
+	}
+
+	if dryRunFlag {
+		fmt.Println("DRY RUN, NOTHING WAS IMPORTED:", plan.Summary())
+		return nil
+	}
+	if err := plan.Apply(todo); err != nil {
+		return err
+	}
+	fmt.Println("IMPORTED:", plan.Summary())
+	return nil
+}
+
+// dbFlagSet tells if --db was given, a profile only picks the database
+// file if it was not
+var dbFlagSet bool
+
+// openStore returns the store the flags and the config file ask for, see
+// openRemote
+func openStore() (db.Store, error) {
+	client, err := openRemote()
+	if err != nil {
+		return nil, err
+	}
+	if client != nil {
+		return client, nil
+	}
+	return db.New(dbFileNameFlag)
+}
+
+// openRemote returns a client for the todo API from the flags or the
+// config file, nil if there is none.  --remote wins over --profile, which
+// wins over the default profile in the config file.  A profile with a
+// database file sets --db, unless it was given.
+func openRemote() (*remote.Client, error) {
+	baseURL := remoteFlag
+	timeout := remote.DefaultTimeout
+	if baseURL == "" {
+		cfg, err := remote.LoadConfig(configFlag)
+		if err != nil {
+			return nil, err
+		}
+		profile, err := cfg.Profile(profileFlag)
+		if err != nil {
+			return nil, err
+		}
+		if timeout, err = profile.TimeoutOrDefault(); err != nil {
+			return nil, err
+		}
+		baseURL = profile.Remote
+		if profile.DB != "" && !dbFlagSet {
+			dbFileNameFlag = profile.DB
+		}
+	}
+
+	if baseURL == "" {
+		return nil, nil
+	}
+	return remote.New(baseURL, timeout, remote.DefaultQueueFile(baseURL))
+}
+
+// localStore is for the commands that only make sense for a database file
+func localStore(store db.Store) (*db.ToDo, error) {
+	local, ok := store.(*db.ToDo)
+	if !ok {
+		return nil, errors.New("this command only works with a local database file, not with --remote")
+	}
+	return local, nil
+}
+
+// storeName is shown in the title bar of the tui
+func storeName(store db.Store) string {
+	if client, ok := store.(*remote.Client); ok {
+		return client.BaseURL()
+	}
+	return dbFileNameFlag
+}
+
+// addItem adds item and tells which id it got.  The todo API picks the id
+// itself, the id in the item is ignored.
+func addItem(store db.Store, item db.ToDoItem) error {
+	client, ok := store.(*remote.Client)
+	if !ok {
+		return store.AddItem(item)
+	}
+	created, err := client.CreateItem(item)
+	if err != nil {
+		return err
+	}
+	//id 0 means the item was queued, main tells about the queue
+	if created.Id != 0 {
+		fmt.Println("ADDED ITEM", created.Id)
+	}
+	return nil
+}
+
+// flushQueue sends the changes made while the todo API was not reachable,
+// before the command runs
+func flushQueue(client *remote.Client) {
+	if len(client.Queue().Pending()) == 0 {
+		return
+	}
+	sent, rejected, err := client.Flush()
+	if sent > 0 {
+		fmt.Println("SENT", sent, "QUEUED CHANGES TO", client.BaseURL())
+	}
+	for _, r := range rejected {
+		fmt.Println("Error: queued change", r.Change, "was rejected and dropped:", r.Err)
+	}
+	//still not reachable is not worth a message, the command itself
+	//will fail or queue its change too
+	switch {
+	case errors.Is(err, remote.ErrPending):
+		fmt.Println("KEPT", len(client.Queue().Pending()), "QUEUED CHANGES, THE TODO API IS STILL CREATING AN ITEM")
+	case err != nil && !errors.Is(err, remote.ErrUnavailable):
+		fmt.Println("Error: ", err)
+	}
+}
+
+// syncItems reconciles the database file with the todo API and prints
+// the report
+func syncItems() error {
+	strategy, err := reconcile.ParseStrategy(strategyFlag)
+	if err != nil {
+		return err
+	}
+	client, err := openRemote()
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		return errors.New("sync needs --remote, or a profile with a remote")
+	}
+	local, err := db.New(dbFileNameFlag)
+	if err != nil {
+		return err
+	}
+
+	//changes queued by --remote go first, they are older than the sync
+	if !dryRunFlag {
+		flushQueue(client)
+	}
+
+	states, err := reconcile.OpenStateFile(reconcile.StateFileFor(dbFileNameFlag))
+	if err != nil {
+		return err
+	}
+	state := states.State(client.BaseURL())
+	report, err := reconcile.Sync(local, client, state, reconcile.Options{
+		Strategy: strategy,
+		Ask:      askConflict(bufio.NewReader(os.Stdin)),
+		DryRun:   dryRunFlag,
+	})
+	if err != nil && len(report.Entries) == 0 {
+		return err
+	}
+	if _, werr := report.WriteTo(os.Stdout); werr != nil {
+		return werr
+	}
+	if dryRunFlag {
+		fmt.Println("DRY RUN, NOTHING WAS SYNCED:", report.Summary())
+		return err
+	}
+	if serr := states.Save(); serr != nil {
+		return serr
+	}
+	fmt.Println("SYNCED WITH", client.BaseURL()+":", report.Summary())
+	if err == nil && report.Failed() > 0 {
+		err = fmt.Errorf("%d changes failed, they are tried again by the next sync", report.Failed())
+	}
+	return err
+}
+
+// askConflict asks on the terminal which side of a conflict to keep
+func askConflict(in *bufio.Reader) func(reconcile.Conflict) (reconcile.Resolution, error) {
+	show := func(item *db.ToDoItem) string {
+		if item == nil {
+			return "deleted"
+		}
+		return fmt.Sprintf("%d %q done=%t, changed %s", item.Id, item.Title, item.IsDone, item.UpdatedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	return func(c reconcile.Conflict) (reconcile.Resolution, error) {
+		fmt.Println("CONFLICT")
+		fmt.Println("  local: ", show(c.Local))
+		fmt.Println("  remote:", show(c.Remote))
+		for {
+			fmt.Print("keep [l]ocal, [r]emote or [s]kip? ")
+			answer, err := in.ReadString('\n')
+			switch strings.ToLower(strings.TrimSpace(answer)) {
+			case "l", "local":
+				return reconcile.KeepLocal, nil
+			case "r", "remote":
+				return reconcile.KeepRemote, nil
+			case "s", "skip":
+				return reconcile.Skip, nil
+			}
+			if err != nil {
+				return reconcile.Skip, fmt.Errorf("no answer, nothing was synced: %w", err)
+			}
+		}
+	}
+}
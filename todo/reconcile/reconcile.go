@@ -0,0 +1,617 @@
+// Package reconcile is "todo sync", it brings a local todo database and
+// the todo API back to the same items after both were changed on their
+// own, eg while someone worked offline.
+//
+// Every item carries a version that its store raises on each change.  The
+// state of the last sync (see State) remembers which local item belongs to
+// which remote item and the versions both had then, so a sync can tell
+// which side changed an item since.  A change on one side is copied to the
+// other, a change on both sides is a conflict that the Strategy decides.
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/remote"
+)
+
+type Strategy string
+
+const (
+	LastWriterWins Strategy = "last-writer-wins" // the item changed last wins
+	PreferLocal    Strategy = "prefer-local"
+	PreferRemote   Strategy = "prefer-remote"
+	Interactive    Strategy = "interactive" // Options.Ask decides
+)
+
+var Strategies = []Strategy{LastWriterWins, PreferLocal, PreferRemote, Interactive}
+
+func ParseStrategy(name string) (Strategy, error) {
+	for _, s := range Strategies {
+		if string(s) == strings.ToLower(name) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown strategy %q, use last-writer-wins, prefer-local, prefer-remote or interactive", name)
+}
+
+// Resolution is the outcome of a conflict
+type Resolution string
+
+const (
+	KeepLocal  Resolution = "local"
+	KeepRemote Resolution = "remote"
+	Skip       Resolution = "skip" // change nothing, the conflict comes up again next time
+)
+
+// Conflict is an item that changed on both sides.  Local or Remote is nil
+// if the item was deleted on that side.
+type Conflict struct {
+	Local  *db.ToDoItem
+	Remote *db.ToDoItem
+}
+
+// Local is the part of db.ToDo that a sync needs
+type Local interface {
+	GetAllItems() ([]db.ToDoItem, error)
+	GetItem(id int) (db.ToDoItem, error)
+	AddItem(item db.ToDoItem) error
+	UpdateItem(item db.ToDoItem) error
+	DeleteItem(id int, cascade db.Cascade) error
+}
+
+// Remote is the part of remote.Client that a sync needs.  Unlike the
+// db.Store methods of the client these never queue a change, a sync has
+// to know if it happened.
+type Remote interface {
+	GetAllItems() ([]db.ToDoItem, error)
+	Create(item db.ToDoItem, idempotencyKey string) (db.ToDoItem, error)
+	Put(item db.ToDoItem) (db.ToDoItem, error)
+	Remove(id int, cascade db.Cascade) error
+}
+
+type Options struct {
+	Strategy Strategy
+	// Ask decides each conflict for the Interactive strategy
+	Ask func(c Conflict) (Resolution, error)
+	// DryRun only works out the report, nothing is changed
+	DryRun bool
+}
+
+type Action string
+
+const (
+	ActionPush         Action = "push"          // copy the local change to the API
+	ActionPushNew      Action = "push new"      // create the local item in the API
+	ActionPull         Action = "pull"          // copy the remote change to the database
+	ActionPullNew      Action = "pull new"      // add the remote item to the database
+	ActionDeleteRemote Action = "delete remote" // it was deleted locally
+	ActionDeleteLocal  Action = "delete local"  // it was deleted in the API
+	ActionSame         Action = "same"          // both sides made the same change
+	ActionForget       Action = "forget"        // both sides deleted the item
+	ActionSkip         Action = "skip"          // a conflict that was not resolved
+	ActionUnchanged    Action = "unchanged"
+)
+
+// Entry is what a sync does with one item.  Local and Remote are the item
+// on both sides before the sync, nil if it is not there.  RemoteId is 0
+// for ActionPushNew until the API picked an id.
+type Entry struct {
+	Action     Action
+	LocalId    int
+	RemoteId   int
+	Local      *db.ToDoItem
+	Remote     *db.ToDoItem
+	Conflict   bool
+	Resolution Resolution
+	Err        error
+
+	link *Link // the link from the last sync, if there was one
+}
+
+// Report lists what a sync did, or would do for a dry run
+type Report struct {
+	DryRun  bool
+	Entries []Entry
+}
+
+// Sync reconciles the local database with the API and records the result
+// in state.  Changes that fail are reported in their entry and tried
+// again by the next sync.  If the API stops answering the sync stops too,
+// the changes made until then are kept in state.
+func Sync(local Local, api Remote, state *State, opts Options) (Report, error) {
+	if opts.Strategy == "" {
+		opts.Strategy = LastWriterWins
+	}
+	if opts.Strategy == Interactive && opts.Ask == nil {
+		return Report{}, errors.New("the interactive strategy needs a way to ask")
+	}
+
+	localItems, err := local.GetAllItems()
+	if err != nil {
+		return Report{}, err
+	}
+	remoteItems, err := api.GetAllItems()
+	if err != nil {
+		return Report{}, err
+	}
+
+	//a side that lost every item, eg a fresh redis behind the API, looks
+	//like someone deleted them all, which we would copy to the other side
+	if len(state.Links) > 0 && (len(localItems) == 0 || len(remoteItems) == 0) {
+		side := "the todo API"
+		if len(localItems) == 0 {
+			side = "the database"
+		}
+		return Report{}, fmt.Errorf("%s has none of the %d items synced before, refusing to delete them on the other side.  Delete the items there instead, or remove the sync state to start over", side, len(state.Links))
+	}
+
+	p := newPlanner(localItems, remoteItems)
+	report := Report{DryRun: opts.DryRun}
+	report.Entries, err = p.plan(state, opts)
+	if err != nil || opts.DryRun {
+		return report, err
+	}
+
+	state.Links, err = applyAll(local, api, report.Entries)
+	if err != nil {
+		return report, err
+	}
+	state.LastSync = time.Now().UTC()
+	return report, nil
+}
+
+// applyAll makes the changes of the entries and returns the links to keep
+// for the next sync.  The parent and the blockers of an item are ids of
+// other items, which a new item only has on the other side once it is
+// created there.  So the new items are created first, without them, and
+// the rest of the changes, the relations of the new items among them,
+// follow once every item has its ids.
+func applyAll(local Local, api Remote, entries []Entry) ([]Link, error) {
+	type result struct {
+		link       Link
+		keep, done bool
+	}
+	results := make([]result, len(entries))
+	ids := newIdMap(entries)
+
+	//the links of the items that were changed, and the old links of
+	//everything that was not
+	links := func() []Link {
+		var links []Link
+		for i, r := range results {
+			switch {
+			case r.done && r.keep:
+				links = append(links, r.link)
+			case !r.done && entries[i].link != nil:
+				links = append(links, *entries[i].link)
+			}
+		}
+		return links
+	}
+
+	for _, creating := range []bool{true, false} {
+		for i := range entries {
+			e := &entries[i]
+			if creating && !isCreation(e.Action) {
+				continue
+			}
+
+			var link Link
+			var keep bool
+			var err error
+			if creating {
+				link, keep, err = apply(local, api, e, ids)
+			} else if r := results[i]; r.done {
+				if e.Err != nil {
+					continue
+				}
+				link, keep = r.link, r.keep
+				link, err = relate(local, api, e, link, ids)
+			} else {
+				link, keep, err = apply(local, api, e, ids)
+			}
+
+			if err != nil {
+				e.Err = err
+				switch {
+				case keep:
+					//created, but without its relations, the next sync
+					//copies them again
+				case e.link != nil:
+					link, keep = *e.link, true
+				case e.Local != nil && e.Remote != nil:
+					//paired by id on the first sync, keep the pair so the
+					//next sync does not take them for two new items
+					link = Link{Local: e.LocalId, Remote: e.RemoteId, Conflict: true}
+					keep = true
+				}
+			}
+			results[i] = result{link: link, keep: keep, done: true}
+
+			if errors.Is(err, remote.ErrUnavailable) {
+				return links(), err
+			}
+		}
+	}
+	return links(), nil
+}
+
+func isCreation(action Action) bool {
+	return action == ActionPushNew || action == ActionPullNew
+}
+
+// idMap pairs the ids that an item has on both sides
+type idMap struct {
+	toRemote map[int]int
+	toLocal  map[int]int
+}
+
+// newIdMap pairs the ids of the items that are on both sides, and stay
+// there.  New items are paired once they are created.
+func newIdMap(entries []Entry) idMap {
+	ids := idMap{toRemote: map[int]int{}, toLocal: map[int]int{}}
+	for _, e := range entries {
+		switch e.Action {
+		case ActionUnchanged, ActionSame, ActionPush, ActionPull, ActionSkip:
+			ids.pair(e.LocalId, e.RemoteId)
+		}
+	}
+	return ids
+}
+
+func (ids idMap) pair(localId int, remoteId int) {
+	ids.toRemote[localId] = remoteId
+	ids.toLocal[remoteId] = localId
+}
+
+type planner struct {
+	local    map[int]db.ToDoItem
+	remote   map[int]db.ToDoItem
+	ids      idMap        // the items that are linked, to compare relations
+	takenIds map[int]bool // local ids that are in use, or will be
+	nextId   int
+}
+
+func newPlanner(localItems []db.ToDoItem, remoteItems []db.ToDoItem) *planner {
+	p := &planner{
+		local:    map[int]db.ToDoItem{},
+		remote:   map[int]db.ToDoItem{},
+		ids:      idMap{toRemote: map[int]int{}, toLocal: map[int]int{}},
+		takenIds: map[int]bool{},
+		nextId:   1,
+	}
+	for _, item := range localItems {
+		p.local[item.Id] = item
+		p.takenIds[item.Id] = true
+		if item.Id >= p.nextId {
+			p.nextId = item.Id + 1
+		}
+	}
+	for _, item := range remoteItems {
+		p.remote[item.Id] = item
+	}
+	return p
+}
+
+// sameContent tells if l and r have the same title, status, parent and
+// blockers
+func (p *planner) sameContent(l db.ToDoItem, r db.ToDoItem) bool {
+	if !l.SameContent(r) {
+		return false
+	}
+	pulled := withContent(l, r, p.ids.toLocal)
+	if pulled.ParentId != l.ParentId || len(pulled.BlockedBy) != len(l.BlockedBy) {
+		return false
+	}
+	for i, blocker := range pulled.BlockedBy {
+		if blocker != l.BlockedBy[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// localId picks the local id for an item pulled from the API, the
+// preferred one if it is free
+func (p *planner) localId(preferred int) int {
+	if preferred > 0 && !p.takenIds[preferred] {
+		p.takenIds[preferred] = true
+		if preferred >= p.nextId {
+			p.nextId = preferred + 1
+		}
+		return preferred
+	}
+	for p.takenIds[p.nextId] {
+		p.nextId++
+	}
+	p.takenIds[p.nextId] = true
+	return p.nextId
+}
+
+func (p *planner) plan(state *State, opts Options) ([]Entry, error) {
+	var entries []Entry
+	linkedLocal := map[int]bool{}
+	linkedRemote := map[int]bool{}
+
+	links := append([]Link{}, state.Links...)
+	sort.Slice(links, func(i, j int) bool { return links[i].Local < links[j].Local })
+	for _, link := range links {
+		p.ids.pair(link.Local, link.Remote)
+	}
+	firstSync := state.firstSync()
+	if firstSync {
+		for id := range p.local {
+			if _, ok := p.remote[id]; ok {
+				p.ids.pair(id, id)
+			}
+		}
+	}
+
+	for i := range links {
+		link := &links[i]
+		linkedLocal[link.Local] = true
+		linkedRemote[link.Remote] = true
+
+		e := Entry{LocalId: link.Local, RemoteId: link.Remote, link: link}
+		l, lok := p.local[link.Local]
+		r, rok := p.remote[link.Remote]
+		if lok {
+			e.Local = &l
+		}
+		if rok {
+			e.Remote = &r
+		}
+		localChanged := link.Conflict || !lok || l.Version != link.LocalVersion
+		remoteChanged := link.Conflict || !rok || r.Version != link.RemoteVersion
+
+		switch {
+		case !lok && !rok:
+			e.Action = ActionForget
+		case !localChanged && !remoteChanged:
+			e.Action = ActionUnchanged
+		case localChanged && !remoteChanged && lok:
+			e.Action = ActionPush
+		case localChanged && !remoteChanged:
+			e.Action = ActionDeleteRemote
+		case !localChanged && remoteChanged && rok:
+			e.Action = ActionPull
+		case !localChanged && remoteChanged:
+			e.Action = ActionDeleteLocal
+		case lok && rok && p.sameContent(l, r):
+			e.Action = ActionSame
+		default:
+			if err := p.resolve(&e, opts); err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	//items that are new since the last sync.  The first time a database
+	//is synced nothing is linked yet, then items with the same id on both
+	//sides are taken to be the same item.
+	for _, l := range sortedItems(p.local) {
+		if linkedLocal[l.Id] {
+			continue
+		}
+		l := l
+		e := Entry{LocalId: l.Id, Local: &l, Action: ActionPushNew}
+		if r, ok := p.remote[l.Id]; ok && firstSync && !linkedRemote[l.Id] {
+			linkedRemote[l.Id] = true
+			e.RemoteId, e.Remote = r.Id, &r
+			e.Action = ActionSame
+			if !p.sameContent(l, r) {
+				if err := p.resolve(&e, opts); err != nil {
+					return nil, err
+				}
+			}
+		}
+		entries = append(entries, e)
+	}
+	for _, r := range sortedItems(p.remote) {
+		if linkedRemote[r.Id] {
+			continue
+		}
+		r := r
+		entries = append(entries, Entry{LocalId: p.localId(r.Id), RemoteId: r.Id, Remote: &r, Action: ActionPullNew})
+	}
+	return entries, nil
+}
+
+// resolve picks the action for an item that changed on both sides
+func (p *planner) resolve(e *Entry, opts Options) error {
+	e.Conflict = true
+	var err error
+	switch opts.Strategy {
+	case PreferLocal:
+		e.Resolution = KeepLocal
+	case PreferRemote:
+		e.Resolution = KeepRemote
+	case Interactive:
+		e.Resolution, err = opts.Ask(Conflict{Local: e.Local, Remote: e.Remote})
+		if err != nil {
+			return err
+		}
+	default:
+		//a deletion has no time, so an edit always wins over it
+		switch {
+		case e.Local == nil:
+			e.Resolution = KeepRemote
+		case e.Remote == nil:
+			e.Resolution = KeepLocal
+		case e.Local.UpdatedAt.After(e.Remote.UpdatedAt):
+			e.Resolution = KeepLocal
+		default:
+			e.Resolution = KeepRemote
+		}
+	}
+
+	switch {
+	case e.Resolution == KeepLocal && e.Local == nil:
+		e.Action = ActionDeleteRemote
+	case e.Resolution == KeepLocal && e.Remote == nil:
+		e.Action = ActionPushNew
+		e.RemoteId = 0
+	case e.Resolution == KeepLocal:
+		e.Action = ActionPush
+	case e.Resolution == KeepRemote && e.Remote == nil:
+		e.Action = ActionDeleteLocal
+	case e.Resolution == KeepRemote && e.Local == nil:
+		e.Action = ActionPullNew
+		e.LocalId = p.localId(e.LocalId)
+	case e.Resolution == KeepRemote:
+		e.Action = ActionPull
+	default:
+		e.Resolution = Skip
+		e.Action = ActionSkip
+	}
+	return nil
+}
+
+// apply makes the change of one entry.  It returns the link to keep for
+// the next sync, keep is false if the item is gone on both sides.  A new
+// item is created without its parent and blockers, see applyAll.
+func apply(local Local, api Remote, e *Entry, ids idMap) (link Link, keep bool, err error) {
+	link = Link{Local: e.LocalId, Remote: e.RemoteId}
+	if e.Local != nil {
+		link.LocalVersion = e.Local.Version
+	}
+	if e.Remote != nil {
+		link.RemoteVersion = e.Remote.Version
+	}
+
+	switch e.Action {
+	case ActionUnchanged, ActionSame:
+		return link, true, nil
+	case ActionForget:
+		return link, false, nil
+	case ActionSkip:
+		if e.link != nil {
+			link = *e.link
+		}
+		link.Conflict = true
+		return link, true, nil
+
+	case ActionPush:
+		item := withContent(*e.Remote, *e.Local, ids.toRemote)
+		//the version we saw, so a change made in the API meanwhile is
+		//refused instead of overwritten
+		updated, err := api.Put(item)
+		if err != nil {
+			return link, false, err
+		}
+		link.RemoteVersion = updated.Version
+		return link, true, nil
+	case ActionPushNew:
+		item := withContent(db.ToDoItem{}, *e.Local, nil)
+		created, err := api.Create(item, remote.NewIdempotencyKey())
+		if err != nil {
+			return link, false, err
+		}
+		e.RemoteId = created.Id
+		ids.pair(e.LocalId, created.Id)
+		link.Remote, link.RemoteVersion = created.Id, created.Version
+		return link, true, nil
+	case ActionDeleteRemote:
+		//the subtasks have entries of their own, keep them for those
+		if err := api.Remove(e.RemoteId, db.CascadeOrphan); err != nil && !errors.Is(err, db.ErrNotFound) {
+			return link, false, err
+		}
+		return link, false, nil
+
+	case ActionPull:
+		item := withContent(*e.Local, *e.Remote, ids.toLocal)
+		if err := local.UpdateItem(item); err != nil {
+			return link, false, err
+		}
+		return relinkLocal(local, link)
+	case ActionPullNew:
+		item := withContent(db.ToDoItem{Id: e.LocalId}, *e.Remote, nil)
+		if err := local.AddItem(item); err != nil {
+			return link, false, err
+		}
+		ids.pair(e.LocalId, e.RemoteId)
+		return relinkLocal(local, link)
+	case ActionDeleteLocal:
+		if err := local.DeleteItem(e.LocalId, db.CascadeOrphan); err != nil && !errors.Is(err, db.ErrNotFound) {
+			return link, false, err
+		}
+		return link, false, nil
+	}
+	return link, false, fmt.Errorf("unknown action %q", e.Action)
+}
+
+// relinkLocal reads the version the local store gave the item
+func relinkLocal(local Local, link Link) (Link, bool, error) {
+	item, err := local.GetItem(link.Local)
+	if err != nil {
+		return link, false, err
+	}
+	link.LocalVersion = item.Version
+	return link, true, nil
+}
+
+// relate copies the parent and the blockers of an item that apply
+// created.  If that fails the version of the new item is dropped from the
+// link, so the next sync takes it for changed and copies them again.
+func relate(local Local, api Remote, e *Entry, link Link, ids idMap) (Link, error) {
+	switch {
+	case e.Action == ActionPushNew && hasRelations(*e.Local):
+		item := withContent(db.ToDoItem{Id: link.Remote, Version: link.RemoteVersion}, *e.Local, ids.toRemote)
+		updated, err := api.Put(item)
+		if err != nil {
+			link.LocalVersion = 0
+			return link, err
+		}
+		link.RemoteVersion = updated.Version
+	case e.Action == ActionPullNew && hasRelations(*e.Remote):
+		item, err := local.GetItem(link.Local)
+		if err == nil {
+			err = local.UpdateItem(withContent(item, *e.Remote, ids.toLocal))
+		}
+		if err == nil {
+			link, _, err = relinkLocal(local, link)
+		}
+		if err != nil {
+			link.RemoteVersion = 0
+			return link, err
+		}
+	}
+	return link, nil
+}
+
+func hasRelations(item db.ToDoItem) bool {
+	return item.ParentId != 0 || len(item.BlockedBy) > 0
+}
+
+// withContent returns item with the title, the status, the parent and the
+// blockers of from.  The parent and the blockers are ids on the side of
+// from, ids maps them to the side of item.  Those that have no item there
+// are left out.
+func withContent(item db.ToDoItem, from db.ToDoItem, ids map[int]int) db.ToDoItem {
+	item.Title = from.Title
+	item.IsDone = from.IsDone
+	item.ParentId = ids[from.ParentId]
+	item.BlockedBy = nil
+	for _, blocker := range from.BlockedBy {
+		if id, ok := ids[blocker]; ok {
+			item.BlockedBy = append(item.BlockedBy, id)
+		}
+	}
+	return item
+}
+
+func sortedItems(items map[int]db.ToDoItem) []db.ToDoItem {
+	sorted := make([]db.ToDoItem, 0, len(items))
+	for _, item := range items {
+		sorted = append(sorted, item)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Id < sorted[j].Id })
+	return sorted
+}
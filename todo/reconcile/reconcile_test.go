@@ -0,0 +1,404 @@
+package reconcile_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/reconcile"
+)
+
+// fakeAPI is the todo API in memory.  It picks its own ids for new items,
+// starting at 100, and checks versions and relations like the API does.
+type fakeAPI struct {
+	items  map[int]db.ToDoItem
+	nextId int
+}
+
+func newFakeAPI() *fakeAPI {
+	return &fakeAPI{items: map[int]db.ToDoItem{}, nextId: 100}
+}
+
+// add stores item under its own id, the way another client of the API
+// would have
+func (a *fakeAPI) add(item db.ToDoItem) {
+	item.Version = 1
+	item.UpdatedAt = time.Now().UTC()
+	a.items[item.Id] = item
+}
+
+func (a *fakeAPI) GetAllItems() ([]db.ToDoItem, error) {
+	items := make([]db.ToDoItem, 0, len(a.items))
+	for _, item := range a.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	return items, nil
+}
+
+func (a *fakeAPI) Create(item db.ToDoItem, idempotencyKey string) (db.ToDoItem, error) {
+	item.Id = a.nextId
+	if err := a.checkRelations(item); err != nil {
+		return db.ToDoItem{}, err
+	}
+	a.nextId++
+	a.add(item)
+	return a.items[item.Id], nil
+}
+
+func (a *fakeAPI) Put(item db.ToDoItem) (db.ToDoItem, error) {
+	old, ok := a.items[item.Id]
+	if !ok {
+		return db.ToDoItem{}, db.ErrNotFound
+	}
+	if item.Version != 0 && item.Version != old.Version {
+		return db.ToDoItem{}, db.ErrConflict
+	}
+	if err := a.checkRelations(item); err != nil {
+		return db.ToDoItem{}, err
+	}
+	item.Version = old.Version + 1
+	item.UpdatedAt = time.Now().UTC()
+	a.items[item.Id] = item
+	return item, nil
+}
+
+func (a *fakeAPI) Remove(id int, cascade db.Cascade) error {
+	removed, ok := a.items[id]
+	if !ok {
+		return db.ErrNotFound
+	}
+	delete(a.items, id)
+	for _, item := range a.items {
+		if item.ParentId == id {
+			item.ParentId = removed.ParentId
+		}
+		var blockers []int
+		for _, blocker := range item.BlockedBy {
+			if blocker != id {
+				blockers = append(blockers, blocker)
+			}
+		}
+		item.BlockedBy = blockers
+		a.items[item.Id] = item
+	}
+	return nil
+}
+
+func (a *fakeAPI) checkRelations(item db.ToDoItem) error {
+	for _, id := range append([]int{item.ParentId}, item.BlockedBy...) {
+		if _, ok := a.items[id]; id != 0 && !ok {
+			return fmt.Errorf("%w: item %d refers to %d, which does not exist", db.ErrValidation, item.Id, id)
+		}
+	}
+	return nil
+}
+
+func newLocal(t *testing.T) *db.ToDo {
+	t.Helper()
+	todo, err := db.New(filepath.Join(t.TempDir(), "todo.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return todo
+}
+
+// describe lists the items of a side, eg "2 b parent=1 blockedBy=[1]"
+func describe(t *testing.T, side interface{ GetAllItems() ([]db.ToDoItem, error) }) []string {
+	t.Helper()
+	items, err := side.GetAllItems()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		line := fmt.Sprintf("%d %s", item.Id, item.Title)
+		if item.IsDone {
+			line += " done"
+		}
+		if item.ParentId != 0 {
+			line += fmt.Sprintf(" parent=%d", item.ParentId)
+		}
+		if len(item.BlockedBy) > 0 {
+			line += fmt.Sprintf(" blockedBy=%v", item.BlockedBy)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name string
+		// local and remote are on both sides before the first sync
+		local  []db.ToDoItem
+		remote []db.ToDoItem
+		// change, if set, changes both sides after the first sync, then
+		// they are synced again with strategy
+		change      func(t *testing.T, local *db.ToDo, api *fakeAPI)
+		strategy    reconcile.Strategy
+		wantActions []reconcile.Action
+		wantLocal   []string
+		wantRemote  []string
+	}{
+		{
+			name:        "the first sync pairs the same ids and copies the new items",
+			local:       []db.ToDoItem{{Id: 1, Title: "a"}, {Id: 2, Title: "b"}},
+			remote:      []db.ToDoItem{{Id: 1, Title: "a"}, {Id: 5, Title: "c"}},
+			wantActions: []reconcile.Action{reconcile.ActionSame, reconcile.ActionPushNew, reconcile.ActionPullNew},
+			wantLocal:   []string{"1 a", "2 b", "5 c"},
+			wantRemote:  []string{"1 a", "5 c", "100 b"},
+		},
+		{
+			name:  "a local change is pushed",
+			local: []db.ToDoItem{{Id: 1, Title: "a"}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				update(t, local, db.ToDoItem{Id: 1, Title: "a2", IsDone: true})
+			},
+			wantActions: []reconcile.Action{reconcile.ActionPush},
+			wantLocal:   []string{"1 a2 done"},
+			wantRemote:  []string{"100 a2 done"},
+		},
+		{
+			name:  "a remote change is pulled",
+			local: []db.ToDoItem{{Id: 1, Title: "a"}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				put(t, api, db.ToDoItem{Id: 100, Title: "a2"})
+			},
+			wantActions: []reconcile.Action{reconcile.ActionPull},
+			wantLocal:   []string{"1 a2"},
+			wantRemote:  []string{"100 a2"},
+		},
+		{
+			name:  "deletions are repeated on the other side",
+			local: []db.ToDoItem{{Id: 1, Title: "a"}, {Id: 2, Title: "b"}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				if err := local.DeleteItem(1, db.CascadeOrphan); err != nil {
+					t.Fatal(err)
+				}
+				if err := api.Remove(101, db.CascadeOrphan); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantActions: []reconcile.Action{reconcile.ActionDeleteRemote, reconcile.ActionDeleteLocal},
+			wantLocal:   []string{},
+			wantRemote:  []string{},
+		},
+		{
+			name:  "prefer-local keeps the local side of a conflict",
+			local: []db.ToDoItem{{Id: 1, Title: "a"}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				update(t, local, db.ToDoItem{Id: 1, Title: "local"})
+				put(t, api, db.ToDoItem{Id: 100, Title: "remote"})
+			},
+			strategy:    reconcile.PreferLocal,
+			wantActions: []reconcile.Action{reconcile.ActionPush},
+			wantLocal:   []string{"1 local"},
+			wantRemote:  []string{"100 local"},
+		},
+		{
+			name:  "prefer-remote keeps the remote side of a conflict",
+			local: []db.ToDoItem{{Id: 1, Title: "a"}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				update(t, local, db.ToDoItem{Id: 1, Title: "local"})
+				put(t, api, db.ToDoItem{Id: 100, Title: "remote"})
+			},
+			strategy:    reconcile.PreferRemote,
+			wantActions: []reconcile.Action{reconcile.ActionPull},
+			wantLocal:   []string{"1 remote"},
+			wantRemote:  []string{"100 remote"},
+		},
+		{
+			name:  "the same change on both sides is no conflict",
+			local: []db.ToDoItem{{Id: 1, Title: "a"}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				update(t, local, db.ToDoItem{Id: 1, Title: "b"})
+				put(t, api, db.ToDoItem{Id: 100, Title: "b"})
+			},
+			strategy:    reconcile.PreferLocal,
+			wantActions: []reconcile.Action{reconcile.ActionSame},
+			wantLocal:   []string{"1 b"},
+			wantRemote:  []string{"100 b"},
+		},
+		{
+			name: "new local items are pushed with their relations",
+			local: []db.ToDoItem{
+				{Id: 1, Title: "parent"},
+				{Id: 3, Title: "blocker"},
+				{Id: 2, Title: "child", ParentId: 1, BlockedBy: []int{3}},
+			},
+			wantActions: []reconcile.Action{reconcile.ActionPushNew, reconcile.ActionPushNew, reconcile.ActionPushNew},
+			wantLocal:   []string{"1 parent", "2 child parent=1 blockedBy=[3]", "3 blocker"},
+			wantRemote:  []string{"100 parent", "101 child parent=100 blockedBy=[102]", "102 blocker"},
+		},
+		{
+			name:  "new remote items are pulled with their relations",
+			local: []db.ToDoItem{{Id: 1, Title: "mine"}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				api.add(db.ToDoItem{Id: 1, Title: "parent"})
+				api.add(db.ToDoItem{Id: 2, Title: "child", ParentId: 1, BlockedBy: []int{1}})
+			},
+			wantActions: []reconcile.Action{reconcile.ActionUnchanged, reconcile.ActionPullNew, reconcile.ActionPullNew},
+			wantLocal:   []string{"1 mine", "2 parent", "3 child parent=2 blockedBy=[2]"},
+			wantRemote:  []string{"1 parent", "2 child parent=1 blockedBy=[1]", "100 mine"},
+		},
+		{
+			name:  "a changed relation is pushed",
+			local: []db.ToDoItem{{Id: 1, Title: "parent"}, {Id: 2, Title: "child", ParentId: 1}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				update(t, local, db.ToDoItem{Id: 2, Title: "child", BlockedBy: []int{1}})
+			},
+			wantActions: []reconcile.Action{reconcile.ActionUnchanged, reconcile.ActionPush},
+			wantLocal:   []string{"1 parent", "2 child blockedBy=[1]"},
+			wantRemote:  []string{"100 parent", "101 child blockedBy=[100]"},
+		},
+		{
+			name:  "different relations on both sides are a conflict",
+			local: []db.ToDoItem{{Id: 1, Title: "parent"}, {Id: 2, Title: "child"}},
+			change: func(t *testing.T, local *db.ToDo, api *fakeAPI) {
+				update(t, local, db.ToDoItem{Id: 2, Title: "child", ParentId: 1})
+				put(t, api, db.ToDoItem{Id: 101, Title: "child", BlockedBy: []int{100}})
+			},
+			strategy:    reconcile.PreferRemote,
+			wantActions: []reconcile.Action{reconcile.ActionUnchanged, reconcile.ActionPull},
+			wantLocal:   []string{"1 parent", "2 child blockedBy=[1]"},
+			wantRemote:  []string{"100 parent", "101 child blockedBy=[100]"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			local := newLocal(t)
+			for _, item := range tt.local {
+				if err := local.AddItem(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			api := newFakeAPI()
+			for _, item := range tt.remote {
+				api.add(item)
+			}
+
+			state := &reconcile.State{}
+			report, err := reconcile.Sync(local, api, state, reconcile.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.change != nil {
+				tt.change(t, local, api)
+				report, err = reconcile.Sync(local, api, state, reconcile.Options{Strategy: tt.strategy})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var actions []reconcile.Action
+			for _, e := range report.Entries {
+				if e.Err != nil {
+					t.Errorf("%s %d: %v", e.Action, e.LocalId, e.Err)
+				}
+				actions = append(actions, e.Action)
+			}
+			if !reflect.DeepEqual(actions, tt.wantActions) {
+				t.Errorf("got actions %v, want %v", actions, tt.wantActions)
+			}
+			if got := describe(t, local); !reflect.DeepEqual(got, tt.wantLocal) {
+				t.Errorf("got local items %q, want %q", got, tt.wantLocal)
+			}
+			if got := describe(t, api); !reflect.DeepEqual(got, tt.wantRemote) {
+				t.Errorf("got remote items %q, want %q", got, tt.wantRemote)
+			}
+
+			//once synced, the next sync has nothing left to do
+			again, err := reconcile.Sync(local, api, state, reconcile.Options{DryRun: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range again.Entries {
+				if e.Action != reconcile.ActionUnchanged {
+					t.Errorf("the next sync would %s item %d", e.Action, e.LocalId)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncRefusesToEmptyASide(t *testing.T) {
+	local := newLocal(t)
+	if err := local.AddItem(db.ToDoItem{Id: 1, Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	api := newFakeAPI()
+	state := &reconcile.State{}
+	if _, err := reconcile.Sync(local, api, state, reconcile.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	//a fresh API has lost the item, it must not be deleted locally
+	_, err := reconcile.Sync(local, newFakeAPI(), state, reconcile.Options{})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	if _, err := local.GetItem(1); err != nil {
+		t.Fatalf("the local item is gone: %v", err)
+	}
+}
+
+func TestSyncInteractive(t *testing.T) {
+	local := newLocal(t)
+	if err := local.AddItem(db.ToDoItem{Id: 1, Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	api := newFakeAPI()
+	state := &reconcile.State{}
+	if _, err := reconcile.Sync(local, api, state, reconcile.Options{Strategy: reconcile.Interactive}); err == nil {
+		t.Fatal("interactive without Ask: got no error")
+	}
+	if _, err := reconcile.Sync(local, api, state, reconcile.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	update(t, local, db.ToDoItem{Id: 1, Title: "local"})
+	put(t, api, db.ToDoItem{Id: 100, Title: "remote"})
+
+	//a skipped conflict changes nothing and comes up again
+	asked := 0
+	skip := func(c reconcile.Conflict) (reconcile.Resolution, error) {
+		asked++
+		if c.Local == nil || c.Remote == nil || c.Local.Title != "local" || c.Remote.Title != "remote" {
+			t.Errorf("asked about %+v", c)
+		}
+		return reconcile.Skip, nil
+	}
+	for i := 0; i < 2; i++ {
+		report, err := reconcile.Sync(local, api, state, reconcile.Options{Strategy: reconcile.Interactive, Ask: skip})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Count(reconcile.ActionSkip) != 1 {
+			t.Fatalf("sync %d: got %s", i+1, report.Summary())
+		}
+	}
+	if asked != 2 {
+		t.Fatalf("asked %d times, want 2", asked)
+	}
+}
+
+// update changes a local item, keeping its version
+func update(t *testing.T, local *db.ToDo, item db.ToDoItem) {
+	t.Helper()
+	if err := local.UpdateItem(item); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// put changes an item in the API, the way another client would
+func put(t *testing.T, api *fakeAPI, item db.ToDoItem) {
+	t.Helper()
+	if _, err := api.Put(item); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,99 @@
+package reconcile
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"drexel.edu/todo/db"
+)
+
+// Count returns how many entries have action
+func (r Report) Count(action Action) int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// Failed returns how many changes failed
+func (r Report) Failed() int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.Err != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary counts the entries, eg "2 pushed, 1 pulled, ..."
+func (r Report) Summary() string {
+	conflicts := 0
+	for _, e := range r.Entries {
+		if e.Conflict {
+			conflicts++
+		}
+	}
+	return fmt.Sprintf("%d pushed, %d pulled, %d deleted remotely, %d deleted locally, %d unchanged, %d conflicts (%d skipped), %d failed",
+		r.Count(ActionPush)+r.Count(ActionPushNew), r.Count(ActionPull)+r.Count(ActionPullNew),
+		r.Count(ActionDeleteRemote), r.Count(ActionDeleteLocal),
+		r.Count(ActionUnchanged)+r.Count(ActionSame)+r.Count(ActionForget),
+		conflicts, r.Count(ActionSkip), r.Failed())
+}
+
+// WriteTo writes one line for every item the sync changed, or would
+// change, with the local and the remote id, eg
+// "pull           3  7   [x] Learn Go".  Items that were already the
+// same on both sides are left out.
+func (r Report) WriteTo(w io.Writer) (int64, error) {
+	var written int64
+	for _, e := range r.Entries {
+		if e.Action == ActionUnchanged || (e.Action == ActionSame && !e.Conflict) || e.Action == ActionForget {
+			continue
+		}
+
+		var item string
+		switch e.Action {
+		case ActionPush, ActionPushNew, ActionDeleteLocal:
+			item = describe(e.Local)
+		case ActionPull, ActionPullNew, ActionDeleteRemote:
+			item = describe(e.Remote)
+		case ActionSkip:
+			item = fmt.Sprintf("local %s, remote %s", describe(e.Local), describe(e.Remote))
+		}
+		if e.Conflict && e.Resolution != Skip {
+			item += fmt.Sprintf(" (conflict, kept %s)", e.Resolution)
+		}
+		if e.Err != nil {
+			item += fmt.Sprintf(" FAILED: %v", e.Err)
+		}
+
+		n, err := fmt.Fprintf(w, "%-14s %4s %4s   %s\n", e.Action, idOrNew(e.LocalId), idOrNew(e.RemoteId), item)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func idOrNew(id int) string {
+	if id == 0 {
+		return "new"
+	}
+	return strconv.Itoa(id)
+}
+
+func describe(item *db.ToDoItem) string {
+	switch {
+	case item == nil:
+		return "deleted"
+	case item.IsDone:
+		return "[x] " + item.Title
+	}
+	return "[ ] " + item.Title
+}
@@ -0,0 +1,92 @@
+package reconcile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Link pairs a local item with a remote item.  The ids differ when the
+// API picked a different id for an item that was created locally, or the
+// id of a remote item was already taken locally.  The versions are the
+// ones both items had after the last sync, an item whose version moved
+// on was changed since.
+type Link struct {
+	Local         int  `json:"local"`
+	Remote        int  `json:"remote"`
+	LocalVersion  int  `json:"localVersion"`
+	RemoteVersion int  `json:"remoteVersion"`
+	Conflict      bool `json:"conflict,omitempty"` // a skipped conflict, it comes up again
+}
+
+// State is what the last sync of a database with one todo API left
+// behind
+type State struct {
+	LastSync time.Time `json:"lastSync"`
+	Links    []Link    `json:"links"`
+}
+
+// firstSync tells if the database was never synced with this API, then
+// items with the same id on both sides are taken to be the same item
+func (s *State) firstSync() bool {
+	return s.LastSync.IsZero() && len(s.Links) == 0
+}
+
+// StateFile keeps the state of every API a database is synced with, keyed
+// by the url of the API.  It lives next to the database, eg
+// data/todo.json.sync.
+type StateFile struct {
+	fileName string
+	states   map[string]*State
+}
+
+// StateFileFor returns the name of the state file of a database file
+func StateFileFor(dbFileName string) string {
+	return dbFileName + ".sync"
+}
+
+// OpenStateFile reads fileName, a missing file means nothing was synced yet
+func OpenStateFile(fileName string) (*StateFile, error) {
+	f := &StateFile{fileName: fileName, states: map[string]*State{}}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return f, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.states); err != nil {
+		return nil, fmt.Errorf("the sync state %s is corrupt, remove it to sync from scratch: %w", fileName, err)
+	}
+	return f, nil
+}
+
+// State returns the state for the API at baseURL
+func (f *StateFile) State(baseURL string) *State {
+	s, ok := f.states[baseURL]
+	if !ok {
+		s = &State{}
+		f.states[baseURL] = s
+	}
+	return s
+}
+
+// Save writes the file to a temporary file and renames it over the old
+// one, so a crash never leaves a half written state behind
+func (f *StateFile) Save() error {
+	data, err := json.MarshalIndent(f.states, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.fileName), 0755); err != nil {
+		return err
+	}
+	tmp := f.fileName + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.fileName)
+}
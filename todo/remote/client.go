@@ -0,0 +1,318 @@
+// Package remote is a db.Store that keeps the items in the todo API
+// (todo-api-w-cache) instead of a local file, for "todo --remote".
+//
+// Changes that can not be sent because the API is not reachable are kept
+// in an offline queue, and sent in order the next time the API can be
+// reached, see Queue and Client.Flush.
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"validate"
+
+	"drexel.edu/todo/db"
+)
+
+// ErrUnavailable is returned when the todo API can not be reached, or
+// answers that it is not available right now.  Changes that fail with it
+// are queued instead of lost.
+var ErrUnavailable = errors.New("the todo api is not reachable")
+
+// ErrPending is returned by Create while the API is still handling an
+// earlier request with the same Idempotency-Key, eg one whose answer was
+// lost.  Retry later, the earlier request has created the item by then or
+// has given the key free.
+var ErrPending = errors.New("the todo api is still creating the item")
+
+// Client talks to the todo API at baseURL
+type Client struct {
+	baseURL string
+	http    *http.Client
+	queue   *Queue
+}
+
+var _ db.Store = (*Client)(nil)
+
+// New returns a client for the todo API at baseURL, eg
+// "http://localhost:1080".  Changes made while the API is not reachable
+// are queued in queueFile.
+func New(baseURL string, timeout time.Duration, queueFile string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("remote %q is not an http or https url", baseURL)
+	}
+	queue, err := OpenQueue(queueFile)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: timeout},
+		queue:   queue,
+	}, nil
+}
+
+// BaseURL is the url of the todo API
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Queue holds the changes that are waiting for the API
+func (c *Client) Queue() *Queue {
+	return c.queue
+}
+
+//------------------------------------------------------------
+// db.Store
+//------------------------------------------------------------
+
+// AddItem creates the item, the API assigns the id.  Use CreateItem to
+// learn the id.
+func (c *Client) AddItem(item db.ToDoItem) error {
+	_, err := c.CreateItem(item)
+	return err
+}
+
+// CreateItem creates the item and returns it with the id the API gave
+// it.  If the API is not reachable the change is queued and the item is
+// returned with id 0.
+func (c *Client) CreateItem(item db.ToDoItem) (db.ToDoItem, error) {
+	change := Change{Op: OpAdd, Item: &item, IdempotencyKey: NewIdempotencyKey()}
+	created, err := c.Create(item, change.IdempotencyKey)
+	if errors.Is(err, ErrUnavailable) {
+		item.Id = 0
+		return item, c.queue.Push(change)
+	}
+	return created, err
+}
+
+func (c *Client) UpdateItem(item db.ToDoItem) error {
+	_, err := c.Put(item)
+	if errors.Is(err, ErrUnavailable) {
+		return c.queue.Push(Change{Op: OpUpdate, Item: &item})
+	}
+	return err
+}
+
+func (c *Client) DeleteItem(id int, cascade db.Cascade) error {
+	err := c.Remove(id, cascade)
+	if errors.Is(err, ErrUnavailable) {
+		return c.queue.Push(Change{Op: OpDelete, Id: id, Cascade: cascade})
+	}
+	return err
+}
+
+// ChangeItemDoneStatus reads the item and writes it back with the new
+// status, the API has no call to change only the status
+func (c *Client) ChangeItemDoneStatus(id int, value bool) error {
+	err := c.setDone(id, value)
+	if errors.Is(err, ErrUnavailable) {
+		return c.queue.Push(Change{Op: OpSetDone, Id: id, Done: value})
+	}
+	return err
+}
+
+func (c *Client) GetItem(id int) (db.ToDoItem, error) {
+	var item db.ToDoItem
+	err := c.do(http.MethodGet, "/todo/"+strconv.Itoa(id), nil, nil, &item)
+	return item, err
+}
+
+func (c *Client) GetAllItems() ([]db.ToDoItem, error) {
+	items := []db.ToDoItem{}
+	err := c.do(http.MethodGet, "/todo", nil, nil, &items)
+	return items, err
+}
+
+func (c *Client) GetSubtasks(id int) ([]db.ToDoItem, error) {
+	items := []db.ToDoItem{}
+	err := c.do(http.MethodGet, "/todo/"+strconv.Itoa(id)+"/children", nil, nil, &items)
+	return items, err
+}
+
+func (c *Client) PrintItem(item db.ToDoItem) {
+	jsonBytes, _ := json.MarshalIndent(item, "", "  ")
+	fmt.Println(string(jsonBytes))
+}
+
+// JsonToItem parses and checks an item the same way db.ToDo does
+func (c *Client) JsonToItem(jsonString string) (db.ToDoItem, error) {
+	var item db.ToDoItem
+	if err := json.Unmarshal([]byte(jsonString), &item); err != nil {
+		return db.ToDoItem{}, err
+	}
+	if err := db.ValidateItem(item); err != nil {
+		return db.ToDoItem{}, err
+	}
+	return item, nil
+}
+
+//------------------------------------------------------------
+// THE CALLS TO THE API, SHARED BY THE STORE AND THE QUEUE
+//------------------------------------------------------------
+
+// Create, Put and Remove send a change right away and never queue it, for
+// callers that must know if it happened, like "todo sync"
+
+// Create adds item under the id the API picks.  A retry with the same
+// idempotencyKey returns the item created the first time, or fails with
+// ErrPending while the first request is still running.
+func (c *Client) Create(item db.ToDoItem, idempotencyKey string) (db.ToDoItem, error) {
+	var created db.ToDoItem
+	header := http.Header{"Idempotency-Key": {idempotencyKey}}
+	err := c.do(http.MethodPost, "/todo", header, item, &created)
+	//the API answers 409 for a key that is taken, it only tells this
+	//apart from the other conflicts of a new item in the detail
+	if errors.Is(err, db.ErrConflict) && strings.Contains(err.Error(), "Idempotency-Key") {
+		return db.ToDoItem{}, fmt.Errorf("%w: %v", ErrPending, err)
+	}
+	return created, err
+}
+
+// Put replaces the item and returns it with its new version.  If
+// item.Version is not 0 and the item changed since, the API refuses with
+// db.ErrConflict.
+func (c *Client) Put(item db.ToDoItem) (db.ToDoItem, error) {
+	var updated db.ToDoItem
+	err := c.do(http.MethodPut, "/todo", nil, item, &updated)
+	return updated, err
+}
+
+// Remove deletes the item, cascade says what happens to its subtasks
+func (c *Client) Remove(id int, cascade db.Cascade) error {
+	path := "/todo/" + strconv.Itoa(id)
+	if cascade != "" {
+		path += "?cascade=" + url.QueryEscape(string(cascade))
+	}
+	return c.do(http.MethodDelete, path, nil, nil, nil)
+}
+
+func (c *Client) setDone(id int, value bool) error {
+	item, err := c.GetItem(id)
+	if err != nil {
+		return err
+	}
+	item.IsDone = value
+	_, err = c.Put(item)
+	return err
+}
+
+// do sends one request.  body, if not nil, is sent as json, and a
+// successful response is decoded into out, if not nil.  Failures are
+// turned into the errors of the db package, see apiError.
+func (c *Client) do(method string, path string, header http.Header, body any, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return transportError(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return transportError(err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return apiError(method, path, resp.StatusCode, data)
+	}
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("%s %s: unexpected response: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+// transportError wraps network failures, eg connection refused, a timeout
+// or an unknown host, in ErrUnavailable
+func transportError(err error) error {
+	var netErr net.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &netErr) || errors.As(err, &opErr) || errors.As(err, &dnsErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	return err
+}
+
+// problem is the RFC 7807 error body of the todo API
+type problem struct {
+	Title  string                `json:"title"`
+	Detail string                `json:"detail"`
+	Errors []validate.FieldError `json:"errors"`
+}
+
+// apiError maps an error response to the errors of the db package, so the
+// CLI can treat a remote store like a local one:
+//
+//	404      db.ErrNotFound
+//	409      db.ErrConflict
+//	400, 422 db.ErrValidation
+//	502-504  ErrUnavailable, the change is queued
+func apiError(method string, path string, status int, body []byte) error {
+	var p problem
+	detail := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &p) == nil && (p.Detail != "" || p.Title != "") {
+		detail = p.Detail
+		if detail == "" {
+			detail = p.Title
+		}
+	}
+	if detail == "" {
+		detail = http.StatusText(status)
+	}
+
+	switch status {
+	case http.StatusNotFound:
+		return wrap(db.ErrNotFound, detail)
+	case http.StatusConflict:
+		return wrap(db.ErrConflict, detail)
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		if len(p.Errors) > 0 {
+			return &validate.ValidationError{Fields: p.Errors}
+		}
+		return wrap(db.ErrValidation, detail)
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return fmt.Errorf("%w: %s %s returned %d", ErrUnavailable, method, path, status)
+	}
+	return fmt.Errorf("%s %s returned %d: %s", method, path, status, detail)
+}
+
+// wrap adds detail to err, unless the API already said the same thing
+func wrap(err error, detail string) error {
+	if detail == err.Error() {
+		return err
+	}
+	return fmt.Errorf("%w: %s", err, detail)
+}
@@ -0,0 +1,102 @@
+package remote
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is how long a request to the todo API may take, unless a
+// profile says otherwise
+const DefaultTimeout = 5 * time.Second
+
+// Config is the todo CLI config file, a json object with named profiles:
+//
+//	{
+//	  "default": "team",
+//	  "profiles": {
+//	    "team":  {"remote": "http://todo.example.com:1080", "timeout": "10s"},
+//	    "local": {"db": "./data/todo.json"}
+//	  }
+//	}
+//
+// A profile either names a todo API with remote, or a database file with
+// db.  The default profile is used when no --profile is given.
+type Config struct {
+	Default  string             `json:"default,omitempty"`
+	Profiles map[string]Profile `json:"profiles,omitempty"`
+}
+
+type Profile struct {
+	Remote  string `json:"remote,omitempty"`
+	DB      string `json:"db,omitempty"`
+	Timeout string `json:"timeout,omitempty"` // eg "10s", DefaultTimeout if empty
+}
+
+// DefaultConfigFile is todo/config.json in the user's config directory,
+// eg ~/.config/todo/config.json on linux
+func DefaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(".", "todo-config.json")
+	}
+	return filepath.Join(dir, "todo", "config.json")
+}
+
+// LoadConfig reads the config file, a missing file is an empty config
+func LoadConfig(fileName string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return cfg, nil
+}
+
+// Profile returns the named profile, or the default profile if name is
+// empty.  Without a name or a default there is no profile, which is not
+// an error.
+func (c Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = c.Default
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return p, fmt.Errorf("there is no profile %q in the config file, the profiles are: %s", name, strings.Join(names, ", "))
+	}
+	if p.Remote != "" && p.DB != "" {
+		return p, fmt.Errorf("profile %q has both a remote and a db, it can only have one", name)
+	}
+	return p, nil
+}
+
+// TimeoutOrDefault parses Timeout
+func (p Profile) TimeoutOrDefault() (time.Duration, error) {
+	if p.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	d, err := time.ParseDuration(p.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("profile timeout: %w", err)
+	}
+	return d, nil
+}
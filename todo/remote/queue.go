@@ -0,0 +1,202 @@
+package remote
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"drexel.edu/todo/db"
+)
+
+type Op string
+
+const (
+	OpAdd     Op = "add"
+	OpUpdate  Op = "update"
+	OpDelete  Op = "delete"
+	OpSetDone Op = "done"
+)
+
+// Change is one change that is waiting for the todo API
+type Change struct {
+	Op             Op           `json:"op"`
+	Item           *db.ToDoItem `json:"item,omitempty"`
+	Id             int          `json:"id,omitempty"`
+	Done           bool         `json:"done,omitempty"`
+	Cascade        db.Cascade   `json:"cascade,omitempty"`
+	IdempotencyKey string       `json:"idempotencyKey,omitempty"`
+	QueuedAt       time.Time    `json:"queuedAt"`
+}
+
+func (c Change) String() string {
+	switch c.Op {
+	case OpAdd:
+		return fmt.Sprintf("add %q", c.Item.Title)
+	case OpUpdate:
+		return fmt.Sprintf("update %d to %q (done %t)", c.Item.Id, c.Item.Title, c.Item.IsDone)
+	case OpDelete:
+		if c.Cascade != "" && c.Cascade != db.CascadeRestrict {
+			return fmt.Sprintf("delete %d (cascade %s)", c.Id, c.Cascade)
+		}
+		return fmt.Sprintf("delete %d", c.Id)
+	case OpSetDone:
+		return fmt.Sprintf("set %d done %t", c.Id, c.Done)
+	}
+	return string(c.Op)
+}
+
+// Queue is the offline queue, the changes that could not be sent to the
+// todo API yet, oldest first.  It is kept in a json file, which is
+// rewritten as a whole on every change.
+type Queue struct {
+	fileName string
+	changes  []Change
+}
+
+// DefaultQueueFile is the queue for the todo API at baseURL, in the
+// user's config directory next to the config file.  Every API has its own
+// queue, so switching profiles does not send changes to the wrong one.
+func DefaultQueueFile(baseURL string) string {
+	name := baseURL
+	if u, err := url.Parse(baseURL); err == nil && u.Host != "" {
+		name = u.Host + u.Path
+	}
+	name = strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, strings.TrimSuffix(name, "/"))
+	return filepath.Join(filepath.Dir(DefaultConfigFile()), "queue", name+".json")
+}
+
+// OpenQueue reads the queue in fileName, a missing file is an empty queue
+func OpenQueue(fileName string) (*Queue, error) {
+	q := &Queue{fileName: fileName}
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return q, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &q.changes); err != nil {
+		return nil, fmt.Errorf("the offline queue %s is corrupt: %w", fileName, err)
+	}
+	return q, nil
+}
+
+// Pending returns the changes waiting for the API, oldest first
+func (q *Queue) Pending() []Change {
+	return append([]Change{}, q.changes...)
+}
+
+// Push adds a change to the end of the queue
+func (q *Queue) Push(c Change) error {
+	if c.QueuedAt.IsZero() {
+		c.QueuedAt = time.Now().UTC()
+	}
+	q.changes = append(q.changes, c)
+	return q.save()
+}
+
+// save writes the queue to a temporary file and renames it over the old
+// one, so a crash never leaves a half written queue behind.  An empty
+// queue removes the file.
+func (q *Queue) save() error {
+	if len(q.changes) == 0 {
+		err := os.Remove(q.fileName)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(q.changes, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(q.fileName), 0755); err != nil {
+		return err
+	}
+	tmp := q.fileName + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, q.fileName)
+}
+
+// Rejected is a queued change the API refused, eg an update of an item
+// that was deleted in the meantime.  It is dropped from the queue.
+type Rejected struct {
+	Change Change
+	Err    error
+}
+
+// Flush sends the queued changes to the API in the order they were made.
+// It stops at the first change that fails with ErrUnavailable, or with
+// ErrPending because the API is still working on an earlier attempt, and
+// keeps it and the rest for the next time.  Changes the API rejects are dropped and
+// returned, so they can be reported.  sent is how many changes went
+// through.
+func (c *Client) Flush() (sent int, rejected []Rejected, err error) {
+	q := c.queue
+	for len(q.changes) > 0 {
+		change := q.changes[0]
+		err := c.send(change)
+		if errors.Is(err, ErrUnavailable) || errors.Is(err, ErrPending) {
+			return sent, rejected, err
+		}
+		if err != nil {
+			rejected = append(rejected, Rejected{Change: change, Err: err})
+		} else {
+			sent++
+		}
+
+		q.changes = q.changes[1:]
+		if err := q.save(); err != nil {
+			return sent, rejected, err
+		}
+	}
+	return sent, rejected, nil
+}
+
+func (c *Client) send(change Change) error {
+	switch change.Op {
+	case OpAdd:
+		if change.Item == nil {
+			return fmt.Errorf("queued %s has no item", change.Op)
+		}
+		//the same key as the first attempt, so an add that reached the API
+		//before the connection broke is not made twice
+		_, err := c.Create(*change.Item, change.IdempotencyKey)
+		return err
+	case OpUpdate:
+		if change.Item == nil {
+			return fmt.Errorf("queued %s has no item", change.Op)
+		}
+		_, err := c.Put(*change.Item)
+		return err
+	case OpDelete:
+		return c.Remove(change.Id, change.Cascade)
+	case OpSetDone:
+		return c.setDone(change.Id, change.Done)
+	}
+	return fmt.Errorf("unknown queued change %q", change.Op)
+}
+
+// NewIdempotencyKey returns a random key for Create
+func NewIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("todo-cli-%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
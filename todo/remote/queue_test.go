@@ -0,0 +1,162 @@
+package remote_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"drexel.edu/todo/db"
+	"drexel.edu/todo/remote"
+)
+
+// answer is what the fake API says to one request
+type answer struct {
+	status int
+	detail string
+}
+
+// fakeAPI answers the requests it gets with the answers for their method
+// and path, 200 with an empty item for the rest, and records them as
+// "METHOD /path"
+type fakeAPI struct {
+	answers  map[string]answer
+	requests []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request := r.Method + " " + r.URL.Path
+	f.requests = append(f.requests, request)
+	a, ok := f.answers[request]
+	if !ok {
+		json.NewEncoder(w).Encode(db.ToDoItem{Id: 1})
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(a.status)
+	json.NewEncoder(w).Encode(map[string]any{"title": http.StatusText(a.status), "detail": a.detail})
+}
+
+var queued = []remote.Change{
+	{Op: remote.OpAdd, Item: &db.ToDoItem{Title: "new"}, IdempotencyKey: "k1"},
+	{Op: remote.OpDelete, Id: 2},
+	{Op: remote.OpUpdate, Item: &db.ToDoItem{Id: 3, Title: "changed"}},
+}
+
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		name         string
+		answers      map[string]answer
+		wantSent     int
+		wantRejected int
+		wantErr      error
+		wantKept     int
+		wantRequests []string
+	}{
+		{
+			name:         "all sent",
+			wantSent:     3,
+			wantRequests: []string{"POST /todo", "DELETE /todo/2", "PUT /todo"},
+		},
+		{
+			name:         "a rejected change is dropped",
+			answers:      map[string]answer{"DELETE /todo/2": {http.StatusNotFound, "item 2 does not exist"}},
+			wantSent:     2,
+			wantRejected: 1,
+			wantRequests: []string{"POST /todo", "DELETE /todo/2", "PUT /todo"},
+		},
+		{
+			name:         "an unavailable api keeps the rest",
+			answers:      map[string]answer{"DELETE /todo/2": {http.StatusServiceUnavailable, "down"}},
+			wantSent:     1,
+			wantErr:      remote.ErrUnavailable,
+			wantKept:     2,
+			wantRequests: []string{"POST /todo", "DELETE /todo/2"},
+		},
+		{
+			name:         "an add the api is still creating is kept",
+			answers:      map[string]answer{"POST /todo": {http.StatusConflict, "conflict: a request with this Idempotency-Key is still running"}},
+			wantErr:      remote.ErrPending,
+			wantKept:     3,
+			wantRequests: []string{"POST /todo"},
+		},
+		{
+			name:         "another conflict of an add is rejected",
+			answers:      map[string]answer{"POST /todo": {http.StatusConflict, "item 1 is blocked"}},
+			wantSent:     2,
+			wantRejected: 1,
+			wantRequests: []string{"POST /todo", "DELETE /todo/2", "PUT /todo"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeAPI{answers: tt.answers}
+			server := httptest.NewServer(api)
+			defer server.Close()
+			queueFile := filepath.Join(t.TempDir(), "queue.json")
+			client, err := remote.New(server.URL, time.Second, queueFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range queued {
+				if err := client.Queue().Push(c); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			sent, rejected, err := client.Flush()
+			if sent != tt.wantSent || len(rejected) != tt.wantRejected {
+				t.Errorf("sent %d and rejected %d, want %d and %d", sent, len(rejected), tt.wantSent, tt.wantRejected)
+			}
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(api.requests, tt.wantRequests) {
+				t.Errorf("got the requests %q, want %q", api.requests, tt.wantRequests)
+			}
+
+			//the queue file keeps the changes that were not sent, oldest first
+			reopened, err := remote.OpenQueue(queueFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept := reopened.Pending()
+			if len(kept) != tt.wantKept {
+				t.Fatalf("kept %d changes, want %d", len(kept), tt.wantKept)
+			}
+			for i, c := range kept {
+				if want := queued[len(queued)-tt.wantKept+i]; c.String() != want.String() || c.IdempotencyKey != want.IdempotencyKey {
+					t.Errorf("kept %v, want %v", c, want)
+				}
+			}
+		})
+	}
+}
+
+// TestQueueWhileUnavailable queues the changes made while the API can not
+// be reached, with the same Idempotency-Key for the add when it is sent
+func TestQueueWhileUnavailable(t *testing.T) {
+	server := httptest.NewServer(&fakeAPI{})
+	server.Close()
+	client, err := remote.New(server.URL, time.Second, filepath.Join(t.TempDir(), "queue.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := client.CreateItem(db.ToDoItem{Title: "new"})
+	if err != nil || item.Id != 0 {
+		t.Fatalf("got %+v, %v, want the item queued with id 0", item, err)
+	}
+	if err := client.DeleteItem(2, db.CascadeOrphan); err != nil {
+		t.Fatal(err)
+	}
+
+	pending := client.Queue().Pending()
+	if len(pending) != 2 || pending[0].Op != remote.OpAdd || pending[0].IdempotencyKey == "" || pending[1].String() != "delete 2 (cascade orphan)" {
+		t.Errorf("got the queue %+v", pending)
+	}
+}
@@ -0,0 +1,423 @@
+// Package tui is the full screen mode of the todo CLI, started with
+// "todo tui".  It shows the items as a list that can be navigated, filtered
+// and sorted with the keyboard, and changed in place.  Every change goes
+// straight to the store, and the list is reloaded from it afterwards, so
+// changes made by other todo commands in the meantime show up too.
+package tui
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"drexel.edu/todo/db"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Store is the part of db.ToDo that the tui needs
+type Store interface {
+	GetAllItems() ([]db.ToDoItem, error)
+	AddItem(item db.ToDoItem) error
+	UpdateItem(item db.ToDoItem) error
+	DeleteItem(id int, cascade db.Cascade) error
+	ChangeItemDoneStatus(id int, value bool) error
+}
+
+// creator is a Store that picks the id of a new item itself, like the
+// todo API, and tells which id it picked
+type creator interface {
+	CreateItem(item db.ToDoItem) (db.ToDoItem, error)
+}
+
+// Run shows the tui until the user quits.  name is shown in the title bar,
+// eg the name of the database file.
+func Run(store Store, name string) error {
+	m := newModel(store, name)
+	m.reload()
+	_, err := tea.NewProgram(m, tea.WithAltScreen()).Run()
+	return err
+}
+
+type mode int
+
+const (
+	browsing mode = iota
+	adding
+	editing
+	filtering
+	confirmingDelete
+)
+
+type sortOrder int
+
+const (
+	byId sortOrder = iota
+	byTitle
+	byStatus
+	sortOrders // number of sort orders, for cycling through them
+)
+
+func (s sortOrder) String() string {
+	return [...]string{"id", "title", "status"}[s]
+}
+
+type statusFilter int
+
+const (
+	showAll statusFilter = iota
+	showOpen
+	showDone
+	statusFilters
+)
+
+func (f statusFilter) String() string {
+	return [...]string{"all", "open", "done"}[f]
+}
+
+type model struct {
+	store Store
+	name  string
+
+	items   []db.ToDoItem // everything in the store
+	visible []db.ToDoItem // items after filtering and sorting
+	cursor  int           // index into visible
+	offset  int           // index of the first visible row on screen
+
+	mode   mode
+	input  textinput.Model
+	filter string // title filter, case insensitive
+	status statusFilter
+	order  sortOrder
+
+	width, height int
+	message       string // result of the last action, or an error
+	showHelp      bool
+}
+
+func newModel(store Store, name string) *model {
+	input := textinput.New()
+	input.CharLimit = 500
+	return &model{store: store, name: name, input: input, width: 80, height: 24}
+}
+
+func (m *model) Init() tea.Cmd {
+	return nil
+}
+
+// reload reads the items from the store again and keeps the cursor on the
+// same item, if it is still there
+func (m *model) reload() {
+	items, err := m.store.GetAllItems()
+	if err != nil {
+		m.message = "Error: " + err.Error()
+		return
+	}
+	m.items = items
+	m.refresh()
+}
+
+// refresh applies the filters and the sort order to the items
+func (m *model) refresh() {
+	selected, hadSelection := m.selected()
+
+	m.visible = m.visible[:0]
+	needle := strings.ToLower(m.filter)
+	for _, item := range m.items {
+		if m.status == showOpen && item.IsDone || m.status == showDone && !item.IsDone {
+			continue
+		}
+		if needle != "" && !strings.Contains(strings.ToLower(item.Title), needle) {
+			continue
+		}
+		m.visible = append(m.visible, item)
+	}
+
+	sort.SliceStable(m.visible, func(i, j int) bool {
+		a, b := m.visible[i], m.visible[j]
+		switch m.order {
+		case byTitle:
+			if ta, tb := strings.ToLower(a.Title), strings.ToLower(b.Title); ta != tb {
+				return ta < tb
+			}
+		case byStatus:
+			if a.IsDone != b.IsDone {
+				return !a.IsDone
+			}
+		}
+		return a.Id < b.Id
+	})
+
+	if hadSelection {
+		for i, item := range m.visible {
+			if item.Id == selected.Id {
+				m.cursor = i
+				break
+			}
+		}
+	}
+	m.clampCursor()
+}
+
+func (m *model) selected() (db.ToDoItem, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.visible) {
+		return db.ToDoItem{}, false
+	}
+	return m.visible[m.cursor], true
+}
+
+// listHeight is how many rows of items fit between the title bar and the
+// footer
+func (m *model) listHeight() int {
+	return max(m.height-4, 1)
+}
+
+// clampCursor keeps the cursor on an item and scrolls so it is on screen
+func (m *model) clampCursor() {
+	m.cursor = min(max(m.cursor, 0), max(len(m.visible)-1, 0))
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+m.listHeight() {
+		m.offset = m.cursor - m.listHeight() + 1
+	}
+	m.offset = min(m.offset, max(len(m.visible)-m.listHeight(), 0))
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.input.Width = max(m.width-len(m.input.Prompt)-2, 10)
+		m.clampCursor()
+		return m, nil
+	case tea.KeyMsg:
+		if msg.String() == "ctrl+c" {
+			return m, tea.Quit
+		}
+		switch m.mode {
+		case browsing:
+			return m.browse(msg)
+		case confirmingDelete:
+			return m.confirmDelete(msg)
+		default:
+			return m.edit(msg)
+		}
+	}
+	return m, nil
+}
+
+// browse handles the keys of the list view
+func (m *model) browse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.message = ""
+	switch msg.String() {
+	case "q", "esc":
+		if msg.String() == "esc" && m.filter != "" {
+			m.filter = ""
+			m.refresh()
+			return m, nil
+		}
+		return m, tea.Quit
+	case "up", "k":
+		m.cursor--
+	case "down", "j":
+		m.cursor++
+	case "pgup", "ctrl+b":
+		m.cursor -= m.listHeight()
+	case "pgdown", "ctrl+f":
+		m.cursor += m.listHeight()
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.visible) - 1
+	case " ", "x":
+		m.toggleDone()
+	case "a":
+		return m, m.startInput(adding, "New: ", "")
+	case "e", "enter":
+		if item, ok := m.selected(); ok {
+			return m, m.startInput(editing, fmt.Sprintf("Edit %d: ", item.Id), item.Title)
+		}
+	case "d", "delete":
+		if _, ok := m.selected(); ok {
+			m.mode = confirmingDelete
+		}
+	case "/":
+		return m, m.startInput(filtering, "Filter: ", m.filter)
+	case "f":
+		m.status = (m.status + 1) % statusFilters
+		m.refresh()
+	case "s":
+		m.order = (m.order + 1) % sortOrders
+		m.refresh()
+	case "r":
+		m.reload()
+		m.message = "Reloaded"
+	case "?":
+		m.showHelp = !m.showHelp
+	}
+	m.clampCursor()
+	return m, nil
+}
+
+func (m *model) startInput(md mode, prompt string, value string) tea.Cmd {
+	m.mode = md
+	m.input.Prompt = prompt
+	m.input.Width = max(m.width-len(prompt)-2, 10)
+	m.input.SetValue(value)
+	m.input.CursorEnd()
+	return m.input.Focus()
+}
+
+// edit handles the keys while the input line is open, for adding, editing
+// and filtering
+func (m *model) edit(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		if m.mode == filtering {
+			m.filter = ""
+			m.refresh()
+		}
+		m.stopInput()
+		return m, nil
+	case "enter":
+		value := m.input.Value()
+		switch m.mode {
+		case adding:
+			m.add(value)
+		case editing:
+			m.rename(value)
+		case filtering:
+			m.filter = value
+			m.refresh()
+		}
+		m.stopInput()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.mode == filtering {
+		//filter while typing
+		m.filter = m.input.Value()
+		m.refresh()
+	}
+	return m, cmd
+}
+
+func (m *model) stopInput() {
+	m.mode = browsing
+	m.input.Blur()
+}
+
+func (m *model) confirmDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.mode = browsing
+	item, ok := m.selected()
+	if !ok || (msg.String() != "y" && msg.String() != "Y") {
+		m.message = "Not deleted"
+		return m, nil
+	}
+	if m.report(m.store.DeleteItem(item.Id, db.CascadeRestrict), fmt.Sprintf("Deleted %d", item.Id)) {
+		m.reload()
+	}
+	return m, nil
+}
+
+func (m *model) toggleDone() {
+	item, ok := m.selected()
+	if !ok {
+		return
+	}
+	err := m.store.ChangeItemDoneStatus(item.Id, !item.IsDone)
+	if m.report(err, "") {
+		m.reload()
+	}
+}
+
+func (m *model) add(title string) {
+	//another todo command may have taken ids since we last looked
+	m.reload()
+	item := db.ToDoItem{Id: m.nextId(), Title: strings.TrimSpace(title)}
+	if err := db.ValidateItem(item); !m.report(err, "") {
+		return
+	}
+	if !m.report(m.create(&item), "") {
+		return
+	}
+	m.message = fmt.Sprintf("Added %d", item.Id)
+	if item.Id == 0 {
+		m.message = "Queued, the todo API is not reachable"
+	}
+	m.reload()
+
+	//show the new item, even if a filter would hide it
+	for i, it := range m.visible {
+		if it.Id == item.Id {
+			m.cursor = i
+		}
+	}
+	m.clampCursor()
+}
+
+func (m *model) rename(title string) {
+	item, ok := m.selected()
+	if !ok {
+		return
+	}
+	item.Title = strings.TrimSpace(title)
+	if err := db.ValidateItem(item); !m.report(err, "") {
+		return
+	}
+	if m.report(m.store.UpdateItem(item), fmt.Sprintf("Updated %d", item.Id)) {
+		m.reload()
+	}
+}
+
+// create adds item to the store and sets its id to the one the store gave
+// it, 0 if the store queued it for later
+func (m *model) create(item *db.ToDoItem) error {
+	c, ok := m.store.(creator)
+	if !ok {
+		return m.store.AddItem(*item)
+	}
+	created, err := c.CreateItem(*item)
+	if err != nil {
+		return err
+	}
+	*item = created
+	return nil
+}
+
+// nextId is one more than the highest id in the store
+func (m *model) nextId() int {
+	next := 1
+	for _, item := range m.items {
+		next = max(next, item.Id+1)
+	}
+	return next
+}
+
+// report shows err, or success if there was no error, and tells if there
+// was no error
+func (m *model) report(err error, success string) bool {
+	if err != nil {
+		m.message = "Error: " + err.Error()
+		return false
+	}
+	m.message = success
+	return true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,249 @@
+package tui
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"drexel.edu/todo/db"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// memStore is a Store in memory
+type memStore struct {
+	items map[int]db.ToDoItem
+}
+
+func newMemStore(items ...db.ToDoItem) *memStore {
+	s := &memStore{items: map[int]db.ToDoItem{}}
+	for _, item := range items {
+		s.items[item.Id] = item
+	}
+	return s
+}
+
+func (s *memStore) GetAllItems() ([]db.ToDoItem, error) {
+	items := []db.ToDoItem{}
+	for _, item := range s.items {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	return items, nil
+}
+
+func (s *memStore) AddItem(item db.ToDoItem) error {
+	if _, ok := s.items[item.Id]; ok {
+		return fmt.Errorf("%w: id %d already existed", db.ErrConflict, item.Id)
+	}
+	s.items[item.Id] = item
+	return nil
+}
+
+func (s *memStore) UpdateItem(item db.ToDoItem) error {
+	if _, ok := s.items[item.Id]; !ok {
+		return fmt.Errorf("%w: id %d not existed", db.ErrNotFound, item.Id)
+	}
+	s.items[item.Id] = item
+	return nil
+}
+
+func (s *memStore) DeleteItem(id int, cascade db.Cascade) error {
+	if _, ok := s.items[id]; !ok {
+		return fmt.Errorf("%w: id %d not existed", db.ErrNotFound, id)
+	}
+	delete(s.items, id)
+	return nil
+}
+
+func (s *memStore) ChangeItemDoneStatus(id int, value bool) error {
+	item, ok := s.items[id]
+	if !ok {
+		return fmt.Errorf("%w: id %d not existed", db.ErrNotFound, id)
+	}
+	item.IsDone = value
+	s.items[id] = item
+	return nil
+}
+
+// apiStore picks the ids itself like the todo API, from next.  next 0
+// queues the item instead, like the API when it is not reachable.
+type apiStore struct {
+	*memStore
+	next int
+}
+
+func (s *apiStore) CreateItem(item db.ToDoItem) (db.ToDoItem, error) {
+	item.Id = s.next
+	if s.next == 0 {
+		return item, nil
+	}
+	s.next++
+	return item, s.memStore.AddItem(item)
+}
+
+func items() []db.ToDoItem {
+	return []db.ToDoItem{
+		{Id: 1, Title: "Buy milk"},
+		{Id: 2, Title: "answer mail", IsDone: true},
+		{Id: 5, Title: "Clean up"},
+	}
+}
+
+// press sends keys to m, a key with more than one rune is typed
+func press(m *model, keys ...string) {
+	for _, key := range keys {
+		var msg tea.KeyMsg
+		switch key {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEscape}
+		case "down":
+			msg = tea.KeyMsg{Type: tea.KeyDown}
+		default:
+			msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+		}
+		m.Update(msg)
+	}
+}
+
+func visibleIds(m *model) []int {
+	ids := []int{}
+	for _, item := range m.visible {
+		ids = append(ids, item.Id)
+	}
+	return ids
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name        string
+		store       Store
+		title       string
+		wantMessage string
+		wantIds     []int
+		wantCursor  int // id of the item under the cursor
+	}{
+		{
+			name:        "the next id of a local store",
+			store:       newMemStore(items()...),
+			title:       "Walk the dog",
+			wantMessage: "Added 6",
+			wantIds:     []int{1, 2, 5, 6},
+			wantCursor:  6,
+		},
+		{
+			name:        "the id the api picks",
+			store:       &apiStore{memStore: newMemStore(items()...), next: 40},
+			title:       "Walk the dog",
+			wantMessage: "Added 40",
+			wantIds:     []int{1, 2, 5, 40},
+			wantCursor:  40,
+		},
+		{
+			name:        "queued while the api is not reachable",
+			store:       &apiStore{memStore: newMemStore(items()...)},
+			title:       "Walk the dog",
+			wantMessage: "Queued, the todo API is not reachable",
+			wantIds:     []int{1, 2, 5},
+			wantCursor:  1,
+		},
+		{
+			name:        "a blank title",
+			store:       newMemStore(items()...),
+			title:       "  ",
+			wantMessage: "Error: ",
+			wantIds:     []int{1, 2, 5},
+			wantCursor:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newModel(tt.store, "test")
+			m.reload()
+			press(m, "a", tt.title, "enter")
+
+			if !strings.HasPrefix(m.message, tt.wantMessage) {
+				t.Errorf("got message %q, want %q", m.message, tt.wantMessage)
+			}
+			if got := visibleIds(m); !reflect.DeepEqual(got, tt.wantIds) {
+				t.Errorf("got the items %v, want %v", got, tt.wantIds)
+			}
+			if item, _ := m.selected(); item.Id != tt.wantCursor {
+				t.Errorf("the cursor is on %d, want %d", item.Id, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		wantIds []int
+		check   func(t *testing.T, s *memStore)
+	}{
+		{name: "sorted by id", wantIds: []int{1, 2, 5}},
+		{name: "sorted by title", keys: []string{"s"}, wantIds: []int{2, 1, 5}},
+		{name: "sorted by status", keys: []string{"s", "s"}, wantIds: []int{1, 5, 2}},
+		{name: "the open items", keys: []string{"f"}, wantIds: []int{1, 5}},
+		{name: "the done items", keys: []string{"f", "f"}, wantIds: []int{2}},
+		{name: "filtered by title", keys: []string{"/", "MIL", "enter"}, wantIds: []int{1}},
+		{name: "a filter is dropped with esc", keys: []string{"/", "mil", "enter", "esc"}, wantIds: []int{1, 2, 5}},
+		{
+			name: "marked done", keys: []string{"down", "down", "x"}, wantIds: []int{1, 2, 5},
+			check: func(t *testing.T, s *memStore) {
+				if !s.items[5].IsDone {
+					t.Error("item 5 is not done")
+				}
+			},
+		},
+		{
+			name: "renamed", keys: []string{"e", " now", "enter"}, wantIds: []int{1, 2, 5},
+			check: func(t *testing.T, s *memStore) {
+				if s.items[1].Title != "Buy milk now" {
+					t.Errorf("got title %q", s.items[1].Title)
+				}
+			},
+		},
+		{name: "deleted", keys: []string{"down", "d", "y"}, wantIds: []int{1, 5}},
+		{name: "not deleted", keys: []string{"down", "d", "n"}, wantIds: []int{1, 2, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMemStore(items()...)
+			m := newModel(store, "test")
+			m.reload()
+			press(m, tt.keys...)
+
+			if strings.HasPrefix(m.message, "Error") {
+				t.Errorf("got %s", m.message)
+			}
+			if got := visibleIds(m); !reflect.DeepEqual(got, tt.wantIds) {
+				t.Errorf("got the items %v, want %v", got, tt.wantIds)
+			}
+			if tt.check != nil {
+				tt.check(t, store)
+			}
+		})
+	}
+}
+
+// TestReportsStoreErrors shows the error of the store instead of the
+// success message
+func TestReportsStoreErrors(t *testing.T) {
+	store := newMemStore(items()...)
+	m := newModel(store, "test")
+	m.reload()
+	delete(store.items, 1)
+
+	press(m, "x")
+	if !strings.HasPrefix(m.message, "Error: ") || !strings.Contains(m.message, "not existed") {
+		t.Errorf("got message %q", m.message)
+	}
+	if got, want := visibleIds(m), []int{1, 2, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the items %v, want %v", got, want)
+	}
+}
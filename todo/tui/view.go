@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true).Reverse(true)
+	selectedStyle = lipgloss.NewStyle().Reverse(true)
+	doneStyle     = lipgloss.NewStyle().Faint(true).Strikethrough(true)
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	hintStyle     = lipgloss.NewStyle().Faint(true)
+)
+
+const help = `up/k down/j  move          space/x  toggle done     a  add
+pgup pgdown  page          e/enter  edit title      d  delete
+home/g end/G first/last    /        filter titles   f  all/open/done
+s  sort by id/title/status r        reload          q  quit`
+
+func (m *model) View() string {
+	var b strings.Builder
+
+	done := 0
+	for _, item := range m.items {
+		if item.IsDone {
+			done++
+		}
+	}
+	title := fmt.Sprintf(" todo: %s  %d items, %d done  sort: %s  show: %s", m.name, len(m.items), done, m.order, m.status)
+	if m.filter != "" {
+		title += fmt.Sprintf("  filter: %q", m.filter)
+	}
+	b.WriteString(titleStyle.Render(pad(title, m.width)) + "\n")
+
+	rows := m.listHeight()
+	if m.showHelp {
+		rows = max(rows-strings.Count(help, "\n")-1, 1)
+	}
+	for i := m.offset; i < m.offset+rows; i++ {
+		if i < len(m.visible) {
+			b.WriteString(m.row(i))
+		}
+		b.WriteString("\n")
+	}
+	if len(m.visible) == 0 {
+		b.WriteString(hintStyle.Render("no items, press a to add one") + "\n")
+	}
+
+	b.WriteString("\n")
+	if m.showHelp {
+		b.WriteString(help + "\n")
+	}
+	b.WriteString(m.footer())
+	return b.String()
+}
+
+func (m *model) row(i int) string {
+	item := m.visible[i]
+	box := "[ ]"
+	if item.IsDone {
+		box = "[x]"
+	}
+	prefix := fmt.Sprintf(" %s %4d  ", box, item.Id)
+	text := item.Title
+	if item.Progress != nil {
+		text += fmt.Sprintf(" (%d/%d)", item.Progress.Done, item.Progress.Total)
+	}
+	title := runewidth.Truncate(text, max(m.width-runewidth.StringWidth(prefix)-1, 1), "…")
+
+	switch {
+	case i == m.cursor:
+		return selectedStyle.Render(pad(prefix+title, m.width))
+	case item.IsDone:
+		return prefix + doneStyle.Render(title)
+	default:
+		return prefix + title
+	}
+}
+
+func (m *model) footer() string {
+	switch m.mode {
+	case adding, editing, filtering:
+		return m.input.View() + "\n" + hintStyle.Render("enter to save, esc to cancel")
+	case confirmingDelete:
+		item, _ := m.selected()
+		return fmt.Sprintf("Delete %d %q? y/n", item.Id, item.Title)
+	}
+
+	status := hintStyle.Render("? for help")
+	if strings.HasPrefix(m.message, "Error") {
+		status = errorStyle.Render(m.message)
+	} else if m.message != "" {
+		status = m.message
+	}
+	position := ""
+	if len(m.visible) > 0 {
+		position = fmt.Sprintf("%d/%d", m.cursor+1, len(m.visible))
+	}
+	return status + strings.Repeat(" ", max(m.width-lipgloss.Width(status)-len(position), 1)) + position
+}
+
+// pad fills s with spaces to width, or cuts it, so reversed bars span the
+// whole terminal
+func pad(s string, width int) string {
+	s = runewidth.Truncate(s, width, "")
+	return s + strings.Repeat(" ", max(width-runewidth.StringWidth(s), 0))
+}
@@ -0,0 +1,166 @@
+// Package validate checks values against their "binding" struct tags.
+// That is the tag gin checks when it binds a request body, so by using the
+// same tag here the APIs and the todo CLI check values with exactly the
+// same rules.  The errors name the fields by their json path.
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes one field that failed validation, Field is the
+// json path of the field, eg "title" or "slides[0].link"
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ErrValidation is wrapped by every *ValidationError, check for it with
+// errors.Is()
+var ErrValidation = errors.New("validation failed")
+
+// ValidationError lists every field that failed validation.  It wraps
+// ErrValidation, so errors.Is(err, ErrValidation) works for it
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	return ErrValidation.Error() + ": " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	RegisterValidators(v)
+	return v
+}
+
+// RegisterValidators adds our custom validation tags to v, and makes v
+// report fields by their json name.  The todo APIs call this on gin's
+// validator so they share these rules with the CLI
+func RegisterValidators(v *validator.Validate) error {
+	v.RegisterTagNameFunc(jsonFieldName)
+	return v.RegisterValidation("notblank", notBlank)
+}
+
+// Struct checks s against its binding tags.  It returns a
+// *ValidationError listing the fields that failed, or nil if s is valid
+func Struct(s any) error {
+	return AsValidationError(validate.Struct(s))
+}
+
+// StructAt is Struct for a value that is part of a larger one, the names
+// of the fields start with prefix, eg "[3]." for the fourth value of a
+// list
+func StructAt(s any, prefix string) error {
+	err := Struct(s)
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		for i := range ve.Fields {
+			ve.Fields[i].Field = prefix + ve.Fields[i].Field
+		}
+	}
+	return err
+}
+
+// AsValidationError converts the errors returned by the validator package
+// into a *ValidationError, any other error is returned unchanged
+func AsValidationError(err error) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+
+	ve := &ValidationError{Fields: make([]FieldError, 0, len(verrs))}
+	for _, fe := range verrs {
+		ve.Fields = append(ve.Fields, FieldError{
+			Field:   fieldPath(fe),
+			Message: fieldMessage(fe),
+		})
+	}
+	return ve
+}
+
+// notBlank is like the builtin required tag, but a string made only
+// of whitespace does not count as a value
+func notBlank(fl validator.FieldLevel) bool {
+	field := fl.Field()
+	if field.Kind() != reflect.String {
+		return !field.IsZero()
+	}
+	return strings.TrimSpace(field.String()) != ""
+}
+
+func jsonFieldName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// fieldPath drops the struct name the validator puts in front of every
+// field, eg "Poll.options[1].value" becomes "options[1].value"
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	isList := fe.Kind() == reflect.Slice || fe.Kind() == reflect.Map
+	switch {
+	case fe.Tag() == "required":
+		return "is required"
+	case fe.Tag() == "required_with":
+		return "is required when " + fe.Param() + " is set"
+	case fe.Tag() == "notblank":
+		return "must not be blank"
+	case fe.Tag() == "unique":
+		return "must not contain duplicates"
+	case fe.Tag() == "len" && isList:
+		return "must have length " + fe.Param()
+	case fe.Tag() == "min" && isList:
+		return "must contain at least " + fe.Param() + " entries"
+	case fe.Tag() == "max" && isList:
+		return "must contain at most " + fe.Param() + " entries"
+	case fe.Tag() == "gt":
+		return "must be greater than " + fe.Param()
+	case fe.Tag() == "gte" || fe.Tag() == "min":
+		return "must be at least " + fe.Param()
+	case fe.Tag() == "lte" || fe.Tag() == "max":
+		return "must be at most " + fe.Param()
+	case fe.Tag() == "ne":
+		return "must not be " + fe.Param()
+	case fe.Tag() == "oneof":
+		return "must be one of " + strings.Join(strings.Fields(fe.Param()), ", ")
+	case fe.Tag() == "startswith":
+		return "must start with " + fe.Param()
+	case fe.Tag() == "excludesall":
+		return "must not contain any of " + fe.Param()
+	case fe.Tag() == "url":
+		return "must be a url"
+	default:
+		return "failed the " + fe.Tag() + " check"
+	}
+}
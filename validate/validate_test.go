@@ -0,0 +1,122 @@
+package validate_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"validate"
+)
+
+type item struct {
+	Title    string `json:"title" binding:"notblank"`
+	Priority string `json:"priority,omitempty" binding:"omitempty,oneof=low high"`
+	ParentId int    `json:"parentId,omitempty" binding:"gte=0"`
+	Due      string `json:"due,omitempty" binding:"required_with=Repeat"`
+	Repeat   string `json:"repeat,omitempty"`
+	Blockers []int  `json:"-" binding:"dive,gt=0"`
+}
+
+func TestStruct(t *testing.T) {
+	tests := []struct {
+		name string
+		item item
+		want []validate.FieldError
+	}{
+		{"valid", item{Title: "a", Priority: "low", Blockers: []int{1}}, nil},
+		{"blank title", item{Title: " \t"}, []validate.FieldError{{Field: "title", Message: "must not be blank"}}},
+		{"oneof", item{Title: "a", Priority: "urgent"}, []validate.FieldError{{Field: "priority", Message: "must be one of low, high"}}},
+		{"gte", item{Title: "a", ParentId: -1}, []validate.FieldError{{Field: "parentId", Message: "must be at least 0"}}},
+		{"required_with", item{Title: "a", Repeat: "daily"}, []validate.FieldError{{Field: "due", Message: "is required when Repeat is set"}}},
+		{"gt in a list", item{Title: "a", Blockers: []int{0}}, []validate.FieldError{{Field: "Blockers[0]", Message: "must be greater than 0"}}},
+		{"every field", item{ParentId: -1}, []validate.FieldError{
+			{Field: "title", Message: "must not be blank"},
+			{Field: "parentId", Message: "must be at least 0"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate.Struct(tt.item)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want nil", err)
+				}
+				return
+			}
+
+			var ve *validate.ValidationError
+			if !errors.As(err, &ve) || !errors.Is(err, validate.ErrValidation) {
+				t.Fatalf("got %v, want a *ValidationError", err)
+			}
+			if !reflect.DeepEqual(ve.Fields, tt.want) {
+				t.Fatalf("got %+v, want %+v", ve.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsValidationErrorKeepsOtherErrors(t *testing.T) {
+	err := errors.New("not json")
+	if got := validate.AsValidationError(err); got != err {
+		t.Fatalf("got %v, want the error unchanged", got)
+	}
+}
+
+type option struct {
+	Value string `json:"value" binding:"notblank,ne=all"`
+	Link  string `json:"link,omitempty" binding:"omitempty,url"`
+}
+
+type poll struct {
+	Key     string   `json:"key" binding:"startswith=p,excludesall=/:"`
+	Options []option `json:"options" binding:"min=2,max=3,dive"`
+	Votes   int      `json:"votes" binding:"max=10"`
+}
+
+func TestStructPaths(t *testing.T) {
+	tests := []struct {
+		name string
+		poll poll
+		want []validate.FieldError
+	}{
+		{"valid", poll{Key: "p1", Options: []option{{Value: "a"}, {Value: "b"}}}, nil},
+		{"a nested field", poll{Key: "p1", Options: []option{{Value: "a"}, {Value: " ", Link: "nope"}}}, []validate.FieldError{
+			{Field: "options[1].value", Message: "must not be blank"},
+			{Field: "options[1].link", Message: "must be a url"},
+		}},
+		{"too few entries", poll{Key: "p1", Options: []option{{Value: "a"}}}, []validate.FieldError{{Field: "options", Message: "must contain at least 2 entries"}}},
+		{"a number that is too large", poll{Key: "p1", Options: []option{{Value: "a"}, {Value: "b"}}, Votes: 11}, []validate.FieldError{{Field: "votes", Message: "must be at most 10"}}},
+		{"ne", poll{Key: "p1", Options: []option{{Value: "a"}, {Value: "all"}}}, []validate.FieldError{{Field: "options[1].value", Message: "must not be all"}}},
+		{"startswith", poll{Key: "x", Options: []option{{Value: "a"}, {Value: "b"}}}, []validate.FieldError{{Field: "key", Message: "must start with p"}}},
+		{"excludesall", poll{Key: "p/", Options: []option{{Value: "a"}, {Value: "b"}}}, []validate.FieldError{{Field: "key", Message: "must not contain any of /:"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate.Struct(tt.poll)
+			var ve *validate.ValidationError
+			switch {
+			case tt.want == nil && err != nil:
+				t.Fatalf("got %v, want nil", err)
+			case tt.want != nil && !errors.As(err, &ve):
+				t.Fatalf("got %v, want a *ValidationError", err)
+			case tt.want != nil && !reflect.DeepEqual(ve.Fields, tt.want):
+				t.Fatalf("got %+v, want %+v", ve.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructAt(t *testing.T) {
+	err := validate.StructAt(option{Value: ""}, "[3].")
+	var ve *validate.ValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("got %v, want a *ValidationError", err)
+	}
+	want := []validate.FieldError{{Field: "[3].value", Message: "must not be blank"}}
+	if !reflect.DeepEqual(ve.Fields, want) {
+		t.Fatalf("got %+v, want %+v", ve.Fields, want)
+	}
+	if err := validate.StructAt(option{Value: "a"}, "[3]."); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+}